@@ -2,16 +2,29 @@ package agent
 
 import (
 	"context"
+	"devagent/internal/artifact"
+	"devagent/internal/checkpoint"
+	"devagent/internal/config"
+	"devagent/internal/gitutil"
+	"devagent/internal/instructions"
 	"devagent/internal/llm"
+	"devagent/internal/lsp"
+	"devagent/internal/memory"
 	"devagent/internal/parser"
+	"devagent/internal/plan"
 	"devagent/internal/prompt"
+	"devagent/internal/repomap"
 	"devagent/internal/sandbox"
 	"devagent/internal/skill"
 	"devagent/internal/tools"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -23,14 +36,39 @@ const (
 const containerWorkspace = "/workspace"
 
 type Agent struct {
-	client     *llm.Client
-	registry   *tools.Registry
-	workDir    string
-	displayDir string // path shown to LLM: workDir or /workspace in Docker mode
-	verbose    bool
-	skillDirs  []string
-	soul       string
-	guidelines string
+	client       *llm.Client
+	registry     *tools.Registry
+	workDir      string
+	displayDir   string // path shown to LLM: workDir or /workspace in Docker mode
+	verbose      bool
+	skillDirs    []string
+	soul         string
+	guidelines   string
+	promptOrigin [2]prompt.Origin // where soul and guidelines come from (FromProject unless set)
+	sandbox      *sandbox.Sandbox
+	docker       bool
+	memory       *memory.Store
+	instructions *instructions.Set        // repository instruction files of the current run
+	nested       []prompt.InstructionFile // directory instructions loaded so far in the run
+	cfg          *config.Project
+	checkpoints  *checkpoint.Store
+	git          *gitSession
+	task         string
+	outputs      *artifact.Store // full tool outputs of the current run
+
+	plan         *plan.Plan // the model's plan for the current run
+	planning     bool       // planning mode: edits wait for an approved plan
+	planApproved bool
+	planBlocked  bool // a command was refused for want of an approved plan
+	approvePlan  PlanApprover
+	systemPrompt string // system message without directory instructions and the plan
+
+	paused   atomic.Bool // set by Pause; guidance is asked for before the next step
+	guidance Guidance
+
+	reviewRepo   *gitutil.Repo // review: the work tree's repository, if any
+	reviewBase   string        // snapshot of the work tree when the run started
+	reviewRounds int
 
 	messages   []llm.Message
 	totalUsage llm.Usage
@@ -46,6 +84,7 @@ func New(client *llm.Client, workDir string, verbose bool, skillDirs []string, s
 		displayDir = containerWorkspace
 		reg.SetContainerPath(workDir, containerWorkspace)
 	}
+	reg.SetShellOptions(tools.ShellOptions{Output: os.Stdout})
 	return &Agent{
 		client:     client,
 		registry:   reg,
@@ -55,48 +94,172 @@ func New(client *llm.Client, workDir string, verbose bool, skillDirs []string, s
 		skillDirs:  skillDirs,
 		soul:       soul,
 		guidelines: guidelines,
+		sandbox:    sb,
+		docker:     dockerExec != nil,
+		memory:     memory.Open(workDir),
 	}
 }
 
+// SetProjectConfig applies .devagent/config.yaml settings to the agent and its tools.
+func (a *Agent) SetProjectConfig(cfg *config.Project) {
+	a.cfg = cfg
+	if cfg == nil {
+		return
+	}
+	if txn := a.registry.Transactions(); txn != nil {
+		txn.SetValidateCommand(cfg.Transaction.ValidateCommand)
+	}
+	opts := tools.ShellOptions{
+		DefaultTimeout: cfg.Shell.DefaultTimeout(),
+		MaxTimeout:     cfg.Shell.TimeoutLimit(),
+	}
+	if cfg.Shell.StreamEnabled() {
+		opts.Output = os.Stdout
+	}
+	a.registry.SetShellOptions(opts)
+
+	hooks := make([]tools.EditHook, 0, len(cfg.Hooks.PostEdit))
+	for _, h := range cfg.Hooks.PostEdit {
+		if h.Glob == "" || h.Command == "" {
+			log.Printf("Warning: post-edit hook needs both glob and command; skipping %+v", h)
+			continue
+		}
+		hooks = append(hooks, tools.EditHook{Glob: h.Glob, Command: h.Command, Timeout: h.Timeout})
+	}
+	a.registry.SetEditHooks(hooks)
+	a.registry.SetFormatters(cfg.Format)
+
+	servers := make([]lsp.ServerConfig, 0, len(cfg.LSP.Servers))
+	for _, s := range cfg.LSP.Servers {
+		if len(s.Command) == 0 || len(s.Extensions) == 0 {
+			log.Printf("Warning: language server needs both command and extensions; skipping %+v", s)
+			continue
+		}
+		servers = append(servers, lsp.ServerConfig{Name: s.Name, Command: s.Command, Extensions: s.Extensions, LanguageID: s.LanguageID})
+	}
+	if len(servers) > 0 {
+		a.registry.SetLSP(lsp.NewManager(a.workDir, servers, cfg.LSP.Timeout))
+	}
+
+	if e := cfg.CodeSearch.Embeddings; e.Model != "" && a.client != nil {
+		embedder := a.client.WithModel(e.Model)
+		if e.BaseURL != "" {
+			embedder = embedder.WithEndpoint(e.BaseURL, os.Getenv(e.APIKeyEnv))
+		}
+		a.registry.SetCodeSearchEmbedder(embedder, e.Model)
+	}
+}
+
+// SetCheckpoints records every file change made during the run in cp, so it can be undone.
+func (a *Agent) SetCheckpoints(cp *checkpoint.Store) {
+	a.checkpoints = cp
+	a.registry.SetCheckpoints(cp)
+}
+
+// SetPromptOrigins says where the soul and guidelines prompts were read from, which
+// decides the files their templates may include. Both default to prompt.FromProject.
+func (a *Agent) SetPromptOrigins(soul, guidelines prompt.Origin) {
+	a.promptOrigin = [2]prompt.Origin{soul, guidelines}
+}
+
+// SetAsker lets the model ask the user questions with ask_user; without one the
+// tool fails at once.
+func (a *Agent) SetAsker(ask tools.Asker) {
+	a.registry.SetAsker(ask)
+}
+
 func (a *Agent) LLMClient() *llm.Client { return a.client }
-func (a *Agent) Verbose() bool           { return a.verbose }
+func (a *Agent) Verbose() bool          { return a.verbose }
 
 func (a *Agent) Run(ctx context.Context, task string) error {
-	fileTree := a.buildFileTree(a.workDir, "", 0, 3)
+	defer a.registry.Close()
+	// A cancelled task must not wait for a long shell command to finish.
+	stop := context.AfterFunc(ctx, a.registry.Interrupt)
+	defer stop()
+	repoMap := a.buildRepoMap(task)
 
 	skills, err := skill.Discover(a.skillDirs)
 	if err != nil {
 		return fmt.Errorf("discover skills: %w", err)
 	}
+	vars := a.promptVars()
 	if len(skills) > 0 {
-		a.registry.Register(tools.NewReadSkillTool(skills))
+		readSkill := tools.NewReadSkillTool(skills)
+		readSkill.SetTemplateVars(vars)
+		a.registry.Register(readSkill)
 	}
+	if outputs, err := artifact.Open(a.workDir, time.Now()); err != nil {
+		log.Printf("Warning: tool outputs will not be saved: %v", err)
+	} else {
+		a.outputs = outputs
+		a.registry.Register(tools.NewReadOutputTool(outputs))
+	}
+	a.plan = plan.New()
+	a.planApproved = !a.planning
+	a.planBlocked = false
+	a.registry.Register(tools.NewUpdatePlanTool(a.plan))
 
 	meta := make([]prompt.SkillMeta, len(skills))
 	for i := range skills {
 		meta[i] = prompt.SkillMeta{Name: skills[i].Name, Description: skills[i].Description}
 	}
-	userContent := prompt.BuildProjectContext(a.displayDir, fileTree) + "\n\n" + prompt.BuildUserTask(task) + prompt.BuildSkillsContext(meta)
-	systemContent := prompt.BuildSystemPrompt(a.soul, a.guidelines)
+	userContent := prompt.BuildProjectContext(a.displayDir, repoMap) + "\n\n" + prompt.BuildUserTask(task) + prompt.BuildSkillsContext(meta)
+	if a.planning {
+		userContent += prompt.BuildPlanningInstruction()
+	}
+	projectMemory, userMemory := a.loadMemory()
+	rootInstructions := a.loadInstructions()
+	soul := renderPrompt("SOUL.md", a.soul, vars, a.promptOrigin[0])
+	guidelines := renderPrompt("GUIDELINES.md", a.guidelines, vars, a.promptOrigin[1])
+	systemContent := prompt.BuildSystemPrompt(soul, guidelines) + prompt.BuildInstructionsContext(rootInstructions) +
+		prompt.BuildMemoryContext(projectMemory, userMemory)
+	a.systemPrompt = systemContent
+	a.nested = nil
 	a.messages = []llm.Message{
 		{Role: "system", Content: systemContent},
 		{Role: "user", Content: userContent},
 	}
 
-	if a.verbose && a.soul != "" {
-		fmt.Printf("[Loaded custom soul prompt (%d chars)]\n", len(a.soul))
+	if a.verbose && soul != "" {
+		fmt.Printf("[Loaded custom soul prompt (%d chars)]\n", len(soul))
+	}
+	if a.verbose && guidelines != "" {
+		fmt.Printf("[Loaded custom guidelines (%d chars)]\n", len(guidelines))
+	}
+	if a.verbose {
+		for _, f := range rootInstructions {
+			fmt.Printf("[Loaded instructions %s (%d chars)]\n", f.Path, len(f.Content))
+		}
 	}
-	if a.verbose && a.guidelines != "" {
-		fmt.Printf("[Loaded custom guidelines (%d chars)]\n", len(a.guidelines))
+	if a.verbose && projectMemory+userMemory != "" {
+		fmt.Printf("[Loaded memory (%d chars)]\n", len(projectMemory)+len(userMemory))
 	}
 	fmt.Printf("\n🤖 DevAgent started (model: %s)\n", a.client.Model())
 	fmt.Printf("📁 Project: %s\n", a.workDir)
 	fmt.Printf("📋 Task: %s\n\n", task)
+	a.task = task
+	a.startGit(task)
+	defer a.leaveGit()
+	a.startReview()
 
 	for i := 0; i < maxIterations; i++ {
+		a.takeGuidance(ctx)
+		if err := a.cancelled(ctx); err != nil {
+			return err
+		}
 		fmt.Printf("━━━ Step %d/%d ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n", i+1, maxIterations)
+		if a.checkpoints != nil {
+			cp := a.checkpoints.BeginStep()
+			if a.verbose {
+				fmt.Printf("[Checkpoint step %d]\n", cp)
+			}
+		}
 
+		a.refreshSystem()
 		response, usage, err := a.callLLM(ctx)
+		if err := a.cancelled(ctx); err != nil {
+			return err
+		}
 		if err != nil {
 			return fmt.Errorf("LLM call failed at step %d: %w", i+1, err)
 		}
@@ -129,50 +292,124 @@ func (a *Agent) Run(ctx context.Context, task string) error {
 			continue
 		}
 
-		for _, cmd := range commands {
+		batch := a.beginBatch(commands)
+		var delegated map[int]tools.Result
+		for j, cmd := range commands {
+			if ctx.Err() != nil {
+				break
+			}
 			fmt.Printf("🔧 Command: %s\n", cmd.Name)
 			if cmd.Reason != "" {
 				fmt.Printf("   Reason: %s\n", cmd.Reason)
 			}
 			if a.verbose {
 				for k, v := range cmd.Args {
-				display := v
-				if r := []rune(display); len(r) > 200 {
-					display = string(r[:200]) + "..."
-				}
+					display := v
+					if r := []rune(display); len(r) > 200 {
+						display = string(r[:200]) + "..."
+					}
 					fmt.Printf("   %s: %s\n", k, display)
 				}
 			}
 
 			if cmd.Name == "done" {
+				if batch {
+					batch = false
+					if !a.endBatch() {
+						break
+					}
+				}
+				if txn := a.registry.Transactions(); txn != nil && txn.Active() {
+					fmt.Printf("   ⚠️  Transaction still open, not finishing yet\n\n")
+					a.messages = append(a.messages, llm.Message{
+						Role:    "user",
+						Content: prompt.BuildObservation(cmd.Name, false, "A transaction is still active. Use commit_transaction or rollback_transaction before calling done."),
+					})
+					continue
+				}
+				if msg := a.missingPlan(); msg != "" {
+					fmt.Printf("   ⚠️  No approved plan yet, not finishing\n\n")
+					a.messages = append(a.messages, llm.Message{
+						Role:    "user",
+						Content: prompt.BuildObservation(cmd.Name, false, msg),
+					})
+					continue
+				}
+				if msg := a.openPlanSteps(); msg != "" {
+					fmt.Printf("   ⚠️  Plan has unfinished steps, not finishing yet\n\n")
+					a.messages = append(a.messages, llm.Message{
+						Role:    "user",
+						Content: prompt.BuildObservation(cmd.Name, false, msg),
+					})
+					continue
+				}
+				if findings := a.review(ctx, cmd.Args["summary"]); findings != "" {
+					fmt.Printf("   🔎 Review found problems, not finishing yet\n\n")
+					a.observe(cmd.Name, tools.Result{Success: false, Output: findings + "\n\nFix these problems, then call done again."})
+					continue
+				}
 				fmt.Printf("\n✅ Task completed!\n")
 				fmt.Printf("   %s\n", cmd.Args["summary"])
+				a.finishGit(cmd.Args["summary"])
 				a.printUsage()
 				return nil
 			}
 
+			if cmd.Name == "delegate" {
+				if _, ok := delegated[j]; !ok {
+					delegated = a.delegate(ctx, commands, j)
+				}
+				result := delegated[j]
+				fmt.Printf("   Status: %s\n", statusIcon(result.Success))
+				if a.verbose || !result.Success {
+					fmt.Printf("   Output: %s\n", truncate(result.Output, 500))
+				}
+				fmt.Println()
+				a.observe(cmd.Name, result)
+				continue
+			}
+
 			if cmd.Name == "debug_code" {
 				result := a.handleDebugCode(ctx, cmd.Args)
 				fmt.Printf("   Status: %s\n\n", statusIcon(result.Success))
-				a.messages = append(a.messages, llm.Message{
-					Role:    "user",
-					Content: prompt.BuildObservation(cmd.Name, result.Success, result.Output),
-				})
+				a.observe(cmd.Name, result)
 				continue
 			}
 
-			result := a.registry.Execute(cmd.Name, cmd.Args)
+			var result tools.Result
+			if a.needsPlan(cmd.Name) {
+				a.planBlocked = true
+				result = tools.Result{Success: false, Output: "Planning mode: submit a plan with update_plan and wait for its approval before editing files or running commands."}
+			} else {
+				result = a.registry.Execute(cmd.Name, cmd.Args)
+			}
 			fmt.Printf("   Status: %s\n", statusIcon(result.Success))
+			if cmd.Name == "update_plan" && result.Success {
+				if a.planApproved || cmd.Args["plan"] == "" {
+					a.printPlan()
+				} else if !a.reviewPlan(&result) {
+					a.printUsage()
+					return ErrPlanRejected
+				}
+			}
 			if a.verbose || !result.Success {
 				fmt.Printf("   Output: %s\n", truncate(result.Output, 500))
 			}
 			fmt.Println()
 
-			a.messages = append(a.messages, llm.Message{
-				Role:    "user",
-				Content: prompt.BuildObservation(cmd.Name, result.Success, result.Output),
-			})
+			a.observe(cmd.Name, result)
+			a.loadNestedInstructions(cmd.Name, cmd.Args)
+
+			if batch && !result.Success && tools.IsMutating(cmd.Name) {
+				batch = false
+				a.abortBatch()
+				break
+			}
+		}
+		if batch {
+			a.endBatch()
 		}
+		a.commitGitStep(i+1, commands[0].Reason)
 
 		a.trimHistory()
 	}
@@ -181,6 +418,75 @@ func (a *Agent) Run(ctx context.Context, task string) error {
 	return fmt.Errorf("reached maximum iterations (%d) without completing the task", maxIterations)
 }
 
+// observe adds a tool result to the conversation. The full output is saved first, so
+// the observation can be shortened and the model can still read the rest with read_output.
+func (a *Agent) observe(name string, result tools.Result) {
+	id := 0
+	if a.outputs != nil {
+		n, err := a.outputs.Save(name, result.Output)
+		if err != nil {
+			log.Printf("Warning: save output: %v", err)
+		} else {
+			id = n
+		}
+	}
+	a.messages = append(a.messages, llm.Message{
+		Role:    "user",
+		Content: prompt.BuildToolObservation(name, result.Success, result.Output, id),
+	})
+}
+
+// beginBatch starts an implicit transaction when batching is enabled and the response
+// contains more than one file edit, so the batch is applied or rolled back as a unit.
+func (a *Agent) beginBatch(commands []parser.Command) bool {
+	if a.cfg == nil || !a.cfg.Transaction.Batch {
+		return false
+	}
+	txn := a.registry.Transactions()
+	if txn == nil || txn.Active() {
+		return false
+	}
+	edits := 0
+	for _, cmd := range commands {
+		if tools.IsMutating(cmd.Name) {
+			edits++
+		}
+	}
+	if edits < 2 {
+		return false
+	}
+	if err := txn.Begin(); err != nil {
+		return false
+	}
+	fmt.Printf("🔒 Batch of %d edits wrapped in a transaction\n", edits)
+	return true
+}
+
+// endBatch commits the implicit batch transaction and reports the result to the model.
+func (a *Agent) endBatch() bool {
+	result := a.registry.Transactions().Commit()
+	fmt.Printf("🔒 Batch commit: %s\n\n", statusIcon(result.Success))
+	a.messages = append(a.messages, llm.Message{
+		Role:    "user",
+		Content: prompt.BuildObservation("commit_transaction", result.Success, result.Output),
+	})
+	return result.Success
+}
+
+// abortBatch rolls back the implicit batch transaction after a failed edit.
+func (a *Agent) abortBatch() {
+	restored, err := a.registry.Transactions().Rollback()
+	out := fmt.Sprintf("An edit in this batch failed; rolled back %d file(s). The remaining commands in the batch were skipped.", restored)
+	if err != nil {
+		out += fmt.Sprintf("\nrollback error: %v", err)
+	}
+	fmt.Printf("🔒 Batch rolled back (%d file(s))\n\n", restored)
+	a.messages = append(a.messages, llm.Message{
+		Role:    "user",
+		Content: prompt.BuildObservation("rollback_transaction", err == nil, out),
+	})
+}
+
 func (a *Agent) callLLM(ctx context.Context) (string, llm.Usage, error) {
 	var fullResp string
 	var usage llm.Usage
@@ -198,6 +504,9 @@ func (a *Agent) callLLM(ctx context.Context) (string, llm.Usage, error) {
 			}
 			return fullResp, usage, nil
 		}
+		if ctx.Err() != nil {
+			break
+		}
 		fmt.Printf("⚠️  LLM error (attempt %d/%d): %v\n", retry+1, maxRetries, err)
 	}
 	return "", usage, err
@@ -253,52 +562,121 @@ func (a *Agent) printUsage() {
 		a.totalUsage.PromptTokens, a.totalUsage.CompletionTokens, a.totalUsage.TotalTokens)
 }
 
-func (a *Agent) buildFileTree(dir, prefix string, depth, maxDepth int) string {
-	if depth >= maxDepth {
-		return ""
+// loadMemory reads the project's and the user's MEMORY.md. An unreadable file is
+// skipped with a warning.
+func (a *Agent) loadMemory() (project, user string) {
+	var err error
+	if project, err = a.memory.Read(memory.Project); err != nil {
+		log.Printf("Warning: reading project memory: %v", err)
+	}
+	if user, err = a.memory.Read(memory.User); err != nil {
+		log.Printf("Warning: reading user memory: %v", err)
+	}
+	return project, user
+}
+
+// promptVars describes the project to the templates in SOUL.md, GUIDELINES.md and
+// skill bodies.
+func (a *Agent) promptVars() prompt.Vars {
+	v := prompt.Vars{
+		Project:    filepath.Base(a.workDir),
+		ProjectDir: a.displayDir,
+		Languages:  prompt.DetectLanguages(a.workDir),
+		GoModule:   prompt.GoModule(a.workDir),
+		OS:         runtime.GOOS,
+		Date:       time.Now().Format("2006-01-02"),
+		Docker:     a.docker,
+		Root:       a.workDir,
+	}
+	if a.docker {
+		v.OS = "linux"
+	}
+	if a.sandbox != nil {
+		v.Sandbox = a.sandbox.Mode().String()
 	}
+	return v
+}
 
-	entries, err := os.ReadDir(dir)
+// renderPrompt renders a prompt file as a template. A file that fails to render is
+// used as written, so stray braces in an older file do not break the run.
+func renderPrompt(name, text string, vars prompt.Vars, origin prompt.Origin) string {
+	out, err := prompt.Render(name, text, vars, origin)
 	if err != nil {
-		return ""
+		log.Printf("Warning: rendering %s: %v (using it as written)", name, err)
+		return text
 	}
+	return strings.TrimSpace(out)
+}
 
-	var sb strings.Builder
-	skipDirs := map[string]bool{
-		".git": true, "node_modules": true, "__pycache__": true,
-		".venv": true, "vendor": true, ".idea": true, ".vscode": true,
-		"dist": true, "build": true, ".next": true, "target": true,
+// loadInstructions discovers the repository's instruction files and returns the
+// ones that apply to the whole repository.
+func (a *Agent) loadInstructions() []prompt.InstructionFile {
+	var names []string
+	if a.cfg != nil {
+		names = a.cfg.Instructions.Files
 	}
-
-	filtered := make([]os.DirEntry, 0)
-	for _, entry := range entries {
-		if entry.IsDir() && skipDirs[entry.Name()] {
-			continue
-		}
-		if strings.HasPrefix(entry.Name(), ".") && entry.Name() != "." {
-			continue
-		}
-		filtered = append(filtered, entry)
+	set, err := instructions.Discover(a.workDir, names)
+	if err != nil {
+		log.Printf("Warning: instruction files: %v", err)
+		return nil
 	}
+	a.instructions = set
+	return instructionFiles(set.Root())
+}
 
-	for i, entry := range filtered {
-		connector := "├── "
-		childPrefix := prefix + "│   "
-		if i == len(filtered)-1 {
-			connector = "└── "
-			childPrefix = prefix + "    "
+// loadNestedInstructions adds the instruction files of the directory a tool call
+// worked in to the system prompt, the first time the agent works in that subtree,
+// and notes it in the call's observation.
+func (a *Agent) loadNestedInstructions(name string, args map[string]string) {
+	p := tools.PathArg(name, args)
+	if a.instructions == nil || p == "" || len(a.messages) == 0 {
+		return
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(a.workDir, p)
+	}
+	rel, err := filepath.Rel(a.workDir, p)
+	if err != nil {
+		return
+	}
+	files := a.instructions.For(rel)
+	if len(files) == 0 {
+		return
+	}
+	if a.verbose {
+		for _, f := range files {
+			fmt.Printf("   📘 Loaded instructions %s (%d chars)\n", f.Path, len(f.Content))
 		}
+	}
+	loaded := instructionFiles(files)
+	a.nested = append(a.nested, loaded...)
+	a.messages[len(a.messages)-1].Content += prompt.BuildNestedInstructionsNotice(loaded)
+}
 
-		if entry.IsDir() {
-			sb.WriteString(fmt.Sprintf("%s%s%s/\n", prefix, connector, entry.Name()))
-			sub := a.buildFileTree(filepath.Join(dir, entry.Name()), childPrefix, depth+1, maxDepth)
-			sb.WriteString(sub)
-		} else {
-			sb.WriteString(fmt.Sprintf("%s%s%s\n", prefix, connector, entry.Name()))
-		}
+func instructionFiles(files []instructions.File) []prompt.InstructionFile {
+	out := make([]prompt.InstructionFile, len(files))
+	for i, f := range files {
+		out[i] = prompt.InstructionFile{Path: f.Path, Dir: f.Dir, Content: f.Content}
 	}
+	return out
+}
 
-	return sb.String()
+// buildRepoMap renders the ranked repository map for the first message. Parsed
+// files are cached in .devagent/cache, so only changed files are parsed again.
+func (a *Agent) buildRepoMap(task string) string {
+	opts := repomap.Options{
+		Focus:     task,
+		CacheFile: filepath.Join(a.workDir, ".devagent", "cache", "repomap.json"),
+	}
+	if a.cfg != nil {
+		opts.Tokens = a.cfg.RepoMap.Tokens
+	}
+	m, err := repomap.Build(a.workDir, opts)
+	if err != nil {
+		log.Printf("Warning: repository map: %v", err)
+		return "(unavailable; use list_dir to explore)"
+	}
+	return m
 }
 
 func statusIcon(success bool) string {
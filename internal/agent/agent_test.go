@@ -3,12 +3,18 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"devagent/internal/config"
 	"devagent/internal/llm"
 	"devagent/internal/sandbox"
 )
@@ -186,3 +192,747 @@ func TestAgent_Run_DebugCodeThenDone(t *testing.T) {
 		t.Fatalf("Run: %v", err)
 	}
 }
+
+// scriptedServer returns an SSE server that replies with responses[i] on the i-th
+// streaming call (repeating the last one), and the number of calls made so far.
+func scriptedServer(t *testing.T, responses ...string) (*httptest.Server, *int) {
+	t.Helper()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idx := calls
+		if idx >= len(responses) {
+			idx = len(responses) - 1
+		}
+		calls++
+		chunk, _ := json.Marshal(map[string]interface{}{
+			"id":      "x",
+			"choices": []map[string]interface{}{{"index": 0, "delta": map[string]string{"content": responses[idx]}}},
+			"usage":   map[string]int{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+		})
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: " + string(chunk) + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+const doneResponse = "<think>Ok.</think>\n\n```json\n{\"command\": \"done\", \"args\": {\"summary\": \"ok\"}}\n```"
+
+func TestAgent_Run_BatchRollsBackOnFailedEdit(t *testing.T) {
+	batch := "<think>Edit.</think>\n\n```json\n[" +
+		`{"command": "write_file", "args": {"path": "a.txt", "content": "new"}},` +
+		`{"command": "str_replace", "args": {"path": "missing.txt", "old_str": "x", "new_str": "y"}},` +
+		`{"command": "write_file", "args": {"path": "b.txt", "content": "skipped"}}` +
+		"]\n```"
+	server, _ := scriptedServer(t, batch, doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	a.SetProjectConfig(&config.Project{Transaction: config.TransactionConfig{Batch: true}})
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "a.txt")); !os.IsNotExist(err) {
+		t.Error("a.txt should be rolled back")
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "b.txt")); !os.IsNotExist(err) {
+		t.Error("b.txt should be skipped after the failed edit")
+	}
+}
+
+func TestAgent_Run_DoneWithOpenTransaction(t *testing.T) {
+	begin := "<think>Begin.</think>\n\n```json\n{\"command\": \"begin_transaction\", \"args\": {}}\n```"
+	commit := "<think>Commit.</think>\n\n```json\n{\"command\": \"commit_transaction\", \"args\": {}}\n```"
+	server, calls := scriptedServer(t, begin, doneResponse, commit, doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, t.TempDir(), false, nil, "", "", nil, nil)
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if *calls != 4 {
+		t.Errorf("calls = %d, want 4 (done refused while transaction open)", *calls)
+	}
+}
+
+// gitWorkDir returns a directory holding a git repository with one empty commit on main.
+func gitWorkDir(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	workDir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test"},
+		{"commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", workDir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	return workDir
+}
+
+func TestAgent_Run_GitWorkflow(t *testing.T) {
+	workDir := gitWorkDir(t)
+
+	write := "<think>Write.</think>\n\n```json\n{\"command\": \"write_file\", \"args\": {\"path\": \"hello.txt\", \"content\": \"hi\"}}\n```"
+	done := "<think>Ok.</think>\n\n```json\n{\"command\": \"done\", \"args\": {\"summary\": \"Add hello.txt\"}}\n```"
+	server, _ := scriptedServer(t, write, done)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	a.SetProjectConfig(&config.Project{Git: config.GitConfig{Enabled: true}})
+	if err := a.Run(context.Background(), "say hello"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	branch, _ := exec.Command("git", "-C", workDir, "symbolic-ref", "--short", "HEAD").Output()
+	if !strings.HasPrefix(string(branch), "devagent/say-hello-") {
+		t.Errorf("branch = %q", branch)
+	}
+	msg, _ := exec.Command("git", "-C", workDir, "log", "-1", "--format=%B").Output()
+	if !strings.HasPrefix(string(msg), "Add hello.txt") || !strings.Contains(string(msg), "Task: say hello") {
+		t.Errorf("commit message = %q", msg)
+	}
+}
+
+func TestAgent_Run_GitWorkflowDirtyTree(t *testing.T) {
+	workDir := gitWorkDir(t)
+	os.WriteFile(filepath.Join(workDir, "notes.txt"), []byte("mine"), 0644)
+
+	write := "<think>Write.</think>\n\n```json\n{\"command\": \"write_file\", \"args\": {\"path\": \"hello.txt\", \"content\": \"hi\"}}\n```"
+	server, _ := scriptedServer(t, write, doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	a.SetProjectConfig(&config.Project{Git: config.GitConfig{Enabled: true}})
+	if err := a.Run(context.Background(), "say hello"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if branch, _ := exec.Command("git", "-C", workDir, "symbolic-ref", "--short", "HEAD").Output(); string(branch) != "main\n" {
+		t.Errorf("branch = %q, want main", branch)
+	}
+	if n, _ := exec.Command("git", "-C", workDir, "rev-list", "--count", "HEAD").Output(); string(n) != "1\n" {
+		t.Errorf("commits = %q, want only the initial one", n)
+	}
+	if status, _ := exec.Command("git", "-C", workDir, "status", "--porcelain").Output(); !strings.Contains(string(status), "?? notes.txt") {
+		t.Errorf("the user's change should stay uncommitted: %q", status)
+	}
+}
+
+func TestAgent_Run_RepoMap(t *testing.T) {
+	server, _ := scriptedServer(t, doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, ".gitignore"), []byte("*.log\ngen/\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\nfunc Serve() {}\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "debug.log"), []byte(""), 0644)
+	os.MkdirAll(filepath.Join(workDir, "gen"), 0755)
+	os.WriteFile(filepath.Join(workDir, "gen", "x.go"), []byte("package gen\n"), 0644)
+	os.MkdirAll(filepath.Join(workDir, "node_modules", "x"), 0755)
+	os.WriteFile(filepath.Join(workDir, "node_modules", "x", "y.js"), []byte("function y() {}\n"), 0644)
+	os.MkdirAll(filepath.Join(workDir, ".github", "workflows"), 0755)
+	os.WriteFile(filepath.Join(workDir, ".github", "workflows", "ci.yml"), []byte("on: push\n"), 0644)
+
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	first := a.messages[1].Content
+	for _, want := range []string{"### Repository Map", "main.go:\n  func Serve()", ".github/workflows/ci.yml", ".gitignore"} {
+		if !strings.Contains(first, want) {
+			t.Errorf("first message missing %q:\n%s", want, first)
+		}
+	}
+	for _, hidden := range []string{"debug.log", "gen/", "node_modules"} {
+		if strings.Contains(first, hidden) {
+			t.Errorf("first message should not contain %s:\n%s", hidden, first)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".devagent", "cache", "repomap.json")); err != nil {
+		t.Errorf("repository map not cached: %v", err)
+	}
+}
+
+func TestAgent_Run_SavesToolOutputs(t *testing.T) {
+	long := "<think>Print.</think>\n\n```json\n{\"command\": \"shell\", \"args\": {\"command\": \"seq 1 5000\"}}\n```"
+	server, _ := scriptedServer(t, long, doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	a.SetProjectConfig(&config.Project{Shell: config.ShellConfig{Stream: new(bool)}})
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	saved, _ := filepath.Glob(filepath.Join(workDir, ".devagent", "sessions", "*", "outputs", "1-shell.txt"))
+	if len(saved) != 1 {
+		t.Fatalf("saved outputs = %v", saved)
+	}
+	data, _ := os.ReadFile(saved[0])
+	if !strings.HasPrefix(string(data), "1\n2\n") || !strings.Contains(string(data), "\n5000") {
+		t.Errorf("saved output incomplete (%d bytes)", len(data))
+	}
+	var obs string
+	for _, m := range a.messages {
+		if strings.HasPrefix(m.Content, "[Command: shell") {
+			obs = m.Content
+		}
+	}
+	if !strings.Contains(obs, "Output: #1]") || !strings.Contains(obs, "read_output with id=1") || len(obs) > 9000 {
+		t.Errorf("observation should be shortened with a reference (len %d)", len(obs))
+	}
+}
+
+func TestAgent_SetProjectConfig_CodeSearchEmbeddings(t *testing.T) {
+	var models []string
+	auth := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.EmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Model)
+		auth += r.Header.Get("Authorization")
+		resp := llm.EmbeddingResponse{}
+		for i := range req.Input {
+			resp.Data = append(resp.Data, llm.Embedding{Index: i, Embedding: []float32{1, 0}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\n// Serve starts the server.\nfunc Serve() {}\n"), 0644)
+	a := New(llm.NewClient(llm.Config{APIKey: "secret"}), workDir, false, nil, "", "", nil, nil)
+	a.SetProjectConfig(&config.Project{CodeSearch: config.CodeSearchConfig{
+		Embeddings: config.EmbeddingsConfig{Model: "nomic-embed-text", BaseURL: server.URL},
+	}})
+	res := a.registry.Execute("code_search", map[string]string{"query": "start the server"})
+	if !res.Success || !strings.Contains(res.Output, "main.go:1-4") || strings.Contains(res.Output, "Note:") {
+		t.Errorf("code_search:\n%s", res.Output)
+	}
+	if len(models) != 2 || models[0] != "nomic-embed-text" {
+		t.Errorf("embedding requests = %v", models)
+	}
+	if auth != "" {
+		t.Errorf("chat API key sent to the embedding server: %q", auth)
+	}
+}
+
+func TestAgent_Run_LoadsMemory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	server, _ := scriptedServer(t, doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	os.MkdirAll(filepath.Join(workDir, ".devagent"), 0755)
+	os.WriteFile(filepath.Join(workDir, ".devagent", "MEMORY.md"), []byte("- Build with make build\n"), 0644)
+	os.MkdirAll(filepath.Join(home, ".devagent"), 0755)
+	os.WriteFile(filepath.Join(home, ".devagent", "MEMORY.md"), []byte("- Prefers short commit messages\n"), 0644)
+
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	system := a.messages[0].Content
+	for _, want := range []string{"## Memory", "- Build with make build", "- Prefers short commit messages"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system prompt missing %q", want)
+		}
+	}
+}
+
+func TestAgent_Run_LoadsInstructions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	listAPI := "<think>Look.</think>\n\n```json\n{\"command\": \"list_dir\", \"args\": {\"path\": \"services/api\"}}\n```"
+	listWeb := "<think>Look.</think>\n\n```json\n{\"command\": \"list_dir\", \"args\": {\"path\": \"web\"}}\n```"
+	server, _ := scriptedServer(t, listAPI, listAPI, listWeb, doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	os.MkdirAll(filepath.Join(workDir, "services", "api"), 0755)
+	os.MkdirAll(filepath.Join(workDir, "web"), 0755)
+	os.WriteFile(filepath.Join(workDir, "AGENTS.md"), []byte("Run make check before done.\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "services", "AGENTS.md"), []byte("Services use zap for logging.\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "web", "AGENTS.md"), []byte("Use pnpm.\n"), 0644)
+
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	system := a.messages[0].Content
+	if !strings.Contains(system, "### AGENTS.md\n\nRun make check before done.") {
+		t.Errorf("system prompt missing the root AGENTS.md:\n%s", system)
+	}
+	if !strings.Contains(system, "### services/AGENTS.md (for services/)\n\nServices use zap") || !strings.Contains(system, "### web/AGENTS.md (for web/)\n\nUse pnpm.") {
+		t.Errorf("system prompt missing the directory instructions:\n%s", system)
+	}
+
+	var observations []string
+	for _, m := range a.messages[2:] {
+		if m.Role == "user" {
+			observations = append(observations, m.Content)
+		}
+	}
+	if len(observations) != 3 {
+		t.Fatalf("got %d observations, want 3", len(observations))
+	}
+	if !strings.Contains(observations[0], "from services/AGENTS.md were added to the system prompt") || strings.Contains(observations[0], "zap") {
+		t.Errorf("first list_dir of services/api should note services/AGENTS.md:\n%s", observations[0])
+	}
+	if strings.Contains(observations[1], "services/AGENTS.md") {
+		t.Error("nested instructions should be loaded only once")
+	}
+	if !strings.Contains(observations[2], "from web/AGENTS.md") || strings.Contains(observations[2], "services/AGENTS.md") {
+		t.Errorf("list_dir of web should note only web/AGENTS.md:\n%s", observations[2])
+	}
+}
+
+func TestAgent_Run_NestedInstructionsSurviveTrimming(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	listAPI := "<think>Look.</think>\n\n```json\n{\"command\": \"list_dir\", \"args\": {\"path\": \"services/api\"}}\n```"
+	responses := []string{listAPI}
+	for len(responses) <= maxHistoryMsgs/2 { // enough steps for the history to be trimmed
+		responses = append(responses, "<think>Look.</think>\n\n```json\n{\"command\": \"list_dir\", \"args\": {\"path\": \".\"}}\n```")
+	}
+	server, calls := scriptedServer(t, append(responses, doneResponse)...)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	os.MkdirAll(filepath.Join(workDir, "services", "api"), 0755)
+	os.WriteFile(filepath.Join(workDir, "services", "AGENTS.md"), []byte("Services use zap for logging.\n"), 0644)
+
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if *calls != len(responses)+1 {
+		t.Fatalf("LLM calls = %d, want %d", *calls, len(responses)+1)
+	}
+	for _, m := range a.messages[2:] {
+		if strings.Contains(m.Content, "were added to the system prompt") {
+			t.Fatal("the observation that loaded the instructions should have been trimmed")
+		}
+	}
+	if !strings.Contains(a.messages[0].Content, "Services use zap") {
+		t.Error("directory instructions were lost when history was trimmed")
+	}
+}
+
+func TestAgent_Run_RendersPromptTemplates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server, _ := scriptedServer(t, doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "go.mod"), []byte("module example.com/shop\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "STYLE.md"), []byte("Wrap errors with %w."), 0644)
+
+	soul := "You maintain {{.GoModule}}."
+	guidelines := `{{if has .Languages "Go"}}Go: {{include "STYLE.md"}}{{end}} Broken: {{.Nope}}`
+	a := New(client, workDir, false, nil, soul, guidelines, nil, nil)
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	system := a.messages[0].Content
+	if !strings.Contains(system, "You maintain example.com/shop.") {
+		t.Error("SOUL.md was not rendered")
+	}
+	if !strings.Contains(system, guidelines) {
+		t.Error("a guidelines file that fails to render should be used as written")
+	}
+}
+
+// command is a model response issuing one command.
+func command(name string, args map[string]string) string {
+	data, _ := json.Marshal(map[string]interface{}{"command": name, "args": args})
+	return "<think>Next.</think>\n\n```json\n" + string(data) + "\n```"
+}
+
+func TestAgent_Run_PlanningMode(t *testing.T) {
+	server, calls := scriptedServer(t,
+		command("write_file", map[string]string{"path": "a.txt", "content": "early"}),
+		command("update_plan", map[string]string{"plan": "1. Write a.txt"}),
+		command("update_plan", map[string]string{"plan": "1. Write a.txt\n2. Check it"}),
+		command("write_file", map[string]string{"path": "a.txt", "content": "planned"}),
+		doneResponse,
+		command("update_plan", map[string]string{"step": "1,2", "status": "done"}),
+		doneResponse,
+	)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	var shown []string
+	a.SetPlanning(func(plan string) (bool, string) {
+		shown = append(shown, plan)
+		if len(shown) == 1 {
+			return false, "also check the file"
+		}
+		return true, ""
+	})
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if *calls != 7 {
+		t.Errorf("LLM calls = %d, want 7", *calls)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "a.txt")); string(data) != "planned" {
+		t.Errorf("a.txt = %q; the edit before approval should be refused", data)
+	}
+	if len(shown) != 2 || !strings.Contains(shown[1], "2. [ ] Check it") {
+		t.Errorf("plans shown for approval = %q", shown)
+	}
+	if !strings.Contains(a.messages[1].Content, "Planning mode is on") {
+		t.Error("task should carry the planning instruction")
+	}
+	var log strings.Builder
+	for _, m := range a.messages {
+		log.WriteString(m.Content + "\n")
+	}
+	for _, want := range []string{"Planning mode: submit a plan", "asked for changes:\nalso check the file", "The plan is approved", "Plan steps 1, 2 are not finished"} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("conversation missing %q", want)
+		}
+	}
+	if system := a.messages[0].Content; !strings.Contains(system, "## Current Plan") || !strings.Contains(system, "2. [x] Check it") {
+		t.Errorf("system prompt should show the current plan:\n%s", system)
+	}
+}
+
+func TestAgent_Run_PlanRejected(t *testing.T) {
+	server, _ := scriptedServer(t, command("update_plan", map[string]string{"plan": "1. Delete everything"}), doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, t.TempDir(), false, nil, "", "", nil, nil)
+	a.SetPlanning(func(string) (bool, string) { return false, "" })
+	if err := a.Run(context.Background(), "task"); !errors.Is(err, ErrPlanRejected) {
+		t.Errorf("Run = %v, want ErrPlanRejected", err)
+	}
+}
+
+func TestAgent_Run_PlanningModeDoneWithoutPlan(t *testing.T) {
+	server, calls := scriptedServer(t,
+		command("write_file", map[string]string{"path": "a.txt", "content": "early"}),
+		doneResponse,
+		command("update_plan", map[string]string{"plan": "1. Write a.txt"}),
+		command("write_file", map[string]string{"path": "a.txt", "content": "planned"}),
+		command("update_plan", map[string]string{"step": "1", "status": "done"}),
+		doneResponse,
+	)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	a.SetPlanning(nil)
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if *calls != 6 {
+		t.Errorf("LLM calls = %d, want 6; done without a plan should be refused", *calls)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "a.txt")); string(data) != "planned" {
+		t.Errorf("a.txt = %q", data)
+	}
+	if !strings.Contains(a.messages[5].Content, "no plan has been approved") {
+		t.Errorf("done observation = %q", a.messages[5].Content)
+	}
+
+	// A run that only looked around may finish without a plan.
+	server, calls = scriptedServer(t, command("list_dir", map[string]string{"path": "."}), doneResponse)
+	a = New(llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL}), t.TempDir(), false, nil, "", "", nil, nil)
+	a.SetPlanning(nil)
+	if err := a.Run(context.Background(), "what is here?"); err != nil || *calls != 2 {
+		t.Errorf("Run = %v after %d calls; want done accepted at once", err, *calls)
+	}
+}
+
+func TestAgent_PlanSurvivesTrimming(t *testing.T) {
+	responses := []string{command("update_plan", map[string]string{"plan": "1. Keep me"})}
+	for i := 0; i < maxHistoryMsgs; i++ {
+		responses = append(responses, command("list_dir", map[string]string{"path": "."}))
+	}
+	server, _ := scriptedServer(t, append(responses, doneResponse)...)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, t.TempDir(), false, nil, "", "", nil, nil)
+	if err := a.Run(context.Background(), "task"); err == nil {
+		t.Fatal("Run should stop at the iteration limit")
+	}
+	for _, m := range a.messages[1:] {
+		if strings.Contains(m.Content, "update_plan") && strings.Contains(m.Content, "Keep me") {
+			t.Fatal("the update_plan call should have been trimmed from the history")
+		}
+	}
+	if !strings.Contains(a.messages[0].Content, "1. [ ] Keep me") {
+		t.Error("the plan should stay in the system prompt after trimming")
+	}
+}
+
+func TestAgent_Run_AskUser(t *testing.T) {
+	ask := command("ask_user", map[string]string{"question": "Which port?", "choices": "80|8080"})
+	server, _ := scriptedServer(t, ask, ask, doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, t.TempDir(), false, nil, "", "", nil, nil)
+	asked := 0
+	a.SetAsker(func(question string, choices []string) (string, error) {
+		asked++
+		a.SetAsker(nil) // the second question finds no user
+		return choices[1], nil
+	})
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if asked != 1 {
+		t.Errorf("asked %d times, want 1", asked)
+	}
+	if !strings.Contains(a.messages[3].Content, "The user answered: 8080") {
+		t.Errorf("first observation = %q", a.messages[3].Content)
+	}
+	if !strings.Contains(a.messages[5].Content, "No user is available") {
+		t.Errorf("second observation = %q", a.messages[5].Content)
+	}
+}
+
+func TestAgent_Run_PauseForGuidance(t *testing.T) {
+	server, calls := scriptedServer(t,
+		command("ask_user", map[string]string{"question": "Go on?"}),
+		doneResponse,
+	)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, t.TempDir(), false, nil, "", "", nil, nil)
+	a.SetAsker(func(string, []string) (string, error) {
+		a.Pause() // as Ctrl-C would while the step runs
+		return "yes", nil
+	})
+	asked := 0
+	a.SetGuidance(func() string {
+		asked++
+		return "use the v2 API"
+	})
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if asked != 1 || *calls != 2 {
+		t.Errorf("guidance asked %d times, %d LLM calls; want 1 and 2", asked, *calls)
+	}
+	if got := a.messages[4]; got.Role != "user" || !strings.Contains(got.Content, "## User Guidance") || !strings.Contains(got.Content, "use the v2 API") {
+		t.Errorf("message after the paused step = %+v", got)
+	}
+}
+
+func TestAgent_Run_CancelWhilePaused(t *testing.T) {
+	server, calls := scriptedServer(t, command("list_dir", map[string]string{"path": "."}), doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, t.TempDir(), false, nil, "", "", nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a.Pause()
+	a.SetGuidance(func() string {
+		cancel() // as a second Ctrl-C would
+		return "ignored"
+	})
+	err := a.Run(ctx, "task")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v, want a cancellation", err)
+	}
+	if *calls != 0 {
+		t.Errorf("%d LLM calls after cancelling, want 0", *calls)
+	}
+}
+
+func TestAgent_Run_CancelInterruptsShell(t *testing.T) {
+	server, calls := scriptedServer(t, command("shell", map[string]string{"command": "sleep 30"}), doneResponse)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, t.TempDir(), false, nil, "", "", nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := a.Run(ctx, "task")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run = %v, want a cancellation", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("cancelling took %v; the shell command should be interrupted", d)
+	}
+	if *calls != 1 {
+		t.Errorf("%d LLM calls, want 1", *calls)
+	}
+}
+
+func TestAgent_Run_Delegate(t *testing.T) {
+	var mu sync.Mutex
+	parentCalls, childCalls := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []llm.Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		var content string
+		switch {
+		case !strings.Contains(req.Messages[0].Content, "DevAgent subagent"):
+			parentCalls++
+			content = doneResponse
+			if parentCalls == 1 {
+				content = "<think>Split it.</think>\n\n```json\n[" +
+					`{"command": "delegate", "args": {"task": "question A"}},` +
+					`{"command": "delegate", "args": {"task": "question B"}}` + "]\n```"
+			}
+		case len(req.Messages) == 2:
+			childCalls++
+			content = command("write_file", map[string]string{"path": "sub.txt", "content": "x"})
+		default:
+			childCalls++
+			task := req.Messages[1].Content
+			content = command("done", map[string]string{"summary": "answer to " + task[strings.LastIndex(task, "question"):]})
+		}
+		mu.Unlock()
+		chunk, _ := json.Marshal(map[string]interface{}{
+			"id":      "x",
+			"choices": []map[string]interface{}{{"index": 0, "delta": map[string]string{"content": content}}},
+			"usage":   map[string]int{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+		})
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: " + string(chunk) + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	workDir := t.TempDir()
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	if err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if parentCalls != 2 || childCalls != 4 {
+		t.Errorf("%d parent and %d subagent LLM calls, want 2 and 4", parentCalls, childCalls)
+	}
+	if !strings.Contains(a.messages[3].Content, "answer to question A") || !strings.Contains(a.messages[4].Content, "answer to question B") {
+		t.Errorf("delegate observations = %q, %q", a.messages[3].Content, a.messages[4].Content)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "sub.txt")); err == nil {
+		t.Error("a subagent must not write files")
+	}
+	if a.totalUsage.TotalTokens != 12 {
+		t.Errorf("total tokens = %d, want 12 (subagent usage included)", a.totalUsage.TotalTokens)
+	}
+}
+
+func TestAgent_Run_ReviewBeforeDone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	workDir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test"},
+		{"commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", workDir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	script := []string{
+		command("write_file", map[string]string{"path": "a.txt", "content": "v1"}),
+		doneResponse, // the verify command fails: ok.txt is missing
+		command("write_file", map[string]string{"path": "ok.txt", "content": "ok"}),
+		doneResponse, // the reviewer asks for changes
+		command("write_file", map[string]string{"path": "a.txt", "content": "v2"}),
+		doneResponse,
+	}
+	var reviews []string
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string        `json:"model"`
+			Messages []llm.Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model == "reviewer" {
+			reviews = append(reviews, req.Messages[1].Content)
+			verdict := "VERDICT: changes\n- a.txt: should say v2"
+			if strings.Contains(req.Messages[1].Content, "+v2") {
+				verdict = "VERDICT: approve"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": verdict}}},
+				"usage":   map[string]int{"prompt_tokens": 5, "completion_tokens": 5, "total_tokens": 10},
+			})
+			return
+		}
+		content := script[min(calls, len(script)-1)]
+		calls++
+		chunk, _ := json.Marshal(map[string]interface{}{
+			"id":      "x",
+			"choices": []map[string]interface{}{{"index": 0, "delta": map[string]string{"content": content}}},
+			"usage":   map[string]int{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+		})
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: " + string(chunk) + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	a.SetProjectConfig(&config.Project{Review: config.ReviewConfig{Enabled: true, VerifyCommand: "test -f ok.txt", Model: "reviewer"}})
+	if err := a.Run(context.Background(), "Write v2 to a.txt"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if calls != len(script) || len(reviews) != 2 {
+		t.Fatalf("%d agent calls and %d reviews, want %d and 2", calls, len(reviews), len(script))
+	}
+	if !strings.Contains(reviews[0], "Write v2 to a.txt") || !strings.Contains(reviews[0], "+++ b/a.txt") || !strings.Contains(reviews[0], "+v1") {
+		t.Errorf("first review request:\n%s", reviews[0])
+	}
+	var observations []string
+	for _, m := range a.messages {
+		if strings.Contains(m.Content, "Fix these problems") {
+			observations = append(observations, m.Content)
+		}
+	}
+	if len(observations) != 2 || !strings.Contains(observations[0], "test -f ok.txt` failed") || !strings.Contains(observations[1], "a.txt: should say v2") {
+		t.Errorf("review findings sent back = %q", observations)
+	}
+	if a.totalUsage.TotalTokens != 2*len(script)+20 {
+		t.Errorf("total tokens = %d, want %d (reviews included)", a.totalUsage.TotalTokens, 2*len(script)+20)
+	}
+}
+
+func TestParseVerdict(t *testing.T) {
+	tests := []struct {
+		resp     string
+		approved bool
+		findings string
+		ok       bool
+	}{
+		{"VERDICT: approve", true, "", true},
+		{"**Verdict: Changes**\n- main.go: missing flag\n", false, "- main.go: missing flag", true},
+		{"Looks fine to me.", false, "", false},
+	}
+	for _, tt := range tests {
+		approved, findings, ok := parseVerdict(tt.resp)
+		if approved != tt.approved || findings != tt.findings || ok != tt.ok {
+			t.Errorf("parseVerdict(%q) = %v, %q, %v", tt.resp, approved, findings, ok)
+		}
+	}
+}
+
+func TestAgent_Run_RollsBackOpenTransaction(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("original"), 0644)
+	server, _ := scriptedServer(t,
+		command("begin_transaction", map[string]string{}),
+		command("write_file", map[string]string{"path": "a.txt", "content": "half-done"}),
+		command("write_file", map[string]string{"path": "b.txt", "content": "new"}),
+		command("list_dir", map[string]string{"path": "."}),
+	)
+	client := llm.NewClient(llm.Config{APIKey: "test", BaseURL: server.URL})
+	a := New(client, workDir, false, nil, "", "", nil, nil)
+	if err := a.Run(context.Background(), "task"); err == nil || !strings.Contains(err.Error(), "maximum iterations") {
+		t.Fatalf("Run = %v, want the iteration limit", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "a.txt")); string(data) != "original" {
+		t.Errorf("a.txt = %q, want the edit rolled back", data)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "b.txt")); err == nil {
+		t.Error("b.txt should be removed by the rollback")
+	}
+}
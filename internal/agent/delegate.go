@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"context"
+	"devagent/internal/llm"
+	"devagent/internal/parser"
+	"devagent/internal/prompt"
+	"devagent/internal/tools"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	maxSubagentIterations = 15
+	maxParallelSubagents  = 4
+)
+
+// delegate runs the delegate command at commands[from] and the delegate commands
+// directly after it concurrently, each in a subagent, and returns their results by
+// command index. The subagents' token usage is added to the run.
+func (a *Agent) delegate(ctx context.Context, commands []parser.Command, from int) map[int]tools.Result {
+	to := from
+	for to < len(commands) && commands[to].Name == "delegate" {
+		to++
+	}
+	if n := to - from; n > 1 {
+		fmt.Printf("🧭 Delegating %d tasks\n", n)
+	}
+
+	results := make(map[int]tools.Result, to-from)
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, maxParallelSubagents)
+	)
+	for i := from; i < to; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			result, usage := a.runSubagent(ctx, i-from+1, commands[i].Args["task"])
+			mu.Lock()
+			defer mu.Unlock()
+			results[i] = result
+			a.totalUsage.PromptTokens += usage.PromptTokens
+			a.totalUsage.CompletionTokens += usage.CompletionTokens
+			a.totalUsage.TotalTokens += usage.TotalTokens
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// runSubagent investigates task in a child agent with its own history, the
+// read-only tools and a focused prompt. The result is the child's summary; n
+// labels its progress lines.
+func (a *Agent) runSubagent(ctx context.Context, n int, task string) (tools.Result, llm.Usage) {
+	task = strings.TrimSpace(task)
+	if task == "" {
+		return tools.Result{Success: false, Output: "delegate requires \"task\" argument"}, llm.Usage{}
+	}
+	fmt.Printf("   [%d] 🧭 %s\n", n, truncate(task, 120))
+
+	child := &Agent{
+		client:     a.client,
+		registry:   a.registry.ReadOnly(),
+		workDir:    a.workDir,
+		displayDir: a.displayDir,
+		outputs:    a.outputs,
+	}
+	names := child.registry.List()
+	sort.Strings(names)
+	child.messages = []llm.Message{
+		{Role: "system", Content: prompt.BuildSubagentPrompt(names)},
+		{Role: "user", Content: prompt.BuildSubagentTask(a.displayDir, task)},
+	}
+
+	for i := 0; i < maxSubagentIterations; i++ {
+		response, usage, err := child.callLLM(ctx)
+		child.totalUsage.PromptTokens += usage.PromptTokens
+		child.totalUsage.CompletionTokens += usage.CompletionTokens
+		child.totalUsage.TotalTokens += usage.TotalTokens
+		if err != nil {
+			fmt.Printf("   [%d] ❌ %v\n", n, err)
+			return tools.Result{Success: false, Output: fmt.Sprintf("the subagent failed at step %d: %v", i+1, err)}, child.totalUsage
+		}
+		child.messages = append(child.messages, llm.Message{Role: "assistant", Content: response})
+
+		commands, _, err := parser.ParseCommands(response)
+		if err != nil {
+			child.messages = append(child.messages, llm.Message{
+				Role:    "user",
+				Content: prompt.BuildObservation("parse_error", false, fmt.Sprintf("Failed to parse your command: %v\nPlease output a valid JSON command block.", err)),
+			})
+			continue
+		}
+		if len(commands) == 0 {
+			child.messages = append(child.messages, llm.Message{
+				Role:    "user",
+				Content: "You did not output a command. Output a JSON command block, or call done with your findings.",
+			})
+			continue
+		}
+		for _, cmd := range commands {
+			if cmd.Name == "done" {
+				summary := strings.TrimSpace(cmd.Args["summary"])
+				if summary == "" {
+					summary = "(the subagent returned no summary)"
+				}
+				fmt.Printf("   [%d] ✅ Finished after %d step(s)\n", n, i+1)
+				return tools.Result{Success: true, Output: summary}, child.totalUsage
+			}
+			var result tools.Result
+			if _, ok := child.registry.Get(cmd.Name); ok {
+				fmt.Printf("   [%d] 🔧 %s\n", n, cmd.Name)
+				result = child.registry.Execute(cmd.Name, cmd.Args)
+			} else {
+				result = tools.Result{Success: false, Output: fmt.Sprintf("%s is not available to subagents; you can only read the project. Report what you found with done.", cmd.Name)}
+			}
+			child.observe(cmd.Name, result)
+		}
+		child.trimHistory()
+	}
+	fmt.Printf("   [%d] ⚠️  No summary after %d steps\n", n, maxSubagentIterations)
+	return tools.Result{Success: false, Output: fmt.Sprintf("the subagent did not finish within %d steps; delegate a narrower question or investigate directly", maxSubagentIterations)}, child.totalUsage
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"devagent/internal/gitutil"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxDiffPrint bounds the diff printed for review at the end of a task.
+const maxDiffPrint = 20000
+
+// gitSession holds the state of the git workflow for one Run.
+type gitSession struct {
+	repo       *gitutil.Repo
+	base       string // HEAD before the task started ("" for an empty repository)
+	branch     string // branch created for the task
+	origBranch string
+}
+
+// startGit creates a fresh branch for the task when the git workflow is enabled. A
+// work tree with uncommitted changes is left alone: they would end up in the task's
+// commits.
+func (a *Agent) startGit(task string) {
+	a.git = nil
+	if a.cfg == nil || !a.cfg.Git.Enabled {
+		return
+	}
+	repo, err := gitutil.Open(a.workDir)
+	if err != nil {
+		fmt.Printf("⚠️  Git workflow enabled but %s is not a git repository; continuing without it\n", a.workDir)
+		return
+	}
+	if dirty, err := repo.HasChanges(); err != nil || dirty {
+		fmt.Println("⚠️  Working tree has uncommitted changes; commit or stash them to use the git workflow. Continuing without it")
+		return
+	}
+	gs := &gitSession{repo: repo, base: repo.Head(), origBranch: repo.CurrentBranch()}
+	name := gitutil.BranchName(a.cfg.Git.Prefix(), task, time.Now())
+	if err := repo.CreateBranch(name); err != nil {
+		fmt.Printf("⚠️  Could not create branch %s: %v\n", name, err)
+		return
+	}
+	gs.branch = name
+	a.git = gs
+	fmt.Printf("🌿 Working on branch %s\n", name)
+}
+
+// commitGitStep commits the changes of one step when commit mode is "step".
+func (a *Agent) commitGitStep(step int, reason string) {
+	if a.git == nil || a.cfg.Git.CommitMode() != "step" {
+		return
+	}
+	msg := fmt.Sprintf("devagent: step %d", step)
+	if reason = strings.TrimSpace(reason); reason != "" {
+		msg += ": " + reason
+	}
+	if _, err := a.git.repo.CommitAll(gitutil.CommitMessage(msg, a.task)); err != nil {
+		fmt.Printf("⚠️  git commit failed: %v\n", err)
+	}
+}
+
+// finishGit commits the remaining changes with a message generated from the done
+// summary and prints the task's diff for review.
+func (a *Agent) finishGit(summary string) {
+	if a.git == nil {
+		return
+	}
+	gs := a.git
+	if a.cfg.Git.CommitMode() != "none" {
+		committed, err := gs.repo.CommitAll(gitutil.CommitMessage(summary, a.task))
+		if err != nil {
+			fmt.Printf("⚠️  git commit failed: %v\n", err)
+		} else if committed {
+			fmt.Printf("🌿 Committed changes on %s\n", gs.branch)
+		}
+	}
+	if a.cfg.Git.DiffEnabled() {
+		stat, err := gs.repo.DiffStat(gs.base)
+		if err == nil && strings.TrimSpace(stat) != "" {
+			fmt.Printf("\n📝 Changes on %s:\n%s\n", gs.branch, stat)
+			if diff, err := gs.repo.Diff(gs.base); err == nil {
+				fmt.Println(truncate(diff, maxDiffPrint))
+			}
+		} else if err == nil {
+			fmt.Printf("\n📝 No changes on %s\n", gs.branch)
+		}
+	}
+	if gs.origBranch != "" {
+		fmt.Printf("   To discard: git checkout %s && git branch -D %s\n", gs.origBranch, gs.branch)
+	}
+	a.git = nil
+}
+
+// leaveGit reports where the work is when a run ends without done (cancelled, failed,
+// plan rejected or out of iterations): the task branch stays checked out and nothing
+// more is committed.
+func (a *Agent) leaveGit() {
+	if a.git == nil {
+		return
+	}
+	gs := a.git
+	a.git = nil
+	fmt.Printf("\n🌿 The task did not finish; you are still on branch %s\n", gs.branch)
+	if dirty, _ := gs.repo.HasChanges(); dirty {
+		fmt.Println("   Its last changes are not committed")
+	}
+	if gs.origBranch != "" {
+		fmt.Printf("   To go back: git checkout %s (to discard the branch: git branch -D %s)\n", gs.origBranch, gs.branch)
+	}
+}
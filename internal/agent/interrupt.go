@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"context"
+	"devagent/internal/llm"
+	"devagent/internal/prompt"
+	"fmt"
+	"strings"
+)
+
+// Guidance asks the user what to tell the agent after a pause. An empty answer
+// continues the task unchanged.
+type Guidance func() string
+
+// SetGuidance sets how the agent asks for guidance when it is paused.
+func (a *Agent) SetGuidance(ask Guidance) {
+	a.guidance = ask
+}
+
+// Pause asks the agent to stop after the current step and ask for guidance. It is
+// safe to call from another goroutine, such as a signal handler; without a
+// Guidance it does nothing.
+func (a *Agent) Pause() {
+	a.paused.Store(true)
+}
+
+// takeGuidance asks for guidance if the agent was paused and adds the answer to
+// the conversation before the next LLM call.
+func (a *Agent) takeGuidance(ctx context.Context) {
+	if !a.paused.Swap(false) || a.guidance == nil {
+		return
+	}
+	text := strings.TrimSpace(a.guidance())
+	if text == "" || ctx.Err() != nil {
+		return
+	}
+	a.messages = append(a.messages, llm.Message{Role: "user", Content: prompt.BuildUserGuidance(text)})
+	fmt.Printf("📝 Guidance added\n\n")
+}
+
+// cancelled returns the error ending a run whose context was cancelled, or nil.
+func (a *Agent) cancelled(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	fmt.Printf("\n🛑 Task cancelled\n")
+	a.printUsage()
+	return fmt.Errorf("task cancelled: %w", ctx.Err())
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"devagent/internal/prompt"
+	"devagent/internal/tools"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPlanRejected is returned by Run when the user rejects the plan in planning mode.
+var ErrPlanRejected = errors.New("plan rejected by the user")
+
+// PlanApprover shows a plan to the user. It reports whether the plan is approved;
+// otherwise feedback says what to change, and empty feedback rejects the plan.
+type PlanApprover func(plan string) (approved bool, feedback string)
+
+// SetPlanning turns on planning mode: the model explores, submits a plan with
+// update_plan, and may edit files or run commands only once approve accepts it.
+// A nil approve accepts every plan, which suits runs without a terminal.
+func (a *Agent) SetPlanning(approve PlanApprover) {
+	a.planning = true
+	a.approvePlan = approve
+}
+
+// Planning reports whether planning mode is on.
+func (a *Agent) Planning() bool { return a.planning }
+
+// needsPlan reports whether a command has to wait for an approved plan.
+func (a *Agent) needsPlan(name string) bool {
+	return a.planning && !a.planApproved && (tools.IsMutating(name) || name == "shell" || name == "shell_start")
+}
+
+// reviewPlan asks for approval of a plan just submitted in planning mode (the
+// approver shows it to the user) and adds the answer to the update_plan result. It
+// returns false if the user rejected the plan.
+func (a *Agent) reviewPlan(result *tools.Result) bool {
+	approved, feedback := true, ""
+	if a.approvePlan != nil {
+		approved, feedback = a.approvePlan(a.plan.String())
+	} else {
+		a.printPlan()
+	}
+	switch {
+	case approved:
+		a.planApproved = true
+		fmt.Printf("   👍 Plan approved\n")
+		result.Output += "\n\nThe plan is approved. Work through it step by step."
+	case feedback != "":
+		fmt.Printf("   ✏️  Changes requested\n")
+		result.Output += "\n\nThe user has not approved the plan and asked for changes:\n" + feedback + "\n\nSubmit a revised plan with update_plan."
+	default:
+		return false
+	}
+	return true
+}
+
+// refreshSystem adds the loaded directory instructions and the current plan to the
+// end of the system prompt, so they stay in view however much history is trimmed.
+func (a *Agent) refreshSystem() {
+	a.messages[0].Content = a.systemPrompt + prompt.BuildNestedInstructions(a.nested) + prompt.BuildPlanContext(a.plan.String())
+}
+
+// missingPlan returns the observation refusing "done" in planning mode when the
+// model tried to change something but never got a plan approved, or "". A run that
+// only explored (e.g. to answer a question) may finish without a plan.
+func (a *Agent) missingPlan() string {
+	if !a.planning || a.planApproved || !a.planBlocked {
+		return ""
+	}
+	return "Planning mode: no plan has been approved, so nothing was changed. Submit a plan with update_plan, wait for its approval and carry it out before calling done."
+}
+
+// openPlanSteps returns the observation refusing "done" while steps are open, or "".
+func (a *Agent) openPlanSteps() string {
+	open := a.plan.Open()
+	if len(open) == 0 {
+		return ""
+	}
+	nums := make([]string, len(open))
+	for i, n := range open {
+		nums[i] = fmt.Sprint(n)
+	}
+	return fmt.Sprintf("Plan steps %s are not finished. Complete them, or mark them done or skipped with update_plan, then call done.", strings.Join(nums, ", "))
+}
+
+func (a *Agent) printPlan() {
+	for _, l := range strings.Split(a.plan.String(), "\n") {
+		fmt.Printf("   %s\n", l)
+	}
+}
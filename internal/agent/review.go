@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"context"
+	"devagent/internal/gitutil"
+	"devagent/internal/llm"
+	"devagent/internal/prompt"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// startReview snapshots the work tree when review is enabled, so the reviewer sees
+// only the changes of this run.
+func (a *Agent) startReview() {
+	a.reviewRepo, a.reviewBase, a.reviewRounds = nil, "", 0
+	if a.cfg == nil || !a.cfg.Review.Enabled {
+		return
+	}
+	repo, err := gitutil.Open(a.workDir)
+	if err != nil {
+		fmt.Printf("⚠️  Review enabled but %s is not a git repository; the reviewer will not see a diff\n", a.workDir)
+		return
+	}
+	base, err := repo.Snapshot()
+	if err != nil {
+		log.Printf("Warning: review snapshot: %v", err)
+		return
+	}
+	a.reviewRepo, a.reviewBase = repo, base
+}
+
+// review runs the verify command and the reviewer when the model calls done. It
+// returns the findings that send the model back to work, or "" to finish.
+func (a *Agent) review(ctx context.Context, summary string) string {
+	if a.cfg == nil || !a.cfg.Review.Enabled {
+		return ""
+	}
+	rc := a.cfg.Review
+	a.reviewRounds++
+	findings := a.verify(rc.VerifyCommand)
+	if findings == "" {
+		findings = a.reviewChanges(ctx, summary)
+	}
+	if findings == "" {
+		fmt.Printf("   🔎 Review passed\n")
+		return ""
+	}
+	if a.reviewRounds > rc.Rounds() {
+		fmt.Printf("   ⚠️  Review still failing after %d rounds; finishing anyway:\n", rc.Rounds())
+		for _, l := range strings.Split(truncate(findings, 2000), "\n") {
+			fmt.Printf("   %s\n", l)
+		}
+		return ""
+	}
+	return findings
+}
+
+// verify runs the configured verify command and returns its failure, or "". Like a
+// shell command given a long timeout, it may run up to the shell's max_timeout.
+func (a *Agent) verify(command string) string {
+	if strings.TrimSpace(command) == "" {
+		return ""
+	}
+	fmt.Printf("   🔎 Verifying: %s\n", command)
+	result := a.registry.RunOnce(command, a.cfg.Shell.TimeoutLimit())
+	fmt.Printf("   Verify: %s\n", statusIcon(result.Success))
+	if result.Success {
+		return ""
+	}
+	return fmt.Sprintf("The verify command `%s` failed:\n%s", command, result.Output)
+}
+
+// reviewChanges asks the reviewer model to check the run's diff against the task.
+// A reviewer that cannot be reached does not keep the task from finishing.
+func (a *Agent) reviewChanges(ctx context.Context, summary string) string {
+	diff := "(the project is not a git repository, so no diff is available; judge from the summary)"
+	if a.reviewRepo != nil {
+		head, err := a.reviewRepo.Snapshot()
+		if err == nil {
+			// Agent state such as the code search index is not part of the work.
+			diff, err = a.reviewRepo.DiffTrees(a.reviewBase, head, ".devagent")
+		}
+		if err != nil {
+			log.Printf("Warning: review diff: %v", err)
+			diff = "(the diff could not be computed; judge from the summary)"
+		}
+	}
+
+	fmt.Printf("   🔎 Reviewing changes\n")
+	resp, usage, err := a.reviewClient().Chat(ctx, []llm.Message{
+		{Role: "system", Content: prompt.BuildReviewPrompt()},
+		{Role: "user", Content: prompt.BuildReviewRequest(a.task, summary, diff)},
+	})
+	a.totalUsage.PromptTokens += usage.PromptTokens
+	a.totalUsage.CompletionTokens += usage.CompletionTokens
+	a.totalUsage.TotalTokens += usage.TotalTokens
+	if err != nil {
+		log.Printf("Warning: review failed: %v (accepting the task)", err)
+		return ""
+	}
+	approved, findings, ok := parseVerdict(resp)
+	switch {
+	case !ok:
+		log.Printf("Warning: the reviewer gave no verdict (accepting the task)")
+		return ""
+	case approved:
+		return ""
+	}
+	if findings == "" {
+		findings = "(the reviewer gave no details)"
+	}
+	return "The reviewer found problems:\n" + findings
+}
+
+// reviewClient returns the client of the configured review model, or the run's.
+func (a *Agent) reviewClient() *llm.Client {
+	rc := a.cfg.Review
+	client := a.client
+	if rc.Model != "" {
+		client = client.WithModel(rc.Model)
+	}
+	if rc.BaseURL != "" {
+		client = client.WithEndpoint(rc.BaseURL, os.Getenv(rc.APIKeyEnv))
+	}
+	return client
+}
+
+// parseVerdict reads a "VERDICT: approve" or "VERDICT: changes" line and returns
+// the text after it as the findings. ok is false if there is no verdict.
+func parseVerdict(resp string) (approved bool, findings string, ok bool) {
+	lines := strings.Split(resp, "\n")
+	for i, l := range lines {
+		l = strings.Trim(strings.TrimSpace(l), "*#` ")
+		rest, found := strings.CutPrefix(strings.ToUpper(l), "VERDICT:")
+		if !found {
+			continue
+		}
+		findings = strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+		switch strings.Trim(rest, " .") {
+		case "APPROVE", "APPROVED":
+			return true, findings, true
+		case "CHANGES", "CHANGES REQUESTED", "REJECT":
+			return false, findings, true
+		}
+	}
+	return false, "", false
+}
@@ -0,0 +1,70 @@
+// Package answers reads the answers file given with -answers, which lets ask_user
+// work in -task runs without a terminal:
+//
+//	answers:
+//	  - match: database            # regular expression, matched case-insensitively
+//	    answer: Use PostgreSQL
+//	  - match: (add|write) tests
+//	    answer: "yes"
+//	default: Choose the simplest option and mention it in the summary.
+//
+// The first entry whose pattern matches the question answers it; questions that no
+// entry matches get the default, or an error when there is none.
+package answers
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type entry struct {
+	Match  string `yaml:"match"`
+	Answer string `yaml:"answer"`
+	re     *regexp.Regexp
+}
+
+// File holds the answers of an answers file.
+type File struct {
+	Answers []entry `yaml:"answers"`
+	Default string  `yaml:"default"`
+}
+
+// Load reads and validates the answers file at path.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range f.Answers {
+		e := &f.Answers[i]
+		if e.Match == "" || e.Answer == "" {
+			return nil, fmt.Errorf("%s: answer %d needs both match and answer", path, i+1)
+		}
+		if e.re, err = regexp.Compile("(?i)" + e.Match); err != nil {
+			return nil, fmt.Errorf("%s: answer %d: %w", path, i+1, err)
+		}
+	}
+	return &f, nil
+}
+
+// Ask answers question from the file. choices are not used for matching; an
+// answer is returned as written.
+func (f *File) Ask(question string, choices []string) (string, error) {
+	for _, e := range f.Answers {
+		if e.re.MatchString(question) {
+			return strings.TrimSpace(e.Answer), nil
+		}
+	}
+	if f.Default != "" {
+		return strings.TrimSpace(f.Default), nil
+	}
+	return "", fmt.Errorf("the answers file has no answer for this question")
+}
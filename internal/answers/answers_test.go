@@ -0,0 +1,62 @@
+package answers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeAnswers(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "answers.yaml")
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadAndAsk(t *testing.T) {
+	f, err := Load(writeAnswers(t, `answers:
+  - match: database
+    answer: Use PostgreSQL
+  - match: (add|write) tests
+    answer: "yes"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for q, want := range map[string]string{
+		"Which DATABASE should the service use?": "Use PostgreSQL",
+		"Should I write tests for the handler?":  "yes",
+	} {
+		if got, err := f.Ask(q, nil); err != nil || got != want {
+			t.Errorf("Ask(%q) = %q, %v; want %q", q, got, err, want)
+		}
+	}
+	if _, err := f.Ask("What port?", []string{"80", "8080"}); err == nil {
+		t.Error("an unmatched question without a default should fail")
+	}
+
+	f, err = Load(writeAnswers(t, "default: Pick the simplest option.\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.Ask("What port?", nil); err != nil || got != "Pick the simplest option." {
+		t.Errorf("Ask with default = %q, %v", got, err)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for _, content := range []string{
+		"answers: [",
+		"answers:\n  - match: x\n",
+		"answers:\n  - match: \"(\"\n    answer: y\n",
+	} {
+		if _, err := Load(writeAnswers(t, content)); err == nil {
+			t.Errorf("Load(%q) should fail", content)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load of a missing file should fail")
+	}
+}
@@ -0,0 +1,135 @@
+// Package artifact keeps the full output of every tool call made in an agent session,
+// so observations sent to the model can be shortened without losing anything.
+package artifact
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionsDir = "sessions"
+	outputsDir  = "outputs"
+
+	// maxSessions is how many session directories are kept; older ones are removed.
+	maxSessions = 20
+)
+
+var ErrNotFound = errors.New("output not found")
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Output describes one saved tool output.
+type Output struct {
+	ID   int
+	Tool string
+	Size int64
+	Path string
+}
+
+// Store saves tool outputs for one session under .devagent/sessions/<session>/outputs.
+type Store struct {
+	dir string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// Open creates a new session directory in projectDir and prunes old sessions.
+func Open(projectDir string, now time.Time) (*Store, error) {
+	root := filepath.Join(projectDir, ".devagent", sessionsDir)
+	name := fmt.Sprintf("%s-%d", now.Format("20060102-150405"), os.Getpid())
+	dir := filepath.Join(root, name, outputsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// Session data is local state; keep it out of the project's git status.
+	ignore := filepath.Join(root, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	prune(root, name)
+	return &Store{dir: dir}, nil
+}
+
+// prune removes the oldest session directories beyond maxSessions, never the current one.
+func prune(root, current string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	var sessions []string
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != current {
+			sessions = append(sessions, e.Name())
+		}
+	}
+	sort.Strings(sessions) // names start with a timestamp
+	for len(sessions) >= maxSessions {
+		os.RemoveAll(filepath.Join(root, sessions[0]))
+		sessions = sessions[1:]
+	}
+}
+
+// Dir returns the directory outputs are written to.
+func (s *Store) Dir() string { return s.dir }
+
+// Save writes the output of a tool call and returns its id (1, 2, ...).
+func (s *Store) Save(tool, output string) (int, error) {
+	s.mu.Lock()
+	s.seq++
+	id := s.seq
+	s.mu.Unlock()
+	name := fmt.Sprintf("%d-%s.txt", id, unsafeName.ReplaceAllString(tool, "_"))
+	if err := os.WriteFile(filepath.Join(s.dir, name), []byte(output), 0644); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Get returns the saved output with the given id.
+func (s *Store) Get(id int) (Output, error) {
+	matches, _ := filepath.Glob(filepath.Join(s.dir, strconv.Itoa(id)+"-*.txt"))
+	if len(matches) == 0 {
+		return Output{}, fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return parseOutput(matches[0])
+}
+
+// List returns all saved outputs in id order.
+func (s *Store) List() ([]Output, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var list []Output
+	for _, e := range entries {
+		if o, err := parseOutput(filepath.Join(s.dir, e.Name())); err == nil {
+			list = append(list, o)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func parseOutput(path string) (Output, error) {
+	base := strings.TrimSuffix(filepath.Base(path), ".txt")
+	idStr, tool, ok := strings.Cut(base, "-")
+	id, err := strconv.Atoi(idStr)
+	if !ok || err != nil {
+		return Output{}, fmt.Errorf("unexpected output file %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Output{}, err
+	}
+	return Output{ID: id, Tool: tool, Size: info.Size(), Path: path}, nil
+}
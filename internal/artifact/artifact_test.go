@@ -0,0 +1,62 @@
+package artifact
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_SaveGetList(t *testing.T) {
+	project := t.TempDir()
+	s, err := Open(project, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id1, err := s.Save("shell", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, _ := s.Save("read/file", "x")
+	if id1 != 1 || id2 != 2 {
+		t.Errorf("ids = %d, %d", id1, id2)
+	}
+	o, err := s.Get(1)
+	if err != nil || o.Tool != "shell" || o.Size != 5 {
+		t.Errorf("Get(1) = %+v, %v", o, err)
+	}
+	if data, _ := os.ReadFile(o.Path); string(data) != "hello" {
+		t.Errorf("content = %q", data)
+	}
+	if _, err := s.Get(9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(9) err = %v", err)
+	}
+	list, _ := s.List()
+	if len(list) != 2 || list[1].Tool != "read_file" {
+		t.Errorf("List = %+v", list)
+	}
+	if _, err := os.Stat(filepath.Join(project, ".devagent", "sessions", ".gitignore")); err != nil {
+		t.Error("sessions directory should be git-ignored")
+	}
+}
+
+func TestOpen_PrunesOldSessions(t *testing.T) {
+	project := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < maxSessions+5; i++ {
+		if _, err := Open(project, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Join(project, ".devagent", "sessions"))
+	dirs := 0
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs++
+		}
+	}
+	if dirs != maxSessions {
+		t.Errorf("sessions kept = %d, want %d", dirs, maxSessions)
+	}
+}
@@ -0,0 +1,436 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"devagent/internal/ignore"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	checkpointsDir = "checkpoints"
+	indexFile      = "index.json"
+	blobsDir       = "blobs"
+
+	// maxBlobSize is the largest file whose previous content is kept for shell-detected changes.
+	maxBlobSize = 1 << 20
+	// maxTreeFiles bounds the tree walk used to detect shell modifications.
+	maxTreeFiles = 20000
+)
+
+var ErrNothingToUndo = errors.New("no checkpoints to undo")
+
+// ErrTreeTooLarge is returned by CaptureTree when the project has more than
+// maxTreeFiles files; shell changes are then not checkpointed.
+var ErrTreeTooLarge = fmt.Errorf("project has more than %d files; shell changes are not checkpointed", maxTreeFiles)
+
+// FileRecord is the state of one file before a checkpointed change.
+type FileRecord struct {
+	Path    string      `json:"path"` // relative to the project directory
+	Existed bool        `json:"existed"`
+	Blob    string      `json:"blob,omitempty"` // sha256 of previous content; empty if the file did not exist or was too large
+	Mode    fs.FileMode `json:"mode,omitempty"`
+}
+
+// Entry is one checkpoint: the files a single tool call changed, and their previous state.
+type Entry struct {
+	ID    int          `json:"id"`
+	Step  int          `json:"step"`
+	Tool  string       `json:"tool"`
+	Time  time.Time    `json:"time"`
+	Files []FileRecord `json:"files"`
+}
+
+type index struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Store keeps checkpoints under <project>/.devagent/checkpoints. File contents are
+// stored once per distinct content (content-addressed blobs), so repeated edits
+// of the same file stay cheap. It works without git.
+type Store struct {
+	projectDir string
+	dir        string
+
+	mu    sync.Mutex
+	idx   index
+	step  int
+	cache map[string]treeFile // last known state of tree files, to avoid re-hashing
+}
+
+type treeFile struct {
+	modTime time.Time
+	size    int64
+	mode    fs.FileMode
+	blob    string
+}
+
+// Open loads (or initializes) the checkpoint store for projectDir.
+func Open(projectDir string) (*Store, error) {
+	s := &Store{
+		projectDir: projectDir,
+		dir:        filepath.Join(projectDir, ".devagent", checkpointsDir),
+		cache:      make(map[string]treeFile),
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, indexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.idx); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", indexFile, err)
+		}
+	}
+	for _, e := range s.idx.Entries {
+		if e.Step > s.step {
+			s.step = e.Step
+		}
+	}
+	return s, nil
+}
+
+// BeginStep starts a new agent step; checkpoints recorded afterwards belong to it.
+// Step numbers keep increasing across runs so they stay unique in the store.
+func (s *Store) BeginStep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.step++
+	return s.step
+}
+
+// Entries returns all recorded checkpoints, oldest first.
+func (s *Store) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry(nil), s.idx.Entries...)
+}
+
+// Capture is the pre-change state of a set of files (or the whole tree).
+type Capture struct {
+	tree  bool
+	files map[string]FileRecord
+}
+
+// Capture records the current state of the given files before a tool modifies them.
+func (s *Store) Capture(paths []string) (*Capture, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := &Capture{files: make(map[string]FileRecord)}
+	for _, p := range paths {
+		rel, err := s.rel(p)
+		if err != nil {
+			return nil, err
+		}
+		rec, err := s.snapshotFile(rel, 0)
+		if err != nil {
+			return nil, err
+		}
+		c.files[rel] = rec
+	}
+	return c, nil
+}
+
+// CaptureTree records the state of every file in the project, so that changes made
+// by shell commands can be detected and undone. Files whose modification time and
+// size match the previous capture are not re-read. Ignored files (see walkTree) are
+// not captured, so shell changes to them cannot be undone.
+func (s *Store) CaptureTree() (*Capture, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := &Capture{tree: true, files: make(map[string]FileRecord)}
+	err := s.walkTree(func(rel string, info fs.FileInfo) error {
+		if cached, ok := s.cache[rel]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			c.files[rel] = FileRecord{Path: rel, Existed: true, Blob: cached.blob, Mode: cached.mode}
+			return nil
+		}
+		rec, err := s.snapshotFile(rel, maxBlobSize)
+		if err != nil {
+			return err
+		}
+		s.cache[rel] = treeFile{modTime: info.ModTime(), size: info.Size(), mode: rec.Mode, blob: rec.Blob}
+		c.files[rel] = rec
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Commit compares the current state with c and, if any file changed, records a
+// checkpoint for tool in the current step. It returns nil when nothing changed.
+func (s *Store) Commit(tool string, c *Capture) (*Entry, error) {
+	if c == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []FileRecord
+	if c.tree {
+		seen := make(map[string]bool)
+		err := s.walkTree(func(rel string, info fs.FileInfo) error {
+			seen[rel] = true
+			prev, ok := c.files[rel]
+			if !ok {
+				changed = append(changed, FileRecord{Path: rel, Existed: false})
+				return nil
+			}
+			if cached, ok := s.cache[rel]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+				return nil
+			}
+			if cur, err := s.hashFile(rel, maxBlobSize); err != nil || cur != prev.Blob {
+				changed = append(changed, prev)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		for rel, prev := range c.files {
+			if !seen[rel] {
+				changed = append(changed, prev)
+			}
+		}
+	} else {
+		for rel, prev := range c.files {
+			cur, err := s.hashFile(rel, 0)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			if os.IsNotExist(err) && !prev.Existed {
+				continue
+			}
+			if err == nil && prev.Existed && cur == prev.Blob {
+				continue
+			}
+			changed = append(changed, prev)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Path < changed[j].Path })
+
+	id := 1
+	if n := len(s.idx.Entries); n > 0 {
+		id = s.idx.Entries[n-1].ID + 1
+	}
+	if s.step == 0 {
+		s.step = 1
+	}
+	e := Entry{ID: id, Step: s.step, Tool: tool, Time: time.Now(), Files: changed}
+	s.idx.Entries = append(s.idx.Entries, e)
+	if err := s.saveIndex(); err != nil {
+		s.idx.Entries = s.idx.Entries[:len(s.idx.Entries)-1]
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Undo reverts every checkpoint of the most recent step. It returns the step that
+// was undone and the files that were restored.
+func (s *Store) Undo() (int, []string, error) {
+	s.mu.Lock()
+	n := len(s.idx.Entries)
+	if n == 0 {
+		s.mu.Unlock()
+		return 0, nil, ErrNothingToUndo
+	}
+	last := s.idx.Entries[n-1].Step
+	s.mu.Unlock()
+	files, err := s.RestoreTo(last - 1)
+	return last, files, err
+}
+
+// RestoreTo reverts all checkpoints recorded after step, newest first, so files
+// return to the state they had at the end of that step (0 = before any change).
+// Reverted checkpoints are removed from the store.
+func (s *Store) RestoreTo(step int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep := len(s.idx.Entries)
+	for keep > 0 && s.idx.Entries[keep-1].Step > step {
+		keep--
+	}
+	if keep == len(s.idx.Entries) {
+		return nil, ErrNothingToUndo
+	}
+
+	restored := make(map[string]bool)
+	var errs []string
+	for i := len(s.idx.Entries) - 1; i >= keep; i-- {
+		for _, f := range s.idx.Entries[i].Files {
+			if err := s.restoreFile(f); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", f.Path, err))
+				continue
+			}
+			restored[f.Path] = true
+		}
+	}
+	s.idx.Entries = s.idx.Entries[:keep]
+	s.cache = make(map[string]treeFile)
+	if step < s.step {
+		s.step = step // undone step numbers are reused by the next run
+	}
+	if err := s.saveIndex(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	files := make([]string, 0, len(restored))
+	for p := range restored {
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	if len(errs) > 0 {
+		return files, fmt.Errorf("restore: %s", strings.Join(errs, "; "))
+	}
+	return files, nil
+}
+
+func (s *Store) restoreFile(f FileRecord) error {
+	abs := filepath.Join(s.projectDir, f.Path)
+	if !f.Existed {
+		err := os.Remove(abs)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if f.Blob == "" {
+		return fmt.Errorf("previous content was not saved (file larger than %d bytes)", maxBlobSize)
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, blobsDir, f.Blob))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return err
+	}
+	mode := f.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	if err := os.WriteFile(abs, data, mode); err != nil {
+		return err
+	}
+	return os.Chmod(abs, mode)
+}
+
+// snapshotFile stores the current content of rel as a blob. maxSize 0 means unlimited.
+func (s *Store) snapshotFile(rel string, maxSize int64) (FileRecord, error) {
+	abs := filepath.Join(s.projectDir, rel)
+	info, err := os.Stat(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return FileRecord{Path: rel, Existed: false}, nil
+		}
+		return FileRecord{}, err
+	}
+	rec := FileRecord{Path: rel, Existed: true, Mode: info.Mode().Perm()}
+	if maxSize > 0 && info.Size() > maxSize {
+		return rec, nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return FileRecord{}, err
+	}
+	sum := fmt.Sprintf("%x", sha256.Sum256(data))
+	blobPath := filepath.Join(s.dir, blobsDir, sum)
+	if _, err := os.Stat(blobPath); os.IsNotExist(err) {
+		if err := s.ensureDir(); err != nil {
+			return FileRecord{}, err
+		}
+		if err := os.WriteFile(blobPath, data, 0644); err != nil {
+			return FileRecord{}, err
+		}
+	}
+	rec.Blob = sum
+	return rec, nil
+}
+
+func (s *Store) hashFile(rel string, maxSize int64) (string, error) {
+	abs := filepath.Join(s.projectDir, rel)
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return "", nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// walkTree visits every regular file in the project that is not ignored (see package ignore).
+func (s *Store) walkTree(fn func(rel string, info fs.FileInfo) error) error {
+	count := 0
+	return ignore.New(s.projectDir).Walk(s.projectDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		count++
+		if count > maxTreeFiles {
+			return ErrTreeTooLarge
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(s.projectDir, path)
+		return fn(rel, info)
+	})
+}
+
+func (s *Store) rel(p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		return filepath.Clean(p), nil
+	}
+	rel, err := filepath.Rel(s.projectDir, p)
+	if err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+func (s *Store) ensureDir() error {
+	if err := os.MkdirAll(filepath.Join(s.dir, blobsDir), 0755); err != nil {
+		return err
+	}
+	// Keep checkpoints out of the project's git history.
+	ignore := filepath.Join(s.dir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		return os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	return nil
+}
+
+func (s *Store) saveIndex() error {
+	if err := s.ensureDir(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s.idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.dir, indexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, indexFile))
+}
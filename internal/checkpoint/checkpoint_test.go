@@ -0,0 +1,223 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	p := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, dir, rel string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStore_CaptureCommitUndo(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.go", "v1")
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	s.BeginStep()
+	c, err := s.Capture([]string{"a.go"})
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	writeFile(t, dir, "a.go", "v2")
+	e, err := s.Commit("write_file", c)
+	if err != nil || e == nil {
+		t.Fatalf("Commit = %v, %v", e, err)
+	}
+	if len(e.Files) != 1 || e.Files[0].Path != "a.go" || !e.Files[0].Existed {
+		t.Errorf("entry files = %+v", e.Files)
+	}
+
+	step, files, err := s.Undo()
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if step != 1 || len(files) != 1 {
+		t.Errorf("Undo = %d, %v", step, files)
+	}
+	if got := readFile(t, dir, "a.go"); got != "v1" {
+		t.Errorf("a.go = %q, want v1", got)
+	}
+	if _, _, err := s.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("second Undo err = %v, want ErrNothingToUndo", err)
+	}
+}
+
+func TestStore_CommitUnchangedRecordsNothing(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.go", "same")
+	s, _ := Open(dir)
+	c, _ := s.Capture([]string{"a.go"})
+	e, err := s.Commit("str_replace", c)
+	if err != nil || e != nil {
+		t.Errorf("Commit unchanged = %v, %v; want nil, nil", e, err)
+	}
+	if len(s.Entries()) != 0 {
+		t.Error("no entry should be recorded")
+	}
+}
+
+func TestStore_NewFileRemovedOnUndo(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+	s.BeginStep()
+	c, _ := s.Capture([]string{filepath.Join(dir, "new.go")})
+	writeFile(t, dir, "new.go", "x")
+	if _, err := s.Commit("write_file", c); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.go")); !os.IsNotExist(err) {
+		t.Error("new.go should be removed")
+	}
+}
+
+func TestStore_CaptureTreeDetectsShellChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "keep.txt", "keep")
+	writeFile(t, dir, "mod.txt", "before")
+	writeFile(t, dir, "del.txt", "deleted")
+	writeFile(t, dir, "node_modules/x.js", "ignored")
+	s, _ := Open(dir)
+	s.BeginStep()
+
+	c, err := s.CaptureTree()
+	if err != nil {
+		t.Fatalf("CaptureTree: %v", err)
+	}
+	writeFile(t, dir, "mod.txt", "after!")
+	os.Remove(filepath.Join(dir, "del.txt"))
+	writeFile(t, dir, "created.txt", "new")
+	writeFile(t, dir, "node_modules/x.js", "changed")
+
+	e, err := s.Commit("shell", c)
+	if err != nil || e == nil {
+		t.Fatalf("Commit = %v, %v", e, err)
+	}
+	var paths []string
+	for _, f := range e.Files {
+		paths = append(paths, f.Path)
+	}
+	want := []string{"created.txt", "del.txt", "mod.txt"}
+	if len(paths) != len(want) {
+		t.Fatalf("changed = %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("changed[%d] = %q, want %q", i, paths[i], want[i])
+		}
+	}
+
+	if _, _, err := s.Undo(); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if got := readFile(t, dir, "mod.txt"); got != "before" {
+		t.Errorf("mod.txt = %q", got)
+	}
+	if got := readFile(t, dir, "del.txt"); got != "deleted" {
+		t.Errorf("del.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "created.txt")); !os.IsNotExist(err) {
+		t.Error("created.txt should be removed")
+	}
+}
+
+func TestStore_RestoreToStepAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.go", "v0")
+	s, _ := Open(dir)
+	for _, v := range []string{"v1", "v2", "v3"} {
+		s.BeginStep()
+		c, _ := s.Capture([]string{"a.go"})
+		writeFile(t, dir, "a.go", v)
+		if _, err := s.Commit("write_file", c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if n := len(reopened.Entries()); n != 3 {
+		t.Fatalf("entries after reopen = %d, want 3", n)
+	}
+	if _, err := reopened.RestoreTo(1); err != nil {
+		t.Fatalf("RestoreTo: %v", err)
+	}
+	if got := readFile(t, dir, "a.go"); got != "v1" {
+		t.Errorf("a.go = %q, want v1", got)
+	}
+	if next := reopened.BeginStep(); next != 2 {
+		t.Errorf("BeginStep after restore = %d, want 2", next)
+	}
+	if _, err := reopened.RestoreTo(5); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("RestoreTo(5) err = %v", err)
+	}
+}
+
+func TestStore_GitignoresCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir)
+	c, _ := s.Capture([]string{"x"})
+	writeFile(t, dir, "x", "1")
+	s.Commit("write_file", c)
+	if got := readFile(t, dir, ".devagent/checkpoints/.gitignore"); got != "*\n" {
+		t.Errorf(".gitignore = %q", got)
+	}
+}
+
+func TestStore_CaptureTreeSkipsUnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.txt", "first")
+	s, _ := Open(dir)
+	s.BeginStep()
+	if _, err := s.CaptureTree(); err != nil {
+		t.Fatalf("CaptureTree: %v", err)
+	}
+
+	// Same size and modification time: the file is taken from the previous capture.
+	p := filepath.Join(dir, "a.txt")
+	info, _ := os.Stat(p)
+	writeFile(t, dir, "a.txt", "other")
+	os.Chtimes(p, info.ModTime(), info.ModTime())
+	c, err := s.CaptureTree()
+	if err != nil {
+		t.Fatalf("CaptureTree: %v", err)
+	}
+	if blob, _ := s.hashFile("a.txt", 0); c.files["a.txt"].Blob == blob {
+		t.Error("unchanged file was hashed again")
+	}
+
+	writeFile(t, dir, "a.txt", "changed content")
+	c, err = s.CaptureTree()
+	if err != nil {
+		t.Fatalf("CaptureTree: %v", err)
+	}
+	if blob, _ := s.hashFile("a.txt", 0); c.files["a.txt"].Blob != blob {
+		t.Error("changed file was not hashed again")
+	}
+}
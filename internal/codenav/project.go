@@ -0,0 +1,457 @@
+package codenav
+
+import (
+	"bufio"
+	"bytes"
+	"devagent/internal/ignore"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const maxSourceSize = 2 << 20 // larger files are not indexed
+
+// sourceExts are the non-Go files searched by the text fallback.
+var sourceExts = map[string]bool{
+	".py": true, ".js": true, ".jsx": true, ".mjs": true, ".cjs": true, ".ts": true, ".tsx": true,
+	".rs": true, ".java": true, ".kt": true, ".scala": true, ".rb": true, ".php": true, ".cs": true,
+	".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true, ".swift": true, ".lua": true,
+	".sh": true, ".bash": true, ".pl": true, ".ex": true, ".exs": true, ".dart": true, ".vue": true,
+}
+
+// IsSource reports whether symbols are extracted from the file at path: Go files,
+// and the other languages the keyword heuristics know.
+func IsSource(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".go" || sourceExts[ext]
+}
+
+// Location is a position in a source file with the text of its line.
+type Location struct {
+	File string // slash-separated, relative to the project root
+	Line int
+	Col  int
+	Text string // the trimmed source line
+}
+
+// Project is a snapshot of the source files under a root directory. Go files are
+// parsed when the project is loaded and type-checked on the first References call.
+type Project struct {
+	Root   string
+	Module string // module path from go.mod ("" if there is none)
+
+	fset     *token.FileSet
+	pkgs     []*goPackage
+	byPath   map[string]*goPackage // import path -> package (non-test files)
+	goFiles  map[string]*goFile    // by relative path
+	excluded []*goFile             // Go files build constraints leave out on this platform
+	other    []string              // non-Go source files, relative paths
+	checked  bool
+	std      types.Importer
+}
+
+type goFile struct {
+	rel  string
+	ast  *ast.File
+	src  []byte
+	test bool // _test.go
+}
+
+// goPackage is the Go files of one directory with one package name. Internal tests
+// belong to their package; an external "x_test" package is separate.
+type goPackage struct {
+	dir   string // relative, "." for the root
+	path  string // import path
+	name  string
+	files []*goFile
+
+	base     *types.Package // non-test files only, for importers
+	checking bool
+	baseInfo *types.Info
+	info     *types.Info // all files, recorded by check
+}
+
+// Load parses every Go file under root (respecting ignore rules) and records the other
+// source files for the text fallback. Go files that build constraints exclude for the
+// current platform (_windows.go on Linux, //go:build ignore generators, ...) are not
+// part of their package: their declarations are listed but not type-checked.
+func Load(root string) (*Project, error) {
+	p := &Project{
+		Root:    root,
+		Module:  ModulePath(root),
+		fset:    token.NewFileSet(),
+		byPath:  make(map[string]*goPackage),
+		goFiles: make(map[string]*goFile),
+	}
+	byKey := make(map[string]*goPackage)
+	err := ignore.New(root).Walk(root, func(abs string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		ext := strings.ToLower(filepath.Ext(rel))
+		if ext != ".go" {
+			if sourceExts[ext] {
+				p.other = append(p.other, rel)
+			}
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxSourceSize {
+			return nil
+		}
+		src, err := os.ReadFile(abs)
+		if err != nil {
+			return nil
+		}
+		f, _ := parser.ParseFile(p.fset, rel, src, parser.ParseComments|parser.SkipObjectResolution)
+		if f == nil || f.Name == nil {
+			return nil
+		}
+		gf := &goFile{rel: rel, ast: f, src: src, test: strings.HasSuffix(rel, "_test.go")}
+		p.goFiles[rel] = gf
+		if ok, err := build.Default.MatchFile(filepath.Dir(abs), filepath.Base(abs)); err == nil && !ok {
+			p.excluded = append(p.excluded, gf)
+			return nil
+		}
+		dir := path.Dir(rel)
+		key := dir + "\x00" + f.Name.Name
+		pkg := byKey[key]
+		if pkg == nil {
+			pkg = &goPackage{dir: dir, name: f.Name.Name, path: p.importPath(dir)}
+			if strings.HasSuffix(pkg.name, "_test") && gf.test {
+				pkg.path += "_test"
+			}
+			byKey[key] = pkg
+			p.pkgs = append(p.pkgs, pkg)
+		}
+		pkg.files = append(pkg.files, gf)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(p.pkgs, func(i, j int) bool {
+		if p.pkgs[i].dir != p.pkgs[j].dir {
+			return p.pkgs[i].dir < p.pkgs[j].dir
+		}
+		return p.pkgs[i].name < p.pkgs[j].name
+	})
+	for _, pkg := range p.pkgs {
+		if !strings.HasSuffix(pkg.path, "_test") && pkg.hasNonTest() {
+			p.byPath[pkg.path] = pkg
+		}
+	}
+	return p, nil
+}
+
+func (p *Project) importPath(dir string) string {
+	switch {
+	case p.Module == "":
+		return dir
+	case dir == ".":
+		return p.Module
+	default:
+		return p.Module + "/" + dir
+	}
+}
+
+func (pkg *goPackage) hasNonTest() bool {
+	for _, f := range pkg.files {
+		if !f.test {
+			return true
+		}
+	}
+	return false
+}
+
+// Definitions returns the declarations matching query. A query is a name ("Run"), a
+// type member ("Agent.Run", "(*Agent).Run"), a package-qualified name ("agent.New")
+// or both ("agent.Agent.Run"). A bare name also matches methods and fields with that
+// name. Go declarations come first; non-Go files are searched by keyword for the last
+// part of the name, unless a qualified name was already found in Go code.
+func (p *Project) Definitions(query string) []Symbol {
+	query = strings.NewReplacer("(", "", ")", "", "*", "").Replace(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	parts := strings.Split(query, ".")
+	matches := func(s Symbol) bool {
+		switch len(parts) {
+		case 1:
+			return s.Name == query || strings.HasSuffix(s.Name, "."+query)
+		case 2:
+			return s.Name == query || (s.Package == parts[0] && s.Name == parts[1])
+		default:
+			return s.Package == parts[0] && s.Name == strings.Join(parts[1:], ".")
+		}
+	}
+
+	var exact, members []Symbol
+	files := make([]*goFile, 0, len(p.goFiles))
+	for _, pkg := range p.pkgs {
+		files = append(files, pkg.files...)
+	}
+	files = append(files, p.excluded...)
+	for _, f := range files {
+		for _, s := range goFileSymbols(p.fset, f.ast, f.rel) {
+			if !matches(s) {
+				continue
+			}
+			if s.Depth == 0 || len(parts) > 1 {
+				exact = append(exact, s)
+			} else {
+				members = append(members, s)
+			}
+		}
+	}
+	if len(parts) > 1 && len(exact)+len(members) > 0 {
+		return append(exact, members...) // a qualified name found in Go code
+	}
+	name := parts[len(parts)-1]
+	for _, rel := range p.other {
+		src, err := os.ReadFile(filepath.Join(p.Root, rel))
+		if err != nil || !bytes.Contains(src, []byte(name)) {
+			continue
+		}
+		for _, s := range textFileSymbols(src, rel) {
+			if s.Name == name {
+				exact = append(exact, s)
+			}
+		}
+	}
+	return append(exact, members...)
+}
+
+// ErrNotGo is returned by References for symbols that were not found by the Go parser.
+var ErrNotGo = errors.New("not a Go declaration")
+
+// ErrExcluded is returned by References for declarations in files that build
+// constraints exclude on this platform, which are not type-checked.
+var ErrExcluded = errors.New("declared in a file excluded by build constraints")
+
+// References returns the uses of a Go declaration returned by Definitions, resolved
+// with go/types, so only identifiers that refer to that declaration are reported.
+// Packages that fail to type-check completely are still searched; identifiers whose
+// meaning cannot be resolved are skipped.
+func (p *Project) References(sym Symbol) ([]Location, error) {
+	if !sym.pos.IsValid() {
+		return nil, ErrNotGo
+	}
+	for _, f := range p.excluded {
+		if f.rel == sym.File {
+			return nil, ErrExcluded
+		}
+	}
+	p.check()
+	var locs []Location
+	seen := make(map[token.Pos]bool)
+	for _, pkg := range p.pkgs {
+		if pkg.info == nil {
+			continue
+		}
+		for id, obj := range pkg.info.Uses {
+			if originPos(obj) != sym.pos || seen[id.Pos()] {
+				continue
+			}
+			seen[id.Pos()] = true
+			locs = append(locs, p.location(id.Pos()))
+		}
+	}
+	sortLocations(locs)
+	return locs, nil
+}
+
+// originPos returns the declaration position of obj, looking through instantiations
+// of generic functions and types.
+func originPos(obj types.Object) token.Pos {
+	switch o := obj.(type) {
+	case *types.Func:
+		return o.Origin().Pos()
+	case *types.Var:
+		return o.Origin().Pos()
+	}
+	return obj.Pos()
+}
+
+// TextReferences returns lines containing name as a whole word in all indexed source
+// files, for languages the type checker does not cover. limit caps the result.
+func (p *Project) TextReferences(name string, limit int) []Location {
+	re, err := regexp.Compile(`(^|[^\w$])` + regexp.QuoteMeta(name) + `($|[^\w$])`)
+	if err != nil {
+		return nil
+	}
+	files := make([]string, 0, len(p.goFiles)+len(p.other))
+	for rel := range p.goFiles {
+		files = append(files, rel)
+	}
+	files = append(files, p.other...)
+	sort.Strings(files)
+
+	var locs []Location
+	for _, rel := range files {
+		var src []byte
+		if gf, ok := p.goFiles[rel]; ok {
+			src = gf.src
+		} else if src, err = os.ReadFile(filepath.Join(p.Root, rel)); err != nil {
+			continue
+		}
+		if !bytes.Contains(src, []byte(name)) {
+			continue
+		}
+		sc := bufio.NewScanner(bytes.NewReader(src))
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for n := 1; sc.Scan(); n++ {
+			line := sc.Text()
+			if m := re.FindStringSubmatchIndex(line); m != nil {
+				// m[3] is the end of the character before the name.
+				locs = append(locs, Location{File: rel, Line: n, Col: m[3] + 1, Text: shorten(strings.TrimSpace(line))})
+				if len(locs) >= limit {
+					return locs
+				}
+			}
+		}
+	}
+	return locs
+}
+
+func (p *Project) location(pos token.Pos) Location {
+	position := p.fset.Position(pos)
+	loc := Location{File: position.Filename, Line: position.Line, Col: position.Column}
+	if gf, ok := p.goFiles[position.Filename]; ok {
+		loc.Text = lineText(gf.src, position.Offset)
+	}
+	return loc
+}
+
+func lineText(src []byte, offset int) string {
+	if offset < 0 || offset > len(src) {
+		return ""
+	}
+	start := bytes.LastIndexByte(src[:offset], '\n') + 1
+	end := bytes.IndexByte(src[offset:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += offset
+	}
+	return shorten(strings.TrimSpace(string(src[start:end])))
+}
+
+func sortLocations(locs []Location) {
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].File != locs[j].File {
+			return locs[i].File < locs[j].File
+		}
+		if locs[i].Line != locs[j].Line {
+			return locs[i].Line < locs[j].Line
+		}
+		return locs[i].Col < locs[j].Col
+	})
+}
+
+// check type-checks every package, recording identifier uses. Standard library
+// imports are read from compiler export data; other modules are not resolved, and
+// the errors that causes are ignored.
+func (p *Project) check() {
+	if p.checked {
+		return
+	}
+	p.checked = true
+	p.std = importer.ForCompiler(p.fset, "gc", nil)
+	for _, pkg := range p.pkgs {
+		p.checkBase(pkg)
+		hasTests := false
+		for _, f := range pkg.files {
+			hasTests = hasTests || f.test
+		}
+		if !hasTests {
+			pkg.info = pkg.baseInfo
+			continue
+		}
+		pkg.info = newInfo()
+		p.typeCheck(pkg.path, pkg.files, pkg.info)
+	}
+}
+
+// checkBase type-checks the non-test files of pkg once; importers get the result.
+func (p *Project) checkBase(pkg *goPackage) *types.Package {
+	if pkg.base != nil || pkg.checking {
+		return pkg.base
+	}
+	pkg.checking = true
+	defer func() { pkg.checking = false }()
+	var files []*goFile
+	for _, f := range pkg.files {
+		if !f.test {
+			files = append(files, f)
+		}
+	}
+	pkg.baseInfo = newInfo()
+	pkg.base = p.typeCheck(pkg.path, files, pkg.baseInfo)
+	return pkg.base
+}
+
+func (p *Project) typeCheck(path string, files []*goFile, info *types.Info) *types.Package {
+	asts := make([]*ast.File, len(files))
+	for i, f := range files {
+		asts[i] = f.ast
+	}
+	conf := types.Config{
+		Importer:    importerFunc(p.importPackage),
+		Error:       func(error) {}, // keep going; partial information is still useful
+		FakeImportC: true,
+	}
+	pkg, _ := conf.Check(path, p.fset, asts, info)
+	return pkg
+}
+
+func (p *Project) importPackage(path string) (*types.Package, error) {
+	if pkg, ok := p.byPath[path]; ok {
+		if tp := p.checkBase(pkg); tp != nil {
+			return tp, nil
+		}
+		return nil, fmt.Errorf("import cycle through %s", path)
+	}
+	return p.std.Import(path)
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+func newInfo() *types.Info {
+	return &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+}
+
+// ModulePath returns the module path declared in root/go.mod ("" if there is none).
+func ModulePath(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			if i := strings.Index(rest, "//"); i >= 0 {
+				rest = rest[:i]
+			}
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+package codenav
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func loadFixture(t *testing.T) *Project {
+	t.Helper()
+	dir := writeFiles(t, map[string]string{
+		"go.mod":           "module example.com/app\n\ngo 1.21\n",
+		"shapes/shapes.go": shapesGo,
+		"shapes/shapes_test.go": `package shapes
+
+import "testing"
+
+func TestArea(t *testing.T) {
+	if New(1).Area() <= 0 {
+		t.Fatal("area")
+	}
+}
+`,
+		"shapes/ext_test.go": `package shapes_test
+
+import "example.com/app/shapes"
+
+var _ = shapes.New(2)
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/app/shapes"
+)
+
+type Area int // unrelated name
+
+func main() {
+	c := shapes.New(3)
+	var s shapes.Shape = c
+	fmt.Println(c.Area(), s.Area(), c.R, shapes.Default.R)
+}
+`,
+		"web/app.ts":          "export function New() {}\nNew();\n",
+		"node_modules/x/y.ts": "export function New() {}\n",
+	})
+	p, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Module != "example.com/app" {
+		t.Fatalf("Module = %q", p.Module)
+	}
+	return p
+}
+
+func locs(defs []Symbol) string {
+	var s []string
+	for _, d := range defs {
+		s = append(s, d.Loc())
+	}
+	return strings.Join(s, ",")
+}
+
+func TestProject_Definitions(t *testing.T) {
+	p := loadFixture(t)
+	tests := []struct{ query, want string }{
+		{"New", "shapes/shapes.go:27,web/app.ts:1"},
+		{"shapes.New", "shapes/shapes.go:27"},
+		{"Circle.Area", "shapes/shapes.go:23"},
+		{"(*Circle).Area", "shapes/shapes.go:23"},
+		{"shapes.Circle.Area", "shapes/shapes.go:23"},
+		{"Area", "main.go:9,shapes/shapes.go:7,shapes/shapes.go:23"},
+		{"Circle.R", "shapes/shapes.go:12"},
+		{"Missing", ""},
+	}
+	for _, tt := range tests {
+		if got := locs(p.Definitions(tt.query)); got != tt.want {
+			t.Errorf("Definitions(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestProject_References(t *testing.T) {
+	p := loadFixture(t)
+	refsOf := func(query string) string {
+		t.Helper()
+		defs := p.Definitions(query)
+		if len(defs) == 0 {
+			t.Fatalf("no definition for %s", query)
+		}
+		refs, err := p.References(defs[0])
+		if err != nil {
+			t.Fatalf("References(%s): %v", query, err)
+		}
+		var s []string
+		for _, r := range refs {
+			s = append(s, fmt.Sprintf("%s:%d:%d", r.File, r.Line, r.Col))
+		}
+		return strings.Join(s, ",")
+	}
+
+	// Uses in the package, its internal and external tests, and importers.
+	if got, want := refsOf("shapes.New"), "main.go:12:14,shapes/ext_test.go:5:16,shapes/shapes_test.go:6:5"; got != want {
+		t.Errorf("refs(shapes.New) = %s, want %s", got, want)
+	}
+	// The method, not the unrelated type or the interface method of the same name.
+	if got, want := refsOf("Circle.Area"), "main.go:14:16,shapes/shapes_test.go:6:12"; got != want {
+		t.Errorf("refs(Circle.Area) = %s, want %s", got, want)
+	}
+	if got, want := refsOf("Shape.Area"), "main.go:14:26"; got != want {
+		t.Errorf("refs(Shape.Area) = %s, want %s", got, want)
+	}
+	if got, want := refsOf("Circle.R"), "main.go:14:36,main.go:14:54,shapes/shapes.go:21:22,shapes/shapes.go:24:21,shapes/shapes.go:24:27,shapes/shapes.go:27:46"; got != want {
+		t.Errorf("refs(Circle.R) = %s, want %s", got, want)
+	}
+
+	refs, _ := p.References(p.Definitions("shapes.New")[0])
+	if refs[0].Text != "c := shapes.New(3)" {
+		t.Errorf("Text = %q", refs[0].Text)
+	}
+
+	// Non-Go declarations are not resolved.
+	if _, err := p.References(p.Definitions("New")[1]); err != ErrNotGo {
+		t.Errorf("References(ts symbol) err = %v, want ErrNotGo", err)
+	}
+}
+
+func TestProject_TextReferences(t *testing.T) {
+	p := loadFixture(t)
+	refs := p.TextReferences("New", 100)
+	var got []string
+	for _, r := range refs {
+		got = append(got, fmt.Sprintf("%s:%d:%d", r.File, r.Line, r.Col))
+	}
+	// node_modules is ignored; "NewX"-style partial matches are not reported.
+	want := "main.go:12:14,shapes/ext_test.go:5:16,shapes/shapes.go:27:6,shapes/shapes_test.go:6:5,web/app.ts:1:17,web/app.ts:2:1"
+	if strings.Join(got, ",") != want {
+		t.Errorf("TextReferences = %s, want %s", strings.Join(got, ","), want)
+	}
+	if refs := p.TextReferences("New", 2); len(refs) != 2 {
+		t.Errorf("limit not applied: %d", len(refs))
+	}
+}
+
+func TestProject_BuildConstraints(t *testing.T) {
+	other := "windows"
+	if runtime.GOOS == "windows" {
+		other = "plan9"
+	}
+	dir := writeFiles(t, map[string]string{
+		"go.mod":                          "module example.com/app\n\ngo 1.21\n",
+		"sys/sys.go":                      "package sys\n\nfunc Describe() string { return name() + limit() }\n",
+		"sys/sys_" + runtime.GOOS + ".go": "package sys\n\nfunc name() string { return \"here\" }\n",
+		"sys/sys_" + other + ".go":        "package sys\n\nfunc name() string { return \"there\" }\n",
+		"sys/limit.go":                    "package sys\n\nfunc limit() string { return \"\" }\n",
+		"sys/gen.go":                      "//go:build ignore\n\npackage sys\n\nfunc limit() string { return \"generated\" }\n",
+	})
+	p, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defs := p.Definitions("sys.name")
+	if got, want := locs(defs), "sys/sys_"+runtime.GOOS+".go:3,sys/sys_"+other+".go:3"; got != want {
+		t.Fatalf("Definitions(sys.name) = %s, want %s", got, want)
+	}
+	// The excluded twins do not make the package fail with duplicate declarations.
+	for _, query := range []string{"sys.name", "sys.limit"} {
+		refs, err := p.References(p.Definitions(query)[0])
+		if err != nil || len(refs) != 1 || refs[0].File != "sys/sys.go" {
+			t.Errorf("References(%s) = %v, %v; want the call in sys/sys.go", query, refs, err)
+		}
+	}
+	if _, err := p.References(defs[1]); err != ErrExcluded {
+		t.Errorf("References(excluded) err = %v, want ErrExcluded", err)
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"go.mod": "// comment\nmodule \"example.com/q\" // trailing\n\ngo 1.21\n"})
+	if got := ModulePath(dir); got != "example.com/q" {
+		t.Errorf("ModulePath = %q", got)
+	}
+	if got := ModulePath(t.TempDir()); got != "" {
+		t.Errorf("ModulePath without go.mod = %q", got)
+	}
+}
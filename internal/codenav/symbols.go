@@ -0,0 +1,336 @@
+// Package codenav answers navigation questions about a project's source: where a
+// symbol is defined, where it is used, and what a file or package declares. Go code
+// is parsed and type-checked with the standard library; other languages fall back to
+// declaration-keyword heuristics and word matches.
+package codenav
+
+import (
+	"bytes"
+	"devagent/internal/ignore"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a declaration found in a source file.
+type Symbol struct {
+	Name      string // "New", "Agent" or "Agent.Run" for methods and fields
+	Kind      string // func, method, type, struct, interface, field, var, const; class, def, ... for other languages
+	File      string // slash-separated, relative to the project root
+	Line      int
+	EndLine   int
+	Signature string // one-line declaration, e.g. "func (a *Agent) Run(ctx context.Context, task string) error"
+	Package   string // Go package name ("" for other languages)
+	Depth     int    // nesting level: 0 for top-level, 1 for methods and fields
+	Exported  bool
+
+	pos token.Pos // position of the name identifier (Go only)
+}
+
+// Loc returns "file:line".
+func (s Symbol) Loc() string {
+	return s.File + ":" + strconv.Itoa(s.Line)
+}
+
+// goFileSymbols returns the declarations of a parsed Go file in source order. Methods
+// and struct fields are reported with Depth 1 under the name "Type.Member".
+func goFileSymbols(fset *token.FileSet, file *ast.File, rel string) []Symbol {
+	var syms []Symbol
+	add := func(name, kind string, node ast.Node, ident *ast.Ident, sig string, depth int) {
+		syms = append(syms, Symbol{
+			Name:      name,
+			Kind:      kind,
+			File:      rel,
+			Line:      fset.Position(node.Pos()).Line,
+			EndLine:   fset.Position(node.End()).Line,
+			Signature: sig,
+			Package:   file.Name.Name,
+			Depth:     depth,
+			Exported:  ident.IsExported(),
+			pos:       ident.Pos(),
+		})
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if recv := receiverName(d); recv != "" {
+				add(recv+"."+d.Name.Name, "method", d, d.Name, funcSignature(fset, d), 1)
+			} else {
+				add(d.Name.Name, "func", d, d.Name, funcSignature(fset, d), 0)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				var node ast.Node = spec
+				if len(d.Specs) == 1 {
+					node = d // include the keyword line and doc position
+				}
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					kind := "type"
+					switch s.Type.(type) {
+					case *ast.StructType:
+						kind = "struct"
+					case *ast.InterfaceType:
+						kind = "interface"
+					}
+					add(s.Name.Name, kind, node, s.Name, typeSignature(fset, s), 0)
+					syms = append(syms, memberSymbols(fset, file, rel, s)...)
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					for _, id := range s.Names {
+						if id.Name == "_" {
+							continue
+						}
+						add(id.Name, kind, node, id, valueSignature(fset, kind, id, s), 0)
+					}
+				}
+			}
+		}
+	}
+	return syms
+}
+
+// memberSymbols returns the fields of a struct type or the methods of an interface.
+func memberSymbols(fset *token.FileSet, file *ast.File, rel string, s *ast.TypeSpec) []Symbol {
+	var list *ast.FieldList
+	kind := "field"
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		list = t.Fields
+	case *ast.InterfaceType:
+		list, kind = t.Methods, "method"
+	}
+	if list == nil {
+		return nil
+	}
+	var syms []Symbol
+	for _, f := range list.List {
+		names := f.Names
+		if len(names) == 0 {
+			// Embedded field or interface: named after the type.
+			if id := typeIdent(f.Type); id != nil {
+				names = []*ast.Ident{id}
+			}
+		}
+		for _, id := range names {
+			sig := id.Name + " " + nodeString(fset, f.Type)
+			if kind == "method" {
+				if ft, ok := f.Type.(*ast.FuncType); ok {
+					sig = id.Name + strings.TrimPrefix(nodeString(fset, ft), "func")
+				}
+			}
+			syms = append(syms, Symbol{
+				Name:      s.Name.Name + "." + id.Name,
+				Kind:      kind,
+				File:      rel,
+				Line:      fset.Position(f.Pos()).Line,
+				EndLine:   fset.Position(f.End()).Line,
+				Signature: sig,
+				Package:   file.Name.Name,
+				Depth:     1,
+				Exported:  id.IsExported(),
+				pos:       id.Pos(),
+			})
+		}
+	}
+	return syms
+}
+
+// receiverName returns the receiver type name of a method ("" for functions).
+func receiverName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return ""
+	}
+	if id := typeIdent(fd.Recv.List[0].Type); id != nil {
+		return id.Name
+	}
+	return ""
+}
+
+// typeIdent returns the identifier naming a (possibly pointer, generic or qualified) type.
+func typeIdent(t ast.Expr) *ast.Ident {
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		case *ast.SelectorExpr:
+			return x.Sel
+		case *ast.Ident:
+			return x
+		default:
+			return nil
+		}
+	}
+}
+
+func funcSignature(fset *token.FileSet, d *ast.FuncDecl) string {
+	fn := *d
+	fn.Doc, fn.Body = nil, nil
+	return nodeString(fset, &fn)
+}
+
+func typeSignature(fset *token.FileSet, s *ast.TypeSpec) string {
+	var sb strings.Builder
+	sb.WriteString("type " + s.Name.Name)
+	if s.TypeParams != nil {
+		sb.WriteString(nodeString(fset, s.TypeParams))
+	}
+	if s.Assign.IsValid() {
+		sb.WriteString(" =")
+	}
+	switch s.Type.(type) {
+	case *ast.StructType:
+		sb.WriteString(" struct")
+	case *ast.InterfaceType:
+		sb.WriteString(" interface")
+	default:
+		sb.WriteString(" " + nodeString(fset, s.Type))
+	}
+	return sb.String()
+}
+
+func valueSignature(fset *token.FileSet, kind string, id *ast.Ident, s *ast.ValueSpec) string {
+	sig := kind + " " + id.Name
+	if s.Type != nil {
+		sig += " " + nodeString(fset, s.Type)
+	}
+	for i, n := range s.Names {
+		if n == id && i < len(s.Values) {
+			sig += " = " + nodeString(fset, s.Values[i])
+		}
+	}
+	return sig
+}
+
+const maxSignature = 160
+
+// nodeString prints a node on one line, shortened to maxSignature characters.
+func nodeString(fset *token.FileSet, n ast.Node) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, n)
+	s := strings.Join(strings.Fields(buf.String()), " ")
+	if len(s) > maxSignature {
+		s = s[:maxSignature-3] + "..."
+	}
+	return s
+}
+
+// textDeclRe matches declarations in common languages: Python, JavaScript/TypeScript,
+// Rust, Java/C#-style classes, shell functions, Ruby.
+var textDeclRe = regexp.MustCompile(`^(\s*)(?:export\s+)?(?:default\s+)?(?:pub(?:\([^)]*\))?\s+)?(?:public\s+|private\s+|protected\s+|internal\s+)?(?:abstract\s+)?(?:async\s+)?(?:static\s+)?` +
+	`(def|class|function\*?|func|fn|interface|type|struct|enum|trait|impl|module|sub)\s+([A-Za-z_$][\w$]*)` +
+	`|^(\s*)(?:export\s+)?(const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?:=>|:)|[A-Za-z_$][\w$]*\s*=>)`)
+
+// textFileSymbols finds declarations in a non-Go source file by keyword. Nesting is
+// derived from indentation, so methods inside a class get Depth 1.
+func textFileSymbols(src []byte, rel string) []Symbol {
+	var syms []Symbol
+	var indents []int // indentation of enclosing declarations
+	lines := strings.Split(string(src), "\n")
+	for i, line := range lines {
+		m := textDeclRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		indent, kind, name := m[1], m[2], m[3]
+		if kind == "" {
+			// const f = (...) => ..., let g = function ...
+			indent, kind, name = m[4], "function", m[6]
+		}
+		kind = strings.TrimSuffix(kind, "*")
+		if kind == "def" || kind == "func" || kind == "fn" || kind == "sub" {
+			kind = "function"
+		}
+		width := len(strings.ReplaceAll(indent, "\t", "    "))
+		for len(indents) > 0 && indents[len(indents)-1] >= width {
+			indents = indents[:len(indents)-1]
+		}
+		syms = append(syms, Symbol{
+			Name:      name,
+			Kind:      kind,
+			File:      rel,
+			Line:      i + 1,
+			EndLine:   i + 1,
+			Signature: shorten(strings.TrimSpace(strings.TrimRight(line, "{:"))),
+			Depth:     len(indents),
+			Exported:  !strings.HasPrefix(name, "_"),
+		})
+		indents = append(indents, width)
+	}
+	return syms
+}
+
+func shorten(s string) string {
+	if len(s) > maxSignature {
+		return s[:maxSignature-3] + "..."
+	}
+	return s
+}
+
+// FileSymbols returns the declarations in the file rel (relative to root). Go files
+// are parsed; other text files use keyword heuristics.
+func FileSymbols(root, rel string) ([]Symbol, error) {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	src, err := os.ReadFile(filepath.Join(root, rel))
+	if err != nil {
+		return nil, err
+	}
+	return ParseSymbols(rel, src)
+}
+
+// ParseSymbols is FileSymbols for content that has already been read.
+func ParseSymbols(rel string, src []byte) ([]Symbol, error) {
+	if strings.HasSuffix(rel, ".go") {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, rel, src, parser.SkipObjectResolution)
+		if f == nil || f.Name == nil {
+			return nil, err
+		}
+		return goFileSymbols(fset, f, rel), nil
+	}
+	if bytes.IndexByte(src[:min(len(src), 8000)], 0) >= 0 {
+		return nil, fmt.Errorf("%s is a binary file", rel)
+	}
+	return textFileSymbols(src, rel), nil
+}
+
+// DirSymbols returns the declarations of the source files directly inside dir
+// (relative to root), skipping ignored files. Go test files are included only if
+// tests is set.
+func DirSymbols(root, dir string, tests bool) ([]Symbol, error) {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	entries, err := os.ReadDir(filepath.Join(root, dir))
+	if err != nil {
+		return nil, err
+	}
+	m := ignore.New(root)
+	var syms []Symbol
+	for _, e := range entries {
+		rel := path.Join(dir, e.Name())
+		if !e.Type().IsRegular() || !IsSource(rel) || m.Ignored(rel, false) {
+			continue
+		}
+		if !tests && strings.HasSuffix(rel, "_test.go") {
+			continue
+		}
+		if s, err := FileSymbols(root, rel); err == nil {
+			syms = append(syms, s...)
+		}
+	}
+	return syms, nil
+}
@@ -0,0 +1,144 @@
+package codenav
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const shapesGo = `package shapes
+
+import "math"
+
+// Shape has an area.
+type Shape interface {
+	Area() float64
+}
+
+// Circle is round.
+type Circle struct {
+	R    float64
+	name string
+}
+
+const (
+	Pi2   = 2 * math.Pi
+	small = 1e-9
+)
+
+var Default = Circle{R: 1}
+
+func (c *Circle) Area() float64 {
+	return math.Pi * c.R * c.R
+}
+
+func New(r float64) *Circle { return &Circle{R: r} }
+`
+
+func TestFileSymbols_Go(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"shapes/shapes.go": shapesGo})
+	syms, err := FileSymbols(dir, "shapes/shapes.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range syms {
+		got = append(got, s.Kind+" "+s.Name)
+	}
+	want := "interface Shape,method Shape.Area,struct Circle,field Circle.R,field Circle.name,const Pi2,const small,var Default,method Circle.Area,func New"
+	if strings.Join(got, ",") != want {
+		t.Errorf("symbols =\n%s\nwant\n%s", strings.Join(got, ","), want)
+	}
+	byName := map[string]Symbol{}
+	for _, s := range syms {
+		byName[s.Kind+" "+s.Name] = s
+	}
+	if s := byName["method Circle.Area"]; s.Signature != "func (c *Circle) Area() float64" || s.Line != 23 || s.EndLine != 25 || s.Depth != 1 {
+		t.Errorf("Circle.Area = %+v", s)
+	}
+	if s := byName["struct Circle"]; s.Signature != "type Circle struct" || s.Line != 11 || s.EndLine != 14 || !s.Exported {
+		t.Errorf("Circle = %+v", s)
+	}
+	if s := byName["const Pi2"]; s.Signature != "const Pi2 = 2 * math.Pi" || s.Line != 17 {
+		t.Errorf("Pi2 = %+v", s)
+	}
+	if s := byName["field Circle.name"]; s.Exported || s.Signature != "name string" {
+		t.Errorf("Circle.name = %+v", s)
+	}
+	if syms[0].Package != "shapes" || syms[0].Loc() != "shapes/shapes.go:6" {
+		t.Errorf("first symbol = %+v", syms[0])
+	}
+}
+
+func TestFileSymbols_Text(t *testing.T) {
+	py := "class Greeter:\n    def __init__(self, name):\n        self.name = name\n\n    async def greet(self):\n        pass\n\ndef main():\n    Greeter('x')\n"
+	ts := "export class Store {\n  get(k: string) {}\n}\nexport const load = async (url: string) => fetch(url);\nfunction helper() {}\nconst VALUE = 3;\n"
+	dir := writeFiles(t, map[string]string{"app.py": py, "web/store.ts": ts})
+
+	syms, err := FileSymbols(dir, "app.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range syms {
+		got = append(got, strings.Repeat(">", s.Depth)+s.Kind+" "+s.Name)
+	}
+	if want := "class Greeter,>function __init__,>function greet,function main"; strings.Join(got, ",") != want {
+		t.Errorf("python symbols = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	syms, _ = FileSymbols(dir, "web/store.ts")
+	got = nil
+	for _, s := range syms {
+		got = append(got, s.Kind+" "+s.Name+"@"+s.Loc())
+	}
+	if want := "class Store@web/store.ts:1,function load@web/store.ts:4,function helper@web/store.ts:5"; strings.Join(got, ",") != want {
+		t.Errorf("ts symbols = %s, want %s", strings.Join(got, ","), want)
+	}
+}
+
+func TestDirSymbols(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"pkg/a.go":        "package pkg\n\nfunc A() {}\n",
+		"pkg/a_test.go":   "package pkg\n\nfunc TestA() {}\n",
+		"pkg/gen/g.go":    "package gen\n\nfunc G() {}\n",
+		"pkg/notes.txt":   "def nope(): pass\n",
+		"pkg/skip/x.py":   "def x(): pass\n",
+		"pkg/ignored.go":  "package pkg\n\nfunc Ignored() {}\n",
+		"pkg/.gitignore":  "ignored.go\n",
+		"pkg/tool.py":     "def tool():\n    pass\n",
+		"pkg/README.md":   "# doc\n",
+		"pkg/data.bin.go": "package pkg\n\nvar Data = 1\n",
+	})
+	syms, err := DirSymbols(dir, "pkg", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range syms {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "A,Data,tool" {
+		t.Errorf("DirSymbols = %s", got)
+	}
+	syms, _ = DirSymbols(dir, "pkg", true)
+	if len(syms) != 4 {
+		t.Errorf("with tests: %d symbols", len(syms))
+	}
+}
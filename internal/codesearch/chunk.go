@@ -0,0 +1,144 @@
+package codesearch
+
+import (
+	"devagent/internal/codenav"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	maxChunkLines = 60 // longer declarations are split into windows
+	windowLines   = 40 // window size for long declarations and files without them
+	minChunkLines = 6  // shorter neighbours are merged
+)
+
+// docExts are indexed besides source files: they often explain where things are.
+var docExts = map[string]bool{
+	".md": true, ".proto": true, ".sql": true, ".graphql": true,
+	".yaml": true, ".yml": true, ".toml": true,
+}
+
+// indexed reports whether the file at rel is searched.
+func indexed(rel string) bool {
+	return codenav.IsSource(rel) || docExts[strings.ToLower(path.Ext(rel))]
+}
+
+// span is a range of lines (1-based, inclusive) of a file with the declaration that
+// starts it.
+type span struct {
+	start, end int
+	symbol     string
+}
+
+// split cuts a file into chunks at its declarations: each function, type or class
+// (with the comments above it) becomes one chunk, long ones are split into windows
+// and short neighbours are merged. Markdown is split at headings; other files into
+// windows.
+func split(rel string, src []byte) []span {
+	lines := strings.Split(strings.TrimRight(string(src), "\n"), "\n")
+	n := len(lines)
+	if n == 0 || n == 1 && strings.TrimSpace(lines[0]) == "" {
+		return nil
+	}
+
+	labels := make(map[int]string) // start line -> declaration
+	if strings.EqualFold(path.Ext(rel), ".md") {
+		for i, l := range lines {
+			if strings.HasPrefix(l, "#") {
+				labels[i+1] = strings.TrimSpace(l)
+			}
+		}
+	} else if codenav.IsSource(rel) {
+		syms, _ := codenav.ParseSymbols(rel, src)
+		for _, s := range syms {
+			if !boundary(s, rel) || s.Line > n {
+				continue
+			}
+			start := withComments(lines, s.Line)
+			if _, ok := labels[start]; !ok {
+				labels[start] = s.Signature
+			}
+		}
+	}
+	starts := []int{1}
+	for l := range labels {
+		if l > 1 {
+			starts = append(starts, l)
+		}
+	}
+	sort.Ints(starts)
+
+	var spans []span
+	for i, s := range starts {
+		end := n
+		if i+1 < len(starts) {
+			end = starts[i+1] - 1
+		}
+		label := labels[s]
+		for s <= end {
+			e := end
+			if e-s+1 > maxChunkLines {
+				e = s + windowLines - 1
+			}
+			spans = append(spans, span{start: s, end: e, symbol: label})
+			if label != "" && !strings.HasSuffix(label, " (continued)") {
+				label += " (continued)"
+			}
+			s = e + 1
+		}
+	}
+	return merge(spans)
+}
+
+// boundary reports whether a declaration starts a chunk: top-level declarations and,
+// outside Go, the methods of classes. Go methods are top-level declarations too.
+func boundary(s codenav.Symbol, rel string) bool {
+	if strings.HasSuffix(rel, ".go") {
+		return s.Kind != "field" && (s.Depth == 0 || s.Kind == "method")
+	}
+	return s.Depth <= 1
+}
+
+// withComments moves a declaration's start line up over the comments and
+// decorators directly above it.
+func withComments(lines []string, line int) int {
+	for line > 1 {
+		prev := strings.TrimSpace(lines[line-2])
+		if prev == "" || !isComment(prev) {
+			break
+		}
+		line--
+	}
+	return line
+}
+
+func isComment(l string) bool {
+	for _, p := range []string{"//", "#", "/*", "*", "--", "@", `"""`} {
+		if strings.HasPrefix(l, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// merge joins chunks shorter than minChunkLines with the chunk after them while
+// the result stays within maxChunkLines. The merged chunk is labelled with its
+// longest declaration.
+func merge(spans []span) []span {
+	var out []span
+	var labelLines []int // length of the span that gave out[i] its label
+	for _, s := range spans {
+		n := s.end - s.start + 1
+		if k := len(out) - 1; k >= 0 && out[k].end-out[k].start+1 < minChunkLines && s.end-out[k].start+1 <= maxChunkLines {
+			if s.symbol != "" && (out[k].symbol == "" || n > labelLines[k]) {
+				out[k].symbol, labelLines[k] = s.symbol, n
+			}
+			out[k].end = s.end
+			continue
+		}
+		out = append(out, s)
+		labelLines = append(labelLines, n)
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package codesearch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplit_Go(t *testing.T) {
+	var long strings.Builder
+	for i := 0; i < 80; i++ {
+		fmt.Fprintf(&long, "\tx += %d\n", i)
+	}
+	src := "package auth\n\nimport \"time\"\n\n" + // 1-4
+		"// Validate checks the token.\n// It rejects expired ones.\n" + // 5-6
+		"func Validate(tok string) error {\n\treturn nil\n}\n\n" + // 7-10
+		"const A = 1\n\nconst B = 2\n\n" + // 11-14
+		"type Claims struct {\n\tExp time.Time\n\tSub string\n}\n\n" + // 15-19
+		"func Long() {\n" + long.String() + "}\n" // 20-101
+	spans := split("auth/jwt.go", []byte(src))
+
+	want := []span{
+		{1, 10, "func Validate(tok string) error"}, // header merged into the first function, with its comment
+		{11, 19, "type Claims struct"},             // consts merged with the type, the longest declaration
+		{20, 59, "func Long()"},
+		{60, 101, "func Long() (continued)"},
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("spans = %+v", spans)
+	}
+	for i, w := range want {
+		if spans[i] != w {
+			t.Errorf("span %d = %+v, want %+v", i, spans[i], w)
+		}
+	}
+}
+
+func TestSplit_Python(t *testing.T) {
+	body := strings.Repeat("        x = 1\n", 8)
+	src := "import os\n\n\nclass Store:\n    \"\"\"A store.\"\"\"\n\n" + // 1-6
+		"    @property\n    def size(self):\n" + body + // 7-16
+		"    def get(self, key):\n" + body // 17-25
+	spans := split("store.py", []byte(src))
+	if len(spans) != 3 || spans[0].end != 6 || spans[1].start != 7 || spans[1].symbol != "def size(self)" || spans[2].start != 17 {
+		t.Errorf("spans = %+v", spans)
+	}
+}
+
+func TestSplit_Markdown(t *testing.T) {
+	src := "# Title\n\nIntro text\nmore\nand more\nstill\n\n## Usage\n\nRun it.\n"
+	spans := split("README.md", []byte(src))
+	if len(spans) != 2 || spans[0].symbol != "# Title" || spans[1] != (span{8, 10, "## Usage"}) {
+		t.Errorf("spans = %+v", spans)
+	}
+}
+
+func TestSplit_Windows(t *testing.T) {
+	src := strings.Repeat("key: value\n", 130)
+	spans := split("config.yaml", []byte(src))
+	if len(spans) != 3 || spans[0] != (span{1, 40, ""}) || spans[2] != (span{81, 130, ""}) {
+		t.Errorf("spans = %+v", spans)
+	}
+	if spans := split("empty.go", nil); len(spans) != 0 {
+		t.Errorf("empty file: %+v", spans)
+	}
+}
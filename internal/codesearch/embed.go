@@ -0,0 +1,203 @@
+package codesearch
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	embedBatch    = 32
+	maxEmbedText  = 2000             // bytes of a chunk sent to the model
+	embedBudget   = 30 * time.Second // per search; the rest is embedded by later searches
+	queryTimeout  = 15 * time.Second
+	semanticDepth = 100 // candidates taken from each ranking when fusing
+	rrfK          = 60  // reciprocal rank fusion constant
+)
+
+// Embedder turns texts into vectors, e.g. llm.Client with an embedding model.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// SetEmbedder enables semantic ranking with the vectors of e. model names the
+// embedding model; vectors stored for another model are computed again.
+func (ix *Index) SetEmbedder(e Embedder, model string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.embedder = e
+	ix.load()
+	if e != nil && model != ix.embedModel {
+		for _, f := range ix.files {
+			for _, c := range f.Chunks {
+				c.Vec = nil
+			}
+		}
+		ix.embedModel = model
+		ix.dirty = true
+	}
+}
+
+// embedPending computes the vectors of chunks that have none, in batches, until
+// embedBudget is used up.
+func (ix *Index) embedPending() error {
+	ctx, cancel := context.WithTimeout(context.Background(), embedBudget)
+	defer cancel()
+
+	rels := make([]string, 0, len(ix.files))
+	for rel := range ix.files {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	var batch []*chunk
+	var texts []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		vecs, err := ix.embedder.Embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+		if len(vecs) != len(batch) {
+			return fmt.Errorf("embedding model returned %d vectors for %d texts", len(vecs), len(batch))
+		}
+		for i, c := range batch {
+			c.Vec = normalize(vecs[i])
+		}
+		ix.dirty = true
+		batch, texts = batch[:0], texts[:0]
+		return nil
+	}
+	for _, rel := range rels {
+		var lines []string
+		for _, c := range ix.files[rel].Chunks {
+			if c.Vec != nil {
+				continue
+			}
+			if lines == nil {
+				src, err := os.ReadFile(filepath.Join(ix.root, filepath.FromSlash(rel)))
+				if err != nil {
+					break
+				}
+				lines = strings.Split(string(src), "\n")
+			}
+			batch = append(batch, c)
+			texts = append(texts, embedText(rel, c, lines))
+			if len(batch) == embedBatch {
+				if err := flush(); err != nil {
+					return partial(ctx, err)
+				}
+			}
+		}
+	}
+	return partial(ctx, flush())
+}
+
+// partial treats running out of the time budget as success: the chunks embedded so
+// far are kept and the rest follow with the next search.
+func partial(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return nil
+	}
+	return err
+}
+
+// embedText is what the model sees of a chunk: its file, declaration and code.
+func embedText(rel string, c *chunk, lines []string) string {
+	end := min(c.End, len(lines))
+	start := min(c.Start, end+1)
+	text := rel + "\n" + strings.Join(lines[start-1:end], "\n")
+	if len(text) > maxEmbedText {
+		text = text[:maxEmbedText]
+	}
+	return text
+}
+
+// semantic ranks the chunks under dir by the cosine similarity of their vectors to
+// the query's. It also returns how many chunks have vectors.
+func (ix *Index) semantic(query, dir string) ([]scored, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	vecs, err := ix.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(vecs) != 1 {
+		return nil, 0, fmt.Errorf("embedding model returned %d vectors for 1 text", len(vecs))
+	}
+	q := normalize(vecs[0])
+
+	var out []scored
+	embedded := 0
+	for rel, e := range ix.files {
+		for _, c := range e.Chunks {
+			if c.Vec == nil {
+				continue
+			}
+			embedded++
+			if under(rel, dir) && len(c.Vec) == len(q) {
+				out = append(out, scored{rel, c, dot(q, c.Vec)})
+			}
+		}
+	}
+	sortScored(out)
+	return out, embedded, nil
+}
+
+// fuse merges two rankings by reciprocal rank: a chunk near the top of either list
+// ranks high, one near the top of both ranks highest.
+func fuse(a, b []scored) []scored {
+	type key struct {
+		file  string
+		start int
+	}
+	merged := make(map[key]*scored)
+	var out []scored
+	for _, list := range [][]scored{a, b} {
+		for i, s := range list[:min(len(list), semanticDepth)] {
+			k := key{s.file, s.c.Start}
+			m := merged[k]
+			if m == nil {
+				m = &scored{file: s.file, c: s.c}
+				merged[k] = m
+			}
+			m.score += 1 / float64(rrfK+i+1)
+		}
+	}
+	for _, m := range merged {
+		out = append(out, *m)
+	}
+	sortScored(out)
+	return out
+}
+
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	n := float32(1 / math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * n
+	}
+	return out
+}
+
+func dot(a, b []float32) float64 {
+	var s float64
+	for i := range a {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
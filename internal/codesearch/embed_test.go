@@ -0,0 +1,105 @@
+package codesearch
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// conceptEmbedder maps texts onto a few concepts, so that "log on" and "login"
+// are close without sharing a word.
+type conceptEmbedder struct {
+	calls, texts int
+	err          error
+}
+
+var concepts = [][]string{{"login", "log on", "password"}, {"cache", "memory"}, {"token", "expir"}}
+
+func (e *conceptEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	e.texts += len(texts)
+	if e.err != nil {
+		return nil, e.err
+	}
+	vecs := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, len(concepts)+1)
+		v[len(concepts)] = 0.1
+		for j, words := range concepts {
+			for _, w := range words {
+				v[j] += float32(strings.Count(strings.ToLower(text), w))
+			}
+		}
+		vecs[i] = v
+	}
+	return vecs, nil
+}
+
+func TestSearch_Semantic(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(t.TempDir(), "index")
+	writeFiles(t, dir, project)
+	ix := Open(dir, store)
+	emb := &conceptEmbedder{}
+	ix.SetEmbedder(emb, "concepts")
+
+	// No chunk contains "people" or "log": only the embeddings find the answer.
+	res, err := ix.Search("how do people log on", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.EmbedErr != nil || res.Embedded != res.Stats.Chunks {
+		t.Fatalf("embedded %d of %d chunks: %v", res.Embedded, res.Stats.Chunks, res.EmbedErr)
+	}
+	if len(res.Hits) == 0 || res.Hits[0].File != "web/login.py" {
+		t.Errorf("hits = %+v", res.Hits)
+	}
+
+	// Vectors are stored: a new index only embeds the query.
+	ix = Open(dir, store)
+	emb = &conceptEmbedder{}
+	ix.SetEmbedder(emb, "concepts")
+	if _, err := ix.Search("memory cache", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if emb.texts != 1 {
+		t.Errorf("embedded %d texts, want only the query", emb.texts)
+	}
+
+	// Another model invalidates the stored vectors.
+	emb = &conceptEmbedder{}
+	ix.SetEmbedder(emb, "other")
+	ix.Search("memory cache", Options{})
+	if emb.texts != res.Stats.Chunks+1 {
+		t.Errorf("embedded %d texts after a model change, want %d", emb.texts, res.Stats.Chunks+1)
+	}
+}
+
+func TestSearch_EmbedError(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, project)
+	ix := Open(dir, "")
+	ix.SetEmbedder(&conceptEmbedder{err: errors.New("connection refused")}, "concepts")
+	res, err := ix.Search("validate JWT expiry", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.EmbedErr == nil || !strings.Contains(res.EmbedErr.Error(), "connection refused") {
+		t.Errorf("EmbedErr = %v", res.EmbedErr)
+	}
+	if len(res.Hits) == 0 || res.Hits[0].File != "auth/jwt.go" {
+		t.Errorf("keyword ranking not used: %+v", res.Hits)
+	}
+}
+
+func TestFuse(t *testing.T) {
+	a, b, c := &chunk{Start: 1}, &chunk{Start: 2}, &chunk{Start: 3}
+	bm := []scored{{"f", a, 9}, {"f", b, 5}}
+	vec := []scored{{"f", c, 0.9}, {"f", b, 0.8}}
+	got := fuse(bm, vec)
+	if len(got) != 3 || got[0].c != b {
+		t.Errorf("fused = %+v", got)
+	}
+}
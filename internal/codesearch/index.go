@@ -0,0 +1,454 @@
+// Package codesearch ranks chunks of a project's code for natural-language queries
+// such as "where do we validate JWT expiry". Files are split at their declarations
+// and scored with BM25 over identifiers, comments and code; optional embeddings
+// from a local or remote model add semantic ranking. The index lives on disk and is
+// brought up to date from file modification times before each search.
+package codesearch
+
+import (
+	"bytes"
+	"devagent/internal/ignore"
+	"encoding/gob"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	indexVersion    = 1
+	indexFileName   = "code.gob"
+	maxIndexedFiles = 100000
+	maxFileSize     = 1 << 20
+
+	// Term weights: a chunk's declaration and its file's path say more about it
+	// than a word in its body.
+	symbolWeight = 3
+	pathWeight   = 2
+
+	// BM25 parameters.
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	testWeight   = 0.6 // questions are usually about the code, not its tests
+	maxPerFile   = 2   // results from one file
+	snippetLines = 14  // chunks up to this size are shown whole
+)
+
+// chunk is an indexed range of lines.
+type chunk struct {
+	Start, End int
+	Symbol     string           // the declaration that starts the chunk, if any
+	Terms      map[string]int32 // weighted term frequencies
+	Len        int32            // sum of Terms
+	Vec        []float32        // normalized embedding (nil until computed)
+}
+
+type fileEntry struct {
+	ModTime int64
+	Size    int64
+	Chunks  []*chunk
+}
+
+type indexFile struct {
+	Version    int
+	EmbedModel string // model of the stored vectors
+	Files      map[string]*fileEntry
+}
+
+// Index is the search index of the project under a root directory.
+type Index struct {
+	root string
+	path string // where the index is stored ("" keeps it in memory)
+
+	mu         sync.Mutex
+	loaded     bool
+	dirty      bool
+	files      map[string]*fileEntry
+	embedder   Embedder
+	embedModel string // model of the vectors in files
+}
+
+// Open returns the index of root stored in dir. Nothing is read until the first
+// search.
+func Open(root, dir string) *Index {
+	ix := &Index{root: root, files: make(map[string]*fileEntry)}
+	if dir != "" {
+		ix.path = filepath.Join(dir, indexFileName)
+	}
+	return ix
+}
+
+// Stats describes the index after an update.
+type Stats struct {
+	Files, Chunks int
+	Parsed        int  // files (re)indexed by the update
+	Truncated     bool // the project has more files than maxIndexedFiles
+}
+
+// Update brings the index up to date with the files on disk: new and changed files
+// (by modification time and size) are indexed again and deleted ones dropped.
+func (ix *Index) Update() (Stats, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.update()
+}
+
+func (ix *Index) update() (Stats, error) {
+	ix.load()
+	var st Stats
+	seen := make(map[string]bool)
+	err := ignore.New(ix.root).Walk(ix.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == ix.root {
+				return err
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(ix.root, p)
+		rel = filepath.ToSlash(rel)
+		if !indexed(rel) {
+			return nil
+		}
+		if len(seen) >= maxIndexedFiles {
+			st.Truncated = true
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		seen[rel] = true
+		if e := ix.files[rel]; e != nil && e.ModTime == info.ModTime().UnixNano() && e.Size == info.Size() {
+			return nil
+		}
+		src, err := os.ReadFile(p)
+		if err != nil {
+			delete(seen, rel)
+			return nil
+		}
+		ix.files[rel] = &fileEntry{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Chunks: buildChunks(rel, src)}
+		ix.dirty = true
+		st.Parsed++
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+	for rel := range ix.files {
+		if !seen[rel] {
+			delete(ix.files, rel)
+			ix.dirty = true
+		}
+	}
+	for _, e := range ix.files {
+		st.Files++
+		st.Chunks += len(e.Chunks)
+	}
+	return st, nil
+}
+
+// buildChunks splits a file and computes the term frequencies of its chunks.
+// Binary files have no chunks.
+func buildChunks(rel string, src []byte) []*chunk {
+	if bytes.IndexByte(src[:min(len(src), 8000)], 0) >= 0 {
+		return nil
+	}
+	lines := strings.Split(string(src), "\n")
+	var pathTerms []string
+	tokenize(strings.TrimSuffix(rel, filepath.Ext(rel)), func(t string) { pathTerms = append(pathTerms, t) })
+
+	var chunks []*chunk
+	for _, s := range split(rel, src) {
+		c := &chunk{Start: s.start, End: s.end, Symbol: s.symbol, Terms: make(map[string]int32)}
+		add := func(w int32) func(string) {
+			return func(t string) {
+				c.Terms[t] += w
+				c.Len += w
+			}
+		}
+		tokenize(strings.Join(lines[s.start-1:s.end], "\n"), add(1))
+		if s.symbol != "" {
+			tokenize(strings.TrimSuffix(s.symbol, " (continued)"), add(symbolWeight-1)) // also counted in the body
+		}
+		for _, t := range pathTerms {
+			add(pathWeight)(t)
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
+
+// load reads the stored index once. A missing, unreadable or outdated index is
+// rebuilt from scratch.
+func (ix *Index) load() {
+	if ix.loaded {
+		return
+	}
+	ix.loaded = true
+	if ix.path == "" {
+		return
+	}
+	f, err := os.Open(ix.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var stored indexFile
+	if gob.NewDecoder(f).Decode(&stored) != nil || stored.Version != indexVersion || stored.Files == nil {
+		return
+	}
+	ix.files = stored.Files
+	ix.embedModel = stored.EmbedModel
+}
+
+// save writes the index if it changed since it was loaded.
+func (ix *Index) save() error {
+	if ix.path == "" || !ix.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(ix.path), 0755); err != nil {
+		return err
+	}
+	// The index is local state; keep it out of the project's git status.
+	ignore := filepath.Join(filepath.Dir(ix.path), ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	tmp := ix.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(indexFile{Version: indexVersion, EmbedModel: ix.embedModel, Files: ix.files})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, ix.path); err != nil {
+		return err
+	}
+	ix.dirty = false
+	return nil
+}
+
+// Options narrows a search.
+type Options struct {
+	Dir   string // only files under this directory (relative to the root; "" for all)
+	Limit int    // number of results (default 8)
+}
+
+// Result is a ranked chunk.
+type Result struct {
+	File       string // relative to the root, slash-separated
+	Start, End int
+	Symbol     string
+	Lines      []Line // excerpt; a gap in the line numbers is elided code
+}
+
+// Line is a numbered source line.
+type Line struct {
+	Num  int
+	Text string
+}
+
+// Results are the hits of a search with the state of the index.
+type Results struct {
+	Hits     []Result
+	Stats    Stats
+	Embedded int   // chunks with embeddings
+	EmbedErr error // why semantic ranking was not used, if an embedder is set
+}
+
+// Search updates the index and returns the chunks that best match query.
+func (ix *Index) Search(query string, opts Options) (*Results, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = 8
+	}
+	qterms := terms(query)
+	if len(qterms) == 0 {
+		return nil, fmt.Errorf("the query has no searchable words")
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	st, err := ix.update()
+	if err != nil {
+		return nil, err
+	}
+	res := &Results{Stats: st}
+	if ix.embedder != nil {
+		res.EmbedErr = ix.embedPending()
+	}
+	// The index only saves time; a failed write is retried after the next change.
+	ix.save()
+
+	dir := strings.Trim(filepath.ToSlash(filepath.Clean(opts.Dir)), "/")
+	if dir == "." {
+		dir = ""
+	}
+	ranked := ix.bm25(qterms, dir)
+	if ix.embedder != nil && res.EmbedErr == nil {
+		var byVec []scored
+		byVec, res.Embedded, res.EmbedErr = ix.semantic(query, dir)
+		if res.EmbedErr == nil && len(byVec) > 0 {
+			ranked = fuse(ranked, byVec)
+		}
+	}
+
+	perFile := make(map[string]int)
+	for _, s := range ranked {
+		if len(res.Hits) == opts.Limit {
+			break
+		}
+		if perFile[s.file] == maxPerFile {
+			continue
+		}
+		perFile[s.file]++
+		res.Hits = append(res.Hits, ix.result(s, qterms))
+	}
+	return res, nil
+}
+
+// scored is a chunk with its score for a query.
+type scored struct {
+	file  string
+	c     *chunk
+	score float64
+}
+
+// bm25 scores the chunks under dir that contain at least one query term. Document
+// frequencies are taken over the whole project.
+func (ix *Index) bm25(qterms []string, dir string) []scored {
+	df := make(map[string]int, len(qterms))
+	n, total := 0, 0
+	for _, e := range ix.files {
+		for _, c := range e.Chunks {
+			n++
+			total += int(c.Len)
+			for _, t := range qterms {
+				if c.Terms[t] > 0 {
+					df[t]++
+				}
+			}
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	avg := float64(total) / float64(n)
+	idf := make(map[string]float64, len(qterms))
+	for _, t := range qterms {
+		idf[t] = math.Log(1 + (float64(n)-float64(df[t])+0.5)/(float64(df[t])+0.5))
+	}
+
+	var out []scored
+	for rel, e := range ix.files {
+		if !under(rel, dir) {
+			continue
+		}
+		weight := 1.0
+		if isTest(rel) {
+			weight = testWeight
+		}
+		for _, c := range e.Chunks {
+			score := 0.0
+			for _, t := range qterms {
+				tf := float64(c.Terms[t])
+				if tf == 0 {
+					continue
+				}
+				score += idf[t] * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(c.Len)/avg))
+			}
+			if score > 0 {
+				out = append(out, scored{rel, c, score * weight})
+			}
+		}
+	}
+	sortScored(out)
+	return out
+}
+
+func sortScored(s []scored) {
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].score != s[j].score {
+			return s[i].score > s[j].score
+		}
+		if s[i].file != s[j].file {
+			return s[i].file < s[j].file
+		}
+		return s[i].c.Start < s[j].c.Start
+	})
+}
+
+func isTest(rel string) bool {
+	base := path.Base(rel)
+	return strings.HasSuffix(base, "_test.go") || strings.HasPrefix(base, "test_") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasPrefix(rel, "test/") || strings.HasPrefix(rel, "tests/") || strings.Contains(rel, "/tests/")
+}
+
+func under(rel, dir string) bool {
+	return dir == "" || rel == dir || strings.HasPrefix(rel, dir+"/")
+}
+
+// result reads the lines of a ranked chunk. Long chunks are shown as their first
+// lines plus the window with the most query terms.
+func (ix *Index) result(s scored, qterms []string) Result {
+	r := Result{File: s.file, Start: s.c.Start, End: s.c.End, Symbol: s.c.Symbol}
+	src, err := os.ReadFile(filepath.Join(ix.root, filepath.FromSlash(s.file)))
+	if err != nil {
+		return r
+	}
+	lines := strings.Split(string(src), "\n")
+	end := min(s.c.End, len(lines))
+	line := func(n int) Line { return Line{Num: n, Text: lines[n-1]} }
+	if end-s.c.Start+1 <= snippetLines {
+		for n := s.c.Start; n <= end; n++ {
+			r.Lines = append(r.Lines, line(n))
+		}
+		return r
+	}
+
+	const head, window = 2, snippetLines - 4
+	want := make(map[string]bool, len(qterms))
+	for _, t := range qterms {
+		want[t] = true
+	}
+	hits := make([]int, end+1)
+	for n := s.c.Start + head; n <= end; n++ {
+		tokenize(lines[n-1], func(t string) {
+			if want[t] {
+				hits[n]++
+			}
+		})
+	}
+	best, bestHits := s.c.Start+head, -1
+	for from := s.c.Start + head; from+window-1 <= end; from++ {
+		sum := 0
+		for n := from; n < from+window; n++ {
+			sum += hits[n]
+		}
+		if sum > bestHits {
+			best, bestHits = from, sum
+		}
+	}
+	for n := s.c.Start; n < s.c.Start+head; n++ {
+		r.Lines = append(r.Lines, line(n))
+	}
+	for n := best; n < best+window && n <= end; n++ {
+		r.Lines = append(r.Lines, line(n))
+	}
+	return r
+}
@@ -0,0 +1,219 @@
+package codesearch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var project = map[string]string{
+	"auth/jwt.go": `package auth
+
+import "time"
+
+// checkExpiry rejects tokens whose exp claim is in the past.
+func checkExpiry(c Claims, now time.Time) error {
+	if c.ExpiresAt.Before(now) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Sign creates a token for the user.
+func Sign(user string) string {
+	return "token:" + user
+}
+`,
+	"auth/jwt_test.go": `package auth
+
+func TestCheckExpiry(t *testing.T) {
+	checkExpiry(Claims{}, time.Now())
+}
+`,
+	"store/cache.go": `package store
+
+// Cache keeps recently used values in memory.
+type Cache struct {
+	items map[string]string
+}
+
+// Get returns a cached value.
+func (c *Cache) Get(key string) string { return c.items[key] }
+`,
+	"docs/auth.md": "# Authentication\n\nTokens are signed with HS256 and expire after an hour.\n",
+	"web/login.py": "def login(user, password):\n    \"\"\"Check the password and start a session.\"\"\"\n    return session.start(user)\n",
+	"image.png":    "\x89PNG\x00\x00",
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, project)
+	ix := Open(dir, "")
+	res, err := ix.Search("where do we validate JWT expiry", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Hits) == 0 {
+		t.Fatal("no results")
+	}
+	top := res.Hits[0]
+	// The short file header is merged into the first function.
+	if top.File != "auth/jwt.go" || top.Start != 1 || top.Symbol != "func checkExpiry(c Claims, now time.Time) error" {
+		t.Errorf("top result = %+v", top)
+	}
+	if len(top.Lines) != 12 || top.Lines[4].Num != 5 || !strings.Contains(top.Lines[4].Text, "checkExpiry rejects") {
+		t.Errorf("lines = %+v", top.Lines)
+	}
+	// The implementation ranks above its test.
+	if len(res.Hits) < 2 || res.Hits[1].File != "auth/jwt_test.go" {
+		t.Errorf("hits = %+v", res.Hits)
+	}
+	if res.Stats.Files != 5 || res.Stats.Parsed != 5 {
+		t.Errorf("stats = %+v", res.Stats)
+	}
+
+	res, _ = ix.Search("cached value", Options{Dir: "auth"})
+	for _, h := range res.Hits {
+		if !strings.HasPrefix(h.File, "auth/") {
+			t.Errorf("result outside auth/: %+v", h)
+		}
+	}
+	res, _ = ix.Search("cached value", Options{Dir: "./store/"})
+	if len(res.Hits) == 0 || res.Hits[0].File != "store/cache.go" {
+		t.Errorf("store results = %+v", res.Hits)
+	}
+
+	if _, err := ix.Search("the of and", Options{}); err == nil {
+		t.Error("a query of stop words should fail")
+	}
+}
+
+func TestSearch_Limits(t *testing.T) {
+	dir := t.TempDir()
+	var src strings.Builder
+	src.WriteString("package big\n")
+	for _, name := range []string{"A", "B", "C", "D"} {
+		src.WriteString("\n// Widget" + name + " renders a widget.\nfunc Widget" + name + "() {\n")
+		for i := 0; i < 5; i++ {
+			src.WriteString("\trender(widget)\n")
+		}
+		src.WriteString("}\n")
+	}
+	writeFiles(t, dir, map[string]string{"big.go": src.String(), "other.go": "package big\n\n// widget helpers\nfunc helper() {}\n"})
+	res, err := Open(dir, "").Search("widget", Options{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	perFile := map[string]int{}
+	for _, h := range res.Hits {
+		perFile[h.File]++
+	}
+	if perFile["big.go"] != maxPerFile || perFile["other.go"] != 1 {
+		t.Errorf("results per file = %v", perFile)
+	}
+	if res, _ := Open(dir, "").Search("widget", Options{Limit: 1}); len(res.Hits) != 1 {
+		t.Errorf("limit 1 gave %d results", len(res.Hits))
+	}
+}
+
+func TestSearch_LongChunkExcerpt(t *testing.T) {
+	dir := t.TempDir()
+	var src strings.Builder
+	src.WriteString("package p\n\nfunc Handle() {\n") // 1-3
+	for i := 4; i <= 50; i++ {
+		if i == 40 {
+			src.WriteString("\tverifySignature(token)\n")
+			continue
+		}
+		src.WriteString("\tstep()\n")
+	}
+	src.WriteString("}\n")
+	writeFiles(t, dir, map[string]string{"p.go": src.String()})
+	res, err := Open(dir, "").Search("verify signature", Options{})
+	if err != nil || len(res.Hits) != 1 {
+		t.Fatalf("res = %+v, err = %v", res, err)
+	}
+	lines := res.Hits[0].Lines
+	if len(lines) != snippetLines-2 || lines[0].Num != 1 || lines[1].Num != 2 {
+		t.Fatalf("excerpt = %+v", lines)
+	}
+	found := false
+	for _, l := range lines[2:] {
+		found = found || l.Num == 40
+	}
+	if !found || lines[2].Num <= 2 {
+		t.Errorf("excerpt misses the matching line: %+v", lines)
+	}
+}
+
+func TestIndex_Incremental(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(t.TempDir(), "index")
+	writeFiles(t, dir, project)
+	if _, err := Open(dir, store).Update(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(store, indexFileName)); err == nil {
+		t.Fatal("Update alone should not write the index")
+	}
+	if _, err := Open(dir, store).Search("token", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(store, ".gitignore")); err != nil || string(data) != "*\n" {
+		t.Errorf("index dir .gitignore = %q, %v", data, err)
+	}
+
+	// A new Index reads the stored one and parses nothing.
+	ix := Open(dir, store)
+	st, err := ix.Update()
+	if err != nil || st.Parsed != 0 || st.Files != 5 {
+		t.Fatalf("reopened: %+v, %v", st, err)
+	}
+
+	p := filepath.Join(dir, "store", "cache.go")
+	os.WriteFile(p, []byte("package store\n\n// Evict drops the oldest entries.\nfunc Evict() {}\n"), 0644)
+	os.Chtimes(p, time.Now(), time.Now().Add(time.Hour))
+	os.Remove(filepath.Join(dir, "web", "login.py"))
+	res, err := ix.Search("evict oldest entries", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stats.Parsed != 1 || res.Stats.Files != 4 {
+		t.Errorf("stats = %+v", res.Stats)
+	}
+	if len(res.Hits) == 0 || res.Hits[0].File != "store/cache.go" || res.Hits[0].Symbol != "func Evict()" {
+		t.Errorf("hits = %+v", res.Hits)
+	}
+	if st, _ := Open(dir, store).Update(); st.Parsed != 0 || st.Files != 4 {
+		t.Errorf("changes not saved: %+v", st)
+	}
+}
+
+func TestIndex_CorruptStore(t *testing.T) {
+	dir := t.TempDir()
+	store := t.TempDir()
+	writeFiles(t, dir, project)
+	os.WriteFile(filepath.Join(store, indexFileName), []byte("garbage"), 0644)
+	res, err := Open(dir, store).Search("token", Options{})
+	if err != nil || res.Stats.Parsed != 5 {
+		t.Fatalf("res = %+v, err = %v", res, err)
+	}
+	if st, _ := Open(dir, store).Update(); st.Parsed != 0 {
+		t.Errorf("corrupt index not replaced: %+v", st)
+	}
+}
@@ -0,0 +1,96 @@
+package codesearch
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var wordRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// stopWords are too common in code or questions to tell chunks apart.
+var stopWords = map[string]bool{
+	"the": true, "an": true, "and": true, "or": true, "of": true, "to": true, "in": true,
+	"on": true, "at": true, "by": true, "for": true, "with": true, "from": true, "as": true,
+	"is": true, "are": true, "be": true, "it": true, "its": true, "this": true, "that": true,
+	"we": true, "do": true, "does": true, "where": true, "what": true, "which": true, "how": true,
+	"who": true, "when": true, "not": true, "if": true, "else": true, "then": true, "there": true,
+	"func": true, "function": true, "def": true, "return": true, "var": true, "let": true,
+	"const": true, "nil": true, "null": true, "none": true, "true": true, "false": true,
+	"self": true, "string": true, "int": true, "err": true, "error": true, "import": true,
+	"package": true, "type": true, "struct": true, "class": true, "new": true,
+}
+
+// tokenize splits text into search terms. Identifiers are split at underscores and
+// case changes ("parseJWTClaims" gives "parse", "jwt", "claim") and also kept whole
+// ("parsejwtclaims"), so both descriptions and exact names match. Terms are lower
+// case and stemmed; stop words and single letters are dropped.
+func tokenize(text string, emit func(term string)) {
+	for _, w := range wordRe.FindAllString(text, -1) {
+		parts := splitIdent(w)
+		for _, p := range parts {
+			if l := strings.ToLower(p); len(l) > 1 && !stopWords[l] {
+				emit(stem(l))
+			}
+		}
+		if len(parts) > 1 {
+			if whole := strings.ToLower(strings.ReplaceAll(w, "_", "")); !stopWords[whole] {
+				emit(whole)
+			}
+		}
+	}
+}
+
+// terms returns the distinct terms of text.
+func terms(text string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	tokenize(text, func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	})
+	return out
+}
+
+// splitIdent splits an identifier at underscores, lower-to-upper case changes,
+// the end of an acronym ("HTTPServer" gives "HTTP", "Server") and digit runs.
+func splitIdent(w string) []string {
+	var parts []string
+	for _, seg := range strings.Split(w, "_") {
+		rs := []rune(seg)
+		start := 0
+		for i := 1; i < len(rs); i++ {
+			prev, cur := rs[i-1], rs[i]
+			split := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+				unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(rs) && unicode.IsLower(rs[i+1]) ||
+				unicode.IsDigit(prev) != unicode.IsDigit(cur)
+			if split {
+				parts = append(parts, string(rs[start:i]))
+				start = i
+			}
+		}
+		if start < len(rs) {
+			parts = append(parts, string(rs[start:]))
+		}
+	}
+	return parts
+}
+
+// suffixes are removed by stem, longest first.
+var suffixes = []string{"ions", "ion", "ings", "ing", "ies", "ied", "ers", "ed", "es", "er", "ly", "s", "y", "e"}
+
+// stem reduces a lower-case word to a crude common form, so that "validate",
+// "validation" and "validates" or "expiry" and "expired" give the same term.
+func stem(w string) string {
+	if len(w) <= 4 || w[0] >= '0' && w[0] <= '9' {
+		return w
+	}
+	for _, s := range suffixes {
+		if strings.HasSuffix(w, s) && len(w)-len(s) >= 4 {
+			return w[:len(w)-len(s)]
+		}
+	}
+	return w
+}
@@ -0,0 +1,50 @@
+package codesearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitIdent(t *testing.T) {
+	tests := map[string][]string{
+		"parseJWTClaims": {"parse", "JWT", "Claims"},
+		"HTTPServer":     {"HTTP", "Server"},
+		"max_file_size":  {"max", "file", "size"},
+		"sha256Sum":      {"sha", "256", "Sum"},
+		"ID":             {"ID"},
+		"_private":       {"private"},
+		"ValidatePath":   {"Validate", "Path"},
+	}
+	for in, want := range tests {
+		if got := splitIdent(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitIdent(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	groups := [][]string{
+		{"validate", "validates", "validated", "validation"},
+		{"expiry", "expires", "expired"},
+		{"token", "tokens"},
+		{"parse", "parser", "parsing"},
+	}
+	for _, g := range groups {
+		for _, w := range g[1:] {
+			if stem(w) != stem(g[0]) {
+				t.Errorf("stem(%q) = %q, stem(%q) = %q", w, stem(w), g[0], stem(g[0]))
+			}
+		}
+	}
+	if stem("file") != "file" || stem("rules") != "rule" {
+		t.Errorf("short words: %q %q", stem("file"), stem("rules"))
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := terms("where do we validate JWT expiry? checkJWTExpiry(token)")
+	want := []string{"validat", "jwt", "expir", "check", "checkjwtexpiry", "token"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("terms = %q, want %q", got, want)
+	}
+}
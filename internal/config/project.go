@@ -0,0 +1,219 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const projectConfigFile = "config.yaml"
+
+// Project is the root structure for .devagent/config.yaml.
+// It holds agent behaviour settings; sandbox rules live in sandbox.yaml.
+type Project struct {
+	Transaction TransactionConfig `yaml:"transaction"`
+	Git         GitConfig         `yaml:"git"`
+	Shell       ShellConfig       `yaml:"shell"`
+	Hooks       HooksConfig       `yaml:"hooks"`
+	// Format maps file extensions to the formatter run after each edit, e.g.
+	// ".go": "builtin:gofmt", ".js,.ts": "prettier --write {file}".
+	Format map[string]string `yaml:"format"`
+	LSP    LSPConfig         `yaml:"lsp"`
+	// RepoMap sizes the repository map shown to the model at the start of a run.
+	RepoMap      RepoMapConfig      `yaml:"repo_map"`
+	CodeSearch   CodeSearchConfig   `yaml:"code_search"`
+	Instructions InstructionsConfig `yaml:"instructions"`
+	Plan         PlanConfig         `yaml:"plan"`
+	Review       ReviewConfig       `yaml:"review"`
+}
+
+// ReviewConfig controls the review run when the model calls done: the verify
+// command must pass and a reviewer model checks the task's changes. Findings are
+// sent back to the model instead of finishing.
+type ReviewConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// VerifyCommand must exit 0 before the task can finish (e.g. "go build ./... && go test ./...").
+	VerifyCommand string `yaml:"verify_command"`
+	// Model reviews the diff (default: the model of the run); BaseURL and APIKeyEnv
+	// select another OpenAI-compatible API, as for embeddings.
+	Model     string `yaml:"model"`
+	BaseURL   string `yaml:"base_url"`
+	APIKeyEnv string `yaml:"api_key_env"`
+	// MaxRounds limits how often done is sent back; after that the task finishes
+	// with the last findings reported (default 3).
+	MaxRounds int `yaml:"max_rounds"`
+}
+
+// Rounds returns MaxRounds, defaulting to 3.
+func (c ReviewConfig) Rounds() int {
+	if c.MaxRounds <= 0 {
+		return 3
+	}
+	return c.MaxRounds
+}
+
+// PlanConfig controls planning mode (also enabled with -plan).
+type PlanConfig struct {
+	// Enabled makes the agent submit a plan before changing anything; interactive
+	// runs wait for the user to approve it.
+	Enabled bool `yaml:"enabled"`
+}
+
+// InstructionsConfig names the repository instruction files loaded into the prompt.
+type InstructionsConfig struct {
+	// Files are base names matched anywhere in the repository (default AGENTS.md and
+	// CONTRIBUTING.md). Files at the root apply to every run; nested ones to their
+	// directory.
+	Files []string `yaml:"files"`
+}
+
+// RepoMapConfig controls the repository map: the project's most important files
+// with their top-level declarations, ranked by how much the rest of the code uses them.
+type RepoMapConfig struct {
+	Tokens int `yaml:"tokens"` // approximate size budget (default 2048)
+}
+
+// CodeSearchConfig configures the code_search tool. Keyword (BM25) ranking is
+// always on; embeddings add semantic ranking when a model is set.
+type CodeSearchConfig struct {
+	Embeddings EmbeddingsConfig `yaml:"embeddings"`
+}
+
+// EmbeddingsConfig selects the embedding model, e.g. nomic-embed-text served by a
+// local Ollama at http://localhost:11434/v1.
+type EmbeddingsConfig struct {
+	Model   string `yaml:"model"`    // empty disables embeddings
+	BaseURL string `yaml:"base_url"` // OpenAI-compatible API (default: the chat API and its key)
+	// APIKeyEnv names the environment variable holding the key for BaseURL; local
+	// servers usually need none.
+	APIKeyEnv string `yaml:"api_key_env"`
+}
+
+// TransactionConfig controls multi-file edit transactions.
+type TransactionConfig struct {
+	// ValidateCommand runs before a transaction is committed (e.g. "go build ./...").
+	// A non-zero exit rolls back every file change made in the transaction.
+	ValidateCommand string `yaml:"validate_command"`
+	// Batch wraps several edits issued in one LLM response into an implicit transaction.
+	Batch bool `yaml:"batch"`
+}
+
+// GitConfig controls the optional git workflow: each task runs on a fresh branch,
+// changes are committed as the agent works, and the final diff is shown for review.
+type GitConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	BranchPrefix string `yaml:"branch_prefix"` // default "devagent/"
+	Commit       string `yaml:"commit"`        // "task" (default): one commit per task; "step": one per step; "none"
+	ShowDiff     *bool  `yaml:"show_diff"`     // print the task diff before returning (default true)
+}
+
+// Prefix returns the branch prefix, defaulting to "devagent/".
+func (c GitConfig) Prefix() string {
+	if c.BranchPrefix == "" {
+		return "devagent/"
+	}
+	return c.BranchPrefix
+}
+
+// CommitMode returns "task", "step" or "none" (default "task").
+func (c GitConfig) CommitMode() string {
+	switch c.Commit {
+	case "step", "none":
+		return c.Commit
+	default:
+		return "task"
+	}
+}
+
+// DiffEnabled returns whether the final diff is shown (defaults to true).
+func (c GitConfig) DiffEnabled() bool {
+	if c.ShowDiff == nil {
+		return true
+	}
+	return *c.ShowDiff
+}
+
+// ShellConfig controls how the shell tool runs commands.
+type ShellConfig struct {
+	Timeout    time.Duration `yaml:"timeout"`     // per-command default (default 5m)
+	MaxTimeout time.Duration `yaml:"max_timeout"` // upper bound for the timeout argument (default 30m)
+	Stream     *bool         `yaml:"stream"`      // print command output live while it runs (default true)
+}
+
+// DefaultTimeout returns the per-command timeout used when none is requested.
+func (c ShellConfig) DefaultTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return 5 * time.Minute
+	}
+	return c.Timeout
+}
+
+// TimeoutLimit returns the largest timeout a command may request (never below the default).
+func (c ShellConfig) TimeoutLimit() time.Duration {
+	limit := c.MaxTimeout
+	if limit <= 0 {
+		limit = 30 * time.Minute
+	}
+	if d := c.DefaultTimeout(); limit < d {
+		return d
+	}
+	return limit
+}
+
+// StreamEnabled returns whether shell output is streamed to the terminal (defaults to true).
+func (c ShellConfig) StreamEnabled() bool {
+	if c.Stream == nil {
+		return true
+	}
+	return *c.Stream
+}
+
+// HooksConfig holds commands that run automatically while the agent works.
+type HooksConfig struct {
+	// PostEdit checks run after write_file, str_replace and insert_line change a
+	// matching file; their diagnostics are added to the edit's observation.
+	PostEdit []PostEditHook `yaml:"post_edit"`
+}
+
+// PostEditHook runs Command after a file matching Glob is edited. The command may use
+// {file} (the edited file) and {dir} (its directory, e.g. "./internal/tools").
+type PostEditHook struct {
+	Glob    string        `yaml:"glob"` // comma-separated, e.g. "*.go" or "internal/**/*.go"
+	Command string        `yaml:"command"`
+	Timeout time.Duration `yaml:"timeout"` // default 1m
+}
+
+// LSPConfig lists the language servers behind the lsp_* tools. A server starts the
+// first time one of its files is queried and runs on the host, also in Docker mode.
+type LSPConfig struct {
+	Servers []LSPServer   `yaml:"servers"`
+	Timeout time.Duration `yaml:"timeout"` // per request (default 30s)
+}
+
+// LSPServer is a language server speaking LSP over stdio.
+type LSPServer struct {
+	Name       string   `yaml:"name"`
+	Command    []string `yaml:"command"`     // e.g. [pyright-langserver, --stdio]
+	Extensions []string `yaml:"extensions"`  // e.g. [.ts, .tsx, .js]
+	LanguageID string   `yaml:"language_id"` // default derived from the extension
+}
+
+// LoadProject looks for <projectDir>/.devagent/config.yaml and loads it.
+// If the file does not exist, returns nil, nil (caller should use defaults).
+func LoadProject(projectDir string) (*Project, error) {
+	path := filepath.Join(projectDir, ".devagent", projectConfigFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var p Project
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
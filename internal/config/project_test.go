@@ -0,0 +1,233 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadProject_NotFound(t *testing.T) {
+	cfg, err := LoadProject(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	if cfg != nil {
+		t.Errorf("LoadProject without config.yaml = %+v, want nil", cfg)
+	}
+}
+
+func TestLoadProject_Transaction(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".devagent"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	yaml := "transaction:\n  validate_command: go build ./...\n  batch: true\n"
+	if err := os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	if cfg.Transaction.ValidateCommand != "go build ./..." {
+		t.Errorf("ValidateCommand = %q", cfg.Transaction.ValidateCommand)
+	}
+	if !cfg.Transaction.Batch {
+		t.Error("Batch should be true")
+	}
+}
+
+func TestLoadProject_InvalidYAML(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte("transaction: [\n"), 0644)
+	if _, err := LoadProject(dir); err == nil {
+		t.Error("invalid YAML should error")
+	}
+}
+
+func TestGitConfig_Defaults(t *testing.T) {
+	var c GitConfig
+	if c.Prefix() != "devagent/" {
+		t.Errorf("Prefix = %q", c.Prefix())
+	}
+	if c.CommitMode() != "task" {
+		t.Errorf("CommitMode = %q", c.CommitMode())
+	}
+	if !c.DiffEnabled() {
+		t.Error("DiffEnabled should default to true")
+	}
+	off := false
+	c = GitConfig{BranchPrefix: "ai/", Commit: "step", ShowDiff: &off}
+	if c.Prefix() != "ai/" || c.CommitMode() != "step" || c.DiffEnabled() {
+		t.Errorf("explicit config not honored: %+v", c)
+	}
+	if (GitConfig{Commit: "bogus"}).CommitMode() != "task" {
+		t.Error("unknown commit mode should fall back to task")
+	}
+}
+
+func TestLoadProject_Shell(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	yaml := "shell:\n  timeout: 2m\n  max_timeout: 1h\n  stream: false\n"
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte(yaml), 0644)
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	if cfg.Shell.DefaultTimeout() != 2*time.Minute || cfg.Shell.TimeoutLimit() != time.Hour || cfg.Shell.StreamEnabled() {
+		t.Errorf("Shell = %+v", cfg.Shell)
+	}
+}
+
+func TestShellConfig_Defaults(t *testing.T) {
+	var c ShellConfig
+	if c.DefaultTimeout() != 5*time.Minute || c.TimeoutLimit() != 30*time.Minute || !c.StreamEnabled() {
+		t.Errorf("defaults: %v %v %v", c.DefaultTimeout(), c.TimeoutLimit(), c.StreamEnabled())
+	}
+	c = ShellConfig{Timeout: time.Hour, MaxTimeout: time.Minute}
+	if c.TimeoutLimit() != time.Hour {
+		t.Errorf("limit below default = %v, want the default", c.TimeoutLimit())
+	}
+}
+
+func TestLoadProject_Hooks(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	yaml := "hooks:\n  post_edit:\n    - glob: \"*.go\"\n      command: go vet {dir}\n      timeout: 30s\n    - glob: \"web/**/*.ts\"\n      command: npx tsc --noEmit\n"
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte(yaml), 0644)
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	hooks := cfg.Hooks.PostEdit
+	if len(hooks) != 2 {
+		t.Fatalf("PostEdit = %+v", hooks)
+	}
+	if hooks[0].Glob != "*.go" || hooks[0].Command != "go vet {dir}" || hooks[0].Timeout != 30*time.Second {
+		t.Errorf("hooks[0] = %+v", hooks[0])
+	}
+	if hooks[1].Timeout != 0 {
+		t.Errorf("hooks[1].Timeout = %v, want 0 (default)", hooks[1].Timeout)
+	}
+}
+
+func TestLoadProject_Format(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	yaml := "format:\n  .go: builtin:gofmt\n  \".js,.ts\": prettier --write {file}\n"
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte(yaml), 0644)
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	if cfg.Format[".go"] != "builtin:gofmt" || cfg.Format[".js,.ts"] != "prettier --write {file}" {
+		t.Errorf("Format = %v", cfg.Format)
+	}
+}
+
+func TestLoadProject_LSP(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	yaml := `lsp:
+  timeout: 10s
+  servers:
+    - name: gopls
+      command: [gopls]
+      extensions: [.go]
+    - command: [typescript-language-server, --stdio]
+      extensions: [.ts, .tsx]
+      language_id: typescript
+`
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte(yaml), 0644)
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	if cfg.LSP.Timeout != 10*time.Second || len(cfg.LSP.Servers) != 2 {
+		t.Fatalf("LSP = %+v", cfg.LSP)
+	}
+	ts := cfg.LSP.Servers[1]
+	if len(ts.Command) != 2 || ts.Command[1] != "--stdio" || len(ts.Extensions) != 2 || ts.LanguageID != "typescript" {
+		t.Errorf("server = %+v", ts)
+	}
+}
+
+func TestLoadProject_RepoMap(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte("repo_map:\n  tokens: 4096\n"), 0644)
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	if cfg.RepoMap.Tokens != 4096 {
+		t.Errorf("RepoMap = %+v", cfg.RepoMap)
+	}
+}
+
+func TestLoadProject_CodeSearch(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	yaml := "code_search:\n  embeddings:\n    model: nomic-embed-text\n    base_url: http://localhost:11434/v1\n    api_key_env: EMBED_KEY\n"
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte(yaml), 0644)
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	e := cfg.CodeSearch.Embeddings
+	if e.Model != "nomic-embed-text" || e.BaseURL != "http://localhost:11434/v1" || e.APIKeyEnv != "EMBED_KEY" {
+		t.Errorf("Embeddings = %+v", e)
+	}
+}
+
+func TestLoadProject_Instructions(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	yaml := "instructions:\n  files: [AGENTS.md, CLAUDE.md]\n"
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte(yaml), 0644)
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	if got := cfg.Instructions.Files; len(got) != 2 || got[0] != "AGENTS.md" || got[1] != "CLAUDE.md" {
+		t.Errorf("Instructions.Files = %v", got)
+	}
+}
+
+func TestLoadProject_Plan(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte("plan:\n  enabled: true\n"), 0644)
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	if !cfg.Plan.Enabled {
+		t.Error("Plan.Enabled should be true")
+	}
+}
+
+func TestLoadProject_Review(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	yaml := "review:\n  enabled: true\n  verify_command: go test ./...\n  model: gpt-4o-mini\n"
+	os.WriteFile(filepath.Join(dir, ".devagent", "config.yaml"), []byte(yaml), 0644)
+	cfg, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	r := cfg.Review
+	if !r.Enabled || r.VerifyCommand != "go test ./..." || r.Model != "gpt-4o-mini" {
+		t.Errorf("Review = %+v", r)
+	}
+	if r.Rounds() != 3 {
+		t.Errorf("Rounds() = %d, want default 3", r.Rounds())
+	}
+	r.MaxRounds = 1
+	if r.Rounds() != 1 {
+		t.Errorf("Rounds() = %d, want 1", r.Rounds())
+	}
+}
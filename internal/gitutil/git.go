@@ -0,0 +1,223 @@
+package gitutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const gitTimeout = 30 * time.Second
+
+var ErrNotRepo = errors.New("not a git repository")
+
+// Repo runs git commands on the host for a working tree.
+type Repo struct {
+	dir string
+}
+
+// Open returns a Repo for dir, or ErrNotRepo if dir is not inside a git work tree
+// (or git is not installed).
+func Open(dir string) (*Repo, error) {
+	r := &Repo{dir: dir}
+	out, err := r.run("rev-parse", "--is-inside-work-tree")
+	if err != nil || strings.TrimSpace(out) != "true" {
+		return nil, ErrNotRepo
+	}
+	return r, nil
+}
+
+func (r *Repo) run(args ...string) (string, error) {
+	return r.runEnv(nil, args...)
+}
+
+// runEnv runs git with env added to the environment.
+func (r *Repo) runEnv(env []string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.dir}, args...)...)
+	// Own process group: Ctrl-C pauses the agent and must not kill git midway.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Head returns the commit hash of HEAD, or "" if the repository has no commits yet.
+func (r *Repo) Head() string {
+	out, err := r.run("rev-parse", "--verify", "-q", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// CurrentBranch returns the checked-out branch name ("" when detached).
+func (r *Repo) CurrentBranch() string {
+	out, err := r.run("symbolic-ref", "--short", "-q", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// CreateBranch creates and checks out a new branch from HEAD. Uncommitted changes are carried over.
+func (r *Repo) CreateBranch(name string) error {
+	_, err := r.run("checkout", "-q", "-b", name)
+	return err
+}
+
+// HasChanges reports whether the work tree has uncommitted (including untracked) changes.
+func (r *Repo) HasChanges() (bool, error) {
+	out, err := r.run("status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(out) != "", nil
+}
+
+// CommitAll stages every change and commits it. It returns false when there was nothing to commit.
+// If no git identity is configured, a DevAgent identity is used for the commit.
+func (r *Repo) CommitAll(message string) (bool, error) {
+	changed, err := r.HasChanges()
+	if err != nil || !changed {
+		return false, err
+	}
+	if _, err := r.run("add", "-A"); err != nil {
+		return false, err
+	}
+	args := []string{"commit", "-q", "-m", message}
+	if email, _ := r.run("config", "user.email"); strings.TrimSpace(email) == "" {
+		args = append([]string{"-c", "user.name=DevAgent", "-c", "user.email=devagent@localhost"}, args...)
+	}
+	if _, err := r.run(args...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DiffStat returns "git diff --stat base" (base "" diffs against the empty tree).
+func (r *Repo) DiffStat(base string) (string, error) {
+	return r.run("diff", "--stat", r.diffBase(base))
+}
+
+// Diff returns the full diff from base to the work tree.
+func (r *Repo) Diff(base string) (string, error) {
+	return r.run("diff", r.diffBase(base))
+}
+
+// Snapshot records the work tree, including untracked files that are not ignored,
+// as a tree object and returns its hash. It uses a temporary index, so the
+// repository's index, HEAD and files are left alone. The temporary index starts as
+// a copy of the repository's, so only files whose stat information changed are
+// hashed again.
+func (r *Repo) Snapshot() (string, error) {
+	tmp, err := os.MkdirTemp("", "devagent-snapshot-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	index := filepath.Join(tmp, "index")
+	if out, err := r.run("rev-parse", "--git-path", "index"); err == nil {
+		real := strings.TrimSpace(out)
+		if !filepath.IsAbs(real) {
+			real = filepath.Join(r.dir, real)
+		}
+		if info, err := os.Stat(real); err == nil {
+			if data, err := os.ReadFile(real); err == nil && os.WriteFile(index, data, 0600) == nil {
+				// Keep the index's time, so git still spots entries changed in the same
+				// instant as they were staged ("racily clean" files).
+				os.Chtimes(index, info.ModTime(), info.ModTime())
+			}
+		}
+	}
+	env := []string{"GIT_INDEX_FILE=" + index}
+	if _, err := r.runEnv(env, "add", "-A"); err != nil {
+		// A copied index the temporary location cannot use (e.g. a split index):
+		// start from an empty one.
+		os.Remove(index)
+		if _, err := r.runEnv(env, "add", "-A"); err != nil {
+			return "", err
+		}
+	}
+	out, err := r.runEnv(env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// DiffTrees returns the diff between two trees, such as two snapshots, leaving out
+// the paths in exclude.
+func (r *Repo) DiffTrees(from, to string, exclude ...string) (string, error) {
+	args := []string{"diff", from, to}
+	if len(exclude) > 0 {
+		args = append(args, "--", ".")
+		for _, p := range exclude {
+			args = append(args, ":(exclude)"+p)
+		}
+	}
+	return r.run(args...)
+}
+
+func (r *Repo) diffBase(base string) string {
+	if base == "" {
+		// Well-known hash of the empty tree.
+		return "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	}
+	return base
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// BranchName builds a branch name like "devagent/add-error-handling-20250101-150405" from a task.
+func BranchName(prefix, task string, now time.Time) string {
+	slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(task), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.Trim(slug[:40], "-")
+	}
+	if slug == "" {
+		slug = "task"
+	}
+	return prefix + slug + "-" + now.Format("20060102-150405")
+}
+
+// CommitMessage builds a commit message from a done summary: the first line becomes the
+// subject (at most 72 characters), the rest of the summary and the task go in the body.
+func CommitMessage(summary, task string) string {
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		summary = "DevAgent task"
+	}
+	subject, body, _ := strings.Cut(summary, "\n")
+	subject = strings.TrimSpace(subject)
+	if r := []rune(subject); len(r) > 72 {
+		subject = string(r[:69]) + "..."
+		body = summary
+	}
+	var b strings.Builder
+	b.WriteString(subject)
+	b.WriteString("\n\n")
+	if body = strings.TrimSpace(body); body != "" {
+		b.WriteString(body)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Task: ")
+	b.WriteString(strings.TrimSpace(task))
+	b.WriteString("\n")
+	return b.String()
+}
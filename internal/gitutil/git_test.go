@@ -0,0 +1,191 @@
+package gitutil
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestOpen_NotRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if _, err := Open(t.TempDir()); !errors.Is(err, ErrNotRepo) {
+		t.Errorf("Open(non-repo) err = %v, want ErrNotRepo", err)
+	}
+}
+
+func TestRepo_BranchCommitDiff(t *testing.T) {
+	dir := initRepo(t)
+	r, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if r.Head() != "" {
+		t.Error("empty repo should have no HEAD")
+	}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0644)
+	if ok, err := r.CommitAll("initial"); err != nil || !ok {
+		t.Fatalf("CommitAll = %v, %v", ok, err)
+	}
+	base := r.Head()
+	if base == "" {
+		t.Fatal("HEAD should be set after commit")
+	}
+
+	if err := r.CreateBranch("devagent/test"); err != nil {
+		t.Fatalf("CreateBranch: %v", err)
+	}
+	if b := r.CurrentBranch(); b != "devagent/test" {
+		t.Errorf("CurrentBranch = %q", b)
+	}
+	if ok, err := r.CommitAll("nothing"); err != nil || ok {
+		t.Errorf("CommitAll with clean tree = %v, %v; want false, nil", ok, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("two\n"), 0644)
+	if ok, err := r.CommitAll(CommitMessage("Update a.txt", "change a")); err != nil || !ok {
+		t.Fatalf("CommitAll = %v, %v", ok, err)
+	}
+	stat, err := r.DiffStat(base)
+	if err != nil || !strings.Contains(stat, "a.txt") {
+		t.Errorf("DiffStat = %q, %v", stat, err)
+	}
+	diff, err := r.Diff(base)
+	if err != nil || !strings.Contains(diff, "+two") {
+		t.Errorf("Diff = %q, %v", diff, err)
+	}
+}
+
+func TestRepo_Snapshot(t *testing.T) {
+	dir := initRepo(t)
+	r, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0644)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0644)
+	if _, err := r.CommitAll("initial"); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("dirty\n"), 0644)
+	before, err := r.Snapshot()
+	if err != nil || before == "" {
+		t.Fatalf("Snapshot = %q, %v", before, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("two\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("fresh\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "debug.log"), []byte("noise\n"), 0644)
+	after, err := r.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	diff, err := r.DiffTrees(before, after)
+	if err != nil {
+		t.Fatalf("DiffTrees: %v", err)
+	}
+	for _, want := range []string{"-dirty", "+two", "+fresh"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff should contain %q:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "noise") {
+		t.Errorf("diff should skip ignored files:\n%s", diff)
+	}
+	if diff, err := r.DiffTrees(before, after, "new.txt"); err != nil || strings.Contains(diff, "fresh") || !strings.Contains(diff, "+two") {
+		t.Errorf("DiffTrees excluding new.txt = %q, %v", diff, err)
+	}
+	if changed, _ := r.HasChanges(); !changed {
+		t.Error("Snapshot must not stage or commit anything")
+	}
+	if out, _ := exec.Command("git", "-C", dir, "diff", "--cached", "--name-only").Output(); len(out) != 0 {
+		t.Errorf("Snapshot changed the index: %s", out)
+	}
+}
+
+func TestRepo_Snapshot_StagedChanges(t *testing.T) {
+	dir := initRepo(t)
+	r, _ := Open(dir)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0644)
+	if _, err := r.CommitAll("initial"); err != nil {
+		t.Fatal(err)
+	}
+	// Staged, then deleted or changed again in the work tree: the snapshot follows
+	// the work tree, and the repository's index keeps what was staged.
+	os.WriteFile(filepath.Join(dir, "staged.txt"), []byte("x\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("staged\n"), 0644)
+	exec.Command("git", "-C", dir, "add", "staged.txt", "a.txt").Run()
+	os.Remove(filepath.Join(dir, "staged.txt"))
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("work tree\n"), 0644)
+
+	tree, err := r.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if files, _ := exec.Command("git", "-C", dir, "ls-tree", "--name-only", tree).Output(); string(files) != "a.txt\n" {
+		t.Errorf("snapshot files = %q", files)
+	}
+	if content, _ := exec.Command("git", "-C", dir, "show", tree+":a.txt").Output(); string(content) != "work tree\n" {
+		t.Errorf("snapshot a.txt = %q", content)
+	}
+	if out, _ := exec.Command("git", "-C", dir, "diff", "--cached", "--name-only").Output(); string(out) != "a.txt\nstaged.txt\n" {
+		t.Errorf("Snapshot changed the index: %q", out)
+	}
+}
+
+func TestBranchName(t *testing.T) {
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	if got := BranchName("devagent/", "Add error handling!", now); got != "devagent/add-error-handling-20250102-150405" {
+		t.Errorf("BranchName = %q", got)
+	}
+	if got := BranchName("x/", "日本語", now); got != "x/task-20250102-150405" {
+		t.Errorf("BranchName(non-ascii) = %q", got)
+	}
+	long := BranchName("", strings.Repeat("word ", 20), now)
+	if len(long) > 40+len("-20250102-150405") {
+		t.Errorf("BranchName too long: %q", long)
+	}
+}
+
+func TestCommitMessage(t *testing.T) {
+	msg := CommitMessage("Fix nil check\nAdded guard in handler.", "fix the crash")
+	want := "Fix nil check\n\nAdded guard in handler.\n\nTask: fix the crash\n"
+	if msg != want {
+		t.Errorf("CommitMessage = %q, want %q", msg, want)
+	}
+	long := CommitMessage(strings.Repeat("x", 100), "t")
+	subject, _, _ := strings.Cut(long, "\n")
+	if len(subject) > 72 {
+		t.Errorf("subject too long: %d", len(subject))
+	}
+	if !strings.Contains(long, strings.Repeat("x", 100)) {
+		t.Error("full summary should be kept in the body")
+	}
+	if !strings.HasPrefix(CommitMessage("", "t"), "DevAgent task") {
+		t.Error("empty summary should get a default subject")
+	}
+}
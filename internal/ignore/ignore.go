@@ -0,0 +1,230 @@
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultSkipDirs are directories never walked by the file tree, search, grep and
+// checkpoint code, whether or not the project has a .gitignore.
+var DefaultSkipDirs = map[string]bool{
+	".git": true, ".devagent": true, "node_modules": true, "__pycache__": true,
+	".venv": true, "vendor": true, ".idea": true, ".vscode": true,
+	"dist": true, "build": true, ".next": true, "target": true,
+}
+
+// rule is one line of a .gitignore file.
+type rule struct {
+	pattern  string // slash-separated, relative to base
+	base     string // directory of the .gitignore, relative to the root ("" for root)
+	negate   bool
+	dirOnly  bool
+	anchored bool // pattern contains a slash: matched against the path relative to base
+}
+
+// Matcher decides whether paths under root are ignored. It combines DefaultSkipDirs
+// with the root's .git/info/exclude and every .gitignore from the root down to the
+// path's directory. Nested .gitignore files are loaded lazily and cached.
+type Matcher struct {
+	root string
+
+	mu    sync.Mutex
+	rules map[string][]rule // by directory relative to root
+}
+
+// New creates a Matcher for root.
+func New(root string) *Matcher {
+	m := &Matcher{root: root, rules: make(map[string][]rule)}
+	m.rules[""] = append(loadRules(filepath.Join(root, ".git", "info", "exclude"), ""),
+		loadRules(filepath.Join(root, ".gitignore"), "")...)
+	return m
+}
+
+// Root returns the directory the matcher was created for.
+func (m *Matcher) Root() string { return m.root }
+
+// Ignored reports whether rel (slash- or OS-separated, relative to root) is ignored.
+// A path is ignored when it or any of its parent directories is.
+func (m *Matcher) Ignored(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." || rel == "" {
+		return false
+	}
+	if strings.HasPrefix(rel, "../") || rel == ".." {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		last := i == len(parts)-1
+		if m.ignoredOne(strings.Join(parts[:i+1], "/"), !last || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoredAbs is like Ignored for an absolute path; paths outside root are never ignored.
+func (m *Matcher) IgnoredAbs(abs string, isDir bool) bool {
+	rel, err := filepath.Rel(m.root, abs)
+	if err != nil {
+		return false
+	}
+	return m.Ignored(rel, isDir)
+}
+
+// Walk walks the tree at dir like filepath.WalkDir, skipping ignored files and
+// directories. dir itself is always visited, even when it is ignored, so an explicit
+// request to search an ignored directory still works; rules apply below it.
+func (m *Matcher) Walk(dir string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return fn(p, d, err)
+		}
+		if rel, rerr := filepath.Rel(m.root, p); rerr == nil && !strings.HasPrefix(rel, "..") &&
+			m.ignoredOne(filepath.ToSlash(rel), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return fn(p, d, err)
+	})
+}
+
+// ignoredOne checks a single path without looking at its parents.
+func (m *Matcher) ignoredOne(rel string, isDir bool) bool {
+	name := path.Base(rel)
+	if isDir && DefaultSkipDirs[name] {
+		return true
+	}
+	ignored := false
+	dir := path.Dir(rel)
+	for _, d := range ancestors(dir) {
+		for _, r := range m.rulesFor(d) {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.matches(rel) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+func (m *Matcher) rulesFor(dir string) []rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.rules[dir]; ok {
+		return r
+	}
+	r := loadRules(filepath.Join(m.root, filepath.FromSlash(dir), ".gitignore"), dir)
+	m.rules[dir] = r
+	return r
+}
+
+// ancestors returns "", "a", "a/b" for dir "a/b" ("." yields just "").
+func ancestors(dir string) []string {
+	out := []string{""}
+	if dir == "." || dir == "" {
+		return out
+	}
+	parts := strings.Split(dir, "/")
+	for i := range parts {
+		out = append(out, strings.Join(parts[:i+1], "/"))
+	}
+	return out
+}
+
+func (r rule) matches(rel string) bool {
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if r.anchored {
+		return Match(r.pattern, rel)
+	}
+	return Match(r.pattern, path.Base(rel))
+}
+
+func loadRules(file, base string) []rule {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	return parseRules(data, base)
+}
+
+// parseRules parses .gitignore content whose file lives in base (relative to the root).
+func parseRules(data []byte, base string) []rule {
+	var rules []rule
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := rule{base: base}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.pattern = line
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// Match reports whether the slash-separated path name matches pattern. Besides the
+// path.Match syntax, a "**" segment matches zero or more path segments, so
+// "internal/**/*_test.go" matches "internal/a/b/x_test.go" and "internal/x_test.go".
+func Match(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			rest := pat[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		ok, err := path.Match(pat[0], name[0])
+		if err != nil || !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,123 @@
+package ignore
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "dir/main.go", false},
+		{"internal/**/*_test.go", "internal/a/b/x_test.go", true},
+		{"internal/**/*_test.go", "internal/x_test.go", true},
+		{"internal/**/*_test.go", "cmd/x_test.go", false},
+		{"**/main.go", "main.go", true},
+		{"**/main.go", "cmd/app/main.go", true},
+		{"cmd/*/main.go", "cmd/app/main.go", true},
+		{"cmd/*/main.go", "cmd/a/b/main.go", false},
+		{"build/**", "build/x/y", true},
+		{"[", "x", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher_Gitignore(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("# comment\n*.log\n!keep.log\n/bin/\ntmp/\ndocs/*.pdf\n"), 0644)
+	os.MkdirAll(filepath.Join(root, "sub"), 0755)
+	os.WriteFile(filepath.Join(root, "sub", ".gitignore"), []byte("local.txt\n"), 0644)
+
+	m := New(root)
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"sub/deep/app.log", false, true},
+		{"keep.log", false, false},
+		{"bin", true, true},
+		{"bin/tool", false, true},
+		{"sub/bin", true, false},
+		{"tmp", true, true},
+		{"a/tmp/file.go", false, true},
+		{"tmp", false, false},
+		{"docs/x.pdf", false, true},
+		{"other/docs/x.pdf", false, false},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"node_modules", true, true},
+		{"web/node_modules/pkg/index.js", false, true},
+		{"main.go", false, false},
+		{".", true, false},
+	}
+	for _, tt := range tests {
+		if got := m.Ignored(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, dir=%v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher_GitInfoExclude(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, ".git", "info"), 0755)
+	os.WriteFile(filepath.Join(root, ".git", "info", "exclude"), []byte("secret.txt\n"), 0644)
+	m := New(root)
+	if !m.Ignored("secret.txt", false) {
+		t.Error("secret.txt should be ignored via .git/info/exclude")
+	}
+}
+
+func TestMatcher_IgnoredAbs(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.tmp\n"), 0644)
+	m := New(root)
+	if !m.IgnoredAbs(filepath.Join(root, "a.tmp"), false) {
+		t.Error("a.tmp should be ignored")
+	}
+	if m.IgnoredAbs(filepath.Join(filepath.Dir(root), "a.tmp"), false) {
+		t.Error("paths outside root are never ignored")
+	}
+}
+
+func TestMatcher_Walk(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"a.go", "out/x.go", "src/b.go", "src/gen/c.go", ".devagent/config.yaml"} {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, nil, 0644)
+	}
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("out/\ngen/\n"), 0644)
+	m := New(root)
+
+	walk := func(dir string) []string {
+		var got []string
+		m.Walk(dir, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(root, p)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		return got
+	}
+	got := strings.Join(walk(root), ",")
+	if got != ".gitignore,a.go,src/b.go" {
+		t.Errorf("Walk(root) = %s", got)
+	}
+	// An explicitly requested ignored directory is still walked.
+	if got := strings.Join(walk(filepath.Join(root, "out")), ","); got != "out/x.go" {
+		t.Errorf("Walk(out) = %s", got)
+	}
+}
@@ -0,0 +1,144 @@
+// Package instructions finds the instruction files a repository keeps for people
+// and agents working in it — AGENTS.md, CONTRIBUTING.md and the like — at its root
+// and in nested directories. Root files apply to the whole run; a nested file applies
+// to its directory and is handed out once the agent starts working in that subtree.
+package instructions
+
+import (
+	"devagent/internal/ignore"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultNames are the instruction files looked for when none are configured.
+var DefaultNames = []string{"AGENTS.md", "CONTRIBUTING.md"}
+
+const (
+	maxFiles    = 200     // instruction files kept per repository
+	maxFileSize = 1 << 20 // larger files are not instructions
+)
+
+// rootAliases are directories whose instruction files count as the root's, as with
+// GitHub's .github/CONTRIBUTING.md.
+var rootAliases = map[string]bool{".github": true}
+
+// File is an instruction file.
+type File struct {
+	Path    string // relative to the root, slash-separated
+	Dir     string // directory it applies to ("" for the whole repository)
+	Content string // trimmed
+}
+
+// Set holds the instruction files of a repository and remembers which have been
+// handed out.
+type Set struct {
+	mu     sync.Mutex
+	files  []File // sorted by Dir depth, then Path
+	loaded map[string]bool
+}
+
+// Discover walks root (respecting .gitignore) for files whose base name matches one
+// of names, case-insensitively. Empty files are skipped.
+func Discover(root string, names []string) (*Set, error) {
+	if len(names) == 0 {
+		names = DefaultNames
+	}
+	want := make(map[string]bool, len(names))
+	for _, n := range names {
+		want[strings.ToLower(n)] = true
+	}
+
+	s := &Set{loaded: make(map[string]bool)}
+	err := ignore.New(root).Walk(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() || !want[strings.ToLower(d.Name())] || len(s.files) >= maxFiles {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+		content := strings.TrimSpace(string(data))
+		if content == "" {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		dir := path.Dir(rel)
+		if dir == "." || rootAliases[dir] {
+			dir = ""
+		}
+		s.files = append(s.files, File{Path: rel, Dir: dir, Content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(s.files, func(i, j int) bool {
+		di, dj := depth(s.files[i].Dir), depth(s.files[j].Dir)
+		if di != dj {
+			return di < dj
+		}
+		return s.files[i].Path < s.files[j].Path
+	})
+	return s, nil
+}
+
+func depth(dir string) int {
+	if dir == "" {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
+
+// All returns every instruction file found.
+func (s *Set) All() []File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]File(nil), s.files...)
+}
+
+// Root returns the files that apply to the whole repository and marks them loaded.
+func (s *Set) Root() []File {
+	return s.take(func(f File) bool { return f.Dir == "" })
+}
+
+// For returns the files not handed out yet that apply to rel (a file or directory
+// relative to the root), outermost first, and marks them loaded.
+func (s *Set) For(rel string) []File {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return nil
+	}
+	return s.take(func(f File) bool { return f.Dir == "" || rel == f.Dir || strings.HasPrefix(rel, f.Dir+"/") })
+}
+
+func (s *Set) take(match func(File) bool) []File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []File
+	for _, f := range s.files {
+		if !s.loaded[f.Path] && match(f) {
+			s.loaded[f.Path] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package instructions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func paths(files []File) []string {
+	var out []string
+	for _, f := range files {
+		out = append(out, f.Path)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"AGENTS.md":                  "  root rules \n",
+		".github/CONTRIBUTING.md":    "contributing",
+		"services/AGENTS.md":         "services",
+		"services/api/agents.md":     "api",
+		"services/api/handler.go":    "package api",
+		"web/AGENTS.md":              "   \n",
+		"node_modules/pkg/AGENTS.md": "vendored",
+		"ignored/AGENTS.md":          "ignored",
+		".gitignore":                 "ignored/\n",
+	})
+	s, err := Discover(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".github/CONTRIBUTING.md", "AGENTS.md", "services/AGENTS.md", "services/api/agents.md"}
+	if got := paths(s.All()); !equal(got, want) {
+		t.Fatalf("All() = %v, want %v", got, want)
+	}
+
+	root0 := s.Root()
+	if got := paths(root0); !equal(got, want[:2]) {
+		t.Errorf("Root() = %v, want %v", got, want[:2])
+	}
+	if root0[1].Content != "root rules" || root0[0].Dir != "" {
+		t.Errorf("Root() files = %+v", root0)
+	}
+	if got := s.For("web/index.js"); len(got) != 0 {
+		t.Errorf("For(web/index.js) = %v, want none", paths(got))
+	}
+	if got := paths(s.For("services/api/handler.go")); !equal(got, want[2:]) {
+		t.Errorf("For(services/api/handler.go) = %v, want %v", got, want[2:])
+	}
+	if got := s.For("services/api"); len(got) != 0 {
+		t.Errorf("files are handed out once; got %v", paths(got))
+	}
+	if got := s.For("../elsewhere"); len(got) != 0 {
+		t.Errorf("For(../elsewhere) = %v", paths(got))
+	}
+}
+
+func TestDiscover_Names(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"AGENTS.md":     "agents",
+		"CLAUDE.md":     "claude",
+		"pkg/CLAUDE.md": "pkg",
+	})
+	s, err := Discover(root, []string{"CLAUDE.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(s.All()); !equal(got, []string{"CLAUDE.md", "pkg/CLAUDE.md"}) {
+		t.Errorf("All() = %v", got)
+	}
+	if got := s.For("pkg"); len(got) != 2 {
+		t.Errorf("For(pkg) before Root() should include the root file; got %v", paths(got))
+	}
+}
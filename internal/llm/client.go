@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -222,3 +223,20 @@ func (c *Client) ChatStream(ctx context.Context, messages []Message, onChunk fun
 func (c *Client) Model() string {
 	return c.model
 }
+
+// WithModel returns a copy of the client that uses model.
+func (c *Client) WithModel(model string) *Client {
+	cp := *c
+	cp.model = model
+	return &cp
+}
+
+// WithEndpoint returns a copy of the client that talks to another OpenAI-compatible
+// API. Unlike NewClient, an empty apiKey is kept, so the default key is not sent to
+// a third party.
+func (c *Client) WithEndpoint(baseURL, apiKey string) *Client {
+	cp := *c
+	cp.baseURL = strings.TrimRight(baseURL, "/")
+	cp.apiKey = apiKey
+	return &cp
+}
@@ -169,3 +169,15 @@ func TestClient_ChatStream_NilOnChunk(t *testing.T) {
 		t.Errorf("content = %q", content)
 	}
 }
+
+func TestClient_WithModelAndEndpoint(t *testing.T) {
+	c := NewClient(Config{APIKey: "key", BaseURL: "https://api.example/v1", Model: "chat"})
+	e := c.WithModel("embed")
+	if e.model != "embed" || e.apiKey != "key" || e.baseURL != "https://api.example/v1" || c.model != "chat" {
+		t.Errorf("WithModel: %+v (original %+v)", e, c)
+	}
+	l := e.WithEndpoint("http://localhost:11434/v1/", "")
+	if l.baseURL != "http://localhost:11434/v1" || l.apiKey != "" || l.model != "embed" {
+		t.Errorf("WithEndpoint: %+v", l)
+	}
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_Embed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		var req EmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "nomic-embed-text" || len(req.Input) != 2 {
+			t.Errorf("request = %+v", req)
+		}
+		// Out of order, as some servers return them.
+		w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{APIKey: "test", BaseURL: server.URL, Model: "nomic-embed-text", Timeout: 5 * time.Second})
+	vecs, err := client.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vecs) != 2 || vecs[0][0] != 1 || vecs[1][1] != 1 {
+		t.Errorf("vecs = %v", vecs)
+	}
+}
+
+func TestClient_Embed_Errors(t *testing.T) {
+	for _, body := range []string{`{"data":[{"index":0,"embedding":[1]}]}`, `{"data":[{"index":5,"embedding":[1]}]}`, `not json`} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		client := NewClient(Config{APIKey: "test", BaseURL: server.URL, Timeout: 5 * time.Second})
+		if _, err := client.Embed(context.Background(), []string{"a", "b"}); err == nil {
+			t.Errorf("no error for %s", body)
+		}
+		server.Close()
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := NewClient(Config{APIKey: "test", BaseURL: server.URL, Timeout: 5 * time.Second})
+	if _, err := client.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Error("expected error on 404")
+	}
+}
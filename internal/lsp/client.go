@@ -0,0 +1,544 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultTimeout  = 30 * time.Second
+	diagnosticsWait = 5 * time.Second // how long to wait for diagnostics after a change
+	stopWait        = 2 * time.Second
+	stderrTail      = 4096
+)
+
+// ServerConfig describes a language server and the files it handles.
+type ServerConfig struct {
+	Name       string   // shown in messages; defaults to the command's base name
+	Command    []string // program and arguments, e.g. ["pyright-langserver", "--stdio"]
+	Extensions []string // ".ts", ".tsx"
+	LanguageID string   // textDocument languageId; derived from the extension when empty
+}
+
+// DisplayName returns Name, or the command's base name.
+func (c ServerConfig) DisplayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if len(c.Command) > 0 {
+		return filepath.Base(c.Command[0])
+	}
+	return "language server"
+}
+
+// Loc is a source range with 1-based lines and 1-based byte columns.
+type Loc struct {
+	Path    string
+	Line    int
+	Col     int
+	EndLine int
+	EndCol  int
+}
+
+// FileDiagnostic is a diagnostic located in a file, converted to Loc coordinates.
+type FileDiagnostic struct {
+	Loc
+	Severity string
+	Source   string
+	Message  string
+}
+
+// document is the state of a file as last sent to the server.
+type document struct {
+	version int
+	text    string
+	seq     int // diagnostics sequence number when the text was sent
+}
+
+// published holds the latest diagnostics the server pushed for a document.
+type published struct {
+	items   []Diagnostic
+	seq     int
+	version *int // document version the diagnostics are for, if the server says
+}
+
+// current reports whether the diagnostics describe the text last sent in doc.
+func (p *published) current(doc *document) bool {
+	return p.seq > doc.seq && (p.version == nil || *p.version >= doc.version)
+}
+
+// Client is a connection to one running language server.
+type Client struct {
+	cfg     ServerConfig
+	root    string
+	timeout time.Duration
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	conn   *conn
+	stderr *tailBuffer
+
+	syncMu  sync.Mutex
+	mu      sync.Mutex
+	docs    map[string]*document // by URI
+	diags   map[string]*published
+	seq     int
+	updated chan struct{} // closed and replaced whenever diagnostics arrive
+}
+
+// Start launches the server in root and performs the initialize handshake.
+func Start(root string, cfg ServerConfig, timeout time.Duration) (*Client, error) {
+	if len(cfg.Command) == 0 {
+		return nil, errors.New("no command configured")
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	c := &Client{
+		cfg:     cfg,
+		root:    root,
+		timeout: timeout,
+		stderr:  &tailBuffer{max: stderrTail},
+		docs:    make(map[string]*document),
+		diags:   make(map[string]*published),
+		updated: make(chan struct{}),
+	}
+	c.cmd = exec.Command(cfg.Command[0], cfg.Command[1:]...)
+	c.cmd.Dir = root
+	c.cmd.Stderr = c.stderr
+	// Own process group: the SIGINT of a Ctrl-C that only pauses the agent must not
+	// stop the server.
+	c.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdin, err := c.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot start %s: %w", cfg.DisplayName(), err)
+	}
+	c.stdin = stdin
+	c.conn = newConn(stdout, stdin, c.handle)
+
+	if err := c.initialize(); err != nil {
+		c.kill()
+		c.cmd.Wait()
+		if tail := strings.TrimSpace(c.stderr.String()); tail != "" {
+			err = fmt.Errorf("%w\n%s", err, tail)
+		}
+		return nil, fmt.Errorf("%s: initialize: %w", cfg.DisplayName(), err)
+	}
+	return c, nil
+}
+
+func (c *Client) initialize() error {
+	rootURI := FileURI(c.root)
+	params := map[string]any{
+		"processId":  os.Getpid(),
+		"clientInfo": map[string]string{"name": "devagent"},
+		"rootUri":    rootURI,
+		"rootPath":   c.root,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": filepath.Base(c.root)},
+		},
+		"capabilities": map[string]any{
+			"workspace": map[string]any{
+				"configuration":    true,
+				"workspaceFolders": true,
+				"workspaceEdit":    map[string]any{"documentChanges": true},
+			},
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": true},
+				"publishDiagnostics": map[string]any{"versionSupport": true},
+				"hover":              map[string]any{"contentFormat": []string{"plaintext", "markdown"}},
+				"definition":         map[string]any{"linkSupport": true},
+				"references":         map[string]any{},
+				"rename":             map[string]any{},
+			},
+		},
+	}
+	if err := c.call("initialize", params, nil); err != nil {
+		return err
+	}
+	return c.conn.notify("initialized", map[string]any{})
+}
+
+// handle serves the server's notifications and requests.
+func (c *Client) handle(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var p publishDiagnosticsParams
+		if json.Unmarshal(params, &p) == nil {
+			c.mu.Lock()
+			c.seq++
+			c.diags[p.URI] = &published{items: p.Diagnostics, seq: c.seq, version: p.Version}
+			close(c.updated)
+			c.updated = make(chan struct{})
+			c.mu.Unlock()
+		}
+		return nil, nil
+	case "workspace/configuration":
+		// No settings of our own: answer every item with null (server defaults).
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(params, &p)
+		return make([]any, len(p.Items)), nil
+	case "workspace/workspaceFolders":
+		return []map[string]string{{"uri": FileURI(c.root), "name": filepath.Base(c.root)}}, nil
+	case "workspace/applyEdit":
+		// Edits are applied only through Rename, where the agent asked for them.
+		return map[string]any{"applied": false}, nil
+	case "client/registerCapability", "client/unregisterCapability",
+		"window/workDoneProgress/create", "window/showMessageRequest":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("method not supported: %s", method)
+}
+
+// call sends a request and waits up to the client's timeout for the answer.
+func (c *Client) call(method string, params, result any) error {
+	cancel := make(chan struct{})
+	timer := time.AfterFunc(c.timeout, func() { close(cancel) })
+	defer timer.Stop()
+	err := c.conn.call(method, params, result, cancel)
+	if err == errTimeout {
+		return fmt.Errorf("%s: no answer to %s within %s", c.cfg.DisplayName(), method, c.timeout)
+	}
+	return err
+}
+
+// Name returns the server's display name.
+func (c *Client) Name() string { return c.cfg.DisplayName() }
+
+// Alive reports whether the server process is still connected.
+func (c *Client) Alive() bool {
+	select {
+	case <-c.conn.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Sync makes the server's view of path match the file on disk: the file is opened
+// on first use and its full text is sent again whenever it changed. A file that no
+// longer exists is closed.
+func (c *Client) Sync(path string) error {
+	// Only Sync changes documents; c.mu is not held while writing to the server,
+	// whose output (read under c.mu) could otherwise block its input.
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	uri := FileURI(path)
+	data, err := os.ReadFile(path)
+	c.mu.Lock()
+	doc, seq := c.docs[uri], c.seq
+	c.mu.Unlock()
+	if err != nil {
+		if doc != nil && os.IsNotExist(err) {
+			c.mu.Lock()
+			delete(c.docs, uri)
+			delete(c.diags, uri)
+			c.mu.Unlock()
+			c.conn.notify("textDocument/didClose", map[string]any{"textDocument": map[string]string{"uri": uri}})
+		}
+		return err
+	}
+	text := string(data)
+	if doc == nil {
+		c.mu.Lock()
+		c.docs[uri] = &document{version: 1, text: text, seq: seq}
+		c.mu.Unlock()
+		return c.conn.notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{
+				"uri": uri, "languageId": c.languageID(path), "version": 1, "text": text,
+			},
+		})
+	}
+	if doc.text == text {
+		return nil
+	}
+	c.mu.Lock()
+	doc.version++
+	doc.text = text
+	doc.seq = seq
+	version := doc.version
+	c.mu.Unlock()
+	if err := c.conn.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version},
+		"contentChanges": []map[string]string{{"text": text}},
+	}); err != nil {
+		return err
+	}
+	return c.conn.notify("textDocument/didSave", map[string]any{"textDocument": map[string]string{"uri": uri}})
+}
+
+func (c *Client) languageID(path string) string {
+	if c.cfg.LanguageID != "" {
+		return c.cfg.LanguageID
+	}
+	return LanguageID(path)
+}
+
+// position syncs path and converts a 1-based line and byte column to an LSP position.
+func (c *Client) position(path string, line, col int) (map[string]any, error) {
+	if err := c.Sync(path); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	text := c.docs[FileURI(path)].text
+	c.mu.Unlock()
+	lines := strings.Split(text, "\n")
+	if line < 1 || line > len(lines) {
+		return nil, fmt.Errorf("line %d is out of range (file has %d lines)", line, len(lines))
+	}
+	lt := strings.TrimSuffix(lines[line-1], "\r")
+	if col < 1 || col > len(lt)+1 {
+		return nil, fmt.Errorf("column %d is out of range (line %d has %d bytes)", col, line, len(lt))
+	}
+	return map[string]any{
+		"textDocument": map[string]string{"uri": FileURI(path)},
+		"position":     Position{Line: line - 1, Character: utf16Len(lt[:col-1])},
+	}, nil
+}
+
+// Definition returns where the symbol at the position is declared.
+func (c *Client) Definition(path string, line, col int) ([]Loc, error) {
+	params, err := c.position(path, line, col)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := c.call("textDocument/definition", params, &raw); err != nil {
+		return nil, err
+	}
+	return c.locations(raw), nil
+}
+
+// References returns the uses of the symbol at the position, including its declaration.
+func (c *Client) References(path string, line, col int) ([]Loc, error) {
+	params, err := c.position(path, line, col)
+	if err != nil {
+		return nil, err
+	}
+	params["context"] = map[string]bool{"includeDeclaration": true}
+	var raw json.RawMessage
+	if err := c.call("textDocument/references", params, &raw); err != nil {
+		return nil, err
+	}
+	return c.locations(raw), nil
+}
+
+// Hover returns the server's description (type, documentation) of the symbol at the position.
+func (c *Client) Hover(path string, line, col int) (string, error) {
+	params, err := c.position(path, line, col)
+	if err != nil {
+		return "", err
+	}
+	var h *hoverResult
+	if err := c.call("textDocument/hover", params, &h); err != nil {
+		return "", err
+	}
+	if h == nil {
+		return "", nil
+	}
+	return strings.TrimSpace(hoverText(h.Contents)), nil
+}
+
+// Rename asks the server how to rename the symbol at the position. The returned edit
+// has not been applied.
+func (c *Client) Rename(path string, line, col int, newName string) (*Edit, error) {
+	params, err := c.position(path, line, col)
+	if err != nil {
+		return nil, err
+	}
+	params["newName"] = newName
+	var we *WorkspaceEdit
+	if err := c.call("textDocument/rename", params, &we); err != nil {
+		return nil, err
+	}
+	if we == nil {
+		return &Edit{}, nil
+	}
+	return newEdit(we)
+}
+
+// Diagnostics syncs path and returns the server's diagnostics for it. When the
+// server has not reported on the current text yet, Diagnostics waits for it; fresh
+// is false if nothing arrived in time and the result may be outdated.
+func (c *Client) Diagnostics(path string) (diags []FileDiagnostic, fresh bool, err error) {
+	if err := c.Sync(path); err != nil {
+		return nil, false, err
+	}
+	uri := FileURI(path)
+	deadline := time.After(diagnosticsWait)
+	for {
+		c.mu.Lock()
+		doc, pub, updated := c.docs[uri], c.diags[uri], c.updated
+		current := pub != nil && doc != nil && pub.current(doc)
+		c.mu.Unlock()
+		if current {
+			return c.convert(path, pub.items), true, nil
+		}
+		select {
+		case <-updated:
+		case <-c.conn.done:
+			return nil, false, ErrClosed
+		case <-deadline:
+			if pub == nil {
+				return nil, false, nil
+			}
+			return c.convert(path, pub.items), false, nil
+		}
+	}
+}
+
+// AllDiagnostics returns every diagnostic the server has published so far, by file.
+func (c *Client) AllDiagnostics() []FileDiagnostic {
+	c.mu.Lock()
+	pubs := make(map[string][]Diagnostic, len(c.diags))
+	for uri, p := range c.diags {
+		pubs[uri] = p.items
+	}
+	c.mu.Unlock()
+	var all []FileDiagnostic
+	for uri, items := range pubs {
+		all = append(all, c.convert(URIPath(uri), items)...)
+	}
+	sortDiagnostics(all)
+	return all
+}
+
+func (c *Client) convert(path string, items []Diagnostic) []FileDiagnostic {
+	lines := newLineCache()
+	out := make([]FileDiagnostic, 0, len(items))
+	for _, d := range items {
+		out = append(out, FileDiagnostic{
+			Loc:      lines.loc(path, d.Range),
+			Severity: SeverityName(d.Severity),
+			Source:   d.Source,
+			Message:  d.Message,
+		})
+	}
+	sortDiagnostics(out)
+	return out
+}
+
+func sortDiagnostics(ds []FileDiagnostic) {
+	sort.SliceStable(ds, func(i, j int) bool {
+		a, b := ds[i], ds[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Col < b.Col
+	})
+}
+
+// locations decodes a Location, a Location array or a LocationLink array.
+func (c *Client) locations(raw json.RawMessage) []Loc {
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) != nil {
+		if string(raw) == "null" || len(raw) == 0 {
+			return nil
+		}
+		list = []json.RawMessage{raw}
+	}
+	lines := newLineCache()
+	var out []Loc
+	for _, item := range list {
+		var l Location
+		var link locationLink
+		switch {
+		case json.Unmarshal(item, &l) == nil && l.URI != "":
+		case json.Unmarshal(item, &link) == nil && link.TargetURI != "":
+			l = Location{URI: link.TargetURI, Range: link.TargetSelectionRange}
+		default:
+			continue
+		}
+		out = append(out, lines.loc(URIPath(l.URI), l.Range))
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		if out[i].Line != out[j].Line {
+			return out[i].Line < out[j].Line
+		}
+		return out[i].Col < out[j].Col
+	})
+	return out
+}
+
+// Close shuts the server down, killing it if it does not exit promptly.
+func (c *Client) Close() {
+	if c.Alive() {
+		done := make(chan struct{})
+		go func() {
+			c.call("shutdown", nil, nil)
+			c.conn.notify("exit", nil)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(stopWait):
+		}
+	}
+	c.stdin.Close()
+	exited := make(chan struct{})
+	go func() {
+		c.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(stopWait):
+		c.kill()
+		<-exited
+	}
+}
+
+func (c *Client) kill() {
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+}
+
+// tailBuffer keeps the last max bytes written to it (the server's stderr).
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf bytes.Buffer
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Write(p)
+	if extra := b.buf.Len() - b.max; extra > 0 {
+		b.buf.Next(extra)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
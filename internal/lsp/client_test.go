@@ -0,0 +1,199 @@
+package lsp
+
+import (
+	"devagent/internal/lsp/lsptest"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	lsptest.Main()
+	os.Exit(m.Run())
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// The fake server's language: "func name" declares, whole words reference.
+const greetSrc = "func greet() {}\n\nfunc main() {\n\tgreet() // héllo greet\n}\n"
+
+func startFake(t *testing.T, dir string) *Client {
+	t.Helper()
+	c, err := Start(dir, ServerConfig{Command: lsptest.Command(t), Extensions: []string{".fake"}}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func locString(dir string, locs []Loc) string {
+	var s []string
+	for _, l := range locs {
+		rel, _ := filepath.Rel(dir, l.Path)
+		s = append(s, fmt.Sprintf("%s:%d:%d", rel, l.Line, l.Col))
+	}
+	return strings.Join(s, ",")
+}
+
+func TestClient_Navigation(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.fake": greetSrc, "b.fake": "greet()\n"})
+	c := startFake(t, dir)
+	a, b := filepath.Join(dir, "a.fake"), filepath.Join(dir, "b.fake")
+
+	defs, err := c.Definition(b, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := locString(dir, defs); got != "a.fake:1:6" {
+		t.Errorf("Definition = %s", got)
+	}
+
+	// Columns after the two-byte é are converted from UTF-16 back to bytes.
+	refs, err := c.References(a, 1, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := locString(dir, refs), "a.fake:1:6,a.fake:4:2,a.fake:4:20,b.fake:1:1"; got != want {
+		t.Errorf("References = %s, want %s", got, want)
+	}
+	if refs[2].EndCol != 25 {
+		t.Errorf("EndCol = %d", refs[2].EndCol)
+	}
+	if refs, _ := c.References(a, 4, 21); len(refs) != 4 {
+		t.Errorf("References from the commented use = %d", len(refs))
+	}
+
+	hover, err := c.Hover(b, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hover != "```fake\nfunc greet() {}\n```" {
+		t.Errorf("Hover = %q", hover)
+	}
+	if hover, _ := c.Hover(a, 2, 1); hover != "" {
+		t.Errorf("Hover on a blank line = %q", hover)
+	}
+
+	if _, err := c.Definition(a, 9, 1); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("Definition past the end: %v", err)
+	}
+	if _, err := c.Definition(a, 1, 40); err == nil {
+		t.Error("Definition past the end of a line should fail")
+	}
+}
+
+func TestClient_Rename(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.fake": greetSrc, "b.fake": "greet()\n"})
+	c := startFake(t, dir)
+	a := filepath.Join(dir, "a.fake")
+
+	edit, err := c.Rename(a, 4, 3, "welcome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := edit.Files()
+	if len(files) != 2 || edit.Count(files[0]) != 3 || edit.Count(files[1]) != 1 {
+		t.Fatalf("edit files = %v", files)
+	}
+	if err := edit.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(a)
+	if want := strings.ReplaceAll(greetSrc, "greet", "welcome"); string(data) != want {
+		t.Errorf("a.fake =\n%s", data)
+	}
+
+	// The server sees the renamed files once they are synced.
+	for _, f := range files {
+		if err := c.Sync(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if refs, _ := c.References(a, 1, 6); len(refs) != 4 {
+		t.Errorf("references after rename = %d", len(refs))
+	}
+
+	if _, err := c.Rename(a, 1, 6, ""); err == nil {
+		t.Error("server error not returned")
+	}
+}
+
+func TestClient_Diagnostics(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"c.fake": "ok\n"})
+	c := startFake(t, dir)
+	p := filepath.Join(dir, "c.fake")
+
+	diags, fresh, err := c.Diagnostics(p)
+	if err != nil || !fresh || len(diags) != 0 {
+		t.Fatalf("Diagnostics = %v, %v, %v", diags, fresh, err)
+	}
+
+	os.WriteFile(p, []byte("ok\nan ERROR here\n  WARN\n"), 0644)
+	diags, fresh, err = c.Diagnostics(p)
+	if err != nil || !fresh {
+		t.Fatalf("Diagnostics after change: fresh=%v err=%v", fresh, err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, fmt.Sprintf("%d:%d-%d %s %s", d.Line, d.Col, d.EndCol, d.Severity, d.Message))
+	}
+	if want := "2:4-9 error found ERROR,3:3-7 warning found WARN"; strings.Join(got, ",") != want {
+		t.Errorf("diagnostics = %s, want %s", strings.Join(got, ","), want)
+	}
+	if all := c.AllDiagnostics(); len(all) != 2 || all[0].Path != p {
+		t.Errorf("AllDiagnostics = %+v", all)
+	}
+
+	// Unchanged files are answered from what was already published.
+	start := time.Now()
+	if diags, fresh, _ := c.Diagnostics(p); len(diags) != 2 || !fresh || time.Since(start) > time.Second {
+		t.Errorf("cached diagnostics = %d, fresh=%v", len(diags), fresh)
+	}
+
+	// A deleted file is closed and its diagnostics dropped.
+	os.Remove(p)
+	if _, _, err := c.Diagnostics(p); !os.IsNotExist(err) {
+		t.Errorf("Diagnostics of deleted file: %v", err)
+	}
+	if all := c.AllDiagnostics(); len(all) != 0 {
+		t.Errorf("AllDiagnostics after delete = %+v", all)
+	}
+}
+
+func TestStart_OwnProcessGroup(t *testing.T) {
+	c := startFake(t, writeFiles(t, map[string]string{"a.fake": greetSrc}))
+	pid := c.cmd.Process.Pid
+	if pgid, err := syscall.Getpgid(pid); err != nil || pgid != pid {
+		t.Errorf("server process group = %d, %v; want its own (%d), out of reach of the terminal's SIGINT", pgid, err, pid)
+	}
+}
+
+func TestStart_Failure(t *testing.T) {
+	_, err := Start(t.TempDir(), ServerConfig{Name: "nope", Command: []string{"devagent-no-such-server"}}, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "cannot start nope") {
+		t.Errorf("err = %v", err)
+	}
+	// A program that exits instead of answering.
+	_, err = Start(t.TempDir(), ServerConfig{Command: []string{"sh", "-c", "echo broken >&2"}}, 5*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "initialize") || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned for calls on a connection whose server has gone away.
+var ErrClosed = errors.New("language server connection closed")
+
+// message is a JSON-RPC 2.0 request, response or notification.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return fmt.Sprintf("%s (code %d)", e.Message, e.Code) }
+
+// handler serves requests and notifications sent by the server. For requests the
+// returned value is sent back as the result.
+type handler func(method string, params json.RawMessage) (any, error)
+
+// conn speaks JSON-RPC over the LSP base protocol (Content-Length framed messages).
+type conn struct {
+	w       io.Writer
+	handle  handler
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan *message
+	closed  bool
+	done    chan struct{}
+}
+
+func newConn(r io.Reader, w io.Writer, handle handler) *conn {
+	c := &conn{w: w, handle: handle, pending: make(map[string]chan *message), done: make(chan struct{})}
+	go c.readLoop(bufio.NewReader(r))
+	return c
+}
+
+// call sends a request and waits for its response, for the connection to close,
+// or for cancel to fire (in which case the server is told to drop the request).
+func (c *conn) call(method string, params any, result any, cancel <-chan struct{}) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	raw := json.RawMessage(id)
+	if err := c.send(&message{ID: &raw, Method: method}, params); err != nil {
+		c.forget(id)
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp == nil {
+			return ErrClosed
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result != nil && len(resp.Result) > 0 {
+			return json.Unmarshal(resp.Result, result)
+		}
+		return nil
+	case <-cancel:
+		c.forget(id)
+		c.notify("$/cancelRequest", map[string]any{"id": raw})
+		return errTimeout
+	}
+}
+
+var errTimeout = errors.New("language server did not answer in time")
+
+func (c *conn) forget(id string) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// notify sends a notification (no response expected).
+func (c *conn) notify(method string, params any) error {
+	return c.send(&message{Method: method}, params)
+}
+
+func (c *conn) send(m *message, params any) error {
+	m.JSONRPC = "2.0"
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		m.Params = data
+	}
+	return c.write(m)
+}
+
+func (c *conn) write(m *message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(data)
+	return err
+}
+
+func (c *conn) readLoop(r *bufio.Reader) {
+	defer c.shutdown()
+	for {
+		data, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		var m message
+		if err := json.Unmarshal(data, &m); err != nil {
+			continue
+		}
+		switch {
+		case m.Method != "" && m.ID != nil:
+			go c.reply(&m) // request from the server
+		case m.Method != "":
+			c.handle(m.Method, m.Params)
+		case m.ID != nil:
+			id := strings.Trim(string(*m.ID), `"`)
+			c.mu.Lock()
+			ch := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- &m
+			}
+		}
+	}
+}
+
+func (c *conn) reply(req *message) {
+	result, err := c.handle(req.Method, req.Params)
+	resp := &message{JSONRPC: "2.0", ID: req.ID}
+	if err != nil {
+		resp.Error = &rpcError{Code: -32601, Message: err.Error()}
+	} else {
+		data, _ := json.Marshal(result)
+		resp.Result = data
+	}
+	c.write(resp)
+}
+
+// shutdown fails every pending call once the server's output ends.
+func (c *conn) shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	close(c.done)
+}
+
+// readFrame reads one Content-Length framed message body.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if length < 0 {
+				continue // stray blank line before the headers
+			}
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("bad Content-Length %q", value)
+			}
+			length = n
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
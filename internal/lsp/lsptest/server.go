@@ -0,0 +1,309 @@
+// Package lsptest provides a small fake language server for tests. It understands
+// just enough of a toy language: "func name" declares name, every whole-word use
+// of a name is a reference, and lines containing ERROR or WARN get diagnostics.
+package lsptest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+const envVar = "DEVAGENT_FAKE_LSP"
+
+// Main runs the fake server instead of the tests when the test binary was started
+// through Command. Call it at the top of TestMain.
+func Main() {
+	if os.Getenv(envVar) == "1" {
+		Serve(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+}
+
+// Command returns a server command line that re-runs the current test binary as
+// the fake server.
+func Command(t testing.TB) []string {
+	t.Setenv(envVar, "1")
+	return []string{os.Args[0]}
+}
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   any              `json:"error,omitempty"`
+}
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type rng struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string `json:"uri"`
+	Range rng    `json:"range"`
+}
+
+type server struct {
+	w    io.Writer
+	root string
+	docs map[string]string // open documents by URI
+}
+
+// Serve answers LSP messages read from r until "exit" or end of input.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{w: w, docs: make(map[string]string)}
+	br := bufio.NewReader(r)
+	for {
+		var m message
+		if err := readMessage(br, &m); err != nil {
+			return err
+		}
+		if m.Method == "exit" {
+			return nil
+		}
+		if m.Method == "" {
+			continue // the client's answer to our own request
+		}
+		result, err := s.handle(m.Method, m.Params)
+		if m.ID == nil {
+			continue
+		}
+		resp := message{JSONRPC: "2.0", ID: m.ID}
+		if err != nil {
+			resp.Error = map[string]any{"code": -32603, "message": err.Error()}
+		} else {
+			resp.Result = result
+			if result == nil {
+				resp.Result = json.RawMessage("null")
+			}
+		}
+		s.write(resp)
+	}
+}
+
+func (s *server) handle(method string, raw json.RawMessage) (any, error) {
+	var p struct {
+		RootURI      string `json:"rootUri"`
+		TextDocument struct {
+			URI     string `json:"uri"`
+			Text    string `json:"text"`
+			Version int    `json:"version"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+		Position position `json:"position"`
+		NewName  string   `json:"newName"`
+	}
+	json.Unmarshal(raw, &p)
+	uri := p.TextDocument.URI
+	switch method {
+	case "initialize":
+		s.root = uriPath(p.RootURI)
+		return map[string]any{"capabilities": map[string]any{
+			"textDocumentSync": 1, "definitionProvider": true, "referencesProvider": true,
+			"hoverProvider": true, "renameProvider": true,
+		}}, nil
+	case "initialized":
+		// Exercise a server-to-client request, as real servers do.
+		s.write(message{JSONRPC: "2.0", ID: rawID(1000), Method: "workspace/configuration",
+			Params: json.RawMessage(`{"items":[{"section":"fake"}]}`)})
+	case "textDocument/didOpen":
+		s.docs[uri] = p.TextDocument.Text
+		s.publish(uri, p.TextDocument.Version)
+	case "textDocument/didChange":
+		if n := len(p.ContentChanges); n > 0 {
+			s.docs[uri] = p.ContentChanges[n-1].Text
+		}
+		s.publish(uri, p.TextDocument.Version)
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+	case "textDocument/definition":
+		word := s.wordAt(uri, p.Position)
+		for _, l := range s.occurrences(word) {
+			if s.isDecl(l, word) {
+				return []location{l}, nil
+			}
+		}
+		return []location{}, nil
+	case "textDocument/references":
+		return s.occurrences(s.wordAt(uri, p.Position)), nil
+	case "textDocument/hover":
+		word := s.wordAt(uri, p.Position)
+		for _, l := range s.occurrences(word) {
+			if s.isDecl(l, word) {
+				line := strings.Split(s.text(l.URI), "\n")[l.Range.Start.Line]
+				return map[string]any{"contents": map[string]string{
+					"kind": "markdown", "value": "```fake\n" + strings.TrimSpace(line) + "\n```",
+				}}, nil
+			}
+		}
+		return nil, nil
+	case "textDocument/rename":
+		if p.NewName == "" {
+			return nil, fmt.Errorf("empty name")
+		}
+		byURI := map[string][]map[string]any{}
+		var uris []string
+		for _, l := range s.occurrences(s.wordAt(uri, p.Position)) {
+			if byURI[l.URI] == nil {
+				uris = append(uris, l.URI)
+			}
+			byURI[l.URI] = append(byURI[l.URI], map[string]any{"range": l.Range, "newText": p.NewName})
+		}
+		var changes []any
+		for _, u := range uris {
+			changes = append(changes, map[string]any{
+				"textDocument": map[string]any{"uri": u, "version": nil},
+				"edits":        byURI[u],
+			})
+		}
+		return map[string]any{"documentChanges": changes}, nil
+	case "shutdown":
+		return nil, nil
+	}
+	return nil, nil
+}
+
+func (s *server) publish(uri string, version int) {
+	diags := []map[string]any{}
+	for i, line := range strings.Split(s.docs[uri], "\n") {
+		for word, sev := range map[string]int{"ERROR": 1, "WARN": 2} {
+			if j := strings.Index(line, word); j >= 0 {
+				c := utf16Col(line, j)
+				diags = append(diags, map[string]any{
+					"range":    rng{position{i, c}, position{i, c + len(word)}},
+					"severity": sev, "source": "fake", "message": "found " + word,
+				})
+			}
+		}
+	}
+	params, _ := json.Marshal(map[string]any{"uri": uri, "version": version, "diagnostics": diags})
+	s.write(message{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+// text returns a document's content: the open version, else the file on disk.
+func (s *server) text(uri string) string {
+	if t, ok := s.docs[uri]; ok {
+		return t
+	}
+	data, _ := os.ReadFile(uriPath(uri))
+	return string(data)
+}
+
+var wordRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+func (s *server) wordAt(uri string, p position) string {
+	lines := strings.Split(s.text(uri), "\n")
+	if p.Line >= len(lines) {
+		return ""
+	}
+	line := lines[p.Line]
+	for _, m := range wordRe.FindAllStringIndex(line, -1) {
+		if start, end := utf16Col(line, m[0]), utf16Col(line, m[1]); p.Character >= start && p.Character <= end {
+			return line[m[0]:m[1]]
+		}
+	}
+	return ""
+}
+
+// occurrences lists the whole-word uses of word in every file under the root.
+func (s *server) occurrences(word string) []location {
+	if word == "" {
+		return nil
+	}
+	var uris []string
+	filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			uris = append(uris, fileURI(p))
+		}
+		return nil
+	})
+	sort.Strings(uris)
+	var out []location
+	for _, u := range uris {
+		for i, line := range strings.Split(s.text(u), "\n") {
+			for _, m := range wordRe.FindAllStringIndex(line, -1) {
+				if line[m[0]:m[1]] == word {
+					out = append(out, location{u, rng{position{i, utf16Col(line, m[0])}, position{i, utf16Col(line, m[1])}}})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// isDecl reports whether l is the name in a "func name" declaration.
+func (s *server) isDecl(l location, word string) bool {
+	line := strings.Split(s.text(l.URI), "\n")[l.Range.Start.Line]
+	f := strings.Fields(line)
+	return len(f) > 1 && f[0] == "func" && wordRe.FindString(f[1]) == word
+}
+
+func (s *server) write(m message) {
+	data, _ := json.Marshal(m)
+	fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func readMessage(r *bufio.Reader, m *message) error {
+	length := 0
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "Content-Length:"); ok {
+			length, _ = strconv.Atoi(strings.TrimSpace(v))
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, m)
+}
+
+func rawID(n int) *json.RawMessage {
+	id := json.RawMessage(strconv.Itoa(n))
+	return &id
+}
+
+// utf16Col converts a byte offset in line to a UTF-16 column.
+func utf16Col(line string, off int) int {
+	return len(utf16.Encode([]rune(line[:off])))
+}
+
+func fileURI(p string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String()
+}
+
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
@@ -0,0 +1,144 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoServer is returned for files no configured server handles.
+var ErrNoServer = errors.New("no language server configured for this file type")
+
+// Manager starts the configured language servers on first use and routes files to
+// them by extension.
+type Manager struct {
+	root    string
+	servers []ServerConfig
+	timeout time.Duration
+
+	mu      sync.Mutex
+	clients map[int]*Client // by index in servers
+	failed  map[int]error   // start errors, so a broken server is not retried on every call
+}
+
+// NewManager creates a Manager for the workspace at root. timeout bounds each request
+// (0 means 30s).
+func NewManager(root string, servers []ServerConfig, timeout time.Duration) *Manager {
+	return &Manager{
+		root:    root,
+		servers: servers,
+		timeout: timeout,
+		clients: make(map[int]*Client),
+		failed:  make(map[int]error),
+	}
+}
+
+// Root returns the workspace directory.
+func (m *Manager) Root() string {
+	return m.root
+}
+
+// Servers returns the configured servers.
+func (m *Manager) Servers() []ServerConfig {
+	return m.servers
+}
+
+// serverFor returns the index of the server handling path, or -1.
+func (m *Manager) serverFor(path string) int {
+	ext := strings.ToLower(filepath.Ext(path))
+	for i, s := range m.servers {
+		for _, e := range s.Extensions {
+			e = strings.ToLower(strings.TrimSpace(e))
+			if !strings.HasPrefix(e, ".") {
+				e = "." + e
+			}
+			if e == ext {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Client returns the server for path, starting it if needed. A server that has
+// exited is started again; one that failed to start is not retried.
+func (m *Manager) Client(path string) (*Client, error) {
+	i := m.serverFor(path)
+	if i < 0 {
+		return nil, fmt.Errorf("%w (%s)", ErrNoServer, filepath.Ext(path))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c := m.clients[i]; c != nil {
+		if c.Alive() {
+			return c, nil
+		}
+		c.Close()
+		delete(m.clients, i)
+	}
+	if err := m.failed[i]; err != nil {
+		return nil, err
+	}
+	c, err := Start(m.root, m.servers[i], m.timeout)
+	if err != nil {
+		m.failed[i] = err
+		return nil, err
+	}
+	m.clients[i] = c
+	return c, nil
+}
+
+// running returns the server for path if it has already been started.
+func (m *Manager) running(path string) *Client {
+	i := m.serverFor(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c := m.clients[i]; c != nil && c.Alive() {
+		return c
+	}
+	return nil
+}
+
+// FileChanged tells the server handling path that the file changed on disk. Servers
+// that have not been started yet are left alone; they read the file when first used.
+func (m *Manager) FileChanged(path string) {
+	if c := m.running(path); c != nil {
+		c.Sync(path)
+	}
+}
+
+// Diagnostics returns what every running server has reported so far.
+func (m *Manager) Diagnostics() []FileDiagnostic {
+	m.mu.Lock()
+	clients := make([]*Client, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	m.mu.Unlock()
+	var all []FileDiagnostic
+	for _, c := range clients {
+		all = append(all, c.AllDiagnostics()...)
+	}
+	sortDiagnostics(all)
+	return all
+}
+
+// Close shuts down every running server.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = make(map[int]*Client)
+	m.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Close()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,60 @@
+package lsp
+
+import (
+	"devagent/internal/lsp/lsptest"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.fake": greetSrc, "notes.md": "# x\n"})
+	m := NewManager(dir, []ServerConfig{
+		{Name: "broken", Command: []string{"devagent-no-such-server"}, Extensions: []string{".broken"}},
+		{Name: "fake", Command: lsptest.Command(t), Extensions: []string{"FAKE", ".other"}},
+	}, 5*time.Second)
+	defer m.Close()
+	a := filepath.Join(dir, "a.fake")
+
+	if _, err := m.Client(filepath.Join(dir, "notes.md")); !errors.Is(err, ErrNoServer) {
+		t.Errorf("markdown: %v", err)
+	}
+	if _, err := m.Client(filepath.Join(dir, "x.broken")); err == nil {
+		t.Error("broken server started")
+	}
+
+	// Changes to files whose server is not running are ignored.
+	m.FileChanged(a)
+	if m.running(a) != nil {
+		t.Fatal("FileChanged started a server")
+	}
+
+	c, err := m.Client(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2, _ := m.Client(filepath.Join(dir, "b.other")); c2 != c {
+		t.Error("extensions of one server got different clients")
+	}
+	if _, _, err := c.Diagnostics(a); err != nil {
+		t.Fatal(err)
+	}
+
+	// An edit made behind the server's back is forwarded by FileChanged.
+	os.WriteFile(a, []byte("ERROR\n"), 0644)
+	m.FileChanged(a)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.Diagnostics()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if diags := m.Diagnostics(); len(diags) != 1 || diags[0].Path != a || diags[0].Message != "found ERROR" {
+		t.Errorf("Diagnostics = %+v", diags)
+	}
+
+	m.Close()
+	if c.Alive() {
+		t.Error("server still running after Close")
+	}
+}
@@ -0,0 +1,222 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var languageIDs = map[string]string{
+	".go": "go", ".py": "python", ".pyi": "python",
+	".ts": "typescript", ".mts": "typescript", ".cts": "typescript", ".tsx": "typescriptreact",
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "javascriptreact",
+	".rs": "rust", ".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp",
+	".java": "java", ".kt": "kotlin", ".rb": "ruby", ".php": "php", ".cs": "csharp",
+	".swift": "swift", ".lua": "lua", ".sh": "shellscript", ".json": "json", ".yaml": "yaml", ".yml": "yaml",
+}
+
+// LanguageID returns the LSP language identifier for a file, based on its extension.
+func LanguageID(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if id, ok := languageIDs[ext]; ok {
+		return id
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// utf16Len counts the UTF-16 code units in s, the unit of LSP columns.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// byteOffset returns the byte offset in line of the given UTF-16 column, clamped to
+// the end of the line.
+func byteOffset(line string, units int) int {
+	n := 0
+	for i, r := range line {
+		if n >= units {
+			return i
+		}
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return len(line)
+}
+
+// lineCache converts LSP ranges to Locs, reading each file at most once.
+type lineCache map[string][]string
+
+func newLineCache() lineCache { return lineCache{} }
+
+func (lc lineCache) loc(path string, r Range) Loc {
+	lines, ok := lc[path]
+	if !ok {
+		if data, err := os.ReadFile(path); err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		lc[path] = lines
+	}
+	col := func(p Position) int {
+		if p.Line < len(lines) {
+			return byteOffset(strings.TrimSuffix(lines[p.Line], "\r"), p.Character) + 1
+		}
+		return p.Character + 1
+	}
+	return Loc{
+		Path: path, Line: r.Start.Line + 1, Col: col(r.Start),
+		EndLine: r.End.Line + 1, EndCol: col(r.End),
+	}
+}
+
+// Edit is a set of text changes across files, such as the result of a rename.
+type Edit struct {
+	files map[string][]TextEdit // by path
+}
+
+func newEdit(we *WorkspaceEdit) (*Edit, error) {
+	e := &Edit{files: make(map[string][]TextEdit)}
+	for uri, edits := range we.Changes {
+		p := URIPath(uri)
+		e.files[p] = append(e.files[p], edits...)
+	}
+	for _, raw := range we.DocumentChanges {
+		var op struct {
+			Kind string `json:"kind"`
+		}
+		json.Unmarshal(raw, &op)
+		if op.Kind != "" {
+			// create, rename or delete: e.g. renaming a module renames its file.
+			return nil, fmt.Errorf("the server wants to %s a file, which is not supported", op.Kind)
+		}
+		var de textDocumentEdit
+		if err := json.Unmarshal(raw, &de); err != nil {
+			return nil, fmt.Errorf("bad documentChanges entry: %w", err)
+		}
+		p := URIPath(de.TextDocument.URI)
+		e.files[p] = append(e.files[p], de.Edits...)
+	}
+	return e, nil
+}
+
+// Files returns the paths the edit changes, sorted.
+func (e *Edit) Files() []string {
+	files := make([]string, 0, len(e.files))
+	for p := range e.files {
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Count returns the number of text changes in file.
+func (e *Edit) Count(file string) int {
+	return len(e.files[file])
+}
+
+// Apply writes the changes to disk. Every file is edited in memory first, so a
+// change that does not fit its file leaves all files untouched.
+func (e *Edit) Apply() error {
+	updated := make(map[string][]byte, len(e.files))
+	for _, p := range e.Files() {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		text, err := applyEdits(string(data), e.files[p])
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		updated[p] = []byte(text)
+	}
+	for _, p := range e.Files() {
+		mode := os.FileMode(0644)
+		if info, err := os.Stat(p); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.WriteFile(p, updated[p], mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEdits applies non-overlapping text edits to text.
+func applyEdits(text string, edits []TextEdit) (string, error) {
+	type span struct {
+		start, end int
+		text       string
+	}
+	starts := lineStarts(text)
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		start, err := offset(text, starts, e.Range.Start)
+		if err != nil {
+			return "", err
+		}
+		end, err := offset(text, starts, e.Range.End)
+		if err != nil {
+			return "", err
+		}
+		if end < start {
+			return "", fmt.Errorf("edit range ends before it starts")
+		}
+		spans = append(spans, span{start, end, e.NewText})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var sb strings.Builder
+	pos := 0
+	for _, s := range spans {
+		if s.start < pos {
+			return "", fmt.Errorf("overlapping edits")
+		}
+		sb.WriteString(text[pos:s.start])
+		sb.WriteString(s.text)
+		pos = s.end
+	}
+	sb.WriteString(text[pos:])
+	return sb.String(), nil
+}
+
+func lineStarts(text string) []int {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// offset converts an LSP position to a byte offset in text.
+func offset(text string, starts []int, p Position) (int, error) {
+	if p.Line >= len(starts) {
+		if p.Line == len(starts) && p.Character == 0 {
+			return len(text), nil
+		}
+		return 0, fmt.Errorf("edit at line %d is past the end of the file", p.Line+1)
+	}
+	end := len(text)
+	if p.Line+1 < len(starts) {
+		end = starts[p.Line+1] - 1
+	}
+	line := strings.TrimSuffix(text[starts[p.Line]:end], "\r")
+	if !utf8.ValidString(line) {
+		return starts[p.Line] + min(p.Character, len(line)), nil
+	}
+	return starts[p.Line] + byteOffset(line, p.Character), nil
+}
@@ -0,0 +1,129 @@
+package lsp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUTF16Columns(t *testing.T) {
+	line := "aé😀b"
+	if n := utf16Len(line); n != 5 {
+		t.Errorf("utf16Len = %d", n)
+	}
+	for units, want := range map[int]int{0: 0, 1: 1, 2: 3, 4: 7, 5: 8, 99: 8} {
+		if got := byteOffset(line, units); got != want {
+			t.Errorf("byteOffset(%d) = %d, want %d", units, got, want)
+		}
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	edit := func(l1, c1, l2, c2 int, text string) TextEdit {
+		return TextEdit{Range: Range{Position{l1, c1}, Position{l2, c2}}, NewText: text}
+	}
+	text := "héllo world\r\nsecond\n"
+	got, err := applyEdits(text, []TextEdit{
+		edit(1, 0, 1, 6, "2nd"),
+		edit(0, 6, 0, 11, "there"),
+		edit(0, 0, 0, 0, ">"),
+		edit(2, 0, 2, 0, "third\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ">héllo there\r\n2nd\nthird\n"; got != want {
+		t.Errorf("applyEdits = %q, want %q", got, want)
+	}
+
+	if _, err := applyEdits(text, []TextEdit{edit(0, 0, 0, 5, "a"), edit(0, 3, 0, 4, "b")}); err == nil {
+		t.Error("overlapping edits accepted")
+	}
+	if _, err := applyEdits(text, []TextEdit{edit(7, 0, 7, 1, "x")}); err == nil {
+		t.Error("edit past the end accepted")
+	}
+}
+
+func TestEdit_Apply(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("one\n"), 0600)
+	os.WriteFile(b, []byte("two\n"), 0644)
+	replace := func(text string) []TextEdit {
+		return []TextEdit{{Range: Range{Position{0, 0}, Position{0, 3}}, NewText: text}}
+	}
+
+	// documentChanges and changes may both be used.
+	var de textDocumentEdit
+	de.TextDocument.URI = FileURI(a)
+	de.Edits = replace("1")
+	raw, _ := json.Marshal(de)
+	e, err := newEdit(&WorkspaceEdit{
+		Changes:         map[string][]TextEdit{FileURI(b): replace("2")},
+		DocumentChanges: []json.RawMessage{raw},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := e.Files(); len(files) != 2 || files[0] != a {
+		t.Fatalf("Files = %v", files)
+	}
+	if err := e.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	da, _ := os.ReadFile(a)
+	db, _ := os.ReadFile(b)
+	if string(da) != "1\n" || string(db) != "2\n" {
+		t.Errorf("files = %q, %q", da, db)
+	}
+	if info, _ := os.Stat(a); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v", info.Mode())
+	}
+
+	// A bad edit in one file leaves the other untouched.
+	e, _ = newEdit(&WorkspaceEdit{Changes: map[string][]TextEdit{
+		FileURI(a): replace("x"),
+		FileURI(b): {{Range: Range{Position{5, 0}, Position{5, 1}}, NewText: "y"}},
+	}})
+	if err := e.Apply(); err == nil {
+		t.Error("bad edit applied")
+	}
+	if da, _ := os.ReadFile(a); string(da) != "1\n" {
+		t.Errorf("a changed: %q", da)
+	}
+
+	_, err = newEdit(&WorkspaceEdit{DocumentChanges: []json.RawMessage{json.RawMessage(`{"kind":"rename","oldUri":"file:///a","newUri":"file:///b"}`)}})
+	if err == nil || !strings.Contains(err.Error(), "rename a file") {
+		t.Errorf("resource operation: %v", err)
+	}
+}
+
+func TestHoverText(t *testing.T) {
+	tests := map[string]string{
+		`"plain"`:                                   "plain",
+		`{"kind":"markdown","value":"**x**"}`:       "**x**",
+		`{"language":"go","value":"func F()"}`:      "```go\nfunc F()\n```",
+		`["doc",{"language":"py","value":"def f"}]`: "doc\n\n```py\ndef f\n```",
+		`null`: "",
+	}
+	for raw, want := range tests {
+		if got := hoverText(json.RawMessage(raw)); got != want {
+			t.Errorf("hoverText(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestFileURI(t *testing.T) {
+	uri := FileURI("/tmp/a dir/x#1.go")
+	if uri != "file:///tmp/a%20dir/x%231.go" {
+		t.Errorf("FileURI = %s", uri)
+	}
+	if p := URIPath(uri); p != filepath.FromSlash("/tmp/a dir/x#1.go") {
+		t.Errorf("URIPath = %s", p)
+	}
+	if LanguageID("web/App.TSX") != "typescriptreact" || LanguageID("x.zig") != "zig" {
+		t.Error("LanguageID")
+	}
+}
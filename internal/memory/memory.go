@@ -0,0 +1,279 @@
+// Package memory keeps durable facts about a project — build commands, test layout,
+// conventions, pitfalls — in Markdown files that are loaded into every run:
+// .devagent/MEMORY.md in the project, and ~/.devagent/MEMORY.md for facts about the
+// user that hold in every project. Facts are list items, optionally grouped under
+// "## Section" headings; people can edit the files freely.
+package memory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of a memory file inside a .devagent directory.
+const FileName = "MEMORY.md"
+
+const maxFactLen = 500
+
+// Scope selects a memory file.
+type Scope string
+
+const (
+	Project Scope = "project"
+	User    Scope = "user"
+)
+
+// ParseScope accepts "project" (the default for "") and "user".
+func ParseScope(s string) (Scope, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "project":
+		return Project, nil
+	case "user":
+		return User, nil
+	}
+	return "", fmt.Errorf("unknown scope %q (use project or user)", s)
+}
+
+// Store locates the memory files of a project and its user.
+type Store struct {
+	project string
+	user    string // "" when there is no home directory
+}
+
+// Open returns the memory of the project in projectDir. Files are created when
+// the first fact is added.
+func Open(projectDir string) *Store {
+	s := &Store{project: filepath.Join(projectDir, ".devagent", FileName)}
+	if home, err := os.UserHomeDir(); err == nil {
+		s.user = filepath.Join(home, ".devagent", FileName)
+	}
+	return s
+}
+
+// Path returns the file of scope ("" if the user has no home directory).
+func (s *Store) Path(scope Scope) string {
+	if scope == User {
+		return s.user
+	}
+	return s.project
+}
+
+// Read returns the content of scope's file; a missing file is empty.
+func (s *Store) Read(scope Scope) (string, error) {
+	p := s.Path(scope)
+	if p == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// Init creates scope's file with its header if it does not exist yet.
+func (s *Store) Init(scope Scope) error {
+	p := s.Path(scope)
+	if p == "" {
+		return fmt.Errorf("no home directory for the user memory")
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		return err
+	}
+	return write(p, header(scope))
+}
+
+// Fact is a list item of a memory file.
+type Fact struct {
+	Section string // heading the fact is listed under ("" before any heading)
+	Text    string
+	line    int // index of the item's first line
+	lines   int // number of lines, including wrapped continuation lines
+}
+
+// Facts returns the facts of scope in file order.
+func (s *Store) Facts(scope Scope) ([]Fact, error) {
+	content, err := s.Read(scope)
+	if err != nil {
+		return nil, err
+	}
+	return parse(splitLines(content)), nil
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(content, "\n"), "\n")
+}
+
+func parse(lines []string) []Fact {
+	var facts []Fact
+	section := ""
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if h, ok := heading(l); ok {
+			section = h
+			continue
+		}
+		text, ok := item(l)
+		if !ok {
+			continue
+		}
+		f := Fact{Section: section, Text: text, line: i, lines: 1}
+		// Continuation lines are indented and not items themselves.
+		for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "  ") && !isItem(lines[i+1]) {
+			i++
+			f.lines++
+			f.Text += " " + strings.TrimSpace(lines[i])
+		}
+		facts = append(facts, f)
+	}
+	return facts
+}
+
+// heading returns the text of a "## Section" line (any level below the title).
+func heading(l string) (string, bool) {
+	if !strings.HasPrefix(l, "##") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimLeft(l, "#")), true
+}
+
+func item(l string) (string, bool) {
+	for _, p := range []string{"- ", "* "} {
+		if strings.HasPrefix(l, p) {
+			return strings.TrimSpace(l[len(p):]), true
+		}
+	}
+	return "", false
+}
+
+func isItem(l string) bool {
+	_, ok := item(strings.TrimSpace(l))
+	return ok
+}
+
+// normalize turns a fact into a single line without a list marker.
+func normalize(fact string) string {
+	fact = strings.Join(strings.Fields(fact), " ")
+	if t, ok := item(fact); ok {
+		fact = t
+	}
+	return fact
+}
+
+// Add records fact under section ("" for none) in scope's file. It reports false
+// if the same fact is already there.
+func (s *Store) Add(scope Scope, section, fact string) (bool, error) {
+	fact = normalize(fact)
+	section = strings.Join(strings.Fields(section), " ")
+	if fact == "" {
+		return false, fmt.Errorf("empty fact")
+	}
+	if len(fact) > maxFactLen {
+		return false, fmt.Errorf("fact is %d characters; keep it under %d", len(fact), maxFactLen)
+	}
+	p := s.Path(scope)
+	if p == "" {
+		return false, fmt.Errorf("no home directory for the user memory")
+	}
+	content, err := s.Read(scope)
+	if err != nil {
+		return false, err
+	}
+	lines := splitLines(content)
+	if len(lines) == 0 {
+		lines = header(scope)
+	}
+	for _, f := range parse(lines) {
+		if strings.EqualFold(f.Text, fact) {
+			return false, nil
+		}
+	}
+	return true, write(p, insert(lines, section, "- "+fact))
+}
+
+func header(scope Scope) []string {
+	title := "# Project Memory"
+	if scope == User {
+		title = "# User Memory"
+	}
+	return []string{title, "", "Durable facts recorded by DevAgent and loaded into every run. Edit or delete them freely."}
+}
+
+// insert adds an item at the end of section, creating the section at the end of
+// the file when it does not exist. Items without a section go before the first
+// heading.
+func insert(lines []string, section, itemLine string) []string {
+	start, end := -1, len(lines) // the section's lines are (start, end)
+	if section == "" {
+		start = 0
+		for i, l := range lines {
+			if _, ok := heading(l); ok {
+				end = i
+				break
+			}
+		}
+	} else {
+		for i, l := range lines {
+			h, ok := heading(l)
+			if !ok {
+				continue
+			}
+			if start >= 0 {
+				end = i
+				break
+			}
+			if strings.EqualFold(h, section) {
+				start = i
+			}
+		}
+	}
+	if start < 0 {
+		return append(lines, "", "## "+section, "", itemLine)
+	}
+
+	// After the last non-blank line of the section; a list is separated from a
+	// preceding paragraph by a blank line.
+	at := end
+	for at > start+1 && strings.TrimSpace(lines[at-1]) == "" {
+		at--
+	}
+	add := []string{itemLine}
+	if at > 0 && !isItem(lines[at-1]) && !strings.HasPrefix(lines[at-1], "  ") {
+		add = []string{"", itemLine}
+	}
+	if at < len(lines) && strings.TrimSpace(lines[at]) != "" {
+		add = append(add, "")
+	}
+	out := make([]string, 0, len(lines)+len(add))
+	out = append(out, lines[:at]...)
+	out = append(out, add...)
+	return append(out, lines[at:]...)
+}
+
+// Forget removes the n-th fact (1-based, as listed by Facts) and returns it.
+func (s *Store) Forget(scope Scope, n int) (Fact, error) {
+	content, err := s.Read(scope)
+	if err != nil {
+		return Fact{}, err
+	}
+	lines := splitLines(content)
+	facts := parse(lines)
+	if n < 1 || n > len(facts) {
+		return Fact{}, fmt.Errorf("no fact #%d (there are %d)", n, len(facts))
+	}
+	f := facts[n-1]
+	lines = append(lines[:f.line], lines[f.line+f.lines:]...)
+	return f, write(s.Path(scope), lines)
+}
+
+func write(path string, lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
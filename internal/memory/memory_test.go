@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore_AddAndFacts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	s := Open(dir)
+
+	for _, add := range []struct{ section, fact string }{
+		{"", "The module is devagent; run  go build ./... from the root."},
+		{"Testing", "Integration tests need the fake LSP helper process."},
+		{"", "- Use log.Printf(\"Warning: ...\") for warnings"},
+		{"testing", "go test -race is slow in internal/lsp"},
+	} {
+		if ok, err := s.Add(Project, add.section, add.fact); err != nil || !ok {
+			t.Fatalf("Add(%q): %v %v", add.fact, ok, err)
+		}
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ".devagent", FileName))
+	want := `# Project Memory
+
+Durable facts recorded by DevAgent and loaded into every run. Edit or delete them freely.
+
+- The module is devagent; run go build ./... from the root.
+- Use log.Printf("Warning: ...") for warnings
+
+## Testing
+
+- Integration tests need the fake LSP helper process.
+- go test -race is slow in internal/lsp
+`
+	if string(data) != want {
+		t.Errorf("file =\n%s\nwant\n%s", data, want)
+	}
+
+	if ok, err := s.Add(Project, "Other", "use LOG.PRINTF(\"Warning: ...\") for warnings"); ok || err != nil {
+		t.Errorf("duplicate added: %v %v", ok, err)
+	}
+	facts, err := s.Facts(Project)
+	if err != nil || len(facts) != 4 {
+		t.Fatalf("facts = %+v, %v", facts, err)
+	}
+	if facts[2].Section != "Testing" || facts[1].Section != "" {
+		t.Errorf("sections = %+v", facts)
+	}
+
+	for _, bad := range []string{"", "  \n ", strings.Repeat("x", maxFactLen+1)} {
+		if _, err := s.Add(Project, "", bad); err == nil {
+			t.Errorf("Add(%.20q) should fail", bad)
+		}
+	}
+}
+
+func TestStore_HandEditedFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".devagent"), 0755)
+	p := filepath.Join(dir, ".devagent", FileName)
+	os.WriteFile(p, []byte("## Build\nRun make first.\n* make build\n  (needs Docker)\n\n## Style\n- tabs\n"), 0644)
+	s := Open(dir)
+
+	facts, _ := s.Facts(Project)
+	if len(facts) != 2 || facts[0].Text != "make build (needs Docker)" || facts[1].Section != "Style" {
+		t.Fatalf("facts = %+v", facts)
+	}
+	s.Add(Project, "build", "make test runs the linters too")
+	s.Add(Project, "", "Go 1.25")
+	data, _ := os.ReadFile(p)
+	want := "- Go 1.25\n\n## Build\nRun make first.\n* make build\n  (needs Docker)\n- make test runs the linters too\n\n## Style\n- tabs\n"
+	if string(data) != want {
+		t.Errorf("file =\n%s\nwant\n%s", data, want)
+	}
+
+	f, err := s.Forget(Project, 2)
+	if err != nil || f.Text != "make build (needs Docker)" {
+		t.Fatalf("Forget = %+v, %v", f, err)
+	}
+	data, _ = os.ReadFile(p)
+	if strings.Contains(string(data), "make build") || strings.Contains(string(data), "needs Docker") {
+		t.Errorf("fact not removed:\n%s", data)
+	}
+	if _, err := s.Forget(Project, 9); err == nil {
+		t.Error("Forget out of range should fail")
+	}
+}
+
+func TestStore_UserScope(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s := Open(t.TempDir())
+	if _, err := s.Add(User, "", "Prefers table-driven tests"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Path(User) != filepath.Join(home, ".devagent", FileName) {
+		t.Errorf("user path = %s", s.Path(User))
+	}
+	content, _ := s.Read(User)
+	if !strings.HasPrefix(content, "# User Memory\n") || !strings.Contains(content, "- Prefers table-driven tests\n") {
+		t.Errorf("user memory =\n%s", content)
+	}
+	if content, err := s.Read(Project); content != "" || err != nil {
+		t.Errorf("project memory = %q, %v", content, err)
+	}
+
+	if err := s.Init(Project); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := s.Read(Project); !strings.HasPrefix(content, "# Project Memory\n") {
+		t.Errorf("initialized project memory = %q", content)
+	}
+	if err := s.Init(User); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := s.Read(User); !strings.Contains(content, "- Prefers table-driven tests") {
+		t.Errorf("Init overwrote the user memory:\n%s", content)
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	for in, want := range map[string]Scope{"": Project, "project": Project, "User": User} {
+		if got, err := ParseScope(in); got != want || err != nil {
+			t.Errorf("ParseScope(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseScope("global"); err == nil {
+		t.Error("ParseScope(global) should fail")
+	}
+}
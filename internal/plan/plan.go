@@ -0,0 +1,226 @@
+// Package plan keeps the agent's plan for a task as a numbered todo list. The
+// agent edits it through the update_plan tool, and the current plan is shown to
+// the model at every step, so it survives trimmed history.
+package plan
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	maxSteps    = 30
+	maxStepText = 300
+)
+
+// Status is the state of a plan step.
+type Status string
+
+const (
+	Pending    Status = "pending"
+	InProgress Status = "in_progress"
+	Done       Status = "done"
+	Skipped    Status = "skipped"
+)
+
+// ParseStatus accepts the statuses and a few common synonyms ("todo", "active",
+// "completed", ...).
+func ParseStatus(s string) (Status, error) {
+	switch strings.ToLower(strings.TrimSpace(strings.ReplaceAll(s, "-", "_"))) {
+	case "pending", "todo":
+		return Pending, nil
+	case "in_progress", "active", "started", "doing":
+		return InProgress, nil
+	case "done", "complete", "completed", "finished":
+		return Done, nil
+	case "skipped", "skip", "cancelled", "canceled":
+		return Skipped, nil
+	}
+	return "", fmt.Errorf("unknown status %q (use pending, in_progress, done or skipped)", s)
+}
+
+var marks = map[Status]string{Pending: "[ ]", InProgress: "[>]", Done: "[x]", Skipped: "[-]"}
+
+// Step is one item of a plan.
+type Step struct {
+	Text   string
+	Status Status
+}
+
+// Plan is a numbered list of steps, safe for concurrent use.
+type Plan struct {
+	mu    sync.Mutex
+	steps []Step
+}
+
+// New returns an empty plan.
+func New() *Plan { return &Plan{} }
+
+// Parse reads a plan written as a list: one step per numbered ("1." or "1)") or
+// bulleted line, optionally starting with a checkbox ("[x]", "[>]", "[-]", "[ ]").
+// Indented lines continue the previous step. Text without list markers has one
+// step per line.
+func Parse(text string) ([]Step, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	listed := false
+	for _, l := range lines {
+		if _, ok := listItem(l); ok {
+			listed = true
+			break
+		}
+	}
+
+	var steps []Step
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		item, ok := listItem(l)
+		if !ok && listed {
+			// Continuation of the previous step, or a heading before the list.
+			if len(steps) > 0 && (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) {
+				steps[len(steps)-1].Text += " " + strings.TrimSpace(l)
+			}
+			continue
+		}
+		if !ok {
+			item = strings.TrimSpace(l)
+		}
+		steps = append(steps, parseStep(item))
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("the plan has no steps; write one step per line, e.g. \"1. Read the config loader\"")
+	}
+	if len(steps) > maxSteps {
+		return nil, fmt.Errorf("the plan has %d steps; keep it under %d by grouping related work", len(steps), maxSteps)
+	}
+	for i := range steps {
+		if steps[i].Text == "" {
+			return nil, fmt.Errorf("step %d is empty", i+1)
+		}
+		steps[i].Text = clip(steps[i].Text)
+	}
+	return steps, nil
+}
+
+// listItem returns the text after a leading "1.", "1)", "-" or "*".
+func listItem(l string) (string, bool) {
+	t := strings.TrimSpace(l)
+	if strings.HasPrefix(t, "- ") || strings.HasPrefix(t, "* ") {
+		return strings.TrimSpace(t[2:]), true
+	}
+	i := 0
+	for i < len(t) && t[i] >= '0' && t[i] <= '9' {
+		i++
+	}
+	if i > 0 && i < len(t) && (t[i] == '.' || t[i] == ')') {
+		return strings.TrimSpace(t[i+1:]), true
+	}
+	return "", false
+}
+
+func parseStep(item string) Step {
+	s := Step{Text: item, Status: Pending}
+	for st, m := range marks {
+		if len(item) >= 3 && strings.EqualFold(item[:3], m) {
+			s.Text, s.Status = strings.TrimSpace(item[3:]), st
+			break
+		}
+	}
+	return s
+}
+
+func clip(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > maxStepText {
+		text = string(r[:maxStepText]) + "..."
+	}
+	return text
+}
+
+// Set replaces the steps of the plan.
+func (p *Plan) Set(steps []Step) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.steps = append([]Step(nil), steps...)
+}
+
+// Mark sets the status of step n (1-based).
+func (p *Plan) Mark(n int, status Status) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n < 1 || n > len(p.steps) {
+		return fmt.Errorf("no step %d (the plan has %d)", n, len(p.steps))
+	}
+	p.steps[n-1].Status = status
+	return nil
+}
+
+// Add inserts a pending step after step after (0 for the beginning, -1 or more
+// than the number of steps for the end). It returns the new step's number.
+func (p *Plan) Add(text string, after int) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	text = clip(text)
+	if text == "" {
+		return 0, fmt.Errorf("empty step")
+	}
+	if len(p.steps) >= maxSteps {
+		return 0, fmt.Errorf("the plan already has %d steps", maxSteps)
+	}
+	if after < 0 || after > len(p.steps) {
+		after = len(p.steps)
+	}
+	p.steps = append(p.steps, Step{})
+	copy(p.steps[after+1:], p.steps[after:])
+	p.steps[after] = Step{Text: text, Status: Pending}
+	return after + 1, nil
+}
+
+// Steps returns a copy of the steps.
+func (p *Plan) Steps() []Step {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Step(nil), p.steps...)
+}
+
+// Empty reports whether the plan has no steps.
+func (p *Plan) Empty() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.steps) == 0
+}
+
+// Open returns the numbers of the steps that are pending or in progress.
+func (p *Plan) Open() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var open []int
+	for i, s := range p.steps {
+		if s.Status == Pending || s.Status == InProgress {
+			open = append(open, i+1)
+		}
+	}
+	return open
+}
+
+// String renders the plan as a numbered checklist followed by its progress, or ""
+// for an empty plan.
+func (p *Plan) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.steps) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	finished := 0
+	for i, s := range p.steps {
+		fmt.Fprintf(&b, "%d. %s %s\n", i+1, marks[s.Status], s.Text)
+		if s.Status == Done || s.Status == Skipped {
+			finished++
+		}
+	}
+	fmt.Fprintf(&b, "(%d of %d steps finished; [x] done, [>] in progress, [-] skipped, [ ] pending)", finished, len(p.steps))
+	return b.String()
+}
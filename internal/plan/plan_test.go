@@ -0,0 +1,80 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	steps, err := Parse("Plan:\n1. Read the loader\n2) [x] Add the field\n   with a default\n- [>] Update tests\n* [-] Docs\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Step{
+		{"Read the loader", Pending},
+		{"Add the field with a default", Done},
+		{"Update tests", InProgress},
+		{"Docs", Skipped},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("Parse = %+v, want %+v", steps, want)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Errorf("step %d = %+v, want %+v", i+1, steps[i], want[i])
+		}
+	}
+
+	steps, err = Parse("Read the loader\nAdd the field")
+	if err != nil || len(steps) != 2 || steps[1].Text != "Add the field" {
+		t.Errorf("Parse without markers = %+v, %v", steps, err)
+	}
+	if _, err := Parse("  \n"); err == nil {
+		t.Error("an empty plan should fail")
+	}
+	if _, err := Parse(strings.Repeat("- step\n", maxSteps+1)); err == nil {
+		t.Error("an overlong plan should fail")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	p := New()
+	if !p.Empty() || p.String() != "" {
+		t.Errorf("new plan = %q", p.String())
+	}
+	steps, _ := Parse("1. A\n2. B\n3. C")
+	p.Set(steps)
+	if err := p.Mark(1, Done); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Mark(2, InProgress); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Mark(4, Done); err == nil {
+		t.Error("Mark(4) should fail")
+	}
+	if n, err := p.Add("A2", 1); err != nil || n != 2 {
+		t.Errorf("Add after 1 = %d, %v", n, err)
+	}
+	if n, _ := p.Add("D", -1); n != 5 {
+		t.Errorf("Add at end = %d, want 5", n)
+	}
+	want := "1. [x] A\n2. [ ] A2\n3. [>] B\n4. [ ] C\n5. [ ] D\n(1 of 5 steps finished; [x] done, [>] in progress, [-] skipped, [ ] pending)"
+	if got := p.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+	if got := p.Open(); len(got) != 4 || got[0] != 2 || got[3] != 5 {
+		t.Errorf("Open() = %v", got)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for in, want := range map[string]Status{"done": Done, "Completed": Done, "in-progress": InProgress, "todo": Pending, "skip": Skipped} {
+		if got, err := ParseStatus(in); err != nil || got != want {
+			t.Errorf("ParseStatus(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseStatus("maybe"); err == nil {
+		t.Error("ParseStatus(maybe) should fail")
+	}
+}
@@ -10,6 +10,13 @@ import (
 // Lookup order: flagPath (if non-empty), projectDir/.devagent/filename, ~/.devagent/filename.
 // Returns empty string if none found.
 func ResolvePromptFile(flagPath, projectDir, filename string) string {
+	content, _ := FindPromptFile(flagPath, projectDir, filename)
+	return content
+}
+
+// FindPromptFile is ResolvePromptFile that also returns the path the content was
+// read from ("" if none), for OriginOf.
+func FindPromptFile(flagPath, projectDir, filename string) (content, path string) {
 	var candidates []string
 	if flagPath != "" {
 		candidates = append(candidates, flagPath)
@@ -25,7 +32,7 @@ func ResolvePromptFile(flagPath, projectDir, filename string) string {
 		if err != nil {
 			continue
 		}
-		return strings.TrimSpace(string(data))
+		return strings.TrimSpace(string(data)), p
 	}
-	return ""
+	return "", ""
 }
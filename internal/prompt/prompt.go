@@ -3,6 +3,7 @@ package prompt
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
 const systemPromptIdentity = `You are DevAgent, an expert software engineer AI assistant. You help users understand, modify, debug, and build software projects.
@@ -15,8 +16,9 @@ const systemPromptBody = `## Available Commands
 You have the following commands at your disposal. To invoke them, output a JSON code block with the command and arguments.
 
 ### File Operations
-- **read_file**: Read file contents with line numbers
-  Args: {"path": "<file_path>"}
+- **read_file**: Read file contents with line numbers. Returns up to 500 lines per call; long files end with a hint telling you which offset to continue from. Binary files return a short summary instead of their content.
+  Args: {"path": "<file_path>", "offset": "<first line, 1-based (optional)>", "limit": "<max lines (optional)>", "symbol": "<declaration to read, e.g. ParseConfig or Server.Start (optional)>"}
+  Prefer "symbol" or a line range over reading a large file in full.
 - **write_file**: Write content to a file (creates parent directories automatically)
   Args: {"path": "<file_path>", "content": "<file_content>"}
 - **str_replace**: Replace a unique string in a file (for precise edits). old_str must match exactly once.
@@ -25,14 +27,60 @@ You have the following commands at your disposal. To invoke them, output a JSON
   Args: {"path": "<file_path>", "after": "<line_to_match>", "content": "<content_to_insert>"}
 - **list_dir**: List directory contents
   Args: {"path": "<directory_path>"}
-- **search_files**: Search for files matching a glob pattern
-  Args: {"path": "<directory_path>", "pattern": "<glob_pattern>"}
-- **grep**: Search for text in files using regex
-  Args: {"path": "<directory_path>", "pattern": "<regex_pattern>"}
+- **search_files**: Find files by glob. Patterns match paths relative to "path" and support "**" (e.g. internal/**/*_test.go, cmd/*/main.go); a pattern without "/" matches file names anywhere. Results show file sizes and respect .gitignore.
+  Args: {"path": "<directory_path (optional)>", "pattern": "<comma-separated globs>", "exclude": "<comma-separated globs (optional)>", "sort": "<path|mtime (optional; mtime lists recently modified files first)>"}
+- **grep**: Search file contents with a regular expression (Go RE2 syntax). Output lines are "file:line:text"; context lines use "file-line-text". Respects .gitignore and skips binary files.
+  Args: {"path": "<file_or_directory (optional)>", "pattern": "<regex_pattern>", "include": "<comma-separated globs, e.g. *.go,cmd/**/*.go (optional)>", "exclude": "<comma-separated globs (optional)>", "context": "<lines of context, max 10 (optional)>", "ignore_case": "<true|false (optional)>", "max_results": "<default 100, max 1000 (optional)>"}
+
+### Code Navigation
+- **code_search**: Find code by describing it in plain words, e.g. "where do we validate JWT expiry" or "retry logic for failed uploads". Returns the best-matching functions, types and doc sections as file:start-end with an excerpt. Use it to locate code when you don't know the names; use grep for exact text and find_definition for a known name.
+  Args: {"query": "<description or keywords>", "path": "<file_or_directory to search in (optional)>", "max_results": "<default 8, max 30 (optional)>"}
+- **find_definition**: Find where a symbol is declared. Go code is parsed, so names can be qualified: "New", "agent.New", "Agent.Run" or "(*Agent).Run"; other languages are matched by declaration keywords. Returns file:line ranges with signatures.
+  Args: {"name": "<symbol>"}
+- **find_references**: Find every use of a symbol. For Go the results are resolved by the type checker, so only real references to that declaration are listed; other languages get whole-word matches.
+  Args: {"name": "<symbol>", "max_results": "<default 100, max 1000 (optional)>"}
+- **list_symbols**: List the declarations in a file, or in the package (source files) of a directory, as file:line with signatures
+  Args: {"path": "<file_or_directory (optional)>", "exported": "<true for exported symbols only (optional)>", "tests": "<true to include _test.go files (optional)>"}
+- **outline**: Show the structure of one file: types, fields, functions and methods with their line ranges. Use it to decide which lines to read.
+  Args: {"path": "<file_path>"}
+
+The lsp_* tools ask the project's language servers (gopls, pyright, typescript-language-server, ...) and exist only when servers are configured. They work in any configured language. Point them at a symbol with path and 1-based line plus either the symbol's name on that line or a 1-based column.
+- **lsp_definition**: Go to the declaration of the symbol at a position
+  Args: {"path": "<file_path>", "line": "<line>", "symbol": "<name on that line (optional)>", "column": "<column (optional)>"}
+- **lsp_references**: List every use of the symbol at a position, including its declaration
+  Args: {"path": "<file_path>", "line": "<line>", "symbol": "<name (optional)>", "column": "<column (optional)>", "max_results": "<default 100, max 1000 (optional)>"}
+- **lsp_hover**: Show the type, signature and documentation of the symbol at a position
+  Args: {"path": "<file_path>", "line": "<line>", "symbol": "<name (optional)>", "column": "<column (optional)>"}
+- **lsp_rename**: Rename the symbol at a position everywhere it is used. Safer than editing each use by hand.
+  Args: {"path": "<file_path>", "line": "<line>", "symbol": "<name (optional)>", "column": "<column (optional)>", "new_name": "<new name>"}
+- **lsp_diagnostics**: Show the language server's errors and warnings for a file. Without a path, lists everything reported so far.
+  Args: {"path": "<file_path (optional)>"}
+
+### Delegation
+- **delegate**: Hand a self-contained question about the code to a subagent, e.g. "find every caller of Store.Save and how each handles its error" or "explain how the config loader merges files". The subagent works in its own conversation with the read-only file and navigation commands and returns only a summary with file:line references, which keeps your context small. It cannot see this conversation, edit files or run commands, so give it everything it needs in the task. Several delegate commands output together as a JSON array run concurrently.
+  Args: {"task": "<what to find out, with the names and paths you already know>"}
+
+### Transactions
+- **begin_transaction**: Start a multi-file edit transaction. Later file edits are tracked so they can be applied or undone together.
+  Args: {}
+- **commit_transaction**: Validate (with the project's configured build command, if any) and keep all edits made in the transaction. If validation fails, all edits are rolled back.
+  Args: {}
+- **rollback_transaction**: Undo every file edit made since begin_transaction
+  Args: {}
 
 ### Shell Operations
-- **shell**: Execute a shell command (can install packages, run tests, build projects, etc.)
-  Args: {"command": "<shell_command>"}
+- **shell**: Execute a shell command (can install packages, run tests, build projects, etc.). Commands run in one persistent bash session: the working directory, exported variables and activated virtualenvs carry over to later commands. Commands cannot read stdin. Pass "reset": "true" to start a fresh session in the project root. Commands time out after 5 minutes by default; pass a larger "timeout" for slow builds or test suites.
+  Args: {"command": "<shell_command>", "reset": "<true to reset the session first (optional)>", "timeout": "<seconds or duration such as 600 or 10m (optional)>"}
+- **run_tests**: Run Go tests ("go test -json") and get a structured report: pass/fail counts, each failing test with its output and file:line locations, build errors, and per-package status. Prefer it over running "go test" in the shell.
+  Args: {"packages": "<space- or comma-separated packages, default ./... (optional)>", "run": "<-run regex selecting tests (optional)>", "race": "<true to enable the race detector (optional)>", "no_cache": "<true to bypass cached results (optional)>", "timeout": "<seconds or duration (optional)>"}
+- **shell_start**: Start a long-running command in the background (dev server, file watcher) and return immediately with its first output. It runs from the project root, not the shell session's directory.
+  Args: {"command": "<shell_command>", "name": "<short name to refer to it (optional)>"}
+- **shell_read_output**: Show the status and new output of a background process since the last read. Without a name, lists all background processes.
+  Args: {"name": "<process name>", "wait": "<seconds to wait for new output, max 60 (optional)>", "all": "<true to return all buffered output (optional)>"}
+- **shell_stop**: Stop a background process and everything it started
+  Args: {"name": "<process name>"}
+- **read_output**: Read the full saved output of an earlier command. Every observation header shows its output id (e.g. "Output: #12"); long outputs are shortened in the conversation, so use this to see the omitted part. Without an id, lists saved outputs.
+  Args: {"id": "<output id>", "offset": "<first line (optional)>", "limit": "<max lines (optional)>", "pattern": "<regex to search for instead of paging (optional)>", "context": "<lines around each match (optional)>"}
 
 ### Code Repair
 - **debug_code**: Analyze code errors and suggest fixes. Provide the code, the error, and optionally test code.
@@ -41,6 +89,16 @@ You have the following commands at your disposal. To invoke them, output a JSON
 ### Task Management
 - **done**: Signal that the task is complete
   Args: {"summary": "<summary_of_what_was_done>"}
+- **ask_user**: Ask the user a clarifying question and wait for the answer. Use it when the task is ambiguous in a way that changes what you would build and the code can't tell you; don't ask what you can find out yourself. Never end the task with done just to ask a question. If no user is available, the call fails and you should proceed on a stated assumption.
+  Args: {"question": "<one specific question>", "choices": "<options separated by | (optional)>"}
+
+### Planning
+- **update_plan**: Keep a numbered todo list for the task. The current plan is shown in the "Current Plan" section at every step, even after older messages are trimmed. Pass "plan" to write or replace the whole list, "add" to insert a step, or "step" with "status" to mark progress (several steps can be given as "2,3").
+  Args: {"plan": "<numbered list, one step per line (optional)>", "add": "<new step (optional)>", "after": "<step number to insert after (optional, default end)>", "step": "<step number(s) (optional)>", "status": "<pending|in_progress|done|skipped>"}
+
+### Memory
+- **remember**: Record a durable fact that a later run would otherwise have to rediscover: how to build or test the project, where things live, conventions, pitfalls. It is added to .devagent/MEMORY.md (or ~/.devagent/MEMORY.md for scope "user": the user's preferences across projects) and shown in the Memory section of every later run. One short, self-contained fact per call; don't record details of the current task.
+  Args: {"fact": "<the fact>", "section": "<heading to file it under, e.g. Build, Testing, Conventions (optional)>", "scope": "<project|user (optional, default project)>"}
 
 ### Skills
 - **read_skill**: Load instructions from an available skill. When a skill is relevant to the user's task, use this to load its full instructions, then follow them.
@@ -71,7 +129,7 @@ I need to read the main.go file to understand the project structure.
    a. Read the failing code and error logs
    b. Identify the root cause
    c. Write the fix (prefer str_replace for targeted edits, write_file for new files or full rewrites)
-   d. Run tests/build to verify the fix
+   d. Run tests/build to verify the fix (run_tests for Go projects)
    e. If tests still fail, retry (up to 3 times)
 5. When you need to install tools or dependencies, use the shell command
 6. For code repair, analyze both the code and error output, then rewrite the code with fixes
@@ -81,8 +139,12 @@ I need to read the main.go file to understand the project structure.
 10. If a file does not exist yet, use write_file to create it
 11. For small, targeted edits, prefer str_replace over write_file to avoid accidentally overwriting content
 12. Always read a file before editing it to understand its current content
-13. After writing or modifying code, verify correctness by running the build/test command
-14. Before starting a task, check the "Available Skills" section (if present); when a skill is relevant, call read_skill to load its instructions and follow them
+13. After writing or modifying code, verify correctness by running the build/test command. When an edit result includes "Post-edit checks", fix any problems they report before moving on
+14. For refactors that span several files, wrap the edits in begin_transaction / commit_transaction so a failed change does not leave the tree half-edited
+15. Before starting a task, check the "Available Skills" section (if present); when a skill is relevant, call read_skill to load its instructions and follow them
+16. Use the facts in the "Memory" section (if present) instead of rediscovering them, but trust the code when they disagree. When you learn something durable about the project (a build or test command that works, a convention, a trap), record it with remember
+17. For tasks with several steps, write a plan with update_plan before editing, mark each step in_progress when you start it and done when it is finished, and revise the plan when it turns out wrong
+18. On a large codebase, delegate broad investigations (finding all uses of something, understanding a subsystem) instead of reading everything yourself; independent questions can be delegated together
 `
 
 // BuildSystemPrompt composes the system prompt from identity, optional soul, body, and optional guidelines.
@@ -102,6 +164,112 @@ func BuildSystemPrompt(soul, guidelines string) string {
 	return b.String()
 }
 
+// maxMemoryPrompt caps each memory file in the prompt.
+const maxMemoryPrompt = 16 << 10
+
+// BuildMemoryContext formats the project's and the user's memory files for the end of
+// the system prompt. It returns "" when both are empty.
+func BuildMemoryContext(project, user string) string {
+	project, user = strings.TrimSpace(project), strings.TrimSpace(user)
+	if project == "" && user == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n## Memory\n\nFacts recorded in earlier runs with `remember` or by the user.\n")
+	for _, m := range []struct{ title, content string }{
+		{"Project (.devagent/MEMORY.md)", project},
+		{"User (~/.devagent/MEMORY.md)", user},
+	} {
+		if m.content == "" {
+			continue
+		}
+		m.content = clip(m.content, maxMemoryPrompt, "ask the user to prune it with \"devagent memory edit\"")
+		b.WriteString(fmt.Sprintf("\n### %s\n\n%s\n", m.title, m.content))
+	}
+	return b.String()
+}
+
+// clip shortens s to at most max bytes at a rune boundary and adds a note saying so.
+func clip(s string, max int, hint string) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "\n... (truncated; " + hint + ")"
+}
+
+// maxInstructionsPrompt caps each repository instruction file in the prompt.
+const maxInstructionsPrompt = 16 << 10
+
+// InstructionFile is a repository instruction file such as AGENTS.md.
+type InstructionFile struct {
+	Path    string // relative to the project root
+	Dir     string // directory it applies to ("" for the whole repository)
+	Content string
+}
+
+// BuildInstructionsContext formats the repository-wide instruction files for the
+// system prompt. It returns "" when there are none.
+func BuildInstructionsContext(files []InstructionFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n## Repository Instructions\n\n")
+	b.WriteString("The project's own instructions for contributors. Follow them; where they conflict with the general rules above, they win. Directories may have their own instruction files, which are shown when you start working there.\n")
+	for _, f := range files {
+		b.WriteString(fmt.Sprintf("\n### %s\n\n%s\n", f.Path, clip(f.Content, maxInstructionsPrompt, "read_file has the rest")))
+	}
+	return b.String()
+}
+
+// BuildNestedInstructions formats the instruction files of the subdirectories the
+// agent has worked in, for the end of the system prompt, where they survive history
+// trimming. It returns "" when there are none.
+func BuildNestedInstructions(files []InstructionFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n## Directory Instructions\n\n")
+	b.WriteString("Instruction files of the directories you have worked in. Follow each one for files in its directory.\n")
+	for _, f := range files {
+		b.WriteString(fmt.Sprintf("\n### %s (for %s/)\n\n%s\n", f.Path, f.Dir, clip(f.Content, maxInstructionsPrompt, "read_file has the rest")))
+	}
+	return b.String()
+}
+
+// BuildNestedInstructionsNotice tells the model, in the observation of the tool call
+// that reached a subdirectory, that its instruction files were added to the system prompt.
+func BuildNestedInstructionsNotice(files []InstructionFile) string {
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString(fmt.Sprintf("\n\n[Instructions for %s/ from %s were added to the system prompt — follow them for files in that directory]", f.Dir, f.Path))
+	}
+	return b.String()
+}
+
+// BuildPlanContext formats the current plan for the end of the system prompt, where
+// it is refreshed before every step. It returns "" for an empty plan.
+func BuildPlanContext(plan string) string {
+	if plan == "" {
+		return ""
+	}
+	return "\n\n## Current Plan\n\nYour plan for this task. Keep it current with update_plan.\n\n" + plan + "\n"
+}
+
+// BuildPlanningInstruction tells the model to plan first; it is added to the task
+// in planning mode.
+func BuildPlanningInstruction() string {
+	return "\n\n## Planning\n\nPlanning mode is on. Before changing anything, explore as much as you need with read-only commands, " +
+		"then submit a numbered plan with update_plan (the \"plan\" argument). Each step should be a concrete change or check. " +
+		"File edits and shell commands are refused until the plan is approved; the user may ask for changes first. " +
+		"Once it is approved, work through the plan and keep it current with update_plan."
+}
+
 // SkillMeta holds name and description for listing available skills in the prompt.
 type SkillMeta struct {
 	Name        string
@@ -123,32 +291,164 @@ func BuildSkillsContext(skills []SkillMeta) string {
 	return sb.String()
 }
 
-func BuildProjectContext(projectPath string, fileTree string) string {
+func BuildProjectContext(projectPath string, repoMap string) string {
 	return fmt.Sprintf(`## Current Project
 
 Project path: %s
 
-### File Structure
+### Repository Map
+The project's most important files with their main declarations (exported functions, types and methods). Use list_dir, list_symbols or outline for the rest.
+
 %s
-`, projectPath, fileTree)
+`, projectPath, repoMap)
 }
 
 func BuildUserTask(task string) string {
 	return fmt.Sprintf("## User Task\n\n%s", task)
 }
 
+const subagentPromptIntro = `You are a DevAgent subagent: a software engineer investigating one question about a codebase for the main agent. The main agent sees only your final summary, not your commands or their output.
+
+You operate in a ReAct loop: Think → Act → Observe → Think → Act → ...
+
+## Available Commands
+
+To invoke a command, output a JSON code block with the command and arguments. You can only read the project.
+
+`
+
+const subagentPromptRules = `- **done**: Finish and report your findings
+  Args: {"summary": "<your findings>"}
+
+## Output Format
+
+Think inside <think>...</think> tags, then output a JSON code block, e.g.:
+
+` + "```json" + `
+{"command": "grep", "args": {"pattern": "func Load", "include": "*.go"}}
+` + "```" + `
+
+## Rules
+
+1. Stay on the question you were given; don't investigate beyond it
+2. Prefer find_definition, find_references, code_search and grep over reading whole files, and read files by symbol or line range
+3. When you know the answer, or have looked everywhere reasonable, call done. The summary is all the main agent gets: give the answer first, then the evidence as file:line references with short excerpts, and say what you could not find. Keep it short unless you were asked for a complete list
+`
+
+// BuildSubagentPrompt returns the system prompt of a subagent that may use the
+// named commands. Their descriptions are taken from the main system prompt.
+func BuildSubagentPrompt(commands []string) string {
+	var b strings.Builder
+	b.WriteString(subagentPromptIntro)
+	lines := strings.Split(systemPromptBody, "\n")
+	for i, l := range lines {
+		name, ok := commandName(l)
+		if !ok || !contains(commands, name) {
+			continue
+		}
+		b.WriteString(l + "\n")
+		for _, next := range lines[i+1:] {
+			if !strings.HasPrefix(next, "  ") {
+				break
+			}
+			b.WriteString(next + "\n")
+		}
+	}
+	b.WriteString(subagentPromptRules)
+	return b.String()
+}
+
+// commandName returns the command a "- **name**: ..." line of the system prompt
+// describes.
+func commandName(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, "- **")
+	if !ok {
+		return "", false
+	}
+	name, _, ok := strings.Cut(rest, "**:")
+	return name, ok
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildSubagentTask is the first user message of a subagent.
+func BuildSubagentTask(projectPath, task string) string {
+	return fmt.Sprintf("## Current Project\n\nProject path: %s\n\n## Question\n\n%s", projectPath, strings.TrimSpace(task))
+}
+
+const reviewPrompt = `You are a senior software engineer reviewing the work of a coding agent before it is accepted. You are given the user's task, the agent's summary and the diff of everything it changed.
+
+Check that the changes do what the task asks, completely: nothing requested is missing, nothing unrelated was changed, and there are no bugs, leftover debugging code, placeholders or TODOs. Judge the task, not your own preferences; don't ask for extras the task did not request.
+
+Answer with a first line of exactly "VERDICT: approve" or "VERDICT: changes". After "VERDICT: changes", list each problem as a bullet with the file and what has to change.`
+
+// maxReviewDiff caps the diff shown to the reviewer.
+const maxReviewDiff = 60 << 10
+
+// BuildReviewPrompt returns the reviewer's system prompt.
+func BuildReviewPrompt() string {
+	return reviewPrompt
+}
+
+// BuildReviewRequest asks the reviewer to check a finished task's diff against the task.
+func BuildReviewRequest(task, summary, diff string) string {
+	if strings.TrimSpace(diff) == "" {
+		diff = "(no files were changed)"
+	}
+	return fmt.Sprintf("## Task\n\n%s\n\n## Agent Summary\n\n%s\n\n## Diff\n\n%s",
+		strings.TrimSpace(task), strings.TrimSpace(summary), clip(diff, maxReviewDiff, "review the part shown"))
+}
+
+// BuildUserGuidance wraps guidance the user typed while the task was paused.
+func BuildUserGuidance(guidance string) string {
+	return fmt.Sprintf("## User Guidance\n\nThe user paused the task to say:\n\n%s\n\nTake this into account from now on; it overrides earlier instructions where they conflict.", strings.TrimSpace(guidance))
+}
+
+// maxObservation bounds the tool output placed in the conversation. Longer output keeps
+// its head and tail; the full text stays available through read_output when it was saved.
+const maxObservation = 8000
+
 func BuildObservation(cmdName string, success bool, output string) string {
+	return BuildToolObservation(cmdName, success, output, 0)
+}
+
+// BuildToolObservation is BuildObservation for a tool call whose full output was saved
+// as output #outputID (0 if it was not saved).
+func BuildToolObservation(cmdName string, success bool, output string, outputID int) string {
 	status := "SUCCESS"
 	if !success {
 		status = "FAILED"
 	}
 
-	const maxOutput = 8000
-	if len(output) > maxOutput {
-		half := maxOutput / 2
-		output = output[:half] + "\n\n... (output truncated) ...\n\n" + output[len(output)-half:]
+	if len(output) > maxObservation {
+		half := maxObservation / 2
+		head, tail := output[:half], output[len(output)-half:]
+		// Cut at line boundaries so no line is shown half.
+		if i := strings.LastIndexByte(head, '\n'); i > 0 {
+			head = head[:i+1]
+		}
+		if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+			tail = tail[i+1:]
+		}
+		omitted := output[len(head) : len(output)-len(tail)]
+		note := "... (output truncated) ..."
+		if outputID > 0 {
+			note = fmt.Sprintf("... (output truncated: %d lines omitted. The full output is saved as #%d; use read_output with id=%d and offset/limit or pattern to see the rest) ...",
+				strings.Count(omitted, "\n"), outputID, outputID)
+		}
+		output = head + "\n" + note + "\n\n" + tail
 	}
 
+	if outputID > 0 {
+		return fmt.Sprintf("[Command: %s | Status: %s | Output: #%d]\n\n%s", cmdName, status, outputID, output)
+	}
 	return fmt.Sprintf("[Command: %s | Status: %s]\n\n%s", cmdName, status, output)
 }
 
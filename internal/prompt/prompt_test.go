@@ -1,8 +1,10 @@
 package prompt
 
 import (
+	"fmt"
 	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestBuildSystemPrompt_EmptySoulAndGuidelines(t *testing.T) {
@@ -136,12 +138,12 @@ func TestBuildSkillsContext_MultipleSkills(t *testing.T) {
 }
 
 func TestBuildProjectContext(t *testing.T) {
-	got := BuildProjectContext("/project", "├── main.go\n└── pkg/\n")
+	got := BuildProjectContext("/project", "main.go:\n  func Run() error\n")
 	if !strings.Contains(got, "Project path: /project") {
 		t.Error("should contain project path")
 	}
-	if !strings.Contains(got, "├── main.go") {
-		t.Error("should contain file tree")
+	if !strings.Contains(got, "### Repository Map") || !strings.Contains(got, "main.go:\n  func Run() error") {
+		t.Error("should contain repository map")
 	}
 }
 
@@ -155,6 +157,55 @@ func TestBuildUserTask(t *testing.T) {
 	}
 }
 
+func TestBuildSubagentPrompt(t *testing.T) {
+	got := BuildSubagentPrompt([]string{"read_file", "grep", "find_references", "lsp_hover"})
+	for _, want := range []string{
+		"- **read_file**:", `Args: {"path": "<file_path>", "offset"`, "Prefer \"symbol\" or a line range",
+		"- **grep**:", "- **find_references**:", "- **lsp_hover**:", "- **done**:", "You can only read the project",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("subagent prompt should contain %q", want)
+		}
+	}
+	for _, unwanted := range []string{"**write_file**", "**shell**", "**delegate**", "**lsp_rename**"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("subagent prompt should not contain %s", unwanted)
+		}
+	}
+}
+
+func TestBuildSubagentTask(t *testing.T) {
+	got := BuildSubagentTask("/workspace", " find callers of Load \n")
+	if !strings.Contains(got, "Project path: /workspace") || !strings.HasSuffix(got, "## Question\n\nfind callers of Load") {
+		t.Errorf("BuildSubagentTask = %q", got)
+	}
+}
+
+func TestBuildReviewRequest(t *testing.T) {
+	got := BuildReviewRequest("Add a flag", "Added -x", "+flag.Bool(\"x\")")
+	for _, want := range []string{"## Task\n\nAdd a flag", "## Agent Summary\n\nAdded -x", "## Diff\n\n+flag.Bool"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("review request should contain %q:\n%s", want, got)
+		}
+	}
+	if got := BuildReviewRequest("t", "s", ""); !strings.Contains(got, "(no files were changed)") {
+		t.Errorf("empty diff: %q", got)
+	}
+	if got := BuildReviewRequest("t", "s", strings.Repeat("x", maxReviewDiff+10)); !strings.Contains(got, "(truncated; ") {
+		t.Error("long diff should be truncated")
+	}
+	if !strings.Contains(BuildReviewPrompt(), "VERDICT: approve") {
+		t.Error("review prompt should ask for a verdict")
+	}
+}
+
+func TestBuildUserGuidance(t *testing.T) {
+	got := BuildUserGuidance("  use the v2 API  \n")
+	if !strings.Contains(got, "## User Guidance") || !strings.Contains(got, "say:\n\nuse the v2 API\n\n") {
+		t.Errorf("BuildUserGuidance = %q", got)
+	}
+}
+
 func TestBuildObservation_Success(t *testing.T) {
 	got := BuildObservation("read_file", true, "line 1\nline 2")
 	if !strings.Contains(got, "read_file") || !strings.Contains(got, "SUCCESS") {
@@ -202,3 +253,84 @@ func TestBuildDebugPrompt_WithTestCode(t *testing.T) {
 		t.Error("should contain test code content")
 	}
 }
+
+func TestBuildToolObservation_Reference(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	got := BuildToolObservation("shell", false, sb.String(), 7)
+	if !strings.Contains(got, "Output: #7]") || !strings.Contains(got, "read_output with id=7") {
+		t.Errorf("missing reference: %s", got[:200])
+	}
+	if !strings.Contains(got, "\nline 0\n") || !strings.Contains(got, "\nline 1999\n") {
+		t.Error("head and tail should be kept")
+	}
+	if len(got) > maxObservation+500 {
+		t.Errorf("observation too long: %d", len(got))
+	}
+	short := BuildToolObservation("read_file", true, "ok", 3)
+	if short != "[Command: read_file | Status: SUCCESS | Output: #3]\n\nok" {
+		t.Errorf("short = %q", short)
+	}
+}
+
+func TestBuildMemoryContext(t *testing.T) {
+	if got := BuildMemoryContext("", " \n"); got != "" {
+		t.Errorf("empty memory = %q", got)
+	}
+	got := BuildMemoryContext("# Project Memory\n\n- go test ./... needs Docker\n", "")
+	if !strings.Contains(got, "## Memory") || !strings.Contains(got, "### Project (.devagent/MEMORY.md)\n\n# Project Memory\n\n- go test ./... needs Docker\n") {
+		t.Errorf("project memory:\n%s", got)
+	}
+	if strings.Contains(got, "### User") {
+		t.Errorf("empty user memory shown:\n%s", got)
+	}
+	got = BuildMemoryContext("", strings.Repeat("- x\n", maxMemoryPrompt))
+	if !strings.Contains(got, "### User") || !strings.Contains(got, "(truncated;") || len(got) > maxMemoryPrompt+500 {
+		t.Errorf("long user memory not truncated (%d bytes)", len(got))
+	}
+}
+
+func TestBuildInstructionsContext(t *testing.T) {
+	if got := BuildInstructionsContext(nil); got != "" {
+		t.Errorf("BuildInstructionsContext(nil) = %q, want empty", got)
+	}
+	got := BuildInstructionsContext([]InstructionFile{
+		{Path: "AGENTS.md", Content: "Run make check."},
+		{Path: "CONTRIBUTING.md", Content: strings.Repeat("é", maxInstructionsPrompt)},
+	})
+	for _, want := range []string{"## Repository Instructions", "### AGENTS.md\n\nRun make check.\n", "### CONTRIBUTING.md", "(truncated; read_file has the rest)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if !utf8.ValidString(got) {
+		t.Error("truncation split a rune")
+	}
+
+	services := []InstructionFile{{Path: "services/AGENTS.md", Dir: "services", Content: "Use zap."}}
+	nested := BuildNestedInstructions(services)
+	if !strings.Contains(nested, "## Directory Instructions") || !strings.HasSuffix(nested, "### services/AGENTS.md (for services/)\n\nUse zap.\n") {
+		t.Errorf("BuildNestedInstructions = %q", nested)
+	}
+	if got := BuildNestedInstructions(nil); got != "" {
+		t.Errorf("BuildNestedInstructions(nil) = %q", got)
+	}
+	if notice := BuildNestedInstructionsNotice(services); !strings.Contains(notice, "[Instructions for services/ from services/AGENTS.md were added to the system prompt") {
+		t.Errorf("BuildNestedInstructionsNotice = %q", notice)
+	}
+}
+
+func TestBuildPlanContext(t *testing.T) {
+	if got := BuildPlanContext(""); got != "" {
+		t.Errorf("BuildPlanContext(empty) = %q", got)
+	}
+	got := BuildPlanContext("1. [x] Read\n2. [ ] Edit")
+	if !strings.Contains(got, "## Current Plan") || !strings.HasSuffix(got, "1. [x] Read\n2. [ ] Edit\n") {
+		t.Errorf("BuildPlanContext = %q", got)
+	}
+	if !strings.Contains(BuildPlanningInstruction(), "update_plan") {
+		t.Error("planning instruction should name update_plan")
+	}
+}
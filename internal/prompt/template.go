@@ -0,0 +1,217 @@
+package prompt
+
+import (
+	"bufio"
+	"bytes"
+	"devagent/internal/ignore"
+	"devagent/internal/sandbox"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	maxIncludeDepth = 8
+	maxIncludeSize  = 256 << 10
+	maxDetectFiles  = 50000 // files looked at when detecting languages
+)
+
+// Vars is the data SOUL.md, GUIDELINES.md and skill bodies see when rendered as
+// text/template, e.g. {{.Project}} or {{if has .Languages "Go"}}...{{end}}.
+type Vars struct {
+	Project    string   // name of the project directory
+	ProjectDir string   // project path as the agent sees it (/workspace in Docker mode)
+	Languages  []string // languages of the project's source files, most files first
+	GoModule   string   // module path from go.mod ("" without one)
+	OS         string   // operating system commands run on
+	Date       string   // today, as 2006-01-02
+	Sandbox    string   // sandbox mode: permissive, normal or strict
+	Docker     bool     // whether shell commands run in a Docker container
+
+	// Root is the project directory on the host; include and exists resolve
+	// relative paths against it.
+	Root string
+}
+
+// Origin says where a template comes from, which decides the files include and
+// exists may read.
+type Origin int
+
+const (
+	// FromProject templates are checked into the project (.devagent/SOUL.md, project
+	// skills). They only reach files inside Vars.Root, so a cloned repository cannot
+	// pull the user's files into the prompt.
+	FromProject Origin = iota
+	// FromUser templates are the user's own files (~/.devagent, prompt files passed on
+	// the command line). They may also read "~/" and absolute paths.
+	FromUser
+)
+
+// OriginOf returns the origin of the template file at path for the project at root:
+// FromProject if it lies inside root, FromUser otherwise.
+func OriginOf(path, root string) Origin {
+	if path == "" || inside(root, path) {
+		return FromProject
+	}
+	return FromUser
+}
+
+// Render executes text as a template named name. Besides the fields of Vars,
+// templates can call:
+//
+//	include "path"    the rendered content of another file (relative to the project)
+//	exists "path"     whether a file or directory exists in the project
+//	has list "item"   whether list contains item, ignoring case
+//	join list ", "    strings.Join
+//
+// origin limits the paths include and exists accept; an included file inside the
+// project is rendered as FromProject. Text without "{{" is returned unchanged.
+func Render(name, text string, vars Vars, origin Origin) (string, error) {
+	return render(name, text, vars, origin, 0)
+}
+
+func render(name, text string, vars Vars, origin Origin, depth int) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	funcs := template.FuncMap{
+		"include": func(p string) (string, error) {
+			if depth >= maxIncludeDepth {
+				return "", fmt.Errorf("include %s: nested more than %d levels", p, maxIncludeDepth)
+			}
+			abs, err := vars.resolve(p, origin)
+			if err != nil {
+				return "", fmt.Errorf("include %s: %w", p, err)
+			}
+			info, err := os.Stat(abs)
+			if err != nil {
+				return "", fmt.Errorf("include %s: %w", p, err)
+			}
+			if info.Size() > maxIncludeSize {
+				return "", fmt.Errorf("include %s: file is larger than %d bytes", p, maxIncludeSize)
+			}
+			data, err := os.ReadFile(abs)
+			if err != nil {
+				return "", fmt.Errorf("include %s: %w", p, err)
+			}
+			return render(p, strings.TrimSpace(string(data)), vars, OriginOf(abs, vars.Root), depth+1)
+		},
+		"exists": func(p string) bool {
+			abs, err := vars.resolve(p, origin)
+			if err != nil {
+				return false
+			}
+			_, err = os.Stat(abs)
+			return err == nil
+		},
+		"has": func(list []string, item string) bool {
+			for _, s := range list {
+				if strings.EqualFold(s, item) {
+					return true
+				}
+			}
+			return false
+		},
+		"join": strings.Join,
+	}
+	t, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// resolve turns a path from a template into an absolute one: "~/" is the home
+// directory and relative paths are under the project root. Templates FromProject
+// may only name files inside the root, after following symlinks.
+func (v Vars) resolve(p string, origin Origin) (string, error) {
+	abs := p
+	switch {
+	case strings.HasPrefix(p, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		abs = filepath.Join(home, p[2:])
+	case !filepath.IsAbs(p):
+		abs = filepath.Join(v.Root, filepath.FromSlash(p))
+	}
+	if origin == FromProject && !inside(v.Root, abs) {
+		return "", fmt.Errorf("%s is outside the project", p)
+	}
+	return abs, nil
+}
+
+// inside reports whether path lies within root once symlinks are resolved.
+func inside(root, path string) bool {
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return sandbox.ValidatePath(root, path, nil) == nil
+}
+
+// languageNames maps source file extensions to the names used in Vars.Languages.
+var languageNames = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".jsx": "JavaScript", ".mjs": "JavaScript",
+	".cjs": "JavaScript", ".ts": "TypeScript", ".tsx": "TypeScript", ".rs": "Rust", ".java": "Java",
+	".kt": "Kotlin", ".scala": "Scala", ".rb": "Ruby", ".php": "PHP", ".cs": "C#", ".c": "C",
+	".h": "C", ".cc": "C++", ".cpp": "C++", ".hpp": "C++", ".swift": "Swift", ".lua": "Lua",
+	".sh": "Shell", ".bash": "Shell", ".ex": "Elixir", ".exs": "Elixir", ".dart": "Dart", ".vue": "Vue",
+}
+
+// DetectLanguages returns the languages of the source files under root (respecting
+// .gitignore), ordered by number of files.
+func DetectLanguages(root string) []string {
+	counts := make(map[string]int)
+	seen := 0
+	errStop := fmt.Errorf("enough files")
+	ignore.New(root).Walk(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if seen++; seen > maxDetectFiles {
+			return errStop
+		}
+		if lang, ok := languageNames[strings.ToLower(filepath.Ext(p))]; ok {
+			counts[lang]++
+		}
+		return nil
+	})
+	langs := make([]string, 0, len(counts))
+	for l := range counts {
+		langs = append(langs, l)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if counts[langs[i]] != counts[langs[j]] {
+			return counts[langs[i]] > counts[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+	return langs
+}
+
+// GoModule returns the module path declared in root/go.mod, or "".
+func GoModule(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		if f := strings.Fields(sc.Text()); len(f) >= 2 && f[0] == "module" {
+			return strings.Trim(f[1], `"`)
+		}
+	}
+	return ""
+}
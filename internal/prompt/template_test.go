@@ -0,0 +1,119 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	os.WriteFile(filepath.Join(root, "docs", "style.md"), []byte("Style for {{.Project}}.\n"), 0644)
+	os.WriteFile(filepath.Join(root, "docs", "loop.md"), []byte(`{{include "docs/loop.md"}}`), 0644)
+	vars := Vars{
+		Project:   "shop",
+		Languages: []string{"Go", "TypeScript"},
+		GoModule:  "example.com/shop",
+		OS:        "linux",
+		Sandbox:   "strict",
+		Docker:    true,
+		Root:      root,
+	}
+
+	tests := []struct {
+		text, want string
+	}{
+		{"plain {text} stays", "plain {text} stays"},
+		{"{{.Project}} ({{.GoModule}}) on {{.OS}}", "shop (example.com/shop) on linux"},
+		{`{{if has .Languages "go"}}gofmt{{end}}{{if has .Languages "Rust"}}rustfmt{{end}}`, "gofmt"},
+		{`{{join .Languages ", "}}`, "Go, TypeScript"},
+		{`{{if .Docker}}container{{end}}, sandbox {{.Sandbox}}`, "container, sandbox strict"},
+		{`{{include "docs/style.md"}}`, "Style for shop."},
+		{`{{if exists "docs"}}has docs{{end}}{{if exists "web"}}has web{{end}}`, "has docs"},
+	}
+	for _, tt := range tests {
+		got, err := Render("GUIDELINES.md", tt.text, vars, FromProject)
+		if err != nil {
+			t.Errorf("Render(%q): %v", tt.text, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	for _, text := range []string{"{{.Values.name}}", "{{if}}", `{{include "missing.md"}}`, `{{include "docs/loop.md"}}`} {
+		if _, err := Render("GUIDELINES.md", text, vars, FromProject); err == nil {
+			t.Errorf("Render(%q) should fail", text)
+		}
+	}
+}
+
+func TestRender_ProjectTemplateStaysInRoot(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	secret := filepath.Join(home, ".ssh", "id_rsa")
+	os.MkdirAll(filepath.Dir(secret), 0755)
+	os.WriteFile(secret, []byte("PRIVATE KEY"), 0600)
+	root := filepath.Join(home, "src", "repo")
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	os.WriteFile(filepath.Join(root, "docs", "style.md"), []byte("tabs"), 0644)
+	os.WriteFile(filepath.Join(root, "docs", "leak.md"), []byte(`{{include "~/.ssh/id_rsa"}}`), 0644)
+	os.Symlink(secret, filepath.Join(root, "key"))
+	vars := Vars{Root: root}
+
+	for _, text := range []string{
+		`{{include "~/.ssh/id_rsa"}}`,
+		`{{include "` + secret + `"}}`,
+		`{{include "../../.ssh/id_rsa"}}`,
+		`{{include "key"}}`,
+	} {
+		if out, err := Render("SOUL.md", text, vars, FromProject); err == nil || strings.Contains(out, "PRIVATE") {
+			t.Errorf("project template %q read a file outside the project: %q", text, out)
+		}
+	}
+	if out, _ := Render("SOUL.md", `{{if exists "~/.ssh/id_rsa"}}found{{end}}`, vars, FromProject); out != "" {
+		t.Errorf("exists reached outside the project: %q", out)
+	}
+	if out, err := Render("SOUL.md", `{{include "docs/style.md"}}`, vars, FromProject); err != nil || out != "tabs" {
+		t.Errorf("include inside the project = %q, %v", out, err)
+	}
+
+	// User templates may read the home directory, but a project file they include
+	// is still limited to the project.
+	if out, err := Render("SOUL.md", `{{include "~/.ssh/id_rsa"}}`, vars, FromUser); err != nil || out != "PRIVATE KEY" {
+		t.Errorf("user template include = %q, %v", out, err)
+	}
+	if _, err := Render("SOUL.md", `{{include "docs/leak.md"}}`, vars, FromUser); err == nil {
+		t.Error("a project file included by a user template should not reach the home directory")
+	}
+
+	if OriginOf(filepath.Join(root, ".devagent", "SOUL.md"), root) != FromProject || OriginOf(filepath.Join(home, ".devagent", "SOUL.md"), root) != FromUser {
+		t.Error("OriginOf: wrong origin")
+	}
+}
+
+func TestDetectLanguages(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"main.go", "a/b.go", "a/c.go", "web/app.ts", "web/app.test.ts", "tools/x.py", "README.md", "vendor/dep/d.rs"} {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, nil, 0644)
+	}
+	if got := strings.Join(DetectLanguages(root), ","); got != "Go,TypeScript,Python" {
+		t.Errorf("DetectLanguages = %s, want Go,TypeScript,Python", got)
+	}
+}
+
+func TestGoModule(t *testing.T) {
+	root := t.TempDir()
+	if got := GoModule(root); got != "" {
+		t.Errorf("GoModule without go.mod = %q", got)
+	}
+	os.WriteFile(filepath.Join(root, "go.mod"), []byte("// comment\nmodule example.com/shop\n\ngo 1.22\n"), 0644)
+	if got := GoModule(root); got != "example.com/shop" {
+		t.Errorf("GoModule = %q, want example.com/shop", got)
+	}
+}
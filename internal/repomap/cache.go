@@ -0,0 +1,69 @@
+package repomap
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// cacheVersion changes whenever the cached entries would be extracted differently.
+const cacheVersion = 1
+
+// entry is what the map keeps per source file; it is reused while the file's
+// modification time and size are unchanged.
+type entry struct {
+	ModTime int64    `json:"mtime"`
+	Size    int64    `json:"size"`
+	Symbols []symbol `json:"symbols,omitempty"`
+	Idents  []string `json:"idents,omitempty"` // identifiers used in the file, for ranking
+}
+
+type symbol struct {
+	Name      string `json:"name"` // unqualified: "Run" for "Agent.Run"
+	Signature string `json:"sig"`
+	Line      int    `json:"line"`
+	Depth     int    `json:"depth,omitempty"`
+}
+
+type cacheFile struct {
+	Version int               `json:"version"`
+	Files   map[string]*entry `json:"files"`
+}
+
+// loadCache reads the cache at path. A missing, unreadable or outdated cache is empty.
+func loadCache(path string) map[string]*entry {
+	files := make(map[string]*entry)
+	if path == "" {
+		return files
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return files
+	}
+	var c cacheFile
+	if json.Unmarshal(data, &c) != nil || c.Version != cacheVersion || c.Files == nil {
+		return files
+	}
+	return c.Files
+}
+
+// saveCache writes the entries to path through a temporary file.
+func saveCache(path string, files map[string]*entry) error {
+	data, err := json.Marshal(cacheFile{Version: cacheVersion, Files: files})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// The cache is local state; keep it out of the project's git status.
+	ignore := filepath.Join(filepath.Dir(path), ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,63 @@
+package repomap
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuild_Cache(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.go": "package a\n\nfunc Alpha() {}\n", "b.go": "package a\n\nfunc Beta() {}\n"})
+	cache := filepath.Join(t.TempDir(), "cache", "repomap.json")
+	if _, err := Build(dir, Options{CacheFile: cache}); err != nil {
+		t.Fatal(err)
+	}
+	files := loadCache(cache)
+	if len(files) != 2 || files["a.go"].Symbols[0].Name != "Alpha" {
+		t.Fatalf("cache = %+v", files)
+	}
+	if data, err := os.ReadFile(filepath.Join(filepath.Dir(cache), ".gitignore")); err != nil || string(data) != "*\n" {
+		t.Errorf("cache dir .gitignore = %q, %v", data, err)
+	}
+
+	// Unchanged files come from the cache: a doctored entry shows up in the map.
+	files["a.go"].Symbols[0].Signature = "func FromCache()"
+	if err := saveCache(cache, files); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := Build(dir, Options{CacheFile: cache})
+	if !strings.Contains(m, "func FromCache()") {
+		t.Errorf("cache not used:\n%s", m)
+	}
+
+	// A modified file is parsed again; a deleted one leaves the cache.
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nfunc Gamma() {}\n"), 0644)
+	os.Chtimes(filepath.Join(dir, "a.go"), time.Now(), time.Now().Add(time.Hour))
+	os.Remove(filepath.Join(dir, "b.go"))
+	m, _ = Build(dir, Options{CacheFile: cache})
+	if !strings.Contains(m, "func Gamma()") || strings.Contains(m, "FromCache") {
+		t.Errorf("changed file not parsed again:\n%s", m)
+	}
+	if files := loadCache(cache); len(files) != 1 {
+		t.Errorf("cache after delete has %d entries", len(files))
+	}
+}
+
+func TestLoadCache_Invalid(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "repomap.json")
+	if files := loadCache(p); len(files) != 0 {
+		t.Errorf("missing cache = %v", files)
+	}
+	os.WriteFile(p, []byte("{not json"), 0644)
+	if files := loadCache(p); len(files) != 0 {
+		t.Errorf("corrupt cache = %v", files)
+	}
+	data, _ := json.Marshal(cacheFile{Version: cacheVersion + 1, Files: map[string]*entry{"a.go": {}}})
+	os.WriteFile(p, data, 0644)
+	if files := loadCache(p); len(files) != 0 {
+		t.Errorf("cache of another version = %v", files)
+	}
+}
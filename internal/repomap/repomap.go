@@ -0,0 +1,376 @@
+// Package repomap builds a ranked, size-limited map of a repository: its most
+// important files with their top-level declarations. It is shown to the model at
+// the start of a run so it knows where things live before reading any file.
+package repomap
+
+import (
+	"devagent/internal/codenav"
+	"devagent/internal/ignore"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultTokens is the map's size budget when none is configured.
+	DefaultTokens = 2048
+
+	bytesPerToken     = 4 // rough estimate for code and paths
+	maxParsedFiles    = 5000
+	maxFileSize       = 1 << 20
+	maxSymbolsPerFile = 12
+	maxCommonDefs     = 20 // names declared in more files than this carry no ranking signal
+	maxOmittedDirs    = 8
+)
+
+// Options controls Build.
+type Options struct {
+	Tokens    int    // approximate size budget (default DefaultTokens)
+	Focus     string // text such as the task; files and symbols it names rank higher
+	CacheFile string // where parsed files are cached between runs ("" disables the cache)
+}
+
+// file is a candidate for the map.
+type file struct {
+	rel   string
+	e     *entry // nil for files without symbols (docs, configs, ...)
+	score float64
+}
+
+func (f *file) symbols() []symbol {
+	if f.e == nil {
+		return nil
+	}
+	return f.e.Symbols
+}
+
+// Build walks root (respecting ignore rules), ranks its files and renders the map
+// within the token budget.
+func Build(root string, opts Options) (string, error) {
+	if opts.Tokens <= 0 {
+		opts.Tokens = DefaultTokens
+	}
+	files, err := scan(root, opts.CacheFile)
+	if err != nil {
+		return "", err
+	}
+	rank(files, opts.Focus)
+	return render(files, opts.Tokens*bytesPerToken), nil
+}
+
+// scan lists the files under root and extracts the symbols of source files, reusing
+// cached entries for files whose modification time and size are unchanged.
+func scan(root, cachePath string) ([]*file, error) {
+	cache := loadCache(cachePath)
+	current := make(map[string]*entry)
+	changed := false
+	var files []*file
+	err := ignore.New(root).Walk(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		f := &file{rel: filepath.ToSlash(rel)}
+		files = append(files, f)
+		if !codenav.IsSource(f.rel) || len(current) >= maxParsedFiles {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		e := cache[f.rel]
+		if e == nil || e.ModTime != info.ModTime().UnixNano() || e.Size != info.Size() {
+			if e = parseFile(p, f.rel); e == nil {
+				return nil
+			}
+			e.ModTime, e.Size = info.ModTime().UnixNano(), info.Size()
+			changed = true
+		}
+		current[f.rel] = e
+		f.e = e
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cachePath != "" && (changed || len(current) != len(cache)) {
+		// The cache only saves time; a failed write is retried on the next run.
+		saveCache(cachePath, current)
+	}
+	return files, nil
+}
+
+var identRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{2,}`)
+
+// parseFile extracts the map symbols and the identifiers used in a source file.
+func parseFile(abs, rel string) *entry {
+	src, err := os.ReadFile(abs)
+	if err != nil {
+		return nil
+	}
+	syms, err := codenav.ParseSymbols(rel, src)
+	if err != nil && syms == nil && !strings.HasSuffix(rel, ".go") {
+		return nil // binary
+	}
+	e := &entry{}
+	isGo := strings.HasSuffix(rel, ".go")
+	for _, s := range syms {
+		if !mapped(s, isGo) {
+			continue
+		}
+		name := s.Name[strings.LastIndex(s.Name, ".")+1:]
+		e.Symbols = append(e.Symbols, symbol{Name: name, Signature: s.Signature, Line: s.Line, Depth: s.Depth})
+	}
+	seen := make(map[string]bool)
+	for _, id := range identRe.FindAll(src, -1) {
+		if s := string(id); !seen[s] {
+			seen[s] = true
+			e.Idents = append(e.Idents, s)
+		}
+	}
+	sort.Strings(e.Idents)
+	return e
+}
+
+// mapped reports whether a declaration belongs in the map: exported top-level
+// functions and types, and the methods of exported types. Fields, variables and
+// constants are left to list_symbols.
+func mapped(s codenav.Symbol, isGo bool) bool {
+	if s.Depth > 1 || !s.Exported {
+		return false
+	}
+	switch s.Kind {
+	case "field", "var", "const":
+		return false
+	}
+	if isGo && s.Depth == 1 {
+		owner, _, _ := strings.Cut(s.Name, ".")
+		r, _ := utf8.DecodeRuneInString(owner)
+		return unicode.IsUpper(r)
+	}
+	return true
+}
+
+// wellKnown files describe a project and rank high even without symbols.
+var wellKnown = map[string]bool{
+	"readme": true, "readme.md": true, "go.mod": true, "package.json": true, "makefile": true,
+	"pyproject.toml": true, "setup.py": true, "cargo.toml": true, "dockerfile": true,
+	"main.go": true, "agents.md": true, "contributing.md": true, "tsconfig.json": true,
+}
+
+// rank scores files: a file is important when other files use the names it
+// declares (weighted down for names declared in many places), when it is a
+// well-known project file, or when the focus text mentions it or its symbols.
+// Tests and deeply nested files score lower.
+func rank(files []*file, focus string) {
+	defs := make(map[string][]*file)
+	for _, f := range files {
+		for _, s := range f.symbols() {
+			if ds := defs[s.Name]; len(ds) == 0 || ds[len(ds)-1] != f {
+				defs[s.Name] = append(ds, f)
+			}
+		}
+	}
+	for _, g := range files {
+		if g.e == nil {
+			continue
+		}
+		contrib := make(map[*file]float64)
+		for _, id := range g.e.Idents {
+			ds := defs[id]
+			if len(ds) == 0 || len(ds) > maxCommonDefs {
+				continue
+			}
+			for _, f := range ds {
+				if f != g {
+					contrib[f] += 1 / float64(len(ds))
+				}
+			}
+		}
+		weight := 1.0
+		if isTest(g.rel) {
+			weight = 0.5
+		}
+		for f, c := range contrib {
+			f.score += weight * math.Sqrt(c)
+		}
+	}
+
+	words := focusWords(focus)
+	for _, f := range files {
+		if n := len(f.symbols()); n > 0 {
+			f.score += 1 + math.Min(float64(n), 20)/20
+		}
+		if wellKnown[strings.ToLower(path.Base(f.rel))] {
+			if strings.Contains(f.rel, "/") {
+				f.score += 2
+			} else {
+				f.score += 10 // the project's own README, go.mod, ...
+			}
+		}
+		if isTest(f.rel) {
+			f.score *= 0.3
+		}
+		f.score /= 1 + 0.1*float64(strings.Count(f.rel, "/"))
+		if mentions(f, focus, words) {
+			f.score = f.score*3 + 5
+		}
+	}
+}
+
+// focusWords returns the distinct lower-case words of at least three letters in s.
+func focusWords(s string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range identRe.FindAllString(s, -1) {
+		words[strings.ToLower(w)] = true
+	}
+	return words
+}
+
+// mentions reports whether the focus text names f: its path, its base name or
+// directory, or one of its symbols.
+func mentions(f *file, focus string, words map[string]bool) bool {
+	if len(words) == 0 {
+		return false
+	}
+	if strings.Contains(focus, f.rel) {
+		return true
+	}
+	base := strings.ToLower(path.Base(f.rel))
+	if words[strings.TrimSuffix(base, path.Ext(base))] {
+		return true
+	}
+	for _, s := range f.symbols() {
+		if len(s.Name) > 3 && words[strings.ToLower(s.Name)] {
+			return true
+		}
+	}
+	return false
+}
+
+func isTest(rel string) bool {
+	base := path.Base(rel)
+	return strings.HasSuffix(base, "_test.go") || strings.HasPrefix(base, "test_") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasPrefix(rel, "test/") || strings.HasPrefix(rel, "tests/") || strings.Contains(rel, "/tests/")
+}
+
+// render picks files in rank order while they fit in budget bytes (dropping a
+// file's symbols when only its path fits) and prints them sorted by path.
+func render(files []*file, budget int) string {
+	ranked := append([]*file(nil), files...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].rel < ranked[j].rel
+	})
+
+	blocks := make(map[string]string)
+	used := 0
+	var omitted []string
+	for _, f := range ranked {
+		full := fileBlock(f)
+		switch {
+		case used+len(full) <= budget:
+			blocks[f.rel] = full
+			used += len(full)
+		case used+len(f.rel)+1 <= budget:
+			blocks[f.rel] = f.rel + "\n"
+			used += len(f.rel) + 1
+		default:
+			omitted = append(omitted, f.rel)
+		}
+	}
+	if len(blocks) == 0 && len(omitted) == 0 {
+		return "(no files)\n"
+	}
+
+	shown := make([]string, 0, len(blocks))
+	for rel := range blocks {
+		shown = append(shown, rel)
+	}
+	sort.Strings(shown)
+	var sb strings.Builder
+	for _, rel := range shown {
+		sb.WriteString(blocks[rel])
+	}
+	if len(omitted) > 0 {
+		sb.WriteString(omittedSummary(omitted))
+	}
+	return sb.String()
+}
+
+// fileBlock is a file's path followed by its indented declarations.
+func fileBlock(f *file) string {
+	syms := f.symbols()
+	if len(syms) == 0 {
+		return f.rel + "\n"
+	}
+	var sb strings.Builder
+	sb.WriteString(f.rel + ":\n")
+	isGo := strings.HasSuffix(f.rel, ".go")
+	for i, s := range syms {
+		if i == maxSymbolsPerFile {
+			sb.WriteString(fmt.Sprintf("  ... %d more\n", len(syms)-i))
+			break
+		}
+		indent := "  "
+		if !isGo || !strings.HasPrefix(s.Signature, "func ") {
+			// Nested in the source; Go methods are declared at the top level.
+			indent += strings.Repeat("  ", s.Depth)
+		}
+		sb.WriteString(indent + s.Signature + "\n")
+	}
+	return sb.String()
+}
+
+// omittedSummary counts the files left out by top-level directory.
+func omittedSummary(omitted []string) string {
+	counts := make(map[string]int)
+	for _, rel := range omitted {
+		dir, _, found := strings.Cut(rel, "/")
+		if !found {
+			dir = "."
+		}
+		counts[dir]++
+	}
+	dirs := make([]string, 0, len(counts))
+	for d := range counts {
+		dirs = append(dirs, d)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if counts[dirs[i]] != counts[dirs[j]] {
+			return counts[dirs[i]] > counts[dirs[j]]
+		}
+		return dirs[i] < dirs[j]
+	})
+	var parts []string
+	for i, d := range dirs {
+		if i == maxOmittedDirs {
+			parts = append(parts, "...")
+			break
+		}
+		name := d + "/"
+		if d == "." {
+			name = "top level"
+		}
+		parts = append(parts, fmt.Sprintf("%s (%d)", name, counts[d]))
+	}
+	return fmt.Sprintf("... %d more files: %s\n", len(omitted), strings.Join(parts, ", "))
+}
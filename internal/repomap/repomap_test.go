@@ -0,0 +1,147 @@
+package repomap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var project = map[string]string{
+	"go.mod":    "module example.com/app\n",
+	"README.md": "# app\n",
+	"store/store.go": `package store
+
+type Store struct{ items map[string]string }
+
+func Open(path string) (*Store, error) { return &Store{}, nil }
+
+func (s *Store) Get(key string) string { return s.items[key] }
+
+func (s *Store) put(key string) {}
+
+type cursor struct{}
+
+func (c cursor) Next() bool { return false }
+
+const Version = 1
+`,
+	"api/handler.go":      "package api\n\nimport \"example.com/app/store\"\n\nfunc Handle(s *store.Store) { s.Get(\"x\") }\n",
+	"api/routes.go":       "package api\n\nfunc Routes() { Handle(nil) }\n",
+	"cmd/app/main.go":     "package main\n\nfunc main() { store.Open(\"db\"); api.Routes() }\n",
+	"tools/lonely.go":     "package tools\n\nfunc Lonely() {}\n",
+	"web/app.py":          "class Greeter:\n    def greet(self):\n        pass\n\n    def _hidden(self):\n        pass\n\ndef _private():\n    pass\n",
+	"store/store_test.go": "package store\n\nimport \"testing\"\n\nfunc TestGet(t *testing.T) { Open(\"\") }\n",
+}
+
+func TestBuild(t *testing.T) {
+	dir := writeFiles(t, project)
+	m, err := Build(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Exported declarations only, Go methods unindented, other languages nested.
+	for _, want := range []string{
+		"store/store.go:\n  type Store struct\n  func Open(path string) (*Store, error)\n  func (s *Store) Get(key string) string\n",
+		"web/app.py:\n  class Greeter\n    def greet(self)\n",
+		"README.md\n", "go.mod\n", "cmd/app/main.go\n",
+	} {
+		if !strings.Contains(m, want) {
+			t.Errorf("map missing %q:\n%s", want, m)
+		}
+	}
+	for _, hidden := range []string{"put", "cursor", "Next", "Version", "_hidden", "_private"} {
+		if strings.Contains(m, hidden) {
+			t.Errorf("map should not contain %s:\n%s", hidden, m)
+		}
+	}
+	// Sorted by path.
+	if strings.Index(m, "api/handler.go") > strings.Index(m, "store/store.go") {
+		t.Errorf("map not sorted:\n%s", m)
+	}
+}
+
+func TestRank(t *testing.T) {
+	dir := writeFiles(t, project)
+	files, err := scan(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rank(files, "")
+	score := map[string]float64{}
+	for _, f := range files {
+		score[f.rel] = f.score
+	}
+	// store.go is used by three files, lonely.go by none.
+	if score["store/store.go"] <= score["tools/lonely.go"] || score["store/store.go"] <= score["api/routes.go"] {
+		t.Errorf("scores = %v", score)
+	}
+	if score["store/store_test.go"] >= score["store/store.go"] {
+		t.Errorf("test file outranks its package: %v", score)
+	}
+	if score["README.md"] <= score["tools/lonely.go"] {
+		t.Errorf("README ranks below an unused file: %v", score)
+	}
+
+	// The focus text lifts the files it names.
+	rank(files, "make Lonely() faster")
+	for _, f := range files {
+		if f.rel == "tools/lonely.go" && f.score <= score["store/store.go"] {
+			t.Errorf("focus not applied: lonely=%.2f store=%.2f", f.score, score["store/store.go"])
+		}
+	}
+}
+
+func TestBuild_Budget(t *testing.T) {
+	dir := writeFiles(t, project)
+	m, err := Build(dir, Options{Tokens: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) > 25*bytesPerToken+120 {
+		t.Errorf("map of %d bytes exceeds the budget:\n%s", len(m), m)
+	}
+	if !strings.Contains(m, "README.md") || !strings.Contains(m, "more files: ") {
+		t.Errorf("small map =\n%s", m)
+	}
+	// Omitted files are counted by top-level directory.
+	if !strings.Contains(m, "tools/ (1)") {
+		t.Errorf("omitted directories not summarized:\n%s", m)
+	}
+}
+
+func TestBuild_IgnoreRules(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".gitignore":           "*.log\nbuild/\n",
+		"main.go":              "package main\n\nfunc Main() {}\n",
+		"app.log":              "x\n",
+		"build/out.go":         "package out\n",
+		"node_modules/x/i.js":  "function x() {}\n",
+		".github/workflows/ci": "on: push\n",
+	})
+	m, err := Build(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(m, "app.log") || strings.Contains(m, "build/") || strings.Contains(m, "node_modules") {
+		t.Errorf("ignored files in map:\n%s", m)
+	}
+	if !strings.Contains(m, ".github/workflows/ci") || !strings.Contains(m, ".gitignore") {
+		t.Errorf("dotfiles missing:\n%s", m)
+	}
+}
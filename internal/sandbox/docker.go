@@ -3,11 +3,15 @@ package sandbox
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -67,6 +71,13 @@ func hashWorkDir(workDir string) string {
 	return fmt.Sprintf("%x", h[:6])
 }
 
+// ownGroup puts cmd in its own process group, so the SIGINT the terminal sends on
+// Ctrl-C (which may only pause the agent) does not reach the docker client.
+func ownGroup(cmd *exec.Cmd) *exec.Cmd {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
 // ContainerName returns the deterministic container name for this project.
 func (d *DockerExecutor) ContainerName() string {
 	return d.containerName
@@ -76,7 +87,7 @@ func (d *DockerExecutor) ContainerName() string {
 func DockerAvailable() bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "docker", "info")
+	cmd := ownGroup(exec.CommandContext(ctx, "docker", "info"))
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 	return cmd.Run() == nil
@@ -86,7 +97,7 @@ func DockerAvailable() bool {
 func (d *DockerExecutor) containerStatus() string {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "docker", "inspect", "-f", "{{.State.Status}}", d.containerName)
+	cmd := ownGroup(exec.CommandContext(ctx, "docker", "inspect", "-f", "{{.State.Status}}", d.containerName))
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = nil
@@ -154,7 +165,7 @@ func (d *DockerExecutor) createContainer() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 	args := d.CreateArgs()
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := ownGroup(exec.CommandContext(ctx, "docker", args...))
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
@@ -166,7 +177,7 @@ func (d *DockerExecutor) createContainer() error {
 func (d *DockerExecutor) startContainer() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "docker", "start", d.containerName)
+	cmd := ownGroup(exec.CommandContext(ctx, "docker", "start", d.containerName))
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
@@ -178,7 +189,7 @@ func (d *DockerExecutor) startContainer() error {
 func (d *DockerExecutor) removeContainer() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "docker", "rm", "-f", d.containerName)
+	cmd := ownGroup(exec.CommandContext(ctx, "docker", "rm", "-f", d.containerName))
 	return cmd.Run()
 }
 
@@ -188,7 +199,7 @@ func (d *DockerExecutor) Stop() {
 	defer d.mu.Unlock()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "docker", "stop", d.containerName)
+	cmd := ownGroup(exec.CommandContext(ctx, "docker", "stop", d.containerName))
 	_ = cmd.Run()
 }
 
@@ -199,49 +210,143 @@ func (d *DockerExecutor) Cleanup() {
 	_ = d.removeContainer()
 }
 
-// Execute runs a command inside the persistent container via docker exec.
-func (d *DockerExecutor) Execute(command string) (output string, exitCode int, err error) {
+// SessionCommand returns a "docker exec -i" command running a bash in the container
+// that reads commands from stdin, for persistent shell sessions. Like a background job,
+// the bash runs in its own process group whose id is recorded under name, so
+// KillSession can stop the commands it started: killing the local docker client
+// alone would leave them running in the container.
+func (d *DockerExecutor) SessionCommand(name string) (*exec.Cmd, error) {
 	if err := d.EnsureRunning(); err != nil {
-		return "", -1, err
+		return nil, err
 	}
+	script := groupScript(sessionPidFile(name), "bash --noprofile --norc <&0")
+	return exec.Command("docker", "exec", "-i", "-w", "/workspace", d.containerName, "bash", "-c", script), nil
+}
 
-	timeout := d.Timeout
-	if timeout == 0 {
-		timeout = 5 * time.Minute
+// KillSession kills the process group of a session started with SessionCommand.
+func (d *DockerExecutor) KillSession(name string) {
+	d.killGroup(sessionPidFile(name), false)
+}
+
+// BackgroundCommand returns a "docker exec" command that runs command as a job in its
+// own process group inside the container, recording the group id so KillBackground can
+// stop it (killing the local docker client alone would leave it running).
+func (d *DockerExecutor) BackgroundCommand(name, command string) (*exec.Cmd, error) {
+	if err := d.EnsureRunning(); err != nil {
+		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return exec.Command("docker", "exec", "-w", "/workspace", d.containerName, "bash", "-c", groupScript(bgPidFile(name), `bash -c "$1"`), "devagent-bg", command), nil
+}
+
+// KillBackground stops a job started with BackgroundCommand: SIGTERM, then SIGKILL
+// if it is still alive after about three seconds.
+func (d *DockerExecutor) KillBackground(name string) {
+	d.killGroup(bgPidFile(name), true)
+}
+
+// groupScript runs job in its own process group, writes the group id to pidFile while
+// it runs and exits with the job's exit code. Job control is switched off again once
+// the job started, so no job status line is added to its output.
+func groupScript(pidFile, job string) string {
+	return fmt.Sprintf(`set -m; %[2]s & echo $! > %[1]s; set +m; wait $!; rc=$?; rm -f %[1]s; exit $rc`, pidFile, job)
+}
+
+// killGroup kills the process group recorded in pidFile by groupScript, waiting
+// briefly for the file if the job is just starting. With graceful set it sends
+// SIGTERM first and SIGKILL only if the group is still alive after about three seconds.
+func (d *DockerExecutor) killGroup(pidFile string, graceful bool) {
+	term := ""
+	if graceful {
+		term = `kill -TERM -- -$p 2>/dev/null
+for i in 1 2 3 4 5 6; do kill -0 -- -$p 2>/dev/null || break; sleep 0.5; done
+`
+	}
+	script := fmt.Sprintf(`for i in 1 2 3 4 5 6 7 8 9 10; do [ -s %[1]s ] && break; sleep 0.1; done
+p=$(cat %[1]s 2>/dev/null) || exit 0
+%[2]skill -KILL -- -$p 2>/dev/null
+rm -f %[1]s`, pidFile, term)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	_ = ownGroup(exec.CommandContext(ctx, "docker", "exec", d.containerName, "bash", "-c", script)).Run()
+}
 
-	args := []string{"exec", "-w", "/workspace", d.containerName, "bash", "-c", command}
-	cmd := exec.CommandContext(ctx, "docker", args...)
+func bgPidFile(name string) string {
+	return "/tmp/devagent-bg-" + name + ".pid"
+}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+func sessionPidFile(name string) string {
+	return "/tmp/devagent-sh-" + name + ".pid"
+}
 
-	runErr := cmd.Run()
+func runPidFile(name string) string {
+	return "/tmp/devagent-run-" + name + ".pid"
+}
 
-	var sb strings.Builder
-	if stdout.Len() > 0 {
-		sb.WriteString(stdout.String())
+// Execute runs a command inside the persistent container via docker exec.
+func (d *DockerExecutor) Execute(command string) (output string, exitCode int, err error) {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
 	}
-	if stderr.Len() > 0 {
+	stdout, stderr, exitCode, err := d.Run(context.Background(), command, timeout, nil)
+
+	var sb strings.Builder
+	sb.WriteString(stdout)
+	if stderr != "" {
 		if sb.Len() > 0 {
 			sb.WriteString("\n")
 		}
 		sb.WriteString("[stderr]\n")
-		sb.WriteString(stderr.String())
+		sb.WriteString(stderr)
+	}
+	return sb.String(), exitCode, err
+}
+
+// Run runs a command inside the persistent container and returns stdout and stderr
+// separately. A non-zero exit code is not an error; cancelling ctx stops the command.
+// If live is not nil, it also receives stdout as it arrives.
+func (d *DockerExecutor) Run(ctx context.Context, command string, timeout time.Duration, live io.Writer) (stdout, stderr string, exitCode int, err error) {
+	if err := d.EnsureRunning(); err != nil {
+		return "", "", -1, err
 	}
-	output = sb.String()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The command runs in its own process group in the container, which is killed on
+	// timeout or cancel: killing the local docker client alone would leave it running.
+	b := make([]byte, 6)
+	rand.Read(b)
+	pidFile := runPidFile(hex.EncodeToString(b))
+	args := []string{"exec", "-w", "/workspace", d.containerName, "bash", "-c", groupScript(pidFile, `bash -c "$1"`), "devagent-run", command}
+	cmd := ownGroup(exec.CommandContext(ctx, "docker", args...))
+	cmd.Cancel = func() error {
+		d.killGroup(pidFile, false)
+		return cmd.Process.Kill()
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	if live != nil {
+		cmd.Stdout = io.MultiWriter(&outBuf, live)
+	}
+	cmd.Stderr = &errBuf
+
+	runErr := cmd.Run()
+	stdout, stderr = outBuf.String(), errBuf.String()
 
 	if runErr != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return output, -1, fmt.Errorf("docker command timed out after %v", timeout)
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return stdout, stderr, -1, fmt.Errorf("docker command timed out after %v", timeout)
+		case context.Canceled:
+			return stdout, stderr, -1, fmt.Errorf("docker command interrupted")
 		}
 		if exitErr, ok := runErr.(*exec.ExitError); ok {
-			return output, exitErr.ExitCode(), nil
+			return stdout, stderr, exitErr.ExitCode(), nil
 		}
-		return output, -1, runErr
+		return stdout, stderr, -1, runErr
 	}
-	return output, 0, nil
+	return stdout, stderr, 0, nil
 }
@@ -1,9 +1,11 @@
 package sandbox
 
 import (
+	"context"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDockerConfig_DockerEnabled_Default(t *testing.T) {
@@ -234,3 +236,19 @@ func TestLoadConfig_DockerDisabled(t *testing.T) {
 		t.Error("expected docker disabled")
 	}
 }
+
+func TestDockerExecutor_Run_TimeoutKillsContainerProcesses(t *testing.T) {
+	if !DockerAvailable() {
+		t.Skip("docker not available")
+	}
+	d := NewDockerExecutor(t.TempDir(), DockerConfig{})
+	defer d.Cleanup()
+	_, _, _, err := d.Run(context.Background(), "sleep 300 & echo $! > /tmp/devagent-test-sleep.pid; wait", 2*time.Second, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	out, _, code, err := d.Run(context.Background(), "kill -0 $(cat /tmp/devagent-test-sleep.pid) && echo alive", 10*time.Second, nil)
+	if err != nil || code == 0 {
+		t.Errorf("the command kept running in the container: %q, %v", out, err)
+	}
+}
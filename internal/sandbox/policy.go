@@ -25,6 +25,18 @@ func ParseMode(s string) SandboxMode {
 	}
 }
 
+// String returns the name ParseMode accepts for m.
+func (m SandboxMode) String() string {
+	switch m {
+	case ModePermissive:
+		return "permissive"
+	case ModeStrict:
+		return "strict"
+	default:
+		return "normal"
+	}
+}
+
 // Policy holds workDir, shell policy, mode, and approval callback.
 type Policy struct {
 	Mode       SandboxMode
@@ -29,6 +29,11 @@ func NewSandbox(policy *Policy) *Sandbox {
 	return &Sandbox{policy: policy}
 }
 
+// Mode returns the policy mode in effect.
+func (s *Sandbox) Mode() SandboxMode {
+	return s.policy.Mode
+}
+
 // NewSandboxFromConfig builds a Sandbox merging default rules with optional Config.
 // CLI mode overrides config mode when non-empty.
 func NewSandboxFromConfig(workDir string, cfg *Config, cliMode string, approveFunc func(action string) bool) *Sandbox {
@@ -100,15 +105,36 @@ func regexpFromGlobLike(s string) (*regexp.Regexp, error) {
 	return regexp.Compile(GlobLikeToRegex(strings.TrimSpace(s)))
 }
 
-// Tool names that are read-only (no approval in strict mode).
+// Tool names that are read-only (no approval in strict mode). See IsReadOnly.
 var readOnlyTools = map[string]bool{
 	"read_file": true, "list_dir": true, "search_files": true, "grep": true,
+	"read_output": true, "find_definition": true, "find_references": true,
+	"list_symbols": true, "outline": true,
+	"lsp_definition": true, "lsp_references": true, "lsp_hover": true, "lsp_diagnostics": true,
+	"code_search": true,
+}
+
+// IsReadOnly reports whether a tool only reads the project. Such tools need no approval
+// in strict mode, are the only ones subagents get, and leave cached project state valid.
+func IsReadOnly(toolName string) bool {
+	return readOnlyTools[toolName]
+}
+
+// Tools that never need approval: they don't touch files or run arbitrary commands themselves.
+// Transaction tools only act on edits that were already checked when they were made.
+var approvalExemptTools = map[string]bool{
+	"done": true, "read_skill": true, "debug_code": true,
+	"begin_transaction": true, "commit_transaction": true, "rollback_transaction": true,
+	"shell_read_output": true, "shell_stop": true, "update_plan": true, "ask_user": true, "delegate": true,
 }
 
 // Tools that take a path argument (for path validation).
 var pathTools = map[string]string{
 	"read_file": "path", "write_file": "path", "str_replace": "path", "insert_line": "path",
 	"list_dir": "path", "search_files": "path", "grep": "path",
+	"list_symbols": "path", "outline": "path",
+	"lsp_definition": "path", "lsp_references": "path", "lsp_hover": "path",
+	"lsp_rename": "path", "lsp_diagnostics": "path", "code_search": "path",
 }
 
 // Check runs policy: path validation for path tools, shell risk for shell tool, and mode-based allow/approve/deny.
@@ -120,7 +146,7 @@ func (s *Sandbox) Check(toolName string, args map[string]string) CheckResult {
 	// Path tools: validate path
 	if pathArg, ok := pathTools[toolName]; ok {
 		path := args[pathArg]
-		if path == "" && (toolName == "list_dir" || toolName == "search_files" || toolName == "grep") {
+		if path == "" && (toolName == "list_dir" || toolName == "search_files" || toolName == "grep" || toolName == "list_symbols") {
 			path = "."
 		}
 		if path != "" {
@@ -135,9 +161,13 @@ func (s *Sandbox) Check(toolName string, args map[string]string) CheckResult {
 		return CheckResult{Allow: true}
 	}
 
-	// Shell: evaluate risk
-	if toolName == "shell" {
+	// Shell and background processes: evaluate risk
+	if toolName == "shell" || toolName == "shell_start" {
 		cmd := args["command"]
+		if cmd == "" && toolName == "shell" && IsTrue(args["reset"]) {
+			// Resetting the session without a command runs nothing.
+			return CheckResult{Allow: true}
+		}
 		if cmd == "" {
 			return CheckResult{Allow: false, DenyErr: fmt.Errorf("%w: empty command", ErrBlocked)}
 		}
@@ -175,8 +205,17 @@ func (s *Sandbox) Check(toolName string, args map[string]string) CheckResult {
 		}
 	}
 
-	// Other tools (write_file, str_replace, insert_line, done, read_skill, debug_code): strict mode may require approval
-	if s.policy.Mode == ModeStrict && !readOnlyTools[toolName] && toolName != "done" && toolName != "read_skill" && toolName != "debug_code" {
+	// remember with scope=user writes ~/.devagent/MEMORY.md, outside the work dir: ask unless permissive
+	if toolName == "remember" && strings.EqualFold(strings.TrimSpace(args["scope"]), "user") && s.policy.Mode != ModePermissive {
+		action := fmt.Sprintf("⚠️  Agent wants to save to your user memory (~/.devagent/MEMORY.md): %s\n   Allow? [y/N]: ", truncateForPrompt(args["fact"], 200))
+		if s.policy.ApproveFunc != nil && s.policy.ApproveFunc(action) {
+			return CheckResult{Allow: true}
+		}
+		return CheckResult{Allow: false, DenyErr: ErrNeedsApproval, ApprovalAction: action}
+	}
+
+	// Other tools (write_file, str_replace, insert_line, ...): strict mode may require approval
+	if s.policy.Mode == ModeStrict && !readOnlyTools[toolName] && !approvalExemptTools[toolName] {
 		path := args["path"]
 		action := fmt.Sprintf("⚠️  Agent wants to run: %s (path: %s)\n   Allow? [y/N]: ", toolName, path)
 		if s.policy.ApproveFunc != nil && s.policy.ApproveFunc(action) {
@@ -188,6 +227,16 @@ func (s *Sandbox) Check(toolName string, args map[string]string) CheckResult {
 	return CheckResult{Allow: true}
 }
 
+// IsTrue reports whether a flag argument of a tool is set ("true", "yes" or "1").
+// Tools parse their flags with it too, so a check and the tool agree on them.
+func IsTrue(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "yes", "1":
+		return true
+	}
+	return false
+}
+
 func truncateForPrompt(s string, max int) string {
 	runes := []rune(s)
 	if len(runes) <= max {
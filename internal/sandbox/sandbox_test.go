@@ -183,6 +183,20 @@ func TestSandbox_Check_ReadOnlyToolAllowed(t *testing.T) {
 	if !result.Allow {
 		t.Errorf("expected allowed for read_file in strict: %v", result.DenyErr)
 	}
+	for _, tool := range []string{"find_definition", "find_references", "list_symbols", "outline", "lsp_hover", "lsp_diagnostics", "code_search"} {
+		if result := sb.Check(tool, map[string]string{"name": "New", "path": "main.go"}); !result.Allow {
+			t.Errorf("expected allowed for %s in strict: %v", tool, result.DenyErr)
+		}
+	}
+	if result := sb.Check("outline", map[string]string{"path": "../../etc/passwd"}); result.Allow {
+		t.Error("expected outline outside the workdir to be blocked")
+	}
+	if result := sb.Check("code_search", map[string]string{"query": "x", "path": "../.."}); result.Allow {
+		t.Error("expected code_search outside the workdir to be blocked")
+	}
+	if result := sb.Check("lsp_rename", map[string]string{"path": "main.go", "new_name": "x"}); result.Allow {
+		t.Error("expected lsp_rename to need approval in strict")
+	}
 }
 
 func TestLoadConfig_NotFound(t *testing.T) {
@@ -272,6 +286,18 @@ func TestParseMode(t *testing.T) {
 	}
 }
 
+func TestSandboxMode_String(t *testing.T) {
+	for _, name := range []string{"permissive", "normal", "strict"} {
+		if got := ParseMode(name).String(); got != name {
+			t.Errorf("ParseMode(%q).String() = %q", name, got)
+		}
+	}
+	sb := NewSandbox(&Policy{Mode: ModeStrict})
+	if sb.Mode() != ModeStrict {
+		t.Errorf("Mode() = %v, want strict", sb.Mode())
+	}
+}
+
 func TestGlobLikeToRegex(t *testing.T) {
 	r := GlobLikeToRegex("sudo *")
 	if r == "" {
@@ -351,6 +377,29 @@ func TestSandbox_Check_StrictModeWriteToolDenied(t *testing.T) {
 	}
 }
 
+func TestSandbox_Check_StrictModeExemptTools(t *testing.T) {
+	policy := &Policy{
+		Mode:    ModeStrict,
+		WorkDir: t.TempDir(),
+		Shell:   &ShellPolicy{},
+		Path:    &PathPolicy{},
+		ApproveFunc: func(action string) bool {
+			t.Errorf("no approval expected, got %q", action)
+			return false
+		},
+	}
+	sb := NewSandbox(policy)
+	if result := sb.Check("update_plan", map[string]string{"step": "1", "status": "done"}); !result.Allow {
+		t.Error("update_plan should be allowed in strict mode")
+	}
+	if result := sb.Check("ask_user", map[string]string{"question": "Which port?"}); !result.Allow {
+		t.Error("ask_user should be allowed in strict mode")
+	}
+	if result := sb.Check("delegate", map[string]string{"task": "Find the callers of Load"}); !result.Allow {
+		t.Error("delegate should be allowed in strict mode")
+	}
+}
+
 func TestSandbox_Check_ShellRiskMediumStrict(t *testing.T) {
 	workDir := t.TempDir()
 	policy := &Policy{
@@ -439,3 +488,87 @@ func TestValidatePath_AllowOutsideWorkdirWithTilde(t *testing.T) {
 		t.Errorf("path under ~/allowed_outside_test should be allowed: %v", err)
 	}
 }
+
+func TestSandbox_Check_ShellReset(t *testing.T) {
+	policy := &Policy{
+		Mode:    ModeStrict,
+		WorkDir: t.TempDir(),
+		Shell:   &ShellPolicy{},
+		Path:    &PathPolicy{},
+	}
+	sb := NewSandbox(policy)
+	if result := sb.Check("shell", map[string]string{"reset": "true"}); !result.Allow {
+		t.Errorf("reset without a command should be allowed: %v", result.DenyErr)
+	}
+	if result := sb.Check("shell", map[string]string{"reset": "yes"}); !result.Allow {
+		t.Errorf("reset=yes without a command should be allowed: %v", result.DenyErr)
+	}
+	if result := sb.Check("shell", map[string]string{}); result.Allow {
+		t.Error("empty command should be blocked")
+	}
+}
+
+func TestSandbox_Check_ShellStartEvaluatesRisk(t *testing.T) {
+	policy := &Policy{
+		Mode:    ModeNormal,
+		WorkDir: t.TempDir(),
+		Shell: &ShellPolicy{
+			BlockPatterns:   DefaultShellBlockPatterns(),
+			ApprovePatterns: DefaultShellApprovePatterns(),
+		},
+		Path: &PathPolicy{},
+	}
+	sb := NewSandbox(policy)
+	if result := sb.Check("shell_start", map[string]string{"command": "sudo ls"}); result.Allow {
+		t.Error("shell_start should be subject to shell block patterns")
+	}
+	if result := sb.Check("shell_start", map[string]string{"command": "go run ./cmd/server"}); !result.Allow {
+		t.Errorf("expected allowed: %v", result.DenyErr)
+	}
+}
+
+func TestSandbox_Check_RememberUserScopeNeedsApproval(t *testing.T) {
+	var asked []string
+	policy := &Policy{
+		Mode:    ModeNormal,
+		WorkDir: t.TempDir(),
+		Shell:   &ShellPolicy{},
+		Path:    &PathPolicy{},
+		ApproveFunc: func(action string) bool {
+			asked = append(asked, action)
+			return false
+		},
+	}
+	sb := NewSandbox(policy)
+	if result := sb.Check("remember", map[string]string{"fact": "Run make gen first"}); !result.Allow {
+		t.Errorf("project scope should be allowed in normal mode: %v", result.DenyErr)
+	}
+	if len(asked) != 0 {
+		t.Errorf("no approval expected for project scope, got %q", asked)
+	}
+	result := sb.Check("remember", map[string]string{"fact": "Prefers tabs", "scope": "user"})
+	if result.Allow || result.DenyErr != ErrNeedsApproval {
+		t.Errorf("user scope should need approval, got %+v", result)
+	}
+	if len(asked) != 1 || !strings.Contains(asked[0], "Prefers tabs") {
+		t.Errorf("approval prompt: %q", asked)
+	}
+
+	policy.Mode = ModePermissive
+	if result := sb.Check("remember", map[string]string{"fact": "Prefers tabs", "scope": "user"}); !result.Allow {
+		t.Errorf("permissive mode should allow user scope: %v", result.DenyErr)
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	for _, name := range []string{"read_file", "grep", "find_references", "code_search", "lsp_hover"} {
+		if !IsReadOnly(name) {
+			t.Errorf("%s should be read-only", name)
+		}
+	}
+	for _, name := range []string{"write_file", "shell", "lsp_rename", "remember", "delegate"} {
+		if IsReadOnly(name) {
+			t.Errorf("%s should not be read-only", name)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Asker puts a question to the user, optionally with choices, and returns the
+// answer. An error means no answer can be had.
+type Asker func(question string, choices []string) (string, error)
+
+// AskUserTool pauses the task to ask the user a clarifying question. Without an
+// Asker (a -task run without an answers file) it fails at once, so batch runs
+// never block.
+type AskUserTool struct {
+	ask Asker
+}
+
+func (t *AskUserTool) Name() string { return "ask_user" }
+
+// SetAsker sets how questions reach the user; nil makes every question fail.
+func (t *AskUserTool) SetAsker(ask Asker) { t.ask = ask }
+
+func (t *AskUserTool) Execute(args map[string]string) Result {
+	question := strings.TrimSpace(args["question"])
+	if question == "" {
+		return Result{Success: false, Output: "ask_user requires \"question\" argument"}
+	}
+	var choices []string
+	for _, c := range strings.Split(args["choices"], "|") {
+		if c = strings.TrimSpace(c); c != "" {
+			choices = append(choices, c)
+		}
+	}
+	if t.ask == nil {
+		return Result{Success: false, Output: "No user is available to answer (non-interactive run). Make the most reasonable assumption, state it in your done summary, and continue."}
+	}
+	answer, err := t.ask(question, choices)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("No answer: %v. Make the most reasonable assumption, state it in your done summary, and continue.", err)}
+	}
+	return Result{Success: true, Output: "The user answered: " + answer}
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAskUserTool(t *testing.T) {
+	tool := &AskUserTool{}
+	if tool.Name() != "ask_user" {
+		t.Errorf("Name() = %q", tool.Name())
+	}
+	if res := tool.Execute(map[string]string{"question": "Which port?"}); res.Success || !strings.Contains(res.Output, "non-interactive") {
+		t.Errorf("without an asker: %+v", res)
+	}
+
+	var gotChoices []string
+	tool.SetAsker(func(question string, choices []string) (string, error) {
+		gotChoices = choices
+		return "8080", nil
+	})
+	if res := tool.Execute(map[string]string{}); res.Success {
+		t.Error("ask_user without a question should fail")
+	}
+	res := tool.Execute(map[string]string{"question": "Which port?", "choices": "80 | 8080 |"})
+	if !res.Success || res.Output != "The user answered: 8080" {
+		t.Errorf("Execute = %+v", res)
+	}
+	if len(gotChoices) != 2 || gotChoices[1] != "8080" {
+		t.Errorf("choices = %q", gotChoices)
+	}
+
+	tool.SetAsker(func(string, []string) (string, error) { return "", errors.New("the user declined to answer") })
+	if res := tool.Execute(map[string]string{"question": "Which port?"}); res.Success || !strings.Contains(res.Output, "declined") {
+		t.Errorf("declined: %+v", res)
+	}
+}
@@ -0,0 +1,344 @@
+package tools
+
+import (
+	"devagent/internal/sandbox"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	maxBackgroundProcs = 8
+	bgBufferSize       = 256 * 1024 // output kept per process; older output is dropped
+	maxBgRead          = 16000      // bytes returned by one shell_read_output call
+	maxBgWait          = 60 * time.Second
+	bgStopGrace        = 3 * time.Second
+)
+
+var bgNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,40}$`)
+
+// ringBuffer keeps the last size bytes written to it and counts everything ever
+// written, so readers can resume from an absolute offset and learn what was dropped.
+type ringBuffer struct {
+	mu    sync.Mutex
+	size  int
+	buf   []byte
+	total int64         // bytes ever written
+	wrote chan struct{} // signalled on every write
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size, wrote: make(chan struct{}, 1)}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.buf = append(r.buf, p...)
+	if over := len(r.buf) - r.size; over > 0 {
+		r.buf = append(r.buf[:0], r.buf[over:]...)
+	}
+	r.total += int64(len(p))
+	r.mu.Unlock()
+	select {
+	case r.wrote <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// written returns the number of bytes ever written.
+func (r *ringBuffer) written() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
+
+// readFrom returns the output written since offset pos, the new offset, and how many
+// bytes after pos were already dropped from the buffer.
+func (r *ringBuffer) readFrom(pos int64) (data []byte, next, dropped int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start := r.total - int64(len(r.buf))
+	if pos < start {
+		dropped = start - pos
+		pos = start
+	}
+	return append([]byte(nil), r.buf[pos-start:]...), r.total, dropped
+}
+
+// bgProcess is one command started with shell_start.
+type bgProcess struct {
+	name    string
+	command string
+	cmd     *exec.Cmd
+	out     *ringBuffer
+	started time.Time
+	readPos int64 // offset of the next unread output byte
+
+	done     chan struct{}
+	exitCode int
+	waitErr  error
+}
+
+func (p *bgProcess) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *bgProcess) status() string {
+	if p.running() {
+		return fmt.Sprintf("running (pid %d, started %s ago)", p.cmd.Process.Pid, time.Since(p.started).Round(time.Second))
+	}
+	if p.waitErr != nil && p.exitCode < 0 {
+		return fmt.Sprintf("exited (%v)", p.waitErr)
+	}
+	return fmt.Sprintf("exited with code %d", p.exitCode)
+}
+
+// BackgroundManager runs named long-lived processes (dev servers, watchers) for the
+// shell_start, shell_read_output and shell_stop tools. Close stops all of them.
+type BackgroundManager struct {
+	workDir string
+	docker  *sandbox.DockerExecutor // nil means direct execution
+
+	mu    sync.Mutex
+	procs map[string]*bgProcess
+	seq   int
+}
+
+func NewBackgroundManager(workDir string, docker *sandbox.DockerExecutor) *BackgroundManager {
+	return &BackgroundManager{workDir: workDir, docker: docker, procs: make(map[string]*bgProcess)}
+}
+
+// Start launches command in the background under name ("" picks a name).
+func (m *BackgroundManager) Start(name, command string) (*bgProcess, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if name == "" {
+		m.seq++
+		name = fmt.Sprintf("bg%d", m.seq)
+	}
+	if !bgNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid name %q: use letters, digits, '.', '_' or '-'", name)
+	}
+	if p, ok := m.procs[name]; ok {
+		if p.running() {
+			return nil, fmt.Errorf("a process named %q is already running; stop it first or pick another name", name)
+		}
+		delete(m.procs, name)
+	}
+	running := 0
+	for _, p := range m.procs {
+		if p.running() {
+			running++
+		}
+	}
+	if running >= maxBackgroundProcs {
+		return nil, fmt.Errorf("too many background processes (max %d); stop one first", maxBackgroundProcs)
+	}
+
+	var cmd *exec.Cmd
+	if m.docker != nil {
+		var err error
+		if cmd, err = m.docker.BackgroundCommand(name, command); err != nil {
+			return nil, err
+		}
+	} else {
+		cmd = exec.Command("bash", "-c", command)
+		cmd.Dir = m.workDir
+	}
+	// Own process group, so stopping kills the children too (e.g. a server started by npm).
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	out := newRingBuffer(bgBufferSize)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &bgProcess{name: name, command: command, cmd: cmd, out: out, started: time.Now(), done: make(chan struct{})}
+	go func() {
+		p.waitErr = cmd.Wait()
+		p.exitCode = cmd.ProcessState.ExitCode()
+		close(p.done)
+	}()
+	m.procs[name] = p
+	return p, nil
+}
+
+// Get returns the process called name.
+func (m *BackgroundManager) Get(name string) (*bgProcess, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.procs[name]
+	return p, ok
+}
+
+// List returns all processes sorted by name.
+func (m *BackgroundManager) List() []*bgProcess {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*bgProcess, 0, len(m.procs))
+	for _, p := range m.procs {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// Stop terminates a process group: SIGTERM first, SIGKILL after a grace period.
+func (m *BackgroundManager) Stop(p *bgProcess) {
+	if !p.running() {
+		return
+	}
+	if m.docker != nil {
+		m.docker.KillBackground(p.name)
+	}
+	syscall.Kill(-p.cmd.Process.Pid, syscall.SIGTERM)
+	select {
+	case <-p.done:
+	case <-time.After(bgStopGrace):
+		syscall.Kill(-p.cmd.Process.Pid, syscall.SIGKILL)
+		<-p.done
+	}
+}
+
+// Close stops every running process.
+func (m *BackgroundManager) Close() {
+	var wg sync.WaitGroup
+	for _, p := range m.List() {
+		wg.Add(1)
+		go func(p *bgProcess) {
+			defer wg.Done()
+			m.Stop(p)
+		}(p)
+	}
+	wg.Wait()
+}
+
+// ShellStartTool starts a long-running command without waiting for it to exit.
+type ShellStartTool struct {
+	bg *BackgroundManager
+}
+
+func (t *ShellStartTool) Name() string { return "shell_start" }
+
+func (t *ShellStartTool) Execute(args map[string]string) Result {
+	command := strings.TrimSpace(args["command"])
+	if command == "" {
+		return Result{Success: false, Output: "empty command"}
+	}
+	p, err := t.bg.Start(strings.TrimSpace(args["name"]), command)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("cannot start process: %v", err)}
+	}
+	// Give fast failures (bad command, port in use) a moment to show up.
+	select {
+	case <-p.done:
+	case <-time.After(500 * time.Millisecond):
+	}
+	out := readNew(p, maxBgRead)
+	return Result{
+		Success: p.running() || p.exitCode == 0,
+		Output:  fmt.Sprintf("Started %q: %s\nStatus: %s\n%s", p.name, command, p.status(), out),
+	}
+}
+
+// Close stops all background processes; the registry calls it when a run ends.
+func (t *ShellStartTool) Close() { t.bg.Close() }
+
+// ShellReadOutputTool returns new output from a background process.
+type ShellReadOutputTool struct {
+	bg *BackgroundManager
+}
+
+func (t *ShellReadOutputTool) Name() string { return "shell_read_output" }
+
+func (t *ShellReadOutputTool) Execute(args map[string]string) Result {
+	name := strings.TrimSpace(args["name"])
+	if name == "" {
+		list := t.bg.List()
+		if len(list) == 0 {
+			return Result{Success: true, Output: "no background processes"}
+		}
+		var sb strings.Builder
+		for _, p := range list {
+			sb.WriteString(fmt.Sprintf("%s: %s — %s\n", p.name, p.status(), p.command))
+		}
+		return Result{Success: true, Output: sb.String()}
+	}
+	p, ok := t.bg.Get(name)
+	if !ok {
+		return Result{Success: false, Output: fmt.Sprintf("no background process named %q", name)}
+	}
+	wait, err := intArg(args, "wait", 0, int(maxBgWait/time.Second))
+	if err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	if isTrue(args["all"]) {
+		p.readPos = 0
+	}
+	if wait > 0 {
+		// Wait until new output arrives or the process exits.
+		timeout := time.After(time.Duration(wait) * time.Second)
+	waitLoop:
+		for p.out.written() == p.readPos && p.running() {
+			select {
+			case <-p.out.wrote:
+			case <-p.done:
+			case <-timeout:
+				break waitLoop
+			}
+		}
+	}
+	return Result{Success: true, Output: fmt.Sprintf("Status: %s\n%s", p.status(), readNew(p, maxBgRead))}
+}
+
+// readNew returns the unread output of p (at most limit bytes, keeping the newest).
+func readNew(p *bgProcess, limit int) string {
+	data, next, dropped := p.out.readFrom(p.readPos)
+	p.readPos = next
+	var sb strings.Builder
+	if dropped > 0 {
+		sb.WriteString(fmt.Sprintf("... (%d bytes of older output dropped)\n", dropped))
+	}
+	if len(data) > limit {
+		sb.WriteString(fmt.Sprintf("... (%d bytes skipped)\n", len(data)-limit))
+		data = data[len(data)-limit:]
+	}
+	if len(data) == 0 {
+		sb.WriteString("(no new output)")
+	}
+	sb.Write(data)
+	return sb.String()
+}
+
+// ShellStopTool stops a background process.
+type ShellStopTool struct {
+	bg *BackgroundManager
+}
+
+func (t *ShellStopTool) Name() string { return "shell_stop" }
+
+func (t *ShellStopTool) Execute(args map[string]string) Result {
+	name := strings.TrimSpace(args["name"])
+	p, ok := t.bg.Get(name)
+	if !ok {
+		return Result{Success: false, Output: fmt.Sprintf("no background process named %q", name)}
+	}
+	wasRunning := p.running()
+	t.bg.Stop(p)
+	out := readNew(p, maxBgRead)
+	if !wasRunning {
+		return Result{Success: true, Output: fmt.Sprintf("%q had already %s\n%s", name, p.status(), out)}
+	}
+	return Result{Success: true, Output: fmt.Sprintf("Stopped %q\n%s", name, out)}
+}
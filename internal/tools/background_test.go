@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRingBuffer(t *testing.T) {
+	r := newRingBuffer(8)
+	r.Write([]byte("hello"))
+	data, next, dropped := r.readFrom(0)
+	if string(data) != "hello" || next != 5 || dropped != 0 {
+		t.Fatalf("readFrom(0) = %q %d %d", data, next, dropped)
+	}
+	r.Write([]byte(" world"))
+	data, next, dropped = r.readFrom(5)
+	if string(data) != " world" || next != 11 || dropped != 0 {
+		t.Errorf("readFrom(5) = %q %d %d", data, next, dropped)
+	}
+	r.Write([]byte("!!!!!!!!!!"))
+	data, _, dropped = r.readFrom(11)
+	if string(data) != "!!!!!!!!" || dropped != 2 {
+		t.Errorf("after overflow = %q dropped %d", data, dropped)
+	}
+}
+
+func TestBackgroundTools(t *testing.T) {
+	bg := NewBackgroundManager(t.TempDir(), nil)
+	start := &ShellStartTool{bg: bg}
+	read := &ShellReadOutputTool{bg: bg}
+	stop := &ShellStopTool{bg: bg}
+	t.Cleanup(start.Close)
+
+	r := start.Execute(map[string]string{"name": "ticker", "command": "echo ready; while true; do sleep 0.1; echo tick; done"})
+	if !r.Success || !strings.Contains(r.Output, "ready") || !strings.Contains(r.Output, "running") {
+		t.Fatalf("start: %+v", r)
+	}
+	if r := start.Execute(map[string]string{"name": "ticker", "command": "true"}); r.Success {
+		t.Error("duplicate name should fail")
+	}
+
+	r = read.Execute(map[string]string{"name": "ticker", "wait": "5"})
+	if !strings.Contains(r.Output, "tick") || strings.Contains(r.Output, "ready") {
+		t.Errorf("read should return only new output: %q", r.Output)
+	}
+	if r := read.Execute(map[string]string{}); !strings.Contains(r.Output, "ticker: running") {
+		t.Errorf("list: %q", r.Output)
+	}
+
+	r = stop.Execute(map[string]string{"name": "ticker"})
+	if !r.Success || !strings.Contains(r.Output, "Stopped") {
+		t.Errorf("stop: %+v", r)
+	}
+	if r := read.Execute(map[string]string{"name": "ticker"}); !strings.Contains(r.Output, "exited") {
+		t.Errorf("status after stop: %q", r.Output)
+	}
+}
+
+func TestBackgroundTools_ExitAndClose(t *testing.T) {
+	bg := NewBackgroundManager(t.TempDir(), nil)
+	start := &ShellStartTool{bg: bg}
+	read := &ShellReadOutputTool{bg: bg}
+
+	r := start.Execute(map[string]string{"command": "echo oops >&2; exit 4"})
+	if r.Success || !strings.Contains(r.Output, `"bg1"`) || !strings.Contains(r.Output, "exited with code 4") || !strings.Contains(r.Output, "oops") {
+		t.Errorf("failing start: %+v", r)
+	}
+
+	start.Execute(map[string]string{"name": "sleeper", "command": "sleep 30"})
+	p, _ := bg.Get("sleeper")
+	done := make(chan struct{})
+	go func() { start.Close(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close did not stop the process")
+	}
+	if p.running() {
+		t.Error("process still running after Close")
+	}
+	if r := read.Execute(map[string]string{"name": "missing"}); r.Success {
+		t.Error("unknown name should fail")
+	}
+}
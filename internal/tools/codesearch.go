@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"devagent/internal/codesearch"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultCodeResults = 8
+	maxCodeResults     = 30
+	maxCodeLabel       = 120
+)
+
+// CodeSearchTool ranks chunks of code (functions, types, sections of docs) for a
+// natural-language query. The index is kept under .devagent/index/ and updated from
+// file modification times before each search.
+type CodeSearchTool struct {
+	workDir string
+	index   *codesearch.Index
+}
+
+func NewCodeSearchTool(workDir string) *CodeSearchTool {
+	return &CodeSearchTool{
+		workDir: workDir,
+		index:   codesearch.Open(workDir, filepath.Join(workDir, ".devagent", "index")),
+	}
+}
+
+func (t *CodeSearchTool) Name() string { return "code_search" }
+
+func (t *CodeSearchTool) Execute(args map[string]string) Result {
+	query := strings.TrimSpace(args["query"])
+	if query == "" {
+		return Result{Success: false, Output: "empty query"}
+	}
+	limit, err := intArg(args, "max_results", defaultCodeResults, maxCodeResults)
+	if err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	opts := codesearch.Options{Limit: limit}
+	if args["path"] != "" {
+		_, rel, _, res := navTarget(t.workDir, args["path"])
+		if res != nil {
+			return *res
+		}
+		opts.Dir = rel
+	}
+
+	res, err := t.index.Search(query, opts)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("code search: %v", err)}
+	}
+	var sb strings.Builder
+	if len(res.Hits) == 0 {
+		sb.WriteString(fmt.Sprintf("no matches for %q (try other words, grep for exact text, or find_definition for a known name)\n", query))
+	} else {
+		sb.WriteString(fmt.Sprintf("%d results for %q (%d files indexed):\n", len(res.Hits), query, res.Stats.Files))
+	}
+	for _, h := range res.Hits {
+		label := h.Symbol
+		if len(label) > maxCodeLabel {
+			cut := maxCodeLabel - 3
+			for cut > 0 && !utf8.RuneStart(label[cut]) {
+				cut--
+			}
+			label = label[:cut] + "..."
+		}
+		sb.WriteString(fmt.Sprintf("\n%s:%d-%d  %s\n", h.File, h.Start, h.End, label))
+		prev := 0
+		for _, l := range h.Lines {
+			if prev != 0 && l.Num != prev+1 {
+				sb.WriteString("     ...\n")
+			}
+			sb.WriteString(fmt.Sprintf("%4d | %s\n", l.Num, cutLine(l.Text, len(l.Text))))
+			prev = l.Num
+		}
+		if prev != 0 && prev < h.End {
+			sb.WriteString("     ...\n")
+		}
+	}
+
+	switch {
+	case res.EmbedErr != nil:
+		sb.WriteString(fmt.Sprintf("\nNote: semantic ranking unavailable (%v); results are keyword matches.\n", res.EmbedErr))
+	case res.Embedded > 0 && res.Embedded < res.Stats.Chunks:
+		sb.WriteString(fmt.Sprintf("\nNote: embeddings cover %d of %d chunks so far; later searches add the rest.\n", res.Embedded, res.Stats.Chunks))
+	}
+	if res.Stats.Truncated {
+		sb.WriteString("Note: the project has too many files; only part of it is indexed. Use grep for the rest.\n")
+	}
+	return Result{Success: true, Output: sb.String()}
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCodeSearchTool(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "auth"), 0755)
+	os.WriteFile(filepath.Join(dir, "auth", "jwt.go"), []byte(`package auth
+
+// Sign creates a token.
+func Sign(user string) string { return user }
+
+// checkExpiry rejects tokens whose exp claim is in the past.
+func checkExpiry(exp, now int64) error {
+	if exp < now {
+		return errExpired
+	}
+	return nil
+}
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+
+	reg := DefaultRegistry(dir, nil)
+	res := reg.Execute("code_search", map[string]string{"query": "where do we validate JWT expiry"})
+	if !res.Success {
+		t.Fatalf("code_search: %s", res.Output)
+	}
+	for _, want := range []string{
+		`results for "where do we validate JWT expiry" (2 files indexed):`,
+		"auth/jwt.go:1-12  func checkExpiry(exp, now int64) error\n",
+		"   6 | // checkExpiry rejects tokens",
+	} {
+		if !strings.Contains(res.Output, want) {
+			t.Errorf("output missing %q:\n%s", want, res.Output)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".devagent", "index", "code.gob")); err != nil {
+		t.Errorf("index not stored: %v", err)
+	}
+
+	res = reg.Execute("code_search", map[string]string{"query": "token expiry", "path": "main.go"})
+	if !res.Success || !strings.HasPrefix(res.Output, "no matches") {
+		t.Errorf("search limited to main.go:\n%s", res.Output)
+	}
+	for _, args := range []map[string]string{
+		{"query": ""},
+		{"query": "x", "path": "../elsewhere"},
+		{"query": "x", "max_results": "many"},
+	} {
+		if res := reg.Execute("code_search", args); res.Success {
+			t.Errorf("%v should fail:\n%s", args, res.Output)
+		}
+	}
+}
+
+type failingEmbedder struct{}
+
+func (failingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestCodeSearchTool_EmbedderError(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\n// Serve starts the server.\nfunc Serve() {}\n"), 0644)
+	reg := DefaultRegistry(dir, nil)
+	reg.SetCodeSearchEmbedder(failingEmbedder{}, "nomic-embed-text")
+	res := reg.Execute("code_search", map[string]string{"query": "start server"})
+	if !res.Success || !strings.Contains(res.Output, "main.go:1-4") || !strings.Contains(res.Output, "semantic ranking unavailable (connection refused)") {
+		t.Errorf("output:\n%s", res.Output)
+	}
+}
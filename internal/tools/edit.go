@@ -50,7 +50,7 @@ func (t *StrReplaceTool) Execute(args map[string]string) Result {
 
 	newContent := strings.Replace(content, oldStr, newStr, 1)
 
-	if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
+	if err := writeFileAtomic(path, []byte(newContent)); err != nil {
 		return Result{Success: false, Output: fmt.Sprintf("write error: %v", err)}
 	}
 
@@ -107,7 +107,7 @@ func (t *InsertLineTool) Execute(args map[string]string) Result {
 	}
 
 	newContent := strings.Join(newLines, "\n")
-	if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
+	if err := writeFileAtomic(path, []byte(newContent)); err != nil {
 		return Result{Success: false, Output: fmt.Sprintf("write error: %v", err)}
 	}
 
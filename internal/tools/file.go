@@ -1,10 +1,16 @@
 package tools
 
 import (
+	"devagent/internal/ignore"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type ReadFileTool struct {
@@ -13,6 +19,9 @@ type ReadFileTool struct {
 
 func (t *ReadFileTool) Name() string { return "read_file" }
 
+// defaultReadLimit is the number of lines returned when read_file is called without a limit.
+const defaultReadLimit = 500
+
 func (t *ReadFileTool) Execute(args map[string]string) Result {
 	path := t.resolvePath(args["path"])
 
@@ -23,24 +32,104 @@ func (t *ReadFileTool) Execute(args map[string]string) Result {
 	if info.IsDir() {
 		return Result{Success: false, Output: fmt.Sprintf("%s is a directory, use list_dir instead", path)}
 	}
-	if info.Size() > 512*1024 {
-		return Result{Success: false, Output: fmt.Sprintf("file too large (%d bytes), consider reading specific sections", info.Size())}
+
+	head, err := sniffFile(path)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("read error: %v", err)}
+	}
+	enc := detectEncoding(head)
+	if enc == encBinary {
+		return Result{Success: true, Output: binarySummary(path, info.Size(), head)}
+	}
+
+	offset, limit := 1, defaultReadLimit
+	if v := strings.TrimSpace(args["offset"]); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return Result{Success: false, Output: fmt.Sprintf("invalid offset %q: must be a line number >= 1", v)}
+		}
+		offset = n
+	}
+	if v := strings.TrimSpace(args["limit"]); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return Result{Success: false, Output: fmt.Sprintf("invalid limit %q: must be >= 1", v)}
+		}
+		limit = n
+	}
+
+	var header string
+	if symbol := strings.TrimSpace(args["symbol"]); symbol != "" {
+		src, err := readDecoded(path, enc, info.Size())
+		if err != nil {
+			return Result{Success: false, Output: fmt.Sprintf("read error: %v", err)}
+		}
+		start, end, err := findSymbol(path, []byte(src), symbol)
+		if err != nil {
+			return Result{Success: false, Output: err.Error()}
+		}
+		offset, limit = start, end-start+1
+		header = fmt.Sprintf("%s (lines %d-%d)\n", symbol, start, end)
 	}
 
-	data, err := os.ReadFile(path)
+	lr, err := openLines(path, enc, info.Size(), maxLineLen+utf8.UTFMax)
 	if err != nil {
 		return Result{Success: false, Output: fmt.Sprintf("read error: %v", err)}
 	}
+	defer lr.close()
 
-	lines := strings.Split(string(data), "\n")
 	var sb strings.Builder
-	for i, line := range lines {
-		sb.WriteString(fmt.Sprintf("%4d | %s\n", i+1, line))
+	if enc != encUTF8 {
+		sb.WriteString(fmt.Sprintf("[encoding: %s, shown as UTF-8]\n", enc))
+	}
+	sb.WriteString(header)
+	n, shown := 0, 0
+	more := false
+	for {
+		line, ok := lr.next()
+		if !ok {
+			break
+		}
+		n++
+		if n < offset {
+			continue
+		}
+		if shown == limit {
+			more = true
+			break
+		}
+		sb.WriteString(fmt.Sprintf("%4d | %s\n", n, cutLine(line, lr.size)))
+		shown++
+	}
+	if offset > 1 && shown == 0 {
+		return Result{Success: false, Output: fmt.Sprintf("offset %d is past the end of the file (%d lines)", offset, n)}
+	}
+	if more {
+		// Count the rest so the hint can say how much is left.
+		rest := 1
+		for _, ok := lr.next(); ok; _, ok = lr.next() {
+			rest++
+		}
+		sb.WriteString(fmt.Sprintf("... %d more lines available. Use offset=%d to continue.\n", rest, offset+shown))
 	}
-
 	return Result{Success: true, Output: sb.String()}
 }
 
+// readDecoded reads a whole text file as a UTF-8 string.
+func readDecoded(path string, enc textEncoding, size int64) (string, error) {
+	lr, err := openLines(path, enc, size, 0)
+	if err != nil {
+		return "", err
+	}
+	defer lr.close()
+	var sb strings.Builder
+	for line, ok := lr.next(); ok; line, ok = lr.next() {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	return sb.String(), nil
+}
+
 func (t *ReadFileTool) resolvePath(p string) string {
 	if filepath.IsAbs(p) {
 		return p
@@ -68,7 +157,7 @@ func (t *WriteFileTool) Execute(args map[string]string) Result {
 		existed = true
 	}
 
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+	if err := writeFileAtomic(path, []byte(content)); err != nil {
 		return Result{Success: false, Output: fmt.Sprintf("write error: %v", err)}
 	}
 
@@ -89,6 +178,42 @@ func (t *WriteFileTool) resolvePath(p string) string {
 	return filepath.Join(t.workDir, p)
 }
 
+// writeFileAtomic writes data to a temp file in the same directory and renames it
+// over path, so readers never observe a half-written file. An existing file keeps its mode,
+// and a symlink is followed so the link stays in place and its target gets the data.
+func writeFileAtomic(path string, data []byte) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
 type ListDirTool struct {
 	workDir string
 }
@@ -128,6 +253,10 @@ func (t *ListDirTool) Execute(args map[string]string) Result {
 	return Result{Success: true, Output: sb.String()}
 }
 
+const maxSearchResults = 200
+
+// SearchFilesTool finds files by glob. Patterns are matched against paths relative to
+// the search root and support "**"; patterns without a slash match the file name.
 type SearchFilesTool struct {
 	workDir string
 }
@@ -142,30 +271,47 @@ func (t *SearchFilesTool) Execute(args map[string]string) Result {
 		root = filepath.Join(t.workDir, root)
 	}
 
-	pattern := args["pattern"]
-	if pattern == "" {
-		pattern = "*"
+	patterns := splitGlobs(args["pattern"])
+	if len(patterns) == 0 {
+		patterns = []string{"*"}
+	}
+	exclude := splitGlobs(args["exclude"])
+	sortBy := strings.TrimSpace(args["sort"])
+	if sortBy != "" && sortBy != "path" && sortBy != "mtime" {
+		return Result{Success: false, Output: fmt.Sprintf("invalid sort %q: use \"path\" or \"mtime\"", sortBy)}
 	}
 
-	var matches []string
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	type match struct {
+		rel   string
+		size  int64
+		mtime time.Time
+	}
+	var matches []match
+	truncated := false
+	err := ignore.New(t.workDir).Walk(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
+			if path == root {
+				return err
+			}
 			return nil
 		}
-		if info.IsDir() {
-			base := filepath.Base(path)
-			if base == ".git" || base == "node_modules" || base == "__pycache__" || base == ".venv" || base == "vendor" {
-				return filepath.SkipDir
-			}
+		if d.IsDir() {
 			return nil
 		}
-		matched, _ := filepath.Match(pattern, filepath.Base(path))
-		if matched {
-			rel, _ := filepath.Rel(root, path)
-			matches = append(matches, rel)
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if !matchAnyGlob(patterns, rel) || matchAnyGlob(exclude, rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
 		}
-		if len(matches) >= 200 {
-			return fmt.Errorf("too many results")
+		matches = append(matches, match{rel: rel, size: info.Size(), mtime: info.ModTime()})
+		// Sorting by mtime needs every match; otherwise stop once the cap is reached.
+		if sortBy != "mtime" && len(matches) >= maxSearchResults {
+			truncated = true
+			return filepath.SkipAll
 		}
 		return nil
 	})
@@ -175,8 +321,36 @@ func (t *SearchFilesTool) Execute(args map[string]string) Result {
 	}
 
 	if len(matches) == 0 {
-		return Result{Success: true, Output: "no files found matching pattern: " + pattern}
+		return Result{Success: true, Output: "no files found matching pattern: " + strings.Join(patterns, ",")}
+	}
+
+	if sortBy == "mtime" {
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].mtime.After(matches[j].mtime) })
+	}
+	total := len(matches)
+	if total > maxSearchResults {
+		matches = matches[:maxSearchResults]
+		truncated = true
 	}
 
-	return Result{Success: true, Output: strings.Join(matches, "\n")}
+	var sb strings.Builder
+	for _, m := range matches {
+		sb.WriteString(fmt.Sprintf("%s  (%s)\n", m.rel, formatSize(m.size)))
+	}
+	if truncated {
+		sb.WriteString(fmt.Sprintf("... results capped at %d files; narrow the pattern or path\n", maxSearchResults))
+	}
+	return Result{Success: true, Output: sb.String()}
+}
+
+// formatSize renders a byte count as a short human-readable string.
+func formatSize(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
 }
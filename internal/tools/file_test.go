@@ -1,10 +1,12 @@
 package tools
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestReadFileTool_Name(t *testing.T) {
@@ -45,16 +47,93 @@ func TestReadFileTool_Execute_Dir(t *testing.T) {
 	}
 }
 
-func TestReadFileTool_Execute_TooLarge(t *testing.T) {
+func TestReadFileTool_Execute_Binary(t *testing.T) {
 	dir := t.TempDir()
-	f := filepath.Join(dir, "big.txt")
-	// > 512*1024
+	f := filepath.Join(dir, "big.bin")
 	data := make([]byte, 600*1024)
 	os.WriteFile(f, data, 0644)
 	tool := &ReadFileTool{workDir: dir}
-	result := tool.Execute(map[string]string{"path": "big.txt"})
-	if result.Success {
-		t.Error("file too large should fail")
+	result := tool.Execute(map[string]string{"path": "big.bin"})
+	if !result.Success {
+		t.Fatalf("binary file should be summarized: %s", result.Output)
+	}
+	if !strings.Contains(result.Output, "Binary file") || !strings.Contains(result.Output, "614400 bytes") {
+		t.Errorf("unexpected summary: %s", result.Output)
+	}
+}
+
+func TestReadFileTool_Execute_Paging(t *testing.T) {
+	dir := t.TempDir()
+	var sb strings.Builder
+	for i := 1; i <= 1200; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	os.WriteFile(filepath.Join(dir, "long.txt"), []byte(sb.String()), 0644)
+	tool := &ReadFileTool{workDir: dir}
+
+	result := tool.Execute(map[string]string{"path": "long.txt"})
+	if !result.Success {
+		t.Fatalf("Execute: %s", result.Output)
+	}
+	if !strings.Contains(result.Output, " 500 | line 500") || strings.Contains(result.Output, "line 501\n") {
+		t.Errorf("default page should stop at line 500")
+	}
+	if !strings.Contains(result.Output, "700 more lines available. Use offset=501 to continue.") {
+		t.Errorf("missing paging hint: %s", result.Output[len(result.Output)-200:])
+	}
+
+	result = tool.Execute(map[string]string{"path": "long.txt", "offset": "1190", "limit": "5"})
+	want := "1190 | line 1190\n1191 | line 1191\n1192 | line 1192\n1193 | line 1193\n1194 | line 1194\n... 6 more lines available. Use offset=1195 to continue.\n"
+	if result.Output != want {
+		t.Errorf("offset/limit output:\n%s\nwant:\n%s", result.Output, want)
+	}
+
+	result = tool.Execute(map[string]string{"path": "long.txt", "offset": "1198"})
+	if strings.Contains(result.Output, "more lines") || !strings.Contains(result.Output, "1200 | line 1200") {
+		t.Errorf("last page: %s", result.Output)
+	}
+
+	if result := tool.Execute(map[string]string{"path": "long.txt", "offset": "5000"}); result.Success {
+		t.Error("offset past end should fail")
+	}
+	if result := tool.Execute(map[string]string{"path": "long.txt", "limit": "x"}); result.Success {
+		t.Error("invalid limit should fail")
+	}
+}
+
+func TestReadFileTool_Execute_Symbol(t *testing.T) {
+	dir := t.TempDir()
+	src := `package demo
+
+// Add adds.
+func Add(a, b int) int {
+	return a + b
+}
+
+func Sub(a, b int) int { return a - b }
+`
+	os.WriteFile(filepath.Join(dir, "demo.go"), []byte(src), 0644)
+	tool := &ReadFileTool{workDir: dir}
+	result := tool.Execute(map[string]string{"path": "demo.go", "symbol": "Add"})
+	if !result.Success {
+		t.Fatalf("Execute: %s", result.Output)
+	}
+	if !strings.Contains(result.Output, "Add (lines 3-6)") || !strings.Contains(result.Output, "   5 | \treturn a + b") || strings.Contains(result.Output, "Sub") {
+		t.Errorf("unexpected symbol output:\n%s", result.Output)
+	}
+	result = tool.Execute(map[string]string{"path": "demo.go", "symbol": "Mul"})
+	if result.Success || !strings.Contains(result.Output, "Add, Sub") {
+		t.Errorf("missing symbol should list available ones: %s", result.Output)
+	}
+}
+
+func TestReadFileTool_Execute_Latin1(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "legacy.txt"), []byte("caf\xe9\n"), 0644)
+	tool := &ReadFileTool{workDir: dir}
+	result := tool.Execute(map[string]string{"path": "legacy.txt"})
+	if !result.Success || !strings.Contains(result.Output, "café") || !strings.Contains(result.Output, "ISO-8859-1") {
+		t.Errorf("latin-1 output: %q", result.Output)
 	}
 }
 
@@ -92,6 +171,31 @@ func TestWriteFileTool_Execute_Update(t *testing.T) {
 	}
 }
 
+func TestWriteFileTool_Execute_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "shared", "config.yaml")
+	os.MkdirAll(filepath.Dir(target), 0755)
+	os.WriteFile(target, []byte("old"), 0600)
+	link := filepath.Join(dir, "config.yaml")
+	if err := os.Symlink(filepath.Join("shared", "config.yaml"), link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	tool := &WriteFileTool{workDir: dir}
+	if r := tool.Execute(map[string]string{"path": "config.yaml", "content": "new"}); !r.Success {
+		t.Fatalf("expected success: %s", r.Output)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("symlink replaced by a regular file: %v", err)
+	}
+	data, _ := os.ReadFile(target)
+	if string(data) != "new" {
+		t.Errorf("target = %q, want %q", data, "new")
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0600 {
+		t.Errorf("target mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
 func TestListDirTool_Name(t *testing.T) {
 	tool := &ListDirTool{workDir: "/tmp"}
 	if tool.Name() != "list_dir" {
@@ -166,3 +270,72 @@ func TestDoneTool_Execute(t *testing.T) {
 		t.Errorf("empty summary default = %q", result2.Output)
 	}
 }
+
+func TestSearchFilesTool_RespectsGitignore(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("gen/\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "gen"), 0755)
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "gen", "a.go"), []byte(""), 0644)
+	os.WriteFile(filepath.Join(dir, "src", "b.go"), []byte(""), 0644)
+	tool := &SearchFilesTool{workDir: dir}
+	result := tool.Execute(map[string]string{"pattern": "*.go"})
+	if !strings.Contains(result.Output, "b.go") || strings.Contains(result.Output, "a.go") {
+		t.Errorf("output = %q", result.Output)
+	}
+}
+
+func TestSearchFilesTool_Execute_Globs(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"cmd/api/main.go", "cmd/api/util.go", "cmd/cli/main.go", "internal/a/a.go", "internal/a/b/b_test.go", "internal/x_test.go", "README.md"} {
+		p := filepath.Join(dir, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte("package x\n"), 0644)
+	}
+	tool := &SearchFilesTool{workDir: dir}
+	tests := []struct {
+		args map[string]string
+		want string
+	}{
+		{map[string]string{"pattern": "cmd/*/main.go"}, "cmd/api/main.go,cmd/cli/main.go"},
+		{map[string]string{"pattern": "internal/**/*_test.go"}, "internal/a/b/b_test.go,internal/x_test.go"},
+		{map[string]string{"pattern": "*.md, cmd/cli/*"}, "README.md,cmd/cli/main.go"},
+		{map[string]string{"pattern": "*.go", "exclude": "*_test.go,cmd/**"}, "internal/a/a.go"},
+		{map[string]string{"path": "cmd", "pattern": "**/util.go"}, "api/util.go"},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(tt.args)
+		if !result.Success {
+			t.Fatalf("%v: %s", tt.args, result.Output)
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(result.Output), "\n") {
+			got = append(got, strings.Fields(line)[0])
+		}
+		if g := strings.Join(got, ","); g != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.args, g, tt.want)
+		}
+	}
+	if result := tool.Execute(map[string]string{"pattern": "README.md"}); !strings.Contains(result.Output, "README.md  (10 B)") {
+		t.Errorf("size missing: %q", result.Output)
+	}
+}
+
+func TestSearchFilesTool_Execute_SortByMtime(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"old.txt", "new.txt", "mid.txt"} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, nil, 0644)
+		age := map[int]time.Duration{0: 3 * time.Hour, 1: 0, 2: time.Hour}[i]
+		os.Chtimes(p, now.Add(-age), now.Add(-age))
+	}
+	tool := &SearchFilesTool{workDir: dir}
+	result := tool.Execute(map[string]string{"pattern": "*.txt", "sort": "mtime"})
+	if !strings.HasPrefix(result.Output, "new.txt") || strings.Index(result.Output, "mid.txt") > strings.Index(result.Output, "old.txt") {
+		t.Errorf("not sorted by mtime: %q", result.Output)
+	}
+	if result := tool.Execute(map[string]string{"sort": "size"}); result.Success {
+		t.Error("invalid sort should fail")
+	}
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const formatTimeout = 30 * time.Second
+
+// Built-in formatters run in-process, so they work without any tool installed.
+const (
+	builtinGofmt      = "builtin:gofmt"      // go/format, same result as gofmt
+	builtinWhitespace = "builtin:whitespace" // strip trailing spaces, end with one newline
+)
+
+// formatters maps file extensions to the formatter applied after an edit. A formatter
+// is a built-in name or a shell command using {file}, run like a post-edit hook.
+type formatters struct {
+	byExt map[string]string // ".go" -> "builtin:gofmt"
+	shell *ShellTool
+}
+
+// newFormatters normalizes the configured mapping: keys may list several extensions
+// (".js,.ts"), with or without the leading "." or "*".
+func newFormatters(config map[string]string, shell *ShellTool) *formatters {
+	f := &formatters{byExt: make(map[string]string), shell: shell}
+	for exts, command := range config {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+		for _, ext := range strings.Split(exts, ",") {
+			ext = strings.ToLower(strings.TrimLeft(strings.TrimSpace(ext), "*."))
+			if ext != "" {
+				f.byExt["."+ext] = command
+			}
+		}
+	}
+	if len(f.byExt) == 0 {
+		return nil
+	}
+	return f
+}
+
+// run formats file and returns a note for the tool's output: empty when no formatter
+// applies or the content was already formatted.
+func (f *formatters) run(file string) string {
+	command, ok := f.byExt[strings.ToLower(filepath.Ext(file))]
+	if !ok {
+		return ""
+	}
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.shell.workDir, path)
+	}
+	rel, ok := relPath(f.shell.workDir, path)
+	if !ok {
+		return ""
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	name := command
+	switch command {
+	case builtinGofmt, builtinWhitespace:
+		name = strings.TrimPrefix(command, "builtin:")
+		after, err := formatBuiltin(command, before)
+		if err != nil {
+			return fmt.Sprintf("\n\nFormatter %s failed; the file was left as written\n%s:%v", name, rel, err)
+		}
+		if bytes.Equal(after, before) {
+			return ""
+		}
+		if err := writeFileAtomic(path, after); err != nil {
+			return fmt.Sprintf("\n\nFormatter %s failed: %v", name, err)
+		}
+	default:
+		command = expandHook(command, rel)
+		name = command
+		stdout, stderr, exitCode, err := f.shell.runRaw(command, formatTimeout, nil)
+		if err == nil && exitCode != 0 {
+			err = fmt.Errorf("exit code %d", exitCode)
+		}
+		if err != nil {
+			out := strings.TrimSpace(combineOutput(stdout, stderr))
+			if lines := strings.Split(out, "\n"); len(lines) > maxHookDiagnostics {
+				out = strings.Join(lines[:maxHookDiagnostics], "\n") + "\n..."
+			}
+			return fmt.Sprintf("\n\nFormatter %s failed (%v); the file was left as written\n%s", name, err, out)
+		}
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil || bytes.Equal(after, before) {
+		return ""
+	}
+	return fmt.Sprintf("\n\nFormatted with %s: content changed (%d -> %d bytes). Re-read the file before editing it again.", name, len(before), len(after))
+}
+
+func formatBuiltin(name string, src []byte) ([]byte, error) {
+	if name == builtinGofmt {
+		return format.Source(src)
+	}
+	lines := strings.Split(string(src), "\n")
+	for i, l := range lines {
+		cr := strings.HasSuffix(l, "\r")
+		l = strings.TrimRight(strings.TrimSuffix(l, "\r"), " \t")
+		if cr {
+			l += "\r" // keep CRLF line endings
+		}
+		lines[i] = l
+	}
+	for len(lines) > 0 && strings.TrimSuffix(lines[len(lines)-1], "\r") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
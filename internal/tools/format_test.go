@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_Formatters_Builtin(t *testing.T) {
+	dir := t.TempDir()
+	reg := DefaultRegistry(dir, nil)
+	defer reg.Close()
+	reg.SetFormatters(map[string]string{"go": builtinGofmt, "*.txt, .md": builtinWhitespace})
+
+	r := reg.Execute("write_file", map[string]string{"path": "main.go", "content": "package main\nfunc main() {\n  println(1)\n}"})
+	if !r.Success || !strings.Contains(r.Output, "Formatted with gofmt: content changed") {
+		t.Fatalf("output = %q", r.Output)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	if want := "package main\n\nfunc main() {\n\tprintln(1)\n}\n"; string(data) != want {
+		t.Errorf("formatted = %q, want %q", data, want)
+	}
+
+	// Already formatted: no note.
+	r = reg.Execute("str_replace", map[string]string{"path": "main.go", "old_str": "println(1)", "new_str": "println(2)"})
+	if !r.Success || strings.Contains(r.Output, "Formatted") {
+		t.Errorf("output = %q", r.Output)
+	}
+
+	// Syntax errors are reported and the file is kept as written.
+	r = reg.Execute("write_file", map[string]string{"path": "bad.go", "content": "package main\nfunc {\n"})
+	if !r.Success || !strings.Contains(r.Output, "Formatter gofmt failed") || !strings.Contains(r.Output, "bad.go:2:") {
+		t.Errorf("output = %q", r.Output)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "bad.go")); string(data) != "package main\nfunc {\n" {
+		t.Errorf("bad.go changed: %q", data)
+	}
+
+	r = reg.Execute("write_file", map[string]string{"path": "notes.md", "content": "a  \nb\t\n\n\n"})
+	if !strings.Contains(r.Output, "Formatted with whitespace") {
+		t.Errorf("output = %q", r.Output)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.md")); string(data) != "a\nb\n" {
+		t.Errorf("notes.md = %q", data)
+	}
+
+	// Unmapped extensions are left alone.
+	r = reg.Execute("write_file", map[string]string{"path": "x.py", "content": "x = 1  "})
+	if strings.Contains(r.Output, "Formatted") {
+		t.Errorf("output = %q", r.Output)
+	}
+}
+
+func TestRegistry_Formatters_Command(t *testing.T) {
+	dir := t.TempDir()
+	reg := DefaultRegistry(dir, nil)
+	defer reg.Close()
+	reg.SetFormatters(map[string]string{
+		".txt": "tr a-z A-Z < {file} > {file}.tmp && mv {file}.tmp {file}",
+		".sh":  "echo 'syntax error' >&2; exit 2",
+	})
+	reg.SetEditHooks([]EditHook{{Glob: "*.txt", Command: "cat {file}"}})
+
+	r := reg.Execute("write_file", map[string]string{"path": "sub/a.txt", "content": "hello\n"})
+	if !strings.Contains(r.Output, "Formatted with tr a-z A-Z < sub/a.txt") {
+		t.Errorf("output = %q", r.Output)
+	}
+	// Hooks see the formatted file.
+	if !strings.Contains(r.Output, "Post-edit checks:") || !strings.Contains(r.Output, "HELLO") {
+		t.Errorf("hooks did not run after formatting: %q", r.Output)
+	}
+
+	r = reg.Execute("write_file", map[string]string{"path": "run.sh", "content": "echo hi\n"})
+	if !r.Success || !strings.Contains(r.Output, "exit code 2") || !strings.Contains(r.Output, "syntax error") {
+		t.Errorf("output = %q", r.Output)
+	}
+}
+
+func TestFormatBuiltin_Whitespace(t *testing.T) {
+	tests := map[string]string{
+		"":                   "",
+		"\n\n":               "",
+		"a":                  "a\n",
+		"a \r\n\r\nb\t\r\n":  "a\r\n\r\nb\r\n",
+		"x\n  \ny  \n\n\n\n": "x\n\ny\n",
+	}
+	for in, want := range tests {
+		got, err := formatBuiltin(builtinWhitespace, []byte(in))
+		if err != nil || string(got) != want {
+			t.Errorf("formatBuiltin(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"devagent/internal/codenav"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	maxFailureLines = 60 // output lines kept per failing test
+	maxFailures     = 30 // failing tests reported in detail
+)
+
+// RunTestsTool runs "go test -json" through the shell tool's execution path (Docker or
+// direct), streaming the tests' output live and stopping on Interrupt, and reports a
+// structured per-test summary instead of raw text.
+type RunTestsTool struct {
+	workDir string
+	shell   *ShellTool
+}
+
+func (t *RunTestsTool) Name() string { return "run_tests" }
+
+func (t *RunTestsTool) Execute(args map[string]string) Result {
+	pkgs := strings.Fields(strings.ReplaceAll(args["packages"], ",", " "))
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+	cmdArgs := []string{"go", "test", "-json"}
+	if run := strings.TrimSpace(args["run"]); run != "" {
+		if _, err := regexp.Compile(run); err != nil {
+			return Result{Success: false, Output: fmt.Sprintf("invalid run pattern: %v", err)}
+		}
+		cmdArgs = append(cmdArgs, "-run", run)
+	}
+	if isTrue(args["race"]) {
+		cmdArgs = append(cmdArgs, "-race")
+	}
+	if isTrue(args["no_cache"]) {
+		cmdArgs = append(cmdArgs, "-count=1")
+	}
+	for _, p := range pkgs {
+		if strings.HasPrefix(p, "-") {
+			return Result{Success: false, Output: fmt.Sprintf("invalid package %q", p)}
+		}
+	}
+	cmdArgs = append(cmdArgs, pkgs...)
+
+	t.shell.mu.Lock()
+	timeout, err := t.shell.timeout(args["timeout"])
+	out := t.shell.opts.Output
+	t.shell.mu.Unlock()
+	if err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	quoted := make([]string, len(cmdArgs))
+	for i, a := range cmdArgs {
+		quoted[i] = shellQuote(a)
+	}
+	command := strings.Join(quoted, " ")
+	var live *testStream
+	if out != nil {
+		live = &testStream{sink: newLineSink(out, "")}
+	}
+	stdout, stderr, exitCode, err := t.shell.runRaw(command, timeout, live.writer())
+	live.flush()
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("%s%s: %v\n%s", t.shell.prefix(), command, err, truncateOutput(combineOutput(stdout, stderr)))}
+	}
+
+	report := parseGoTestJSON(stdout, codenav.ModulePath(t.workDir))
+	report.stderr = strings.TrimSpace(stderr)
+	return Result{Success: exitCode == 0, Output: report.format(strings.Join(cmdArgs, " "), exitCode)}
+}
+
+// testStream shows a "go test -json" run live: the text of each event's Output
+// field is printed like shell output, as plain "go test" would print it.
+type testStream struct {
+	sink    *lineSink
+	partial []byte
+}
+
+// writer returns s as an io.Writer, or nil for a nil s.
+func (s *testStream) writer() io.Writer {
+	if s == nil {
+		return nil
+	}
+	return s
+}
+
+func (s *testStream) Write(p []byte) (int, error) {
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		s.event(s.partial[:i])
+		s.partial = s.partial[i+1:]
+	}
+}
+
+func (s *testStream) event(line []byte) {
+	var ev testEvent
+	if err := json.Unmarshal(line, &ev); err != nil {
+		s.sink.write(append(line, '\n'))
+		return
+	}
+	if ev.Output != "" {
+		s.sink.write([]byte(ev.Output))
+	}
+}
+
+func (s *testStream) flush() {
+	if s == nil {
+		return
+	}
+	if len(s.partial) > 0 {
+		s.event(s.partial)
+		s.partial = nil
+	}
+	s.sink.flush()
+}
+
+// testEvent is one line of "go test -json" output (see "go doc test2json").
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+type testResult struct {
+	pkg, name string
+	action    string // "pass", "fail", "skip" or "" (no final event, e.g. panic or timeout)
+	elapsed   float64
+	output    []string
+}
+
+type testReport struct {
+	tests    []*testResult // in order of first appearance
+	packages []*testResult // package-level results (name empty)
+	build    []string      // build errors
+	other    []string      // non-JSON stdout lines
+	stderr   string
+	module   string
+}
+
+// parseGoTestJSON aggregates test2json events per test and per package.
+func parseGoTestJSON(out, module string) *testReport {
+	r := &testReport{module: module}
+	byKey := make(map[string]*testResult)
+	get := func(pkg, name string) *testResult {
+		key := pkg + "\x00" + name
+		if res, ok := byKey[key]; ok {
+			return res
+		}
+		res := &testResult{pkg: pkg, name: name}
+		byKey[key] = res
+		if name == "" {
+			r.packages = append(r.packages, res)
+		} else {
+			r.tests = append(r.tests, res)
+		}
+		return res
+	}
+
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		var ev testEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &ev) != nil {
+			if strings.TrimSpace(line) != "" {
+				r.other = append(r.other, line)
+			}
+			continue
+		}
+		switch ev.Action {
+		case "build-output":
+			r.build = append(r.build, strings.TrimRight(ev.Output, "\n"))
+			continue
+		case "build-fail", "start":
+			continue
+		}
+		if ev.Package == "" {
+			continue
+		}
+		res := get(ev.Package, ev.Test)
+		switch ev.Action {
+		case "output":
+			text := strings.TrimRight(ev.Output, "\n")
+			if ev.Test != "" && (strings.HasPrefix(strings.TrimSpace(text), "=== ") || strings.HasPrefix(strings.TrimSpace(text), "--- ")) {
+				continue // framing lines; the result is reported separately
+			}
+			res.output = append(res.output, text)
+		case "pass", "fail", "skip":
+			res.action = ev.Action
+			res.elapsed = ev.Elapsed
+		}
+	}
+	return r
+}
+
+// format renders the report: a summary line, failing tests with their output and
+// source locations, build errors, and one line per package.
+func (r *testReport) format(command string, exitCode int) string {
+	var passed, failed, skipped int
+	var failures []*testResult
+	for _, t := range r.tests {
+		switch t.action {
+		case "pass":
+			passed++
+		case "skip":
+			skipped++
+		default: // "fail", or never finished (panic, timeout)
+			failed++
+			failures = append(failures, t)
+		}
+	}
+
+	var sb strings.Builder
+	status := "PASS"
+	if exitCode != 0 {
+		status = "FAIL"
+	}
+	sb.WriteString(fmt.Sprintf("%s: %s — %d passed, %d failed, %d skipped in %d packages\n", command, status, passed, failed, skipped, len(r.packages)))
+
+	if len(r.build) > 0 {
+		sb.WriteString("\nBuild errors:\n")
+		for _, l := range r.build {
+			sb.WriteString("  " + l + "\n")
+		}
+	}
+	if r.stderr != "" {
+		sb.WriteString("\nstderr:\n")
+		for _, l := range strings.Split(r.stderr, "\n") {
+			sb.WriteString("  " + l + "\n")
+		}
+	}
+
+	for i, t := range failures {
+		if i == maxFailures {
+			sb.WriteString(fmt.Sprintf("\n... %d more failing tests not shown\n", len(failures)-maxFailures))
+			break
+		}
+		state := "FAIL"
+		if t.action == "" {
+			state = "DID NOT FINISH"
+		}
+		sb.WriteString(fmt.Sprintf("\n%s %s %s (%.2fs)\n", state, t.pkg, t.name, t.elapsed))
+		if locs := r.locations(t); len(locs) > 0 {
+			sb.WriteString("  at " + strings.Join(locs, ", ") + "\n")
+		}
+		lines := t.output
+		if len(lines) > maxFailureLines {
+			lines = append([]string{fmt.Sprintf("... %d earlier lines omitted", len(lines)-maxFailureLines)}, lines[len(lines)-maxFailureLines:]...)
+		}
+		for _, l := range lines {
+			sb.WriteString("  " + l + "\n")
+		}
+	}
+
+	if len(r.packages) > 0 {
+		sb.WriteString("\nPackages:\n")
+		pkgs := append([]*testResult(nil), r.packages...)
+		sort.SliceStable(pkgs, func(i, j int) bool { return pkgs[i].pkg < pkgs[j].pkg })
+		for _, p := range pkgs {
+			switch {
+			case p.action == "skip" || containsLine(p.output, "[no test files]"):
+				sb.WriteString(fmt.Sprintf("  ?    %s [no test files]\n", p.pkg))
+			case p.action == "pass":
+				sb.WriteString(fmt.Sprintf("  ok   %s (%.2fs)\n", p.pkg, p.elapsed))
+			default:
+				sb.WriteString(fmt.Sprintf("  FAIL %s\n", p.pkg))
+				// Package-level output of a failed package (panics, TestMain errors, build failures).
+				for _, l := range p.output {
+					if t := strings.TrimSpace(l); t != "" && t != "FAIL" && !strings.HasPrefix(t, "FAIL\t") && !strings.HasPrefix(t, "ok ") {
+						sb.WriteString("       " + l + "\n")
+					}
+				}
+			}
+		}
+	}
+	if len(r.other) > 0 {
+		sb.WriteString("\nOther output:\n")
+		for _, l := range r.other {
+			sb.WriteString("  " + l + "\n")
+		}
+	}
+	return sb.String()
+}
+
+var goFileLine = regexp.MustCompile(`^\s*([\w./-]+\.go):(\d+)`)
+
+// locations extracts "file.go:line" references from a failing test's output and makes
+// them relative to the module root using the package's import path.
+func (r *testReport) locations(t *testResult) []string {
+	dir := ""
+	if r.module != "" && strings.HasPrefix(t.pkg, r.module+"/") {
+		dir = strings.TrimPrefix(t.pkg, r.module+"/") + "/"
+	}
+	seen := make(map[string]bool)
+	var locs []string
+	for _, l := range t.output {
+		m := goFileLine.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		file := m[1]
+		if !strings.Contains(file, "/") {
+			file = dir + file
+		}
+		loc := file + ":" + m[2]
+		if !seen[loc] {
+			seen[loc] = true
+			locs = append(locs, loc)
+		}
+	}
+	return locs
+}
+
+func containsLine(lines []string, sub string) bool {
+	for _, l := range lines {
+		if strings.Contains(l, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes s for bash unless it only contains safe characters.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,+@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com/m\n\ngo 1.21\n"
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunTestsTool_Execute(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := writeTestModule(t, map[string]string{
+		"good/good.go":      "package good\n\nfunc Add(a, b int) int { return a + b }\n",
+		"good/good_test.go": "package good\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Fatal(\"bad\")\n\t}\n}\n",
+		"bad/bad_test.go":   "package bad\n\nimport \"testing\"\n\nfunc TestOK(t *testing.T) {}\n\nfunc TestBroken(t *testing.T) {\n\tt.Errorf(\"want %d, got %d\", 1, 2)\n}\n",
+		"broken/broken.go":  "package broken\n\nfunc F() int { return \"x\" }\n",
+		"broken/b_test.go":  "package broken\n\nimport \"testing\"\n\nfunc TestF(t *testing.T) { F() }\n",
+	})
+	shell := &ShellTool{workDir: dir}
+	tool := &RunTestsTool{workDir: dir, shell: shell}
+
+	r := tool.Execute(map[string]string{"packages": "./good"})
+	if !r.Success {
+		t.Fatalf("expected success, got: %s", r.Output)
+	}
+	if !strings.Contains(r.Output, "1 passed, 0 failed") || !strings.Contains(r.Output, "ok   example.com/m/good") {
+		t.Errorf("unexpected report: %s", r.Output)
+	}
+
+	r = tool.Execute(map[string]string{"packages": "./good, ./bad"})
+	if r.Success {
+		t.Fatalf("expected failure, got: %s", r.Output)
+	}
+	for _, want := range []string{"2 passed, 1 failed", "FAIL example.com/m/bad TestBroken", "at bad/bad_test.go:8", "want 1, got 2", "FAIL example.com/m/bad"} {
+		if !strings.Contains(r.Output, want) {
+			t.Errorf("output missing %q: %s", want, r.Output)
+		}
+	}
+	if strings.Contains(r.Output, "TestOK") {
+		t.Errorf("passing test should not be detailed: %s", r.Output)
+	}
+
+	r = tool.Execute(map[string]string{"packages": "./bad", "run": "^TestOK$"})
+	if !r.Success || !strings.Contains(r.Output, "1 passed, 0 failed") {
+		t.Errorf("run filter not applied: %s", r.Output)
+	}
+
+	r = tool.Execute(map[string]string{"packages": "./broken"})
+	if r.Success {
+		t.Fatalf("expected build failure, got: %s", r.Output)
+	}
+	if !strings.Contains(r.Output, "broken.go:3") {
+		t.Errorf("build error location missing: %s", r.Output)
+	}
+}
+
+func TestTestStream(t *testing.T) {
+	var live strings.Builder
+	s := &testStream{sink: newLineSink(&live, "")}
+	s.Write([]byte(`{"Action":"run","Test":"TestA"}` + "\n" + `{"Action":"output","Test":"TestA","Output":"=== RUN   TestA\n"}` + "\n" + `{"Action":"output","Output":"--- PA`))
+	s.Write([]byte(`SS: TestA\n"}` + "\n" + "not json"))
+	s.flush()
+	want := streamPrefix + "=== RUN   TestA\n" + streamPrefix + "--- PASS: TestA\n" + streamPrefix + "not json\n"
+	if live.String() != want {
+		t.Errorf("streamed:\n%q\nwant:\n%q", live.String(), want)
+	}
+}
+
+func TestRunTestsTool_Streams(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := writeTestModule(t, map[string]string{
+		"good/good_test.go": "package good\n\nimport \"testing\"\n\nfunc TestLive(t *testing.T) { t.Log(\"hello\") }\n",
+	})
+	var live strings.Builder
+	shell := &ShellTool{workDir: dir}
+	shell.SetOptions(ShellOptions{Output: &live})
+	tool := &RunTestsTool{workDir: dir, shell: shell}
+	if r := tool.Execute(map[string]string{"packages": "./good", "no_cache": "true"}); !r.Success {
+		t.Fatalf("expected success, got: %s", r.Output)
+	}
+	if !strings.Contains(live.String(), streamPrefix+"=== RUN   TestLive") || strings.Contains(live.String(), `"Action"`) {
+		t.Errorf("live output:\n%s", live.String())
+	}
+}
+
+func TestRunTestsTool_InvalidArgs(t *testing.T) {
+	tool := &RunTestsTool{workDir: t.TempDir(), shell: &ShellTool{}}
+	if r := tool.Execute(map[string]string{"run": "("}); r.Success {
+		t.Error("expected invalid run pattern to fail")
+	}
+	if r := tool.Execute(map[string]string{"packages": "-exec=evil"}); r.Success {
+		t.Error("expected flag-like package to be rejected")
+	}
+}
+
+func TestParseGoTestJSON(t *testing.T) {
+	out := `{"Action":"start","Package":"example.com/m/pkg"}
+{"Action":"run","Package":"example.com/m/pkg","Test":"TestA"}
+{"Action":"output","Package":"example.com/m/pkg","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Action":"output","Package":"example.com/m/pkg","Test":"TestA","Output":"    a_test.go:12: boom\n"}
+{"Action":"output","Package":"example.com/m/pkg","Test":"TestA","Output":"--- FAIL: TestA (0.01s)\n"}
+{"Action":"fail","Package":"example.com/m/pkg","Test":"TestA","Elapsed":0.01}
+{"Action":"run","Package":"example.com/m/pkg","Test":"TestB"}
+{"Action":"skip","Package":"example.com/m/pkg","Test":"TestB"}
+{"Action":"output","Package":"example.com/m/pkg","Output":"FAIL\n"}
+{"Action":"fail","Package":"example.com/m/pkg","Elapsed":0.02}
+# example.com/m/other
+not json
+`
+	r := parseGoTestJSON(out, "example.com/m")
+	if len(r.tests) != 2 || len(r.packages) != 1 {
+		t.Fatalf("got %d tests, %d packages", len(r.tests), len(r.packages))
+	}
+	if r.tests[0].action != "fail" || len(r.tests[0].output) != 1 {
+		t.Errorf("TestA = %+v", r.tests[0])
+	}
+	if locs := r.locations(r.tests[0]); len(locs) != 1 || locs[0] != "pkg/a_test.go:12" {
+		t.Errorf("locations = %v", locs)
+	}
+	if len(r.other) != 2 {
+		t.Errorf("other = %v", r.other)
+	}
+	text := r.format("go test -json ./...", 1)
+	if !strings.Contains(text, "0 passed, 1 failed, 1 skipped in 1 packages") {
+		t.Errorf("summary wrong: %s", text)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	cases := map[string]string{
+		"./...":    "./...",
+		"^TestA$":  "'^TestA$'",
+		"a'b":      `'a'\''b'`,
+		"":         "''",
+		"-count=1": "-count=1",
+		"Test A|B": "'Test A|B'",
+	}
+	for in, want := range cases {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package tools
+
+import (
+	"devagent/internal/ignore"
+	"devagent/internal/sandbox"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultGrepResults = 100
+	maxGrepResults     = 1000
+	maxGrepContext     = 10
+	maxGrepFileSize    = 8 << 20 // larger files are skipped
+)
+
+// GrepTool searches file contents with a Go regular expression. It runs in-process on
+// the host, so the pattern never reaches a shell; the registry still validates the path.
+type GrepTool struct {
+	workDir string
+}
+
+func (t *GrepTool) Name() string { return "grep" }
+
+func (t *GrepTool) Execute(args map[string]string) Result {
+	pattern := args["pattern"]
+	if pattern == "" {
+		return Result{Success: false, Output: "empty pattern"}
+	}
+	if isTrue(args["ignore_case"]) {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("invalid regex: %v", err)}
+	}
+
+	root := args["path"]
+	if root == "" || root == "." {
+		root = t.workDir
+	} else if !filepath.IsAbs(root) {
+		root = filepath.Join(t.workDir, root)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("cannot access %s: %v", root, err)}
+	}
+
+	ctxLines, err := intArg(args, "context", 0, maxGrepContext)
+	if err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	limit, err := intArg(args, "max_results", defaultGrepResults, maxGrepResults)
+	if err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	if limit == 0 {
+		limit = defaultGrepResults
+	}
+
+	g := &grepper{
+		re:      re,
+		context: ctxLines,
+		limit:   limit,
+		include: splitGlobs(args["include"]),
+		exclude: splitGlobs(args["exclude"]),
+		workDir: t.workDir,
+	}
+	if info.IsDir() {
+		err = ignore.New(t.workDir).Walk(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() || !g.wanted(p) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			g.searchFile(p, info.Size())
+			if g.full() {
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			return Result{Success: false, Output: fmt.Sprintf("grep error: %v", err)}
+		}
+	} else {
+		g.searchFile(root, info.Size())
+	}
+
+	if g.matches == 0 {
+		return Result{Success: true, Output: "no matches found for pattern: " + args["pattern"]}
+	}
+	out := g.out.String()
+	if g.full() {
+		out += fmt.Sprintf("\n(results capped at %d matches; narrow the pattern or path, or raise max_results)\n", limit)
+	} else {
+		out += fmt.Sprintf("\n(%d matches in %d files)\n", g.matches, g.files)
+	}
+	if g.skipped > 0 {
+		out += fmt.Sprintf("(%d binary or oversized files skipped)\n", g.skipped)
+	}
+	return Result{Success: true, Output: out}
+}
+
+// grepper holds the state of one grep run.
+type grepper struct {
+	re               *regexp.Regexp
+	context, limit   int
+	include, exclude []string
+	workDir          string
+
+	out            strings.Builder
+	matches, files int
+	skipped        int
+	grouped        bool // a match group has been written (for "--" separators)
+}
+
+// sizedLine is a context line kept for output, with its full length in bytes.
+type sizedLine struct {
+	text string
+	size int
+}
+
+func (g *grepper) full() bool { return g.matches >= g.limit }
+
+// wanted applies the include/exclude globs to a file path.
+func (g *grepper) wanted(p string) bool {
+	rel := g.display(p)
+	if len(g.include) > 0 && !matchAnyGlob(g.include, rel) {
+		return false
+	}
+	return !matchAnyGlob(g.exclude, rel)
+}
+
+// display returns p relative to the working directory when it lies inside it.
+func (g *grepper) display(p string) string {
+	if rel, err := filepath.Rel(g.workDir, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+// searchFile appends the file's matches in grep format: "file:line:text" for matches,
+// "file-line-text" for context lines and "--" between non-adjacent groups.
+func (g *grepper) searchFile(p string, size int64) {
+	if size > maxGrepFileSize {
+		g.skipped++
+		return
+	}
+	head, err := sniffFile(p)
+	if err != nil {
+		return
+	}
+	enc := detectEncoding(head)
+	if enc == encBinary {
+		g.skipped++
+		return
+	}
+	lr, err := openLines(p, enc, size, maxMatchLine)
+	if err != nil {
+		g.skipped++
+		return
+	}
+	defer lr.close()
+
+	name := g.display(p)
+	var before []sizedLine // ring of preceding lines for context
+	lastPrinted := 0       // last line number written for this file
+	afterLeft := 0
+	found := false
+	n := 0
+	for line, ok := lr.next(); ok; line, ok = lr.next() {
+		n++
+		if g.re.MatchString(line) {
+			if g.full() {
+				break
+			}
+			if !found {
+				found = true
+				g.files++
+			}
+			first := n - len(before)
+			if g.context > 0 && g.grouped && (lastPrinted == 0 || first > lastPrinted+1) {
+				g.out.WriteString("--\n")
+			}
+			g.grouped = true
+			for i, b := range before {
+				fmt.Fprintf(&g.out, "%s-%d-%s\n", name, first+i, cutLine(b.text, b.size))
+			}
+			fmt.Fprintf(&g.out, "%s:%d:%s\n", name, n, cutLine(line, lr.size))
+			g.matches++
+			lastPrinted = n
+			afterLeft = g.context
+			before = before[:0]
+			continue
+		}
+		if afterLeft > 0 {
+			fmt.Fprintf(&g.out, "%s-%d-%s\n", name, n, cutLine(line, lr.size))
+			lastPrinted = n
+			afterLeft--
+			continue
+		}
+		if g.full() {
+			break
+		}
+		if g.context > 0 {
+			if len(before) == g.context {
+				before = append(before[:0], before[1:]...)
+			}
+			before = append(before, sizedLine{line, lr.size})
+		}
+	}
+}
+
+// splitGlobs splits a comma-separated list of glob patterns.
+func splitGlobs(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, filepath.ToSlash(p))
+		}
+	}
+	return out
+}
+
+// matchAnyGlob reports whether the slash-separated relative path rel matches one of
+// the globs. Patterns without a slash match the base name ("*.go"); others match the
+// whole relative path and may use "**" ("internal/**/*_test.go").
+func matchAnyGlob(globs []string, rel string) bool {
+	for _, g := range globs {
+		if strings.Contains(g, "/") {
+			if ignore.Match(strings.TrimPrefix(g, "./"), rel) {
+				return true
+			}
+		} else if ignore.Match(g, path.Base(rel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// intArg parses an optional integer argument, bounded to [0, max].
+func intArg(args map[string]string, key string, def, max int) (int, error) {
+	v := strings.TrimSpace(args[key])
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a non-negative integer", key, v)
+	}
+	if n > max {
+		n = max
+	}
+	return n, nil
+}
+
+func isTrue(s string) bool {
+	return sandbox.IsTrue(s)
+}
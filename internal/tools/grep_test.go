@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGrepTool_Name(t *testing.T) {
+	tool := &GrepTool{workDir: "/tmp"}
+	if tool.Name() != "grep" {
+		t.Errorf("Name() = %q", tool.Name())
+	}
+}
+
+func TestGrepTool_Execute_EmptyPattern(t *testing.T) {
+	tool := &GrepTool{workDir: t.TempDir()}
+	result := tool.Execute(map[string]string{"pattern": ""})
+	if result.Success {
+		t.Error("empty pattern should fail")
+	}
+}
+
+func TestGrepTool_Execute_Success(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(f, []byte("needle in haystack"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &GrepTool{workDir: dir}
+	result := tool.Execute(map[string]string{"pattern": "needle", "path": "."})
+	if !result.Success || !strings.Contains(result.Output, "f.txt:1:needle in haystack") {
+		t.Errorf("Execute = %+v", result)
+	}
+}
+
+func TestGrepTool_Execute_QuoteIsNotShell(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "f.txt"), []byte("it's here\n"), 0644)
+	tool := &GrepTool{workDir: dir}
+	result := tool.Execute(map[string]string{"pattern": "it's'; touch pwned; echo '", "path": "."})
+	if !result.Success || !strings.Contains(result.Output, "no matches") {
+		t.Errorf("Execute = %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Fatal("pattern was interpreted by a shell")
+	}
+	result = tool.Execute(map[string]string{"pattern": "it's", "path": "f.txt"})
+	if !strings.Contains(result.Output, "f.txt:1:it's here") {
+		t.Errorf("quoted pattern: %s", result.Output)
+	}
+}
+
+func TestGrepTool_Execute_Options(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "pkg", "sub"), 0755)
+	os.MkdirAll(filepath.Join(dir, "ignored"), 0755)
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored/\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "pkg", "a.go"), []byte("one\nTODO first\nthree\nfour\nfive\ntodo second\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "pkg", "sub", "a_test.go"), []byte("TODO test\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "pkg", "notes.md"), []byte("TODO notes\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "ignored", "x.go"), []byte("TODO ignored\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "blob.bin"), []byte("TODO\x00binary"), 0644)
+	tool := &GrepTool{workDir: dir}
+
+	result := tool.Execute(map[string]string{"pattern": "TODO", "include": "*.go", "exclude": "pkg/**/*_test.go"})
+	if !result.Success {
+		t.Fatalf("Execute: %s", result.Output)
+	}
+	if !strings.Contains(result.Output, "pkg/a.go:2:TODO first") {
+		t.Errorf("missing match: %s", result.Output)
+	}
+	for _, unwanted := range []string{"a_test.go", "notes.md", "ignored", "todo second", "blob.bin"} {
+		if strings.Contains(result.Output, unwanted+":") {
+			t.Errorf("output should not contain %q: %s", unwanted, result.Output)
+		}
+	}
+
+	result = tool.Execute(map[string]string{"pattern": "todo", "path": "pkg/a.go", "ignore_case": "true", "context": "1"})
+	want := "pkg/a.go-1-one\npkg/a.go:2:TODO first\npkg/a.go-3-three\n--\npkg/a.go-5-five\npkg/a.go:6:todo second\n"
+	if !strings.HasPrefix(result.Output, want) {
+		t.Errorf("context output:\n%s\nwant prefix:\n%s", result.Output, want)
+	}
+
+	result = tool.Execute(map[string]string{"pattern": "TODO", "max_results": "1", "include": "*.go,*.md"})
+	if strings.Count(result.Output, ":TODO") != 1 || !strings.Contains(result.Output, "results capped at 1") {
+		t.Errorf("max_results: %s", result.Output)
+	}
+
+	if result := tool.Execute(map[string]string{"pattern": "("}); result.Success {
+		t.Error("invalid regex should fail")
+	}
+}
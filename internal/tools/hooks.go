@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHookTimeout = time.Minute
+	maxHookDiagnostics = 20 // lines reported per hook
+)
+
+// EditHook is a check that runs after a file matching Glob is edited, e.g. "go vet {dir}".
+// In Command, {file} is replaced by the edited file and {dir} by its directory, both
+// relative to the project root ("./internal/tools" for {dir}, so Go package patterns work).
+type EditHook struct {
+	Glob    string        // comma-separated globs, matched like search_files patterns
+	Command string        // shell command run from the project root
+	Timeout time.Duration // default 1m
+}
+
+// editHooks runs the configured hooks through the shell tool's execution path.
+type editHooks struct {
+	hooks        []EditHook
+	shell        *ShellTool
+	containerDir string // "/workspace" when Docker is active
+}
+
+// run executes every hook whose glob matches file and returns a report to append to the
+// tool's output, or "" if no hook applies.
+func (h *editHooks) run(file string) string {
+	rel, ok := relPath(h.shell.workDir, file)
+	if !ok {
+		return ""
+	}
+	var sb strings.Builder
+	for _, hook := range h.hooks {
+		if !matchAnyGlob(splitGlobs(hook.Glob), rel) {
+			continue
+		}
+		command := expandHook(hook.Command, rel)
+		timeout := hook.Timeout
+		if timeout <= 0 {
+			timeout = defaultHookTimeout
+		}
+		stdout, stderr, exitCode, err := h.shell.runRaw(command, timeout, nil)
+		diags := parseDiagnostics(stdout+"\n"+stderr, h.shell.workDir, h.containerDir)
+		switch {
+		case err != nil:
+			sb.WriteString(fmt.Sprintf("  FAIL %s (%v)\n", command, err))
+		case exitCode != 0:
+			sb.WriteString(fmt.Sprintf("  FAIL %s (exit %d)\n", command, exitCode))
+		case len(diags) > 0:
+			sb.WriteString(fmt.Sprintf("  WARN %s\n", command))
+		default:
+			sb.WriteString(fmt.Sprintf("  ok   %s\n", command))
+		}
+		for i, d := range diags {
+			if i == maxHookDiagnostics {
+				sb.WriteString(fmt.Sprintf("    ... %d more\n", len(diags)-maxHookDiagnostics))
+				break
+			}
+			sb.WriteString("    " + d + "\n")
+		}
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "\n\nPost-edit checks:\n" + strings.TrimRight(sb.String(), "\n")
+}
+
+// expandHook substitutes {file} and {dir} in a hook command.
+func expandHook(command, rel string) string {
+	dir := "."
+	if d := path.Dir(rel); d != "." {
+		dir = "./" + d
+	}
+	command = strings.ReplaceAll(command, "{file}", shellQuote(rel))
+	return strings.ReplaceAll(command, "{dir}", shellQuote(dir))
+}
+
+var diagnosticRe = regexp.MustCompile(`^(.+?):(\d+)(?::\d+)?:\s*(.*)$`)
+
+// parseDiagnostics condenses compiler and linter output into "file:line: message" lines
+// with paths relative to the project root. Package headers ("# pkg") and blank lines are
+// dropped; other lines are kept as they are.
+func parseDiagnostics(output, workDir, containerDir string) []string {
+	var diags []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		if file, lineNo, msg, ok := splitDiagnostic(line); ok {
+			line = fmt.Sprintf("%s:%s: %s", trimRoot(file, workDir, containerDir), lineNo, msg)
+		} else {
+			line = trimRoot(line, workDir, containerDir)
+		}
+		if !seen[line] {
+			seen[line] = true
+			diags = append(diags, line)
+		}
+	}
+	return diags
+}
+
+// splitDiagnostic splits "[tool: ]file:line[:col]: message". The file part must look
+// like a path, so ordinary "key: value" lines are not mistaken for diagnostics.
+func splitDiagnostic(line string) (file, lineNo, msg string, ok bool) {
+	m := diagnosticRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", "", false
+	}
+	file = m[1]
+	if i := strings.LastIndex(file, ": "); i >= 0 {
+		file = file[i+2:] // "vet: a.go:3:1: ..." or "error: a.go:3: ..."
+	}
+	if !strings.ContainsAny(file, "./") {
+		return "", "", "", false
+	}
+	return file, m[2], m[3], true
+}
+
+// trimRoot makes a path relative to the project root (on the host or in the container).
+func trimRoot(p, workDir, containerDir string) string {
+	for _, root := range []string{workDir, containerDir} {
+		if root != "" && strings.HasPrefix(p, root+"/") {
+			return p[len(root)+1:]
+		}
+	}
+	return strings.TrimPrefix(p, "./")
+}
+
+// relPath returns p relative to workDir with forward slashes; ok is false if p lies outside.
+func relPath(workDir, p string) (string, bool) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(workDir, p)
+	}
+	rel, err := filepath.Rel(workDir, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_EditHooks(t *testing.T) {
+	dir := t.TempDir()
+	reg := DefaultRegistry(dir, nil)
+	defer reg.Close()
+	reg.SetEditHooks([]EditHook{
+		{Glob: "*.txt", Command: "echo {file}:1:2: checked in {dir}"},
+		{Glob: "docs/**", Command: "exit 3"},
+		{Glob: "*.md", Command: "echo never"},
+	})
+
+	r := reg.Execute("write_file", map[string]string{"path": "docs/a b.txt", "content": "x\n"})
+	if !r.Success {
+		t.Fatalf("write_file failed: %s", r.Output)
+	}
+	for _, want := range []string{
+		"Post-edit checks:",
+		"WARN echo 'docs/a b.txt':1:2: checked in ./docs",
+		"docs/a b.txt:1: checked in ./docs",
+		"FAIL exit 3 (exit 3)",
+	} {
+		if !strings.Contains(r.Output, want) {
+			t.Errorf("output missing %q:\n%s", want, r.Output)
+		}
+	}
+	if strings.Contains(r.Output, "never") {
+		t.Errorf("non-matching hook ran:\n%s", r.Output)
+	}
+
+	// Files no hook matches, and failed edits, get no report.
+	r = reg.Execute("write_file", map[string]string{"path": "main.go", "content": "package main\n"})
+	if strings.Contains(r.Output, "Post-edit") {
+		t.Errorf("unexpected hook report:\n%s", r.Output)
+	}
+	r = reg.Execute("str_replace", map[string]string{"path": "docs/a b.txt", "old_str": "missing", "new_str": "y"})
+	if r.Success || strings.Contains(r.Output, "Post-edit") {
+		t.Errorf("failed edit should not run hooks:\n%s", r.Output)
+	}
+}
+
+func TestRegistry_EditHooks_Passing(t *testing.T) {
+	dir := t.TempDir()
+	reg := DefaultRegistry(dir, nil)
+	defer reg.Close()
+	reg.SetEditHooks([]EditHook{{Glob: "*.go", Command: "test -f {file}"}})
+	r := reg.Execute("write_file", map[string]string{"path": filepath.Join(dir, "main.go"), "content": "package main\n"})
+	if !strings.HasSuffix(r.Output, "Post-edit checks:\n  ok   test -f main.go") {
+		t.Errorf("output = %q", r.Output)
+	}
+}
+
+func TestRegistry_EditHooks_GoVet(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n\ngo 1.21\n"), 0644)
+	reg := DefaultRegistry(dir, nil)
+	defer reg.Close()
+	reg.SetEditHooks([]EditHook{{Glob: "*.go", Command: "go vet {dir}"}})
+	r := reg.Execute("write_file", map[string]string{
+		"path":    "pkg/p.go",
+		"content": "package pkg\n\nfunc F() int {\n\treturn undefinedName\n}\n",
+	})
+	if !strings.Contains(r.Output, "FAIL go vet ./pkg") || !strings.Contains(r.Output, "pkg/p.go:4: undefined: undefinedName") {
+		t.Errorf("output:\n%s", r.Output)
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	out := "# example.com/m/pkg\n" +
+		"./pkg/a.go:3:9: undefined: x\n" +
+		"/home/u/proj/pkg/b.go:10: missing return\n" +
+		"/workspace/pkg/c.go:7:1: bad\n" +
+		"pkg/a.go:3:9: undefined: x\n" +
+		"\n" +
+		"vet: pkg/d.go:1:1: expected 'package'\n" +
+		"pkg/e.go\n" +
+		"note: see https://example.com\n"
+	got := parseDiagnostics(out, "/home/u/proj", "/workspace")
+	want := []string{
+		"pkg/a.go:3: undefined: x",
+		"pkg/b.go:10: missing return",
+		"pkg/c.go:7: bad",
+		"pkg/d.go:1: expected 'package'",
+		"pkg/e.go",
+		"note: see https://example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiagnostics =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestExpandHook(t *testing.T) {
+	tests := []struct{ command, rel, want string }{
+		{"gofmt -l {file}", "main.go", "gofmt -l main.go"},
+		{"go vet {dir}", "main.go", "go vet ."},
+		{"go vet {dir}", "internal/tools/a.go", "go vet ./internal/tools"},
+		{"cat {file}", "it's.txt", `cat 'it'\''s.txt'`},
+	}
+	for _, tt := range tests {
+		if got := expandHook(tt.command, tt.rel); got != tt.want {
+			t.Errorf("expandHook(%q, %q) = %q, want %q", tt.command, tt.rel, got, tt.want)
+		}
+	}
+}
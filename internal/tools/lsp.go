@@ -0,0 +1,323 @@
+package tools
+
+import (
+	"devagent/internal/lsp"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxLSPLocations   = 200
+	maxLSPDiagnostics = 200
+)
+
+// lspBase holds what the language server tools share: the project root and the
+// manager that starts servers on demand.
+type lspBase struct {
+	workDir string
+	mgr     *lsp.Manager
+}
+
+// lspTarget is a resolved path/line/column argument set.
+type lspTarget struct {
+	client    *lsp.Client
+	abs, rel  string
+	line, col int
+	word      string // identifier at the position, if any
+}
+
+var identRe = regexp.MustCompile(`[\p{L}_$][\p{L}\p{N}_$]*`)
+
+// target resolves path, line and column (or symbol, the first whole-word match on
+// the line; without either, the first identifier on the line) and the server for
+// the file.
+func (b *lspBase) target(args map[string]string) (*lspTarget, *Result) {
+	if strings.TrimSpace(args["path"]) == "" {
+		return nil, &Result{Success: false, Output: "empty path"}
+	}
+	abs, rel, info, res := navTarget(b.workDir, args["path"])
+	if res != nil {
+		return nil, res
+	}
+	if info.IsDir() {
+		return nil, &Result{Success: false, Output: fmt.Sprintf("%s is a directory", abs)}
+	}
+	line, err := intArg(args, "line", 0, 1<<30)
+	if err != nil {
+		return nil, &Result{Success: false, Output: err.Error()}
+	}
+	if line == 0 {
+		return nil, &Result{Success: false, Output: "line is required (1-based)"}
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, &Result{Success: false, Output: fmt.Sprintf("cannot read %s: %v", abs, err)}
+	}
+	lines := strings.Split(string(data), "\n")
+	if line > len(lines) {
+		return nil, &Result{Success: false, Output: fmt.Sprintf("line %d is out of range (%s has %d lines)", line, rel, len(lines))}
+	}
+	text := strings.TrimSuffix(lines[line-1], "\r")
+
+	t := &lspTarget{abs: abs, rel: rel, line: line}
+	col, err := intArg(args, "column", 0, len(text)+1)
+	if err != nil {
+		return nil, &Result{Success: false, Output: err.Error()}
+	}
+	symbol := strings.TrimSpace(args["symbol"])
+	for _, m := range identRe.FindAllStringIndex(text, -1) {
+		word := text[m[0]:m[1]]
+		if col > 0 {
+			if col-1 >= m[0] && col-1 < m[1] {
+				t.word = word
+				break
+			}
+		} else if symbol == "" || word == symbol {
+			t.col, t.word = m[0]+1, word
+			break
+		}
+	}
+	switch {
+	case col > 0:
+		t.col = col
+	case t.word == "" && symbol != "":
+		return nil, &Result{Success: false, Output: fmt.Sprintf("%q does not occur as a word on line %d of %s: %s", symbol, line, rel, strings.TrimSpace(text))}
+	case t.word == "":
+		return nil, &Result{Success: false, Output: fmt.Sprintf("no identifier on line %d of %s; pass column or symbol", line, rel)}
+	}
+
+	t.client, err = b.mgr.Client(abs)
+	if err != nil {
+		if errors.Is(err, lsp.ErrNoServer) {
+			return nil, &Result{Success: false, Output: fmt.Sprintf("%v; configure one under lsp.servers in .devagent/config.yaml, or use find_definition/find_references", err)}
+		}
+		return nil, &Result{Success: false, Output: fmt.Sprintf("language server unavailable: %v", err)}
+	}
+	return t, nil
+}
+
+// display returns p relative to the project when it is inside it.
+func (b *lspBase) display(p string) string {
+	if rel, ok := relPath(b.workDir, p); ok {
+		return rel
+	}
+	return p
+}
+
+// writeLocs lists locations as "file:line:col: text".
+func (b *lspBase) writeLocs(sb *strings.Builder, locs []lsp.Loc, limit int) {
+	files := map[string][]string{}
+	for i, l := range locs {
+		if i == limit {
+			sb.WriteString(fmt.Sprintf("... %d more\n", len(locs)-limit))
+			return
+		}
+		lines, ok := files[l.Path]
+		if !ok {
+			if data, err := os.ReadFile(l.Path); err == nil {
+				lines = strings.Split(string(data), "\n")
+			}
+			files[l.Path] = lines
+		}
+		text := ""
+		if l.Line-1 < len(lines) {
+			text = strings.TrimSpace(lines[l.Line-1])
+		}
+		sb.WriteString(fmt.Sprintf("%s:%d:%d: %s\n", b.display(l.Path), l.Line, l.Col, text))
+	}
+}
+
+// LSPDefinitionTool jumps to the declaration of the symbol at a position, as
+// resolved by the file's language server.
+type LSPDefinitionTool struct{ lspBase }
+
+func (t *LSPDefinitionTool) Name() string { return "lsp_definition" }
+
+func (t *LSPDefinitionTool) Execute(args map[string]string) Result {
+	tg, res := t.target(args)
+	if res != nil {
+		return *res
+	}
+	locs, err := tg.client.Definition(tg.abs, tg.line, tg.col)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("definition: %v", err)}
+	}
+	if len(locs) == 0 {
+		return Result{Success: true, Output: fmt.Sprintf("%s found no definition at %s:%d:%d", tg.client.Name(), tg.rel, tg.line, tg.col)}
+	}
+	var sb strings.Builder
+	t.writeLocs(&sb, locs, maxLSPLocations)
+	return Result{Success: true, Output: strings.TrimRight(sb.String(), "\n")}
+}
+
+// LSPReferencesTool lists every use of the symbol at a position.
+type LSPReferencesTool struct{ lspBase }
+
+func (t *LSPReferencesTool) Name() string { return "lsp_references" }
+
+func (t *LSPReferencesTool) Execute(args map[string]string) Result {
+	limit, err := intArg(args, "max_results", defaultRefResults, maxRefResults)
+	if err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	if limit == 0 {
+		limit = defaultRefResults
+	}
+	tg, res := t.target(args)
+	if res != nil {
+		return *res
+	}
+	locs, err := tg.client.References(tg.abs, tg.line, tg.col)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("references: %v", err)}
+	}
+	if len(locs) == 0 {
+		return Result{Success: true, Output: fmt.Sprintf("%s found no references at %s:%d:%d", tg.client.Name(), tg.rel, tg.line, tg.col)}
+	}
+	var sb strings.Builder
+	what := tg.word
+	if what == "" {
+		what = fmt.Sprintf("%s:%d:%d", tg.rel, tg.line, tg.col)
+	}
+	sb.WriteString(fmt.Sprintf("%d references to %s (including the declaration):\n", len(locs), what))
+	t.writeLocs(&sb, locs, limit)
+	return Result{Success: true, Output: strings.TrimRight(sb.String(), "\n")}
+}
+
+// LSPHoverTool shows the type and documentation of the symbol at a position.
+type LSPHoverTool struct{ lspBase }
+
+func (t *LSPHoverTool) Name() string { return "lsp_hover" }
+
+func (t *LSPHoverTool) Execute(args map[string]string) Result {
+	tg, res := t.target(args)
+	if res != nil {
+		return *res
+	}
+	text, err := tg.client.Hover(tg.abs, tg.line, tg.col)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("hover: %v", err)}
+	}
+	if text == "" {
+		return Result{Success: true, Output: fmt.Sprintf("%s has no information for %s:%d:%d", tg.client.Name(), tg.rel, tg.line, tg.col)}
+	}
+	return Result{Success: true, Output: truncateOutput(text)}
+}
+
+// LSPRenameTool renames the symbol at a position in every file that uses it.
+type LSPRenameTool struct {
+	lspBase
+	txn *TxnManager
+}
+
+func (t *LSPRenameTool) Name() string { return "lsp_rename" }
+
+func (t *LSPRenameTool) Execute(args map[string]string) Result {
+	newName := strings.TrimSpace(args["new_name"])
+	if newName == "" {
+		return Result{Success: false, Output: "empty new_name"}
+	}
+	tg, res := t.target(args)
+	if res != nil {
+		return *res
+	}
+	edit, err := tg.client.Rename(tg.abs, tg.line, tg.col, newName)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("rename: %v", err)}
+	}
+	files := edit.Files()
+	if len(files) == 0 {
+		return Result{Success: false, Output: fmt.Sprintf("%s proposed no changes for renaming at %s:%d:%d", tg.client.Name(), tg.rel, tg.line, tg.col)}
+	}
+	for _, f := range files {
+		if _, ok := relPath(t.workDir, f); !ok {
+			return Result{Success: false, Output: fmt.Sprintf("rename would change %s, outside the project; nothing was changed", f)}
+		}
+	}
+	if t.txn != nil {
+		for _, f := range files {
+			if err := t.txn.Track(f); err != nil {
+				return Result{Success: false, Output: fmt.Sprintf("transaction: %v", err)}
+			}
+		}
+	}
+	if err := edit.Apply(); err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("rename: %v", err)}
+	}
+
+	var sb strings.Builder
+	from := tg.word
+	if from == "" {
+		from = "symbol"
+	}
+	total := 0
+	for _, f := range files {
+		total += edit.Count(f)
+	}
+	sb.WriteString(fmt.Sprintf("Renamed %s to %s: %d changes in %d files\n", from, newName, total, len(files)))
+	for _, f := range files {
+		tg.client.Sync(f)
+		sb.WriteString(fmt.Sprintf("  %s (%d)\n", t.display(f), edit.Count(f)))
+	}
+	sb.WriteString("Re-read these files before editing them again.")
+	return Result{Success: true, Output: sb.String()}
+}
+
+// LSPDiagnosticsTool reports the language server's errors and warnings for a file,
+// or everything reported so far when no path is given.
+type LSPDiagnosticsTool struct{ lspBase }
+
+func (t *LSPDiagnosticsTool) Name() string { return "lsp_diagnostics" }
+
+func (t *LSPDiagnosticsTool) Execute(args map[string]string) Result {
+	var diags []lsp.FileDiagnostic
+	note := ""
+	if strings.TrimSpace(args["path"]) == "" {
+		diags = t.mgr.Diagnostics()
+		if len(diags) == 0 {
+			return Result{Success: true, Output: "no diagnostics reported by running language servers (pass path to check a file)"}
+		}
+	} else {
+		abs, rel, info, res := navTarget(t.workDir, args["path"])
+		if res != nil {
+			return *res
+		}
+		if info.IsDir() {
+			return Result{Success: false, Output: fmt.Sprintf("%s is a directory; pass a file, or no path for every file checked so far", abs)}
+		}
+		c, err := t.mgr.Client(abs)
+		if err != nil {
+			return Result{Success: false, Output: fmt.Sprintf("language server unavailable: %v", err)}
+		}
+		var fresh bool
+		diags, fresh, err = c.Diagnostics(abs)
+		if err != nil {
+			return Result{Success: false, Output: fmt.Sprintf("diagnostics: %v", err)}
+		}
+		if !fresh {
+			note = fmt.Sprintf("(%s has not reported on the current content yet; results may be outdated)\n", c.Name())
+		}
+		if len(diags) == 0 {
+			return Result{Success: true, Output: note + fmt.Sprintf("no problems reported for %s", rel)}
+		}
+	}
+
+	errs := 0
+	lines := make([]string, 0, len(diags))
+	for _, d := range diags {
+		if d.Severity == "error" {
+			errs++
+		}
+		msg := strings.ReplaceAll(strings.TrimSpace(d.Message), "\n", "\n    ")
+		src := ""
+		if d.Source != "" {
+			src = " [" + d.Source + "]"
+		}
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %s: %s%s", t.display(d.Path), d.Line, d.Col, d.Severity, msg, src))
+	}
+	header := fmt.Sprintf("%d problems (%d errors)", len(diags), errs)
+	return Result{Success: true, Output: note + header + "\n" + joinCapped(lines, maxLSPDiagnostics)}
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"devagent/internal/lsp"
+	"devagent/internal/lsp/lsptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	lsptest.Main()
+	os.Exit(m.Run())
+}
+
+// lspRegistry returns a registry whose lsp_* tools use the fake server for .fake files.
+func lspRegistry(t *testing.T, files map[string]string) (*Registry, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	reg := DefaultRegistry(dir, nil)
+	reg.SetLSP(lsp.NewManager(dir, []lsp.ServerConfig{
+		{Name: "fake", Command: lsptest.Command(t), Extensions: []string{".fake"}},
+	}, 5*time.Second))
+	t.Cleanup(reg.Close)
+	return reg, dir
+}
+
+const lspGreet = "func greet() {}\n\nfunc main() {\n\tgreet()\n}\n"
+
+func TestLSPNavigationTools(t *testing.T) {
+	reg, _ := lspRegistry(t, map[string]string{"a.fake": lspGreet, "b.fake": "x := greet()\n", "notes.md": "# x\n"})
+
+	res := reg.Execute("lsp_definition", map[string]string{"path": "b.fake", "line": "1", "symbol": "greet"})
+	if !res.Success || res.Output != "a.fake:1:6: func greet() {}" {
+		t.Errorf("lsp_definition = %+v", res)
+	}
+
+	res = reg.Execute("lsp_references", map[string]string{"path": "a.fake", "line": "4"})
+	want := "3 references to greet (including the declaration):\na.fake:1:6: func greet() {}\na.fake:4:2: greet()\nb.fake:1:6: x := greet()"
+	if !res.Success || res.Output != want {
+		t.Errorf("lsp_references =\n%s\nwant\n%s", res.Output, want)
+	}
+	res = reg.Execute("lsp_references", map[string]string{"path": "b.fake", "line": "1", "column": "7", "max_results": "1"})
+	if !strings.Contains(res.Output, "to greet") || !strings.HasSuffix(res.Output, "... 2 more") {
+		t.Errorf("lsp_references by column = %s", res.Output)
+	}
+
+	res = reg.Execute("lsp_hover", map[string]string{"path": "a.fake", "line": "4", "symbol": "greet"})
+	if !res.Success || !strings.Contains(res.Output, "func greet() {}") {
+		t.Errorf("lsp_hover = %+v", res)
+	}
+
+	for _, tc := range []struct {
+		args map[string]string
+		want string
+	}{
+		{map[string]string{"path": "a.fake", "line": "1", "symbol": "nope"}, `"nope" does not occur`},
+		{map[string]string{"path": "a.fake", "line": "2"}, "no identifier on line 2"},
+		{map[string]string{"path": "a.fake"}, "line is required"},
+		{map[string]string{"path": "a.fake", "line": "99"}, "out of range"},
+		{map[string]string{"path": "notes.md", "line": "1"}, "lsp.servers"},
+	} {
+		if res := reg.Execute("lsp_hover", tc.args); res.Success || !strings.Contains(res.Output, tc.want) {
+			t.Errorf("lsp_hover(%v) = %+v, want error containing %q", tc.args, res, tc.want)
+		}
+	}
+}
+
+func TestLSPDiagnosticsTool(t *testing.T) {
+	reg, _ := lspRegistry(t, map[string]string{"c.fake": "fine\n"})
+
+	if res := reg.Execute("lsp_diagnostics", map[string]string{"path": "c.fake"}); !res.Success || res.Output != "no problems reported for c.fake" {
+		t.Errorf("clean file = %+v", res)
+	}
+	// Edits made through the edit tools reach the server.
+	reg.Execute("write_file", map[string]string{"path": "c.fake", "content": "fine\nan ERROR\n"})
+	res := reg.Execute("lsp_diagnostics", map[string]string{"path": "c.fake"})
+	if want := "1 problems (1 errors)\nc.fake:2:4: error: found ERROR [fake]"; res.Output != want {
+		t.Errorf("lsp_diagnostics =\n%s\nwant\n%s", res.Output, want)
+	}
+	if res := reg.Execute("lsp_diagnostics", map[string]string{}); !strings.Contains(res.Output, "c.fake:2:4") {
+		t.Errorf("all diagnostics = %s", res.Output)
+	}
+
+	reg.Execute("str_replace", map[string]string{"path": "c.fake", "old_str": "an ERROR", "new_str": "ok"})
+	if res := reg.Execute("lsp_diagnostics", map[string]string{"path": "c.fake"}); res.Output != "no problems reported for c.fake" {
+		t.Errorf("after fix = %s", res.Output)
+	}
+}
+
+func TestLSPRenameTool(t *testing.T) {
+	reg, dir := lspRegistry(t, map[string]string{"a.fake": lspGreet, "b.fake": "x := greet()\n"})
+
+	reg.Execute("begin_transaction", nil)
+	res := reg.Execute("lsp_rename", map[string]string{"path": "a.fake", "line": "1", "symbol": "greet", "new_name": "welcome"})
+	if !res.Success || !strings.HasPrefix(res.Output, "Renamed greet to welcome: 3 changes in 2 files\n  a.fake (2)\n  b.fake (1)") {
+		t.Fatalf("lsp_rename = %+v", res)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "b.fake"))
+	if string(data) != "x := welcome()\n" {
+		t.Errorf("b.fake = %q", data)
+	}
+
+	// Renamed files are part of the transaction.
+	if res := reg.Execute("rollback_transaction", nil); !res.Success {
+		t.Fatal(res.Output)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "a.fake"))
+	if string(data) != lspGreet {
+		t.Errorf("a.fake after rollback = %q", data)
+	}
+
+	if res := reg.Execute("lsp_rename", map[string]string{"path": "a.fake", "line": "1"}); res.Success || res.Output != "empty new_name" {
+		t.Errorf("missing new_name = %+v", res)
+	}
+}
@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"devagent/internal/memory"
+	"fmt"
+	"strings"
+)
+
+// RememberTool records a durable fact in the project's or the user's MEMORY.md, so
+// later runs start with it instead of rediscovering it.
+type RememberTool struct {
+	store *memory.Store
+}
+
+func NewRememberTool(store *memory.Store) *RememberTool {
+	return &RememberTool{store: store}
+}
+
+func (t *RememberTool) Name() string { return "remember" }
+
+func (t *RememberTool) Execute(args map[string]string) Result {
+	scope, err := memory.ParseScope(args["scope"])
+	if err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	fact := strings.TrimSpace(args["fact"])
+	added, err := t.store.Add(scope, args["section"], fact)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("remember: %v", err)}
+	}
+	file := ".devagent/" + memory.FileName
+	if scope == memory.User {
+		file = "~/.devagent/" + memory.FileName
+	}
+	if !added {
+		return Result{Success: true, Output: fmt.Sprintf("Already in %s: %s", file, fact)}
+	}
+	return Result{Success: true, Output: fmt.Sprintf("Remembered in %s: %s", file, fact)}
+}
@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRememberTool(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := t.TempDir()
+	reg := DefaultRegistry(dir, nil)
+
+	res := reg.Execute("remember", map[string]string{"fact": "Run go vet ./... before committing", "section": "Build"})
+	if !res.Success || res.Output != "Remembered in .devagent/MEMORY.md: Run go vet ./... before committing" {
+		t.Fatalf("remember: %+v", res)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ".devagent", "MEMORY.md"))
+	if !strings.Contains(string(data), "## Build\n\n- Run go vet ./... before committing\n") {
+		t.Errorf("MEMORY.md =\n%s", data)
+	}
+	if res := reg.Execute("remember", map[string]string{"fact": "run go vet ./... before committing"}); !res.Success || !strings.HasPrefix(res.Output, "Already in") {
+		t.Errorf("duplicate: %+v", res)
+	}
+
+	res = reg.Execute("remember", map[string]string{"fact": "Answers in English", "scope": "user"})
+	if !res.Success || !strings.Contains(res.Output, "~/.devagent/MEMORY.md") {
+		t.Errorf("user scope: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".devagent", "MEMORY.md")); err != nil {
+		t.Errorf("user memory not written: %v", err)
+	}
+
+	for _, args := range []map[string]string{{"fact": ""}, {"fact": "x", "scope": "team"}} {
+		if res := reg.Execute("remember", args); res.Success {
+			t.Errorf("%v should fail: %s", args, res.Output)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"devagent/internal/codenav"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	maxDefinitions     = 20
+	maxRefTargets      = 5 // definitions whose references are listed for an ambiguous name
+	defaultRefResults  = 100
+	maxRefResults      = 1000
+	maxSymbolListLines = 500
+)
+
+// projectCache keeps the project loaded by find_definition and find_references
+// between calls, so it is parsed and type-checked once until a tool changes files.
+type projectCache struct {
+	mu   sync.Mutex
+	proj *codenav.Project
+}
+
+// acquire returns the cached project, loading it if there is none, and holds the
+// cache until release is called: the project type-checks lazily and is not safe
+// for concurrent use. A nil cache loads the project on every call.
+func (c *projectCache) acquire(workDir string) (proj *codenav.Project, release func(), err error) {
+	if c == nil {
+		proj, err = codenav.Load(workDir)
+		return proj, func() {}, err
+	}
+	c.mu.Lock()
+	if c.proj == nil {
+		if c.proj, err = codenav.Load(workDir); err != nil {
+			c.mu.Unlock()
+			return nil, nil, err
+		}
+	}
+	return c.proj, c.mu.Unlock, nil
+}
+
+// invalidate drops the cached project; the next call loads it again.
+func (c *projectCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.proj = nil
+	c.mu.Unlock()
+}
+
+// FindDefinitionTool locates declarations by name. Go code is parsed; other languages
+// are matched by declaration keywords.
+type FindDefinitionTool struct {
+	workDir  string
+	projects *projectCache
+}
+
+func (t *FindDefinitionTool) Name() string { return "find_definition" }
+
+func (t *FindDefinitionTool) Execute(args map[string]string) Result {
+	name := strings.TrimSpace(args["name"])
+	if name == "" {
+		return Result{Success: false, Output: "empty name"}
+	}
+	proj, release, err := t.projects.acquire(t.workDir)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("cannot index project: %v", err)}
+	}
+	defer release()
+	defs := proj.Definitions(name)
+	if len(defs) == 0 {
+		return Result{Success: true, Output: fmt.Sprintf("no definition found for %q (try grep, or list_symbols on the package)", name)}
+	}
+	var sb strings.Builder
+	for i, d := range defs {
+		if i == maxDefinitions {
+			sb.WriteString(fmt.Sprintf("... %d more; qualify the name (e.g. pkg.Name or Type.Method)\n", len(defs)-maxDefinitions))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("%s  %s\n", lineRange(d), d.Signature))
+	}
+	return Result{Success: true, Output: sb.String()}
+}
+
+// FindReferencesTool lists the uses of a declaration. For Go the references are
+// resolved with the type checker; otherwise whole-word matches are reported.
+type FindReferencesTool struct {
+	workDir  string
+	projects *projectCache
+}
+
+func (t *FindReferencesTool) Name() string { return "find_references" }
+
+func (t *FindReferencesTool) Execute(args map[string]string) Result {
+	name := strings.TrimSpace(args["name"])
+	if name == "" {
+		return Result{Success: false, Output: "empty name"}
+	}
+	limit, err := intArg(args, "max_results", defaultRefResults, maxRefResults)
+	if err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	if limit == 0 {
+		limit = defaultRefResults
+	}
+	proj, release, err := t.projects.acquire(t.workDir)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("cannot index project: %v", err)}
+	}
+	defer release()
+
+	var sb strings.Builder
+	resolved := 0
+	defs := proj.Definitions(name)
+	for _, d := range defs {
+		if resolved == maxRefTargets {
+			sb.WriteString(fmt.Sprintf("... %d more definitions named %q; qualify the name (e.g. pkg.Name or Type.Method)\n", len(defs)-resolved, name))
+			break
+		}
+		refs, err := proj.References(d)
+		if err != nil {
+			continue // not Go: covered by the text search below
+		}
+		resolved++
+		sb.WriteString(fmt.Sprintf("%s (%s): %d references\n", d.Signature, d.Loc(), len(refs)))
+		writeLocations(&sb, refs, limit)
+		sb.WriteString("\n")
+	}
+	if resolved > 0 {
+		return Result{Success: true, Output: strings.TrimRight(sb.String(), "\n")}
+	}
+
+	word := name
+	if i := strings.LastIndexAny(word, ".)"); i >= 0 {
+		word = word[i+1:]
+	}
+	refs := proj.TextReferences(word, limit+1)
+	if len(refs) == 0 {
+		return Result{Success: true, Output: fmt.Sprintf("no references found for %q", name)}
+	}
+	sb.WriteString(fmt.Sprintf("Whole-word matches for %q (text search, not type-checked):\n", word))
+	writeLocations(&sb, refs, limit)
+	return Result{Success: true, Output: sb.String()}
+}
+
+func writeLocations(sb *strings.Builder, locs []codenav.Location, limit int) {
+	for i, l := range locs {
+		if i == limit {
+			sb.WriteString(fmt.Sprintf("... more results; raise max_results (max %d)\n", maxRefResults))
+			return
+		}
+		sb.WriteString(fmt.Sprintf("%s:%d: %s\n", l.File, l.Line, l.Text))
+	}
+}
+
+// ListSymbolsTool lists the declarations of a file or of the package in a directory.
+type ListSymbolsTool struct {
+	workDir string
+}
+
+func (t *ListSymbolsTool) Name() string { return "list_symbols" }
+
+func (t *ListSymbolsTool) Execute(args map[string]string) Result {
+	abs, rel, info, res := navTarget(t.workDir, args["path"])
+	if res != nil {
+		return *res
+	}
+	var syms []codenav.Symbol
+	var err error
+	if info.IsDir() {
+		syms, err = codenav.DirSymbols(t.workDir, rel, isTrue(args["tests"]))
+	} else {
+		syms, err = codenav.FileSymbols(t.workDir, rel)
+	}
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("cannot list symbols in %s: %v", abs, err)}
+	}
+	exported := isTrue(args["exported"])
+	var lines []string
+	for _, s := range syms {
+		if s.Kind == "field" || (exported && !s.Exported) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s", s.Loc(), s.Signature))
+	}
+	if len(lines) == 0 {
+		return Result{Success: true, Output: fmt.Sprintf("no symbols found in %s", rel)}
+	}
+	return Result{Success: true, Output: joinCapped(lines, maxSymbolListLines)}
+}
+
+// OutlineTool shows the structure of one file: declarations with their line ranges,
+// nested members indented under their type or class.
+type OutlineTool struct {
+	workDir string
+}
+
+func (t *OutlineTool) Name() string { return "outline" }
+
+func (t *OutlineTool) Execute(args map[string]string) Result {
+	if strings.TrimSpace(args["path"]) == "" {
+		return Result{Success: false, Output: "empty path"}
+	}
+	abs, rel, info, res := navTarget(t.workDir, args["path"])
+	if res != nil {
+		return *res
+	}
+	if info.IsDir() {
+		return Result{Success: false, Output: fmt.Sprintf("%s is a directory; outline takes a file (use list_symbols for a package)", abs)}
+	}
+	syms, err := codenav.FileSymbols(t.workDir, rel)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("cannot outline %s: %v", abs, err)}
+	}
+	if len(syms) == 0 {
+		return Result{Success: true, Output: fmt.Sprintf("no declarations found in %s", rel)}
+	}
+	lines := []string{rel + ":"}
+	if syms[0].Package != "" {
+		lines[0] = fmt.Sprintf("%s (package %s):", rel, syms[0].Package)
+	}
+	for _, s := range syms {
+		span := fmt.Sprintf("%d", s.Line)
+		if s.EndLine > s.Line {
+			span = fmt.Sprintf("%d-%d", s.Line, s.EndLine)
+		}
+		lines = append(lines, fmt.Sprintf("%s%-9s %s", strings.Repeat("  ", s.Depth+1), span, s.Signature))
+	}
+	return Result{Success: true, Output: joinCapped(lines, maxSymbolListLines)}
+}
+
+// navTarget resolves a path argument inside the working directory ("" means the root).
+func navTarget(workDir, p string) (abs, rel string, info os.FileInfo, res *Result) {
+	abs = p
+	if abs == "" {
+		abs = workDir
+	} else if !filepath.IsAbs(abs) {
+		abs = filepath.Join(workDir, abs)
+	}
+	rel, ok := relPath(workDir, abs)
+	if !ok {
+		return "", "", nil, &Result{Success: false, Output: fmt.Sprintf("%s is outside the project", p)}
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", "", nil, &Result{Success: false, Output: fmt.Sprintf("cannot access %s: %v", abs, err)}
+	}
+	return abs, rel, info, nil
+}
+
+// lineRange formats a symbol's location as "file:start-end".
+func lineRange(s codenav.Symbol) string {
+	if s.EndLine > s.Line {
+		return fmt.Sprintf("%s-%d", s.Loc(), s.EndLine)
+	}
+	return s.Loc()
+}
+
+func joinCapped(lines []string, max int) string {
+	if len(lines) > max {
+		extra := len(lines) - max
+		lines = append(lines[:max], fmt.Sprintf("... %d more", extra))
+	}
+	return strings.Join(lines, "\n")
+}
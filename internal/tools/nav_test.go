@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func navFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+// Store keeps values.
+type Store struct {
+	items map[string]string
+}
+
+func (s *Store) Get(key string) string { return s.items[key] }
+
+func helper() {}
+`,
+		"main.go": `package main
+
+import "example.com/app/store"
+
+func main() {
+	var s store.Store
+	_ = s.Get("a")
+	_ = s.Get("b")
+}
+`,
+		"scripts/tool.py": "class Runner:\n    def get(self):\n        return Runner()\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFindDefinitionTool(t *testing.T) {
+	dir := navFixture(t)
+	tool := &FindDefinitionTool{workDir: dir}
+	r := tool.Execute(map[string]string{"name": "Store.Get"})
+	if !r.Success || r.Output != "store/store.go:8  func (s *Store) Get(key string) string\n" {
+		t.Errorf("output = %q", r.Output)
+	}
+	r = tool.Execute(map[string]string{"name": "Store"})
+	if !strings.Contains(r.Output, "store/store.go:4-6  type Store struct") {
+		t.Errorf("output = %q", r.Output)
+	}
+	r = tool.Execute(map[string]string{"name": "Runner"})
+	if !strings.Contains(r.Output, "scripts/tool.py:1  class Runner") {
+		t.Errorf("fallback output = %q", r.Output)
+	}
+	r = tool.Execute(map[string]string{"name": "Nope"})
+	if !r.Success || !strings.Contains(r.Output, "no definition found") {
+		t.Errorf("output = %q", r.Output)
+	}
+	if r := tool.Execute(map[string]string{}); r.Success {
+		t.Error("empty name should fail")
+	}
+}
+
+func TestFindReferencesTool(t *testing.T) {
+	dir := navFixture(t)
+	tool := &FindReferencesTool{workDir: dir}
+	r := tool.Execute(map[string]string{"name": "store.Store.Get"})
+	want := "func (s *Store) Get(key string) string (store/store.go:8): 2 references\n" +
+		"main.go:7: _ = s.Get(\"a\")\n" +
+		"main.go:8: _ = s.Get(\"b\")"
+	if !r.Success || r.Output != want {
+		t.Errorf("output =\n%s\nwant\n%s", r.Output, want)
+	}
+
+	r = tool.Execute(map[string]string{"name": "Store.Get", "max_results": "1"})
+	if !strings.Contains(r.Output, "main.go:7:") || strings.Contains(r.Output, "main.go:8:") || !strings.Contains(r.Output, "raise max_results") {
+		t.Errorf("limit not applied: %s", r.Output)
+	}
+
+	// Non-Go symbols fall back to whole-word matches.
+	r = tool.Execute(map[string]string{"name": "Runner"})
+	if !strings.Contains(r.Output, "text search") || !strings.Contains(r.Output, "scripts/tool.py:3: return Runner()") {
+		t.Errorf("fallback output = %s", r.Output)
+	}
+}
+
+func TestRegistry_CachesNavProject(t *testing.T) {
+	dir := navFixture(t)
+	reg := DefaultRegistry(dir, nil)
+	defer reg.Close()
+	if r := reg.Execute("find_definition", map[string]string{"name": "helper"}); !strings.Contains(r.Output, "store/store.go:10") {
+		t.Fatalf("output = %q", r.Output)
+	}
+
+	// Changes made behind the registry's back are not seen: the project is cached.
+	extra := filepath.Join(dir, "store", "extra.go")
+	os.WriteFile(extra, []byte("package store\n\nfunc Extra() {}\n"), 0644)
+	if r := reg.Execute("find_definition", map[string]string{"name": "Extra"}); !strings.Contains(r.Output, "no definition found") {
+		t.Errorf("expected the cached project, got %q", r.Output)
+	}
+
+	// A tool that may change files drops it.
+	if r := reg.Execute("write_file", map[string]string{"path": "store/more.go", "content": "package store\n\nfunc More() {}\n"}); !r.Success {
+		t.Fatalf("write_file: %s", r.Output)
+	}
+	if r := reg.Execute("find_definition", map[string]string{"name": "Extra"}); !strings.Contains(r.Output, "store/extra.go:3  func Extra()") {
+		t.Errorf("output = %q", r.Output)
+	}
+	if r := reg.Execute("find_references", map[string]string{"name": "More"}); !strings.Contains(r.Output, "func More() (store/more.go:3): 0 references") {
+		t.Errorf("output = %q", r.Output)
+	}
+}
+
+func TestListSymbolsTool(t *testing.T) {
+	dir := navFixture(t)
+	tool := &ListSymbolsTool{workDir: dir}
+	r := tool.Execute(map[string]string{"path": "store"})
+	want := "store/store.go:4  type Store struct\nstore/store.go:8  func (s *Store) Get(key string) string\nstore/store.go:10  func helper()"
+	if !r.Success || r.Output != want {
+		t.Errorf("output =\n%s\nwant\n%s", r.Output, want)
+	}
+	r = tool.Execute(map[string]string{"path": "store", "exported": "true"})
+	if strings.Contains(r.Output, "helper") {
+		t.Errorf("unexported symbol listed: %s", r.Output)
+	}
+	r = tool.Execute(map[string]string{"path": "main.go"})
+	if r.Output != "main.go:5  func main()" {
+		t.Errorf("file output = %q", r.Output)
+	}
+	if r := tool.Execute(map[string]string{"path": "missing"}); r.Success {
+		t.Error("missing path should fail")
+	}
+	if r := tool.Execute(map[string]string{"path": "../.."}); r.Success {
+		t.Error("path outside the project should fail")
+	}
+}
+
+func TestOutlineTool(t *testing.T) {
+	dir := navFixture(t)
+	tool := &OutlineTool{workDir: dir}
+	r := tool.Execute(map[string]string{"path": "store/store.go"})
+	want := "store/store.go (package store):\n" +
+		"  4-6       type Store struct\n" +
+		"    5         items map[string]string\n" +
+		"    8         func (s *Store) Get(key string) string\n" +
+		"  10        func helper()"
+	if !r.Success || r.Output != want {
+		t.Errorf("output =\n%s\nwant\n%s", r.Output, want)
+	}
+	r = tool.Execute(map[string]string{"path": filepath.Join(dir, "scripts", "tool.py")})
+	if !strings.Contains(r.Output, "  1         class Runner\n    2         def get(self)") {
+		t.Errorf("python outline = %q", r.Output)
+	}
+	if r := tool.Execute(map[string]string{"path": "store"}); r.Success {
+		t.Error("outline of a directory should fail")
+	}
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"devagent/internal/artifact"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadOutputTool pages or greps through the full output of an earlier tool call,
+// saved by the agent when the observation had to be shortened.
+type ReadOutputTool struct {
+	store *artifact.Store
+}
+
+func NewReadOutputTool(store *artifact.Store) *ReadOutputTool {
+	return &ReadOutputTool{store: store}
+}
+
+func (t *ReadOutputTool) Name() string { return "read_output" }
+
+func (t *ReadOutputTool) Execute(args map[string]string) Result {
+	idArg := strings.TrimPrefix(strings.TrimSpace(args["id"]), "#")
+	if idArg == "" {
+		return t.list()
+	}
+	id, err := strconv.Atoi(idArg)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("invalid id %q", args["id"])}
+	}
+	out, err := t.store.Get(id)
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("%v (use read_output without an id to list saved outputs)", err)}
+	}
+
+	if pattern := args["pattern"]; pattern != "" {
+		grep := &GrepTool{workDir: filepath.Dir(out.Path)}
+		return grep.Execute(map[string]string{
+			"path":        out.Path,
+			"pattern":     pattern,
+			"context":     args["context"],
+			"ignore_case": args["ignore_case"],
+			"max_results": args["max_results"],
+		})
+	}
+	read := &ReadFileTool{workDir: filepath.Dir(out.Path)}
+	return read.Execute(map[string]string{"path": out.Path, "offset": args["offset"], "limit": args["limit"]})
+}
+
+func (t *ReadOutputTool) list() Result {
+	list, err := t.store.List()
+	if err != nil {
+		return Result{Success: false, Output: fmt.Sprintf("cannot list outputs: %v", err)}
+	}
+	if len(list) == 0 {
+		return Result{Success: true, Output: "no saved outputs"}
+	}
+	var sb strings.Builder
+	for _, o := range list {
+		sb.WriteString(fmt.Sprintf("#%d  %s  (%s)\n", o.ID, o.Tool, formatSize(o.Size)))
+	}
+	return Result{Success: true, Output: sb.String()}
+}
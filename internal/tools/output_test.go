@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"devagent/internal/artifact"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadOutputTool(t *testing.T) {
+	store, err := artifact.Open(t.TempDir(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := NewReadOutputTool(store)
+	if r := tool.Execute(map[string]string{}); r.Output != "no saved outputs" {
+		t.Errorf("empty list = %q", r.Output)
+	}
+
+	var sb strings.Builder
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	sb.WriteString("main.go:12: undefined: foo\n")
+	id, _ := store.Save("shell", sb.String())
+
+	r := tool.Execute(map[string]string{"id": fmt.Sprint(id), "offset": "999", "limit": "2"})
+	if !strings.Contains(r.Output, " 999 | line 999") || !strings.Contains(r.Output, "1000 | line 1000") || strings.Contains(r.Output, "undefined") {
+		t.Errorf("paging: %q", r.Output)
+	}
+	r = tool.Execute(map[string]string{"id": "#1", "pattern": "undefined"})
+	if !strings.Contains(r.Output, ":1001:main.go:12: undefined: foo") {
+		t.Errorf("pattern: %q", r.Output)
+	}
+	if r := tool.Execute(map[string]string{}); !strings.Contains(r.Output, "#1  shell") {
+		t.Errorf("list = %q", r.Output)
+	}
+	if r := tool.Execute(map[string]string{"id": "5"}); r.Success {
+		t.Error("unknown id should fail")
+	}
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"devagent/internal/plan"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UpdatePlanTool edits the task's plan: it replaces the whole list, adds a step or
+// sets a step's status. The output is the updated plan.
+type UpdatePlanTool struct {
+	plan *plan.Plan
+}
+
+func NewUpdatePlanTool(p *plan.Plan) *UpdatePlanTool {
+	return &UpdatePlanTool{plan: p}
+}
+
+func (t *UpdatePlanTool) Name() string { return "update_plan" }
+
+func (t *UpdatePlanTool) Execute(args map[string]string) Result {
+	text, add, step := strings.TrimSpace(args["plan"]), strings.TrimSpace(args["add"]), strings.TrimSpace(args["step"])
+	if text == "" && add == "" && step == "" {
+		return Result{Success: false, Output: `update_plan needs "plan" (a numbered list replacing the plan), "add" (a new step) or "step" with "status"`}
+	}
+
+	if text != "" {
+		steps, err := plan.Parse(text)
+		if err != nil {
+			return Result{Success: false, Output: err.Error()}
+		}
+		t.plan.Set(steps)
+	}
+	if add != "" {
+		after := -1
+		if s := strings.TrimSpace(args["after"]); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return Result{Success: false, Output: fmt.Sprintf("invalid after %q: want a step number", s)}
+			}
+			after = n
+		}
+		if _, err := t.plan.Add(add, after); err != nil {
+			return Result{Success: false, Output: err.Error()}
+		}
+	}
+	if step != "" {
+		status, err := plan.ParseStatus(args["status"])
+		if err != nil {
+			return Result{Success: false, Output: err.Error()}
+		}
+		for _, s := range strings.Split(step, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return Result{Success: false, Output: fmt.Sprintf("invalid step %q: want a step number", s)}
+			}
+			if err := t.plan.Mark(n, status); err != nil {
+				return Result{Success: false, Output: err.Error()}
+			}
+		}
+	}
+	return Result{Success: true, Output: "Plan updated:\n" + t.plan.String()}
+}
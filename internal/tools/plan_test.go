@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"devagent/internal/plan"
+	"strings"
+	"testing"
+)
+
+func TestUpdatePlanTool(t *testing.T) {
+	p := plan.New()
+	tool := NewUpdatePlanTool(p)
+	if tool.Name() != "update_plan" {
+		t.Errorf("Name() = %q", tool.Name())
+	}
+	if res := tool.Execute(map[string]string{}); res.Success {
+		t.Error("update_plan without arguments should fail")
+	}
+
+	res := tool.Execute(map[string]string{"plan": "1. Read config.go\n2. Add the timeout field\n3. Run the tests"})
+	if !res.Success || !strings.Contains(res.Output, "2. [ ] Add the timeout field") {
+		t.Fatalf("set plan: %+v", res)
+	}
+	res = tool.Execute(map[string]string{"step": "1, 2", "status": "done"})
+	if !res.Success || !strings.Contains(res.Output, "1. [x] Read config.go\n2. [x] Add the timeout field") {
+		t.Errorf("mark steps: %+v", res)
+	}
+	res = tool.Execute(map[string]string{"add": "Update the README", "after": "2"})
+	if !res.Success || !strings.Contains(res.Output, "3. [ ] Update the README\n4. [ ] Run the tests") {
+		t.Errorf("add step: %+v", res)
+	}
+
+	for _, args := range []map[string]string{
+		{"step": "9", "status": "done"},
+		{"step": "1", "status": "maybe"},
+		{"step": "one", "status": "done"},
+		{"add": "x", "after": "end"},
+		{"plan": "\n"},
+	} {
+		if res := tool.Execute(args); res.Success {
+			t.Errorf("Execute(%v) should fail", args)
+		}
+	}
+}
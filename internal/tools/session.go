@@ -0,0 +1,291 @@
+package tools
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	errSessionExited  = errors.New("shell session exited")
+	errSessionTimeout = errors.New("command timed out")
+)
+
+// shellSession is a long-lived bash process that runs commands one at a time, so the
+// working directory, environment variables and shell functions persist between calls.
+// Each command is followed by a random end marker on stdout and stderr; the marker on
+// stdout carries the command's exit code.
+type shellSession struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	marker string
+	kill   func() // kills what the session started where the local group doesn't reach (Docker); may be nil
+
+	mu     sync.Mutex
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	sinks  [2]*lineSink  // live copies of stdout and stderr for the running command
+	change chan struct{} // signalled whenever output arrives or a stream closes
+	closed int           // number of output streams that reached EOF
+}
+
+// startSession starts cmd (a bash reading commands from stdin) as a session. kill, if
+// not nil, is called with the local process group kill on close and interrupt.
+func startSession(cmd *exec.Cmd, kill func()) (*shellSession, error) {
+	if cmd.SysProcAttr == nil {
+		// Own process group, so a timeout can kill everything the session started.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	s := &shellSession{
+		cmd:    cmd,
+		stdin:  stdin,
+		kill:   kill,
+		marker: "__DEVAGENT_END_" + hex.EncodeToString(b) + "__",
+		change: make(chan struct{}, 1),
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go s.pump(stdout, &s.stdout, 0)
+	go s.pump(stderr, &s.stderr, 1)
+	return s, nil
+}
+
+// pump copies one output stream of the shell into buf and, while a command streams, its sink.
+func (s *shellSession) pump(r io.Reader, buf *bytes.Buffer, stream int) {
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(chunk)
+		s.mu.Lock()
+		buf.Write(chunk[:n])
+		if sink := s.sinks[stream]; sink != nil {
+			sink.write(chunk[:n])
+		}
+		if err != nil {
+			s.closed++
+		}
+		s.mu.Unlock()
+		select {
+		case s.change <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// run executes command in the session and returns its stdout, stderr and exit code.
+// The command is passed to eval as a single-quoted string, so incomplete syntax fails
+// with an error instead of leaving the shell waiting for more input, and its stdin is
+// /dev/null so it cannot consume the session's input. If live is not nil, output lines
+// are also written to it as they arrive.
+func (s *shellSession) run(command string, timeout time.Duration, live io.Writer) (stdout, stderr string, exitCode int, err error) {
+	s.mu.Lock()
+	s.stdout.Reset()
+	s.stderr.Reset()
+	if live != nil {
+		s.sinks = [2]*lineSink{newLineSink(live, s.marker), newLineSink(live, s.marker)}
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		for _, sink := range s.sinks {
+			if sink != nil {
+				sink.flush()
+			}
+		}
+		s.sinks = [2]*lineSink{}
+		s.mu.Unlock()
+	}()
+
+	quoted := "'" + strings.ReplaceAll(command, "'", `'\''`) + "'"
+	script := fmt.Sprintf("eval %s < /dev/null\nprintf '\\n%s %%d\\n' $?\nprintf '\\n%s\\n' >&2\n", quoted, s.marker, s.marker)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		return "", "", -1, errSessionExited
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	outMark := newMarkerScan("\n" + s.marker + " ")
+	errMark := newMarkerScan("\n" + s.marker + "\n")
+	for {
+		s.mu.Lock()
+		out, errOut, closed := s.stdout.Bytes(), s.stderr.Bytes(), s.closed
+		code, ok := 0, false
+		if i := outMark.find(out); i >= 0 {
+			code, ok = parseExit(out[i+len(outMark.mark):])
+		}
+		if ok && errMark.find(errOut) >= 0 {
+			stdout, stderr = string(out[:outMark.at]), string(errOut[:errMark.at])
+			s.mu.Unlock()
+			return stdout, stderr, code, nil
+		}
+		if closed == 2 {
+			stdout, stderr = string(out), string(errOut)
+		}
+		s.mu.Unlock()
+
+		if closed == 2 {
+			// The command ended the shell (e.g. "exit 3"); report what it printed.
+			code := -1
+			if werr := s.cmd.Wait(); werr == nil {
+				code = 0
+			} else if exitErr, ok := werr.(*exec.ExitError); ok {
+				code = exitErr.ExitCode()
+			}
+			return stdout, stderr, code, errSessionExited
+		}
+
+		select {
+		case <-s.change:
+		case <-deadline.C:
+			s.mu.Lock()
+			stdout, stderr = s.stdout.String(), s.stderr.String()
+			s.mu.Unlock()
+			return stdout, stderr, -1, errSessionTimeout
+		}
+	}
+}
+
+// markerScan finds a marker in a growing buffer without rescanning the bytes
+// it has already searched.
+type markerScan struct {
+	mark []byte
+	from int // bytes before from cannot start the marker
+	at   int // offset of the marker, or -1 if not found yet
+}
+
+func newMarkerScan(mark string) *markerScan {
+	return &markerScan{mark: []byte(mark), at: -1}
+}
+
+// find returns the offset of the marker in b, or -1 if it has not arrived yet.
+// b must be the same buffer as in earlier calls, possibly grown.
+func (m *markerScan) find(b []byte) int {
+	if m.at >= 0 {
+		return m.at
+	}
+	if i := bytes.Index(b[m.from:], m.mark); i >= 0 {
+		m.at = m.from + i
+	} else if next := len(b) - len(m.mark) + 1; next > m.from {
+		m.from = next
+	}
+	return m.at
+}
+
+// parseExit reads the exit code that follows the stdout marker, once its line is complete.
+func parseExit(rest []byte) (int, bool) {
+	end := bytes.IndexByte(rest, '\n')
+	if end < 0 {
+		return 0, false
+	}
+	code, err := strconv.Atoi(string(rest[:end]))
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// close ends the session and kills any processes it started.
+func (s *shellSession) close() {
+	s.stdin.Close()
+	if s.kill != nil {
+		s.kill()
+	}
+	if s.cmd.Process != nil {
+		syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL)
+		s.cmd.Process.Kill()
+	}
+	go s.cmd.Wait()
+}
+
+// interrupt kills the shell and everything it started; a running run returns
+// errSessionExited.
+func (s *shellSession) interrupt() {
+	if s.kill != nil {
+		s.kill()
+	}
+	if s.cmd.Process != nil {
+		syscall.Kill(-s.cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// streamPrefix is printed before each line of live shell output.
+const streamPrefix = "   │ "
+
+// lineSink forwards complete output lines to w with streamPrefix, hiding the session's
+// end marker (if any) and the blank line printed before it.
+type lineSink struct {
+	w       io.Writer
+	marker  string
+	partial []byte
+	blanks  int  // blank lines held back until a non-blank line follows
+	done    bool // the end marker has been seen
+}
+
+func newLineSink(w io.Writer, marker string) *lineSink {
+	return &lineSink{w: w, marker: marker}
+}
+
+func (l *lineSink) write(p []byte) {
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			return
+		}
+		l.line(string(l.partial[:i]))
+		l.partial = l.partial[i+1:]
+	}
+}
+
+func (l *lineSink) line(text string) {
+	if l.done {
+		return
+	}
+	if l.marker != "" && strings.Contains(text, l.marker) {
+		l.done = true
+		return
+	}
+	text = strings.TrimRight(text, "\r")
+	if strings.TrimSpace(text) == "" {
+		l.blanks++
+		return
+	}
+	for ; l.blanks > 0; l.blanks-- {
+		fmt.Fprintln(l.w, strings.TrimRight(streamPrefix, " "))
+	}
+	fmt.Fprintf(l.w, "%s%s\n", streamPrefix, text)
+}
+
+// flush writes a trailing line that had no newline.
+func (l *lineSink) flush() {
+	if len(l.partial) > 0 {
+		l.line(string(l.partial))
+		l.partial = nil
+	}
+}
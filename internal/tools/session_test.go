@@ -0,0 +1,250 @@
+package tools
+
+import (
+	"devagent/internal/sandbox"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSession(t *testing.T) *shellSession {
+	t.Helper()
+	cmd := exec.Command("bash", "--noprofile", "--norc")
+	cmd.Dir = t.TempDir()
+	s, err := startSession(cmd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.close)
+	return s
+}
+
+func TestShellSession_Run(t *testing.T) {
+	s := newTestSession(t)
+	stdout, stderr, code, err := s.run("echo out; echo err >&2; printf 'no newline'", 10*time.Second, nil)
+	if err != nil || code != 0 {
+		t.Fatalf("run: code=%d err=%v", code, err)
+	}
+	if stdout != "out\nno newline" || stderr != "err\n" {
+		t.Errorf("stdout=%q stderr=%q", stdout, stderr)
+	}
+
+	_, _, code, err = s.run("false", 10*time.Second, nil)
+	if err != nil || code != 1 {
+		t.Errorf("false: code=%d err=%v", code, err)
+	}
+}
+
+func TestMarkerScan_SplitAcrossWrites(t *testing.T) {
+	m := newMarkerScan("\nMARK ")
+	var buf []byte
+	for _, chunk := range []string{"output\n", "more\nMA", "RK 0\n"} {
+		buf = append(buf, chunk...)
+		m.find(buf)
+	}
+	if m.at != len("output\nmore") {
+		t.Fatalf("at = %d, want %d", m.at, len("output\nmore"))
+	}
+	if code, ok := parseExit(buf[m.at+len(m.mark):]); !ok || code != 0 {
+		t.Errorf("parseExit = %d, %v", code, ok)
+	}
+}
+
+func TestShellSession_IncompleteSyntax(t *testing.T) {
+	s := newTestSession(t)
+	_, stderr, code, err := s.run("echo 'unterminated", 10*time.Second, nil)
+	if err != nil || code == 0 || stderr == "" {
+		t.Errorf("code=%d err=%v stderr=%q", code, err, stderr)
+	}
+	stdout, _, _, _ := s.run("echo still alive", 10*time.Second, nil)
+	if stdout != "still alive\n" {
+		t.Errorf("session broken after syntax error: %q", stdout)
+	}
+}
+
+func TestShellSession_StdinIsNull(t *testing.T) {
+	s := newTestSession(t)
+	stdout, _, code, err := s.run("cat; echo after", 10*time.Second, nil)
+	if err != nil || code != 0 || stdout != "after\n" {
+		t.Errorf("stdout=%q code=%d err=%v", stdout, code, err)
+	}
+}
+
+func TestShellSession_Timeout(t *testing.T) {
+	s := newTestSession(t)
+	_, _, _, err := s.run("sleep 5", 200*time.Millisecond, nil)
+	if err != errSessionTimeout {
+		t.Errorf("err = %v, want timeout", err)
+	}
+}
+
+func TestShellTool_Session_PersistsState(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	tool := &ShellTool{workDir: dir}
+	t.Cleanup(tool.Close)
+
+	for _, cmd := range []string{"cd sub", "export GREETING=hi", "greet() { echo \"$GREETING from $(basename $PWD)\"; }"} {
+		if r := tool.Execute(map[string]string{"command": cmd}); !r.Success {
+			t.Fatalf("%s: %s", cmd, r.Output)
+		}
+	}
+	if r := tool.Execute(map[string]string{"command": "greet"}); strings.TrimSpace(r.Output) != "hi from sub" {
+		t.Errorf("state not kept: %q", r.Output)
+	}
+
+	if r := tool.Execute(map[string]string{"reset": "true"}); !r.Success {
+		t.Fatalf("reset: %s", r.Output)
+	}
+	r := tool.Execute(map[string]string{"command": "echo \"[$GREETING]\" $(basename $PWD)"})
+	if want := "[] " + filepath.Base(dir); strings.TrimSpace(r.Output) != want {
+		t.Errorf("after reset = %q, want %q", r.Output, want)
+	}
+}
+
+func TestShellTool_Session_Exit(t *testing.T) {
+	tool := &ShellTool{workDir: t.TempDir()}
+	t.Cleanup(tool.Close)
+	r := tool.Execute(map[string]string{"command": "echo bye; exit 3"})
+	if r.Success || !strings.Contains(r.Output, "exit code: 3") || !strings.Contains(r.Output, "bye") {
+		t.Errorf("exit: %+v", r)
+	}
+	if r := tool.Execute(map[string]string{"command": "echo again"}); !r.Success || !strings.Contains(r.Output, "again") {
+		t.Errorf("new session: %+v", r)
+	}
+}
+
+func TestShellSession_Stream(t *testing.T) {
+	s := newTestSession(t)
+	var live strings.Builder
+	stdout, _, _, err := s.run("echo one; echo; echo two; printf three", 10*time.Second, &live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := streamPrefix + "one\n" + strings.TrimRight(streamPrefix, " ") + "\n" + streamPrefix + "two\n" + streamPrefix + "three\n"
+	if live.String() != want {
+		t.Errorf("streamed:\n%q\nwant:\n%q", live.String(), want)
+	}
+	if stdout != "one\n\ntwo\nthree" {
+		t.Errorf("stdout = %q", stdout)
+	}
+}
+
+func TestShellTool_Timeout(t *testing.T) {
+	tool := &ShellTool{workDir: t.TempDir()}
+	t.Cleanup(tool.Close)
+	tool.SetOptions(ShellOptions{DefaultTimeout: time.Minute, MaxTimeout: 2 * time.Minute})
+	tests := []struct {
+		arg  string
+		want time.Duration
+	}{
+		{"", time.Minute},
+		{"30", 30 * time.Second},
+		{"90s", 90 * time.Second},
+		{"1h", 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got, err := tool.timeout(tt.arg); err != nil || got != tt.want {
+			t.Errorf("timeout(%q) = %v, %v; want %v", tt.arg, got, err, tt.want)
+		}
+	}
+	if _, err := tool.timeout("soon"); err == nil {
+		t.Error("invalid timeout should fail")
+	}
+
+	r := tool.Execute(map[string]string{"command": "sleep 5", "timeout": "0.3s"})
+	if r.Success || !strings.Contains(r.Output, "timed out after 300ms") {
+		t.Errorf("timeout result: %+v", r)
+	}
+	if r := tool.Execute(map[string]string{"command": "echo ok"}); !r.Success {
+		t.Errorf("session should restart after a timeout: %+v", r)
+	}
+}
+
+func TestShellTool_Timeout_NoConfig(t *testing.T) {
+	tool := &ShellTool{workDir: t.TempDir()}
+	if got, err := tool.timeout(""); err != nil || got != 5*time.Minute {
+		t.Errorf("default timeout = %v, %v; want 5m", got, err)
+	}
+	if got, err := tool.timeout("20m"); err != nil || got != 20*time.Minute {
+		t.Errorf("timeout(\"20m\") = %v, %v; want 20m", got, err)
+	}
+	if got, _ := tool.timeout("2h"); got != 30*time.Minute {
+		t.Errorf("timeout(\"2h\") = %v; want the 30m default limit", got)
+	}
+}
+
+func TestShellTool_Interrupt(t *testing.T) {
+	tool := &ShellTool{workDir: t.TempDir()}
+	t.Cleanup(tool.Close)
+	go func() {
+		for tool.running.Load() == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+		tool.Interrupt()
+	}()
+	start := time.Now()
+	r := tool.Execute(map[string]string{"command": "echo started; sleep 30"})
+	if r.Success || !strings.Contains(r.Output, "interrupted by the user") {
+		t.Errorf("interrupted result: %+v", r)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("interrupt took %v", d)
+	}
+	if r := tool.Execute(map[string]string{"command": "echo ok"}); !r.Success {
+		t.Errorf("session should restart after an interrupt: %+v", r)
+	}
+	tool.Interrupt() // nothing running
+}
+
+func TestShellTool_InterruptOneOffCommand(t *testing.T) {
+	tool := &ShellTool{workDir: t.TempDir()}
+	t.Cleanup(tool.Close)
+	go func() {
+		for {
+			tool.rawMu.Lock()
+			n := len(tool.rawRuns)
+			tool.rawMu.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		tool.Interrupt()
+	}()
+	start := time.Now()
+	// The child keeps the output pipe open, so the whole process group must be killed.
+	r := tool.runOnce("sleep 30 & wait", 0)
+	if r.Success || !strings.Contains(r.Output, "interrupted by the user") {
+		t.Errorf("interrupted result: %+v", r)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("interrupt took %v", d)
+	}
+	tool.rawMu.Lock()
+	defer tool.rawMu.Unlock()
+	if len(tool.rawRuns) != 0 {
+		t.Errorf("%d one-off commands still tracked", len(tool.rawRuns))
+	}
+}
+
+func TestShellTool_DockerTimeoutKillsContainerProcesses(t *testing.T) {
+	if !sandbox.DockerAvailable() {
+		t.Skip("docker not available")
+	}
+	dir := t.TempDir()
+	d := sandbox.NewDockerExecutor(dir, sandbox.DockerConfig{})
+	defer d.Cleanup()
+	tool := &ShellTool{workDir: dir, docker: d}
+	defer tool.Close()
+	r := tool.Execute(map[string]string{"command": "sleep 300 & echo $! > /tmp/devagent-test-sleep.pid; wait", "timeout": "2"})
+	if r.Success || !strings.Contains(r.Output, "timed out") {
+		t.Fatalf("expected a timeout, got %+v", r)
+	}
+	if r := tool.runOnce("kill -0 $(cat /tmp/devagent-test-sleep.pid)", 0); r.Success {
+		t.Errorf("the session's command kept running in the container: %s", r.Output)
+	}
+}
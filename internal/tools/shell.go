@@ -3,41 +3,207 @@ package tools
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"devagent/internal/config"
 	"devagent/internal/sandbox"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
+// shellTimeout bounds a single shell command unless ShellOptions say otherwise.
+const shellTimeout = 5 * time.Minute
+
+// ShellOptions configure the shell tool.
+type ShellOptions struct {
+	DefaultTimeout time.Duration // per-command timeout when the command asks for none
+	MaxTimeout     time.Duration // upper bound for the timeout argument; 0 means the shell config's default limit
+	Output         io.Writer     // receives command output live while it runs; nil disables streaming
+}
+
+// ShellTool runs commands in a persistent bash session (on the host, or inside the
+// Docker container), so cd, exported variables and activated virtualenvs carry over
+// between calls. The session is started lazily and ended by Close or a reset.
 type ShellTool struct {
-	workDir  string
-	docker   *sandbox.DockerExecutor // nil means direct execution
+	workDir string
+	docker  *sandbox.DockerExecutor // nil means direct execution
+	opts    ShellOptions
+
+	mu      sync.Mutex
+	session *shellSession
+
+	running     atomic.Pointer[shellSession] // session of the command being run, for Interrupt
+	interrupted atomic.Bool
+
+	rawMu   sync.Mutex
+	rawRuns map[int]context.CancelFunc // runRaw commands in progress, for Interrupt
+	rawNext int
+}
+
+// SetOptions sets timeouts and live output. Zero timeouts keep the defaults.
+func (t *ShellTool) SetOptions(opts ShellOptions) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.opts = opts
+}
+
+// timeout returns the timeout for a command from its "timeout" argument: seconds
+// ("90") or a duration ("10m"), capped at the configured maximum.
+func (t *ShellTool) timeout(arg string) (time.Duration, error) {
+	def, max := t.opts.DefaultTimeout, t.opts.MaxTimeout
+	if def <= 0 {
+		def = shellTimeout
+	}
+	if max <= 0 {
+		max = config.ShellConfig{}.TimeoutLimit()
+	}
+	if max < def {
+		max = def
+	}
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(arg)
+	if n, aerr := strconv.Atoi(arg); aerr == nil {
+		d, err = time.Duration(n)*time.Second, nil
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q: use seconds (\"120\") or a duration (\"10m\")", arg)
+	}
+	if d > max {
+		d = max
+	}
+	return d, nil
 }
 
 func (t *ShellTool) Name() string { return "shell" }
 
 func (t *ShellTool) Execute(args map[string]string) Result {
 	command := args["command"]
-	if command == "" {
+	reset := isTrue(args["reset"])
+	if command == "" && !reset {
 		return Result{Success: false, Output: "empty command"}
 	}
 
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timeout, err := t.timeout(args["timeout"])
+	if err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	if reset {
+		t.closeSession()
+		if command == "" {
+			return Result{Success: true, Output: "shell session reset: working directory and environment are back to their defaults"}
+		}
+	}
+	if t.session == nil {
+		s, err := t.startSession()
+		if err != nil {
+			return Result{Success: false, Output: fmt.Sprintf("%scannot start shell session: %v", t.prefix(), err)}
+		}
+		t.session = s
+	}
+
+	t.interrupted.Store(false)
+	t.running.Store(t.session)
+	stdout, stderr, exitCode, err := t.session.run(command, timeout, t.opts.Output)
+	t.running.Store(nil)
+	output := truncateOutput(combineOutput(stdout, stderr))
+	switch {
+	case t.interrupted.Load():
+		t.closeSession()
+		return Result{Success: false, Output: fmt.Sprintf("%scommand interrupted by the user; the shell session was reset\n%s", t.prefix(), output)}
+	case errors.Is(err, errSessionTimeout):
+		t.closeSession()
+		return Result{Success: false, Output: fmt.Sprintf("%scommand timed out after %v; the shell session was reset (pass a larger \"timeout\" for slow commands, or use shell_start)\n%s", t.prefix(), timeout, output)}
+	case errors.Is(err, errSessionExited):
+		t.closeSession()
+		return Result{Success: exitCode == 0, Output: fmt.Sprintf("%sexit code: %d (the shell exited; a new session starts with the next command)\n%s", t.prefix(), exitCode, output)}
+	case exitCode != 0:
+		return Result{Success: false, Output: fmt.Sprintf("%sexit code: %d\n%s", t.prefix(), exitCode, output)}
+	}
+	if output == "" {
+		output = "(no output)"
+	}
+	return Result{Success: true, Output: output}
+}
+
+// Interrupt kills the session command that is running, if any, together with its
+// session, and the one-off commands run for tests, hooks, formatters and
+// validation. It does not wait for them to return.
+func (t *ShellTool) Interrupt() {
+	if s := t.running.Load(); s != nil {
+		t.interrupted.Store(true)
+		s.interrupt()
+	}
+	t.rawMu.Lock()
+	defer t.rawMu.Unlock()
+	for _, cancel := range t.rawRuns {
+		cancel()
+	}
+}
+
+// Close ends the shell session and every process it started.
+func (t *ShellTool) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeSession()
+}
+
+func (t *ShellTool) closeSession() {
+	if t.session != nil {
+		t.session.close()
+		t.session = nil
+	}
+}
+
+func (t *ShellTool) startSession() (*shellSession, error) {
 	if t.docker != nil {
-		return t.executeDocker(command)
+		b := make([]byte, 6)
+		rand.Read(b)
+		name := hex.EncodeToString(b)
+		cmd, err := t.docker.SessionCommand(name)
+		if err != nil {
+			return nil, err
+		}
+		return startSession(cmd, func() { t.docker.KillSession(name) })
 	}
-	return t.executeDirect(command)
+	cmd := exec.Command("bash", "--noprofile", "--norc")
+	cmd.Dir = t.workDir
+	return startSession(cmd, nil)
 }
 
-func (t *ShellTool) executeDocker(command string) Result {
-	output, exitCode, err := t.docker.Execute(command)
-	output = truncateOutput(output)
+func (t *ShellTool) prefix() string {
+	if t.docker != nil {
+		return "[docker] "
+	}
+	return ""
+}
 
+// runOnce runs command in a fresh shell from the project root, independent of the
+// session's state. It is used for validation commands. A zero timeout means
+// shellTimeout.
+func (t *ShellTool) runOnce(command string, timeout time.Duration) Result {
+	if timeout <= 0 {
+		timeout = shellTimeout
+	}
+	stdout, stderr, exitCode, err := t.runRaw(command, timeout, nil)
+	output := truncateOutput(combineOutput(stdout, stderr))
 	if err != nil {
-		return Result{Success: false, Output: fmt.Sprintf("[docker] %v\n%s", err, output)}
+		return Result{Success: false, Output: fmt.Sprintf("%s%v\n%s", t.prefix(), err, output)}
 	}
 	if exitCode != 0 {
-		return Result{Success: false, Output: fmt.Sprintf("[docker] exit code: %d\n%s", exitCode, output)}
+		return Result{Success: false, Output: fmt.Sprintf("%sexit code: %d\n%s", t.prefix(), exitCode, output)}
 	}
 	if output == "" {
 		output = "(no output)"
@@ -45,76 +211,92 @@ func (t *ShellTool) executeDocker(command string) Result {
 	return Result{Success: true, Output: output}
 }
 
-func (t *ShellTool) executeDirect(command string) Result {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+// runRaw runs command once from the project root (inside the container when Docker is
+// enabled) and returns its output streams separately. If live is not nil, it also
+// receives stdout as it arrives. err is set only when the command could not run to
+// completion, e.g. on timeout or Interrupt.
+func (t *ShellTool) runRaw(command string, timeout time.Duration, live io.Writer) (stdout, stderr string, exitCode int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	defer t.trackRaw(cancel)()
+	if t.docker != nil {
+		return t.docker.Run(ctx, command, timeout, live)
+	}
 
 	cmd := exec.CommandContext(ctx, "bash", "-c", command)
 	cmd.Dir = t.workDir
+	// Kill everything the command started, or output pipes held open by its children
+	// would keep Run waiting after a timeout or interrupt.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = 5 * time.Second
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-
-	var sb strings.Builder
-	if stdout.Len() > 0 {
-		sb.WriteString(stdout.String())
-	}
-	if stderr.Len() > 0 {
-		if sb.Len() > 0 {
-			sb.WriteString("\n")
-		}
-		sb.WriteString("[stderr]\n")
-		sb.WriteString(stderr.String())
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	if live != nil {
+		cmd.Stdout = io.MultiWriter(&outBuf, live)
 	}
+	cmd.Stderr = &errBuf
 
-	output := truncateOutput(sb.String())
-
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return Result{Success: false, Output: fmt.Sprintf("command timed out after 5 minutes\n%s", output)}
+	runErr := cmd.Run()
+	stdout, stderr = outBuf.String(), errBuf.String()
+	if runErr != nil {
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return stdout, stderr, -1, fmt.Errorf("command timed out after %v", timeout)
+		case context.Canceled:
+			return stdout, stderr, -1, errors.New("command interrupted by the user")
 		}
-		return Result{Success: false, Output: fmt.Sprintf("exit code: %v\n%s", err, output)}
-	}
-
-	if output == "" {
-		output = "(no output)"
+		if exitErr, ok := runErr.(*exec.ExitError); ok {
+			return stdout, stderr, exitErr.ExitCode(), nil
+		}
+		return stdout, stderr, -1, runErr
 	}
-	return Result{Success: true, Output: output}
+	return stdout, stderr, 0, nil
 }
 
-func truncateOutput(output string) string {
-	const maxLen = 16000
-	if len(output) > maxLen {
-		half := maxLen / 2
-		output = output[:half] + "\n\n... (output truncated) ...\n\n" + output[len(output)-half:]
+// trackRaw registers the cancel function of a runRaw command for Interrupt and
+// returns the function that removes it again.
+func (t *ShellTool) trackRaw(cancel context.CancelFunc) func() {
+	t.rawMu.Lock()
+	defer t.rawMu.Unlock()
+	if t.rawRuns == nil {
+		t.rawRuns = make(map[int]context.CancelFunc)
+	}
+	id := t.rawNext
+	t.rawNext++
+	t.rawRuns[id] = cancel
+	return func() {
+		t.rawMu.Lock()
+		defer t.rawMu.Unlock()
+		delete(t.rawRuns, id)
 	}
-	return output
 }
 
-type GrepTool struct {
-	workDir string
+// combineOutput joins stdout and stderr, marking where stderr begins.
+func combineOutput(stdout, stderr string) string {
+	var sb strings.Builder
+	sb.WriteString(stdout)
+	if stderr != "" {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("[stderr]\n")
+		sb.WriteString(stderr)
+	}
+	return sb.String()
 }
 
-func (t *GrepTool) Name() string { return "grep" }
+// maxShellOutput bounds the output kept from one command. The agent saves tool output
+// in full and shortens it for the model, so this only guards against runaway output.
+const maxShellOutput = 4 << 20
 
-func (t *GrepTool) Execute(args map[string]string) Result {
-	pattern := args["pattern"]
-	if pattern == "" {
-		return Result{Success: false, Output: "empty pattern"}
-	}
-
-	path := args["path"]
-	if path == "" || path == "." {
-		path = t.workDir
+func truncateOutput(output string) string {
+	if len(output) > maxShellOutput {
+		half := maxShellOutput / 2
+		output = output[:half] + fmt.Sprintf("\n\n... (%d bytes of output dropped) ...\n\n", len(output)-maxShellOutput) + output[len(output)-half:]
 	}
-
-	shell := &ShellTool{workDir: t.workDir}
-	grepCmd := fmt.Sprintf("rg --no-heading -n --max-count=100 '%s' '%s' 2>/dev/null || grep -rn --max-count=100 '%s' '%s' 2>/dev/null",
-		pattern, path, pattern, path)
-	return shell.Execute(map[string]string{"command": grepCmd})
+	return output
 }
 
 type DoneTool struct{}
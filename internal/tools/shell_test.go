@@ -1,8 +1,6 @@
 package tools
 
 import (
-	"os"
-	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -45,44 +43,23 @@ func TestShellTool_ExecuteDirect_ExitNonZero(t *testing.T) {
 	}
 }
 
-func TestGrepTool_Name(t *testing.T) {
-	tool := &GrepTool{workDir: "/tmp"}
-	if tool.Name() != "grep" {
-		t.Errorf("Name() = %q", tool.Name())
-	}
-}
-
-func TestGrepTool_Execute_EmptyPattern(t *testing.T) {
-	tool := &GrepTool{workDir: t.TempDir()}
-	result := tool.Execute(map[string]string{"pattern": ""})
-	if result.Success {
-		t.Error("empty pattern should fail")
-	}
-}
-
-func TestGrepTool_Execute_Success(t *testing.T) {
-	dir := t.TempDir()
-	f := filepath.Join(dir, "f.txt")
-	if err := os.WriteFile(f, []byte("needle in haystack"), 0644); err != nil {
-		t.Fatal(err)
-	}
-	tool := &GrepTool{workDir: dir}
-	result := tool.Execute(map[string]string{"pattern": "needle", "path": "."})
-	// May succeed (if rg/grep found) or fail (no match); just ensure no panic
-	if result.Output == "" && result.Success {
-		t.Log("grep succeeded with output")
-	}
-}
-
-func TestShellTool_ExecuteDirect_LongOutputTruncated(t *testing.T) {
+func TestShellTool_ExecuteDirect_LongOutputKept(t *testing.T) {
 	dir := t.TempDir()
 	tool := &ShellTool{workDir: dir}
-	// Produce > 16000 chars so truncateOutput is exercised
+	t.Cleanup(tool.Close)
+	// Long output is returned whole; the agent shortens it for the model and saves the rest.
 	result := tool.Execute(map[string]string{"command": "printf '%17000s' x | tr ' ' 'a'"})
 	if !result.Success {
 		t.Fatalf("Execute: %s", result.Output)
 	}
-	if !strings.Contains(result.Output, "(output truncated)") {
-		t.Errorf("long output should be truncated, got len=%d", len(result.Output))
+	if len(result.Output) != 17000 || strings.Contains(result.Output, "dropped") {
+		t.Errorf("long output should be kept, got len=%d", len(result.Output))
+	}
+}
+
+func TestTruncateOutput_Runaway(t *testing.T) {
+	out := truncateOutput(strings.Repeat("a", maxShellOutput+10))
+	if len(out) > maxShellOutput+100 || !strings.Contains(out, "10 bytes of output dropped") {
+		t.Errorf("runaway output not bounded: len=%d", len(out))
 	}
 }
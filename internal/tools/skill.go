@@ -1,13 +1,16 @@
 package tools
 
 import (
+	"devagent/internal/prompt"
 	"devagent/internal/skill"
 	"fmt"
+	"log"
 )
 
 // ReadSkillTool loads the full body of a skill by name from the discovered skills list.
 type ReadSkillTool struct {
 	skills []skill.Skill
+	vars   *prompt.Vars // template data for skill bodies; nil returns them as written
 }
 
 // NewReadSkillTool creates a ReadSkillTool that uses the given skills slice.
@@ -15,6 +18,11 @@ func NewReadSkillTool(skills []skill.Skill) *ReadSkillTool {
 	return &ReadSkillTool{skills: skills}
 }
 
+// SetTemplateVars renders skill bodies as text/template with vars.
+func (t *ReadSkillTool) SetTemplateVars(vars prompt.Vars) {
+	t.vars = &vars
+}
+
 func (t *ReadSkillTool) Name() string { return "read_skill" }
 
 func (t *ReadSkillTool) Execute(args map[string]string) Result {
@@ -41,5 +49,14 @@ func (t *ReadSkillTool) Execute(args map[string]string) Result {
 		return Result{Success: false, Output: fmt.Sprintf("load skill: %v", err)}
 	}
 
-	return Result{Success: true, Output: found.Body}
+	body := found.Body
+	if t.vars != nil {
+		rendered, err := prompt.Render(found.Name, body, *t.vars, prompt.OriginOf(found.Dir, t.vars.Root))
+		if err != nil {
+			log.Printf("Warning: rendering skill %s: %v (using it as written)", found.Name, err)
+		} else {
+			body = rendered
+		}
+	}
+	return Result{Success: true, Output: body}
 }
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"devagent/internal/prompt"
 	"devagent/internal/skill"
 	"os"
 	"path/filepath"
@@ -85,3 +86,26 @@ func TestReadSkillTool_Execute_EmptySkillsList(t *testing.T) {
 		t.Error("Execute with empty skills list should fail for any name")
 	}
 }
+
+func TestReadSkillTool_Execute_Template(t *testing.T) {
+	dir := t.TempDir()
+	content := "---\nname: build\ndescription: Build it.\n---\n\nBuild {{.Project}}{{if .Docker}} in Docker{{end}}.\n\nHelm values look like {{ .Values.name }}.\n"
+	os.MkdirAll(filepath.Join(dir, "build"), 0755)
+	os.WriteFile(filepath.Join(dir, "build", "SKILL.md"), []byte(content), 0644)
+	skills, err := skill.Discover([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewReadSkillTool(skills)
+	tool.SetTemplateVars(prompt.Vars{Project: "shop", Docker: true})
+	// The body is not a valid template for these vars, so it is returned as written.
+	if got := tool.Execute(map[string]string{"name": "build"}).Output; got != "Build {{.Project}}{{if .Docker}} in Docker{{end}}.\n\nHelm values look like {{ .Values.name }}." {
+		t.Errorf("Output = %q", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "build", "SKILL.md"), []byte("---\nname: build\ndescription: Build it.\n---\n\nBuild {{.Project}}{{if .Docker}} in Docker{{end}}.\n"), 0644)
+	if got := tool.Execute(map[string]string{"name": "build"}).Output; got != "Build shop in Docker." {
+		t.Errorf("Output = %q, want %q", got, "Build shop in Docker.")
+	}
+}
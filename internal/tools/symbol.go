@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// findSymbol returns the 1-based inclusive line range of the declaration named
+// name in src. Go files are parsed (name may be "Func", "Type", "Type.Method" or
+// "(*Type).Method"); other languages fall back to a declaration-keyword heuristic.
+func findSymbol(path string, src []byte, name string) (start, end int, err error) {
+	name = strings.TrimSpace(name)
+	if strings.HasSuffix(path, ".go") {
+		return findGoSymbol(path, src, name)
+	}
+	return findTextSymbol(src, name)
+}
+
+func findGoSymbol(path string, src []byte, name string) (int, int, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if f == nil {
+		return 0, 0, fmt.Errorf("parse %s: %v", path, err)
+	}
+	name = strings.NewReplacer("(", "", ")", "", "*", "").Replace(name)
+	recv, fn, isMethod := strings.Cut(name, ".")
+	if !isMethod {
+		fn = recv
+		recv = ""
+	}
+
+	var found ast.Node
+	var doc *ast.CommentGroup
+	var methodMatch ast.Node
+	var methodDoc *ast.CommentGroup
+	var available []string
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			r := receiverName(d)
+			full := d.Name.Name
+			if r != "" {
+				full = r + "." + d.Name.Name
+			}
+			available = append(available, full)
+			if d.Name.Name != fn {
+				continue
+			}
+			if r == recv {
+				found, doc = d, d.Doc
+			} else if recv == "" && methodMatch == nil {
+				// "Method" alone matches a method when no function has that name.
+				methodMatch, methodDoc = d, d.Doc
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				var names []*ast.Ident
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names = []*ast.Ident{s.Name}
+				case *ast.ValueSpec:
+					names = s.Names
+				}
+				for _, id := range names {
+					available = append(available, id.Name)
+					if recv == "" && id.Name == fn && found == nil {
+						// Show the whole declaration group for single specs; just the spec otherwise.
+						if len(d.Specs) == 1 {
+							found, doc = d, d.Doc
+						} else {
+							found = spec
+							if ts, ok := spec.(*ast.TypeSpec); ok {
+								doc = ts.Doc
+							} else if vs, ok := spec.(*ast.ValueSpec); ok {
+								doc = vs.Doc
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if found == nil {
+		found, doc = methodMatch, methodDoc
+	}
+	if found == nil {
+		sort.Strings(available)
+		if len(available) > 50 {
+			available = append(available[:50], "...")
+		}
+		return 0, 0, fmt.Errorf("symbol %q not found in %s. Top-level symbols: %s", name, filepath.Base(path), strings.Join(available, ", "))
+	}
+	startPos := found.Pos()
+	if doc != nil {
+		startPos = doc.Pos()
+	}
+	return fset.Position(startPos).Line, fset.Position(found.End()).Line, nil
+}
+
+// receiverName returns the receiver type name of a method ("" for functions).
+func receiverName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return ""
+	}
+	t := fd.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		case *ast.Ident:
+			return x.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// findTextSymbol locates a declaration by common keywords (def, class, function, fn, ...)
+// and finds its end by brace matching or, for indentation-based languages, by dedent.
+func findTextSymbol(src []byte, name string) (int, int, error) {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	q := regexp.QuoteMeta(name)
+	declRe := regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:static\s+)?` +
+		`(?:(?:def|class|function|func|fn|interface|type|struct|enum|trait|impl|module|sub)\s+` + q + `\b` +
+		`|(?:const|let|var)\s+` + q + `\s*=)`)
+	lines := strings.Split(string(src), "\n")
+	for i, line := range lines {
+		if !declRe.MatchString(line) {
+			continue
+		}
+		return i + 1, blockEnd(lines, i), nil
+	}
+	return 0, 0, fmt.Errorf("symbol %q not found", name)
+}
+
+// blockEnd returns the 1-based last line of the block starting at lines[start].
+func blockEnd(lines []string, start int) int {
+	depth := 0
+	opened := false
+	for i := start; i < len(lines) && i < start+2000; i++ {
+		for _, c := range lines[i] {
+			switch c {
+			case '{':
+				depth++
+				opened = true
+			case '}':
+				depth--
+			}
+		}
+		if opened && depth <= 0 {
+			return i + 1
+		}
+		if !opened {
+			trimmed := strings.TrimSpace(lines[i])
+			// No brace yet: indentation-based block (Python), a one-line declaration,
+			// or a signature spanning a few lines before its "{".
+			if strings.HasSuffix(trimmed, ":") {
+				return indentBlockEnd(lines, i, indentOf(lines[start]))
+			}
+			if strings.HasSuffix(trimmed, ";") || i >= start+10 || (i == start && !continues(trimmed)) {
+				return i + 1
+			}
+		}
+	}
+	if !opened {
+		return start + 1
+	}
+	return len(lines)
+}
+
+// continues reports whether a declaration line without a brace carries on to the next line.
+func continues(line string) bool {
+	for _, suffix := range []string{"(", ",", "=", "=>", "->"} {
+		if strings.HasSuffix(line, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// indentBlockEnd returns the 1-based last line of the block whose header ends at
+// lines[start], i.e. the last following line indented deeper than base.
+func indentBlockEnd(lines []string, start, base int) int {
+	end := start
+	for i := start + 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if indentOf(lines[i]) <= base {
+			break
+		}
+		end = i
+	}
+	return end + 1
+}
+
+func indentOf(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " \t"))
+}
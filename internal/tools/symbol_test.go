@@ -0,0 +1,70 @@
+package tools
+
+import "testing"
+
+func TestFindSymbol_Go(t *testing.T) {
+	src := []byte(`package demo
+
+type Server struct {
+	addr string
+}
+
+// Start starts the server.
+func (s *Server) Start() error {
+	return nil
+}
+
+const (
+	A = 1
+	B = 2
+)
+`)
+	tests := []struct {
+		name       string
+		start, end int
+	}{
+		{"Server", 3, 5},
+		{"Server.Start", 7, 10},
+		{"(*Server).Start", 7, 10},
+		{"Start", 7, 10},
+		{"B", 14, 14},
+	}
+	for _, tt := range tests {
+		start, end, err := findSymbol("demo.go", src, tt.name)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if start != tt.start || end != tt.end {
+			t.Errorf("%s: got %d-%d, want %d-%d", tt.name, start, end, tt.start, tt.end)
+		}
+	}
+	if _, _, err := findSymbol("demo.go", src, "Stop"); err == nil {
+		t.Error("missing symbol should fail")
+	}
+}
+
+func TestFindSymbol_Text(t *testing.T) {
+	py := []byte("import os\n\nclass Foo:\n    def bar(self):\n        return 1\n\n    def baz(self):\n        pass\n\nx = 1\n")
+	js := []byte("const a = 1;\n\nexport async function load(url,\n    opts) {\n  if (x) {\n    return 1;\n  }\n}\n")
+	tests := []struct {
+		src        []byte
+		name       string
+		start, end int
+	}{
+		{py, "Foo", 3, 8},
+		{py, "Foo.bar", 4, 5},
+		{js, "load", 3, 8},
+		{js, "a", 1, 1},
+	}
+	for _, tt := range tests {
+		start, end, err := findSymbol("x.txt", tt.src, tt.name)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if start != tt.start || end != tt.end {
+			t.Errorf("%s: got %d-%d, want %d-%d", tt.name, start, end, tt.start, tt.end)
+		}
+	}
+}
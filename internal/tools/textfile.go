@@ -0,0 +1,252 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	sniffLen      = 8000     // bytes inspected to detect encoding / binary content
+	maxLineLen    = 2000     // longer lines are cut in read_file output
+	maxMatchLine  = 1 << 20  // bytes of a line kept for grep matching; the rest is skipped
+	maxUTF16Bytes = 16 << 20 // UTF-16 files are decoded in memory, so they are capped
+)
+
+// textEncoding is the detected encoding of a file.
+type textEncoding int
+
+const (
+	encUTF8 textEncoding = iota
+	encUTF8BOM
+	encUTF16LE
+	encUTF16BE
+	encLatin1 // not valid UTF-8; bytes are decoded as ISO-8859-1
+	encBinary
+)
+
+func (e textEncoding) String() string {
+	switch e {
+	case encUTF8BOM:
+		return "UTF-8 with BOM"
+	case encUTF16LE:
+		return "UTF-16LE"
+	case encUTF16BE:
+		return "UTF-16BE"
+	case encLatin1:
+		return "ISO-8859-1"
+	case encBinary:
+		return "binary"
+	default:
+		return "UTF-8"
+	}
+}
+
+// detectEncoding guesses the encoding of a file from its first bytes.
+func detectEncoding(head []byte) textEncoding {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return encUTF8BOM
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return encUTF16LE
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return encUTF16BE
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return encBinary
+	}
+	// The sniffed prefix may end in the middle of a multi-byte rune.
+	valid := head
+	for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(head[i]) {
+			if !utf8.FullRune(head[i:]) {
+				valid = head[:i]
+			}
+			break
+		}
+	}
+	if utf8.Valid(valid) {
+		return encUTF8
+	}
+	// Mostly control characters means binary; otherwise assume a legacy 8-bit text encoding.
+	control := 0
+	for _, b := range head {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			control++
+		}
+	}
+	if control*10 > len(head) {
+		return encBinary
+	}
+	return encLatin1
+}
+
+func sniffFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// binarySummary describes a binary file instead of dumping its bytes.
+func binarySummary(path string, size int64, head []byte) string {
+	return fmt.Sprintf("Binary file: %s\nSize: %d bytes\nType: %s\n(content not shown; use shell tools such as `file` or `xxd | head` to inspect it)",
+		path, size, http.DetectContentType(head))
+}
+
+func latin1ToUTF8(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func decodeUTF16(b []byte, bigEndian bool) string {
+	b = b[2:] // BOM
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			u[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+	return string(utf16.Decode(u))
+}
+
+// lineReader yields decoded lines of a text file one at a time, so large files
+// can be paged without reading them fully into memory.
+type lineReader struct {
+	enc   textEncoding
+	f     *os.File
+	r     *bufio.Reader // nil for UTF-16, which is decoded up front into lines
+	lines []string
+	pos   int
+	limit int // bytes kept per line, 0 = whole lines; see next
+	size  int // full length in bytes of the line last returned by next
+	buf   []byte
+}
+
+// openLines opens path for reading line by line. Lines longer than limit bytes
+// are cut to limit, so a minified or single-line file is not held in memory;
+// limit 0 keeps whole lines.
+func openLines(path string, enc textEncoding, size int64, limit int) (*lineReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if enc != encUTF16LE && enc != encUTF16BE {
+		return &lineReader{enc: enc, f: f, r: bufio.NewReaderSize(f, 64*1024), limit: limit}, nil
+	}
+	defer f.Close()
+	if size > maxUTF16Bytes {
+		return nil, fmt.Errorf("%s file too large to decode (%d bytes)", enc, size)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(decodeUTF16(data, enc == encUTF16BE), "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	lr := &lineReader{enc: enc, limit: limit}
+	if text != "" {
+		lr.lines = strings.Split(text, "\n")
+	}
+	return lr, nil
+}
+
+// next returns the next line (without its line ending) and false at end of file.
+// With a limit, only the first limit bytes of the line are returned; lr.size
+// still reports its full length.
+func (lr *lineReader) next() (string, bool) {
+	if lr.r == nil {
+		if lr.pos >= len(lr.lines) {
+			return "", false
+		}
+		lr.pos++
+		line := lr.lines[lr.pos-1]
+		lr.size = len(line)
+		if lr.limit > 0 && len(line) > lr.limit {
+			line = line[:lr.limit]
+		}
+		return line, true
+	}
+	line, total := lr.buf[:0], 0
+	var last, prev byte // the line's last two bytes, to find its line ending
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		total += len(chunk)
+		switch {
+		case len(chunk) >= 2:
+			prev, last = chunk[len(chunk)-2], chunk[len(chunk)-1]
+		case len(chunk) == 1:
+			prev, last = last, chunk[0]
+		}
+		keep := chunk
+		if lr.limit > 0 && len(line)+len(keep) > lr.limit {
+			keep = keep[:lr.limit-len(line)]
+		}
+		line = append(line, keep...)
+		if err != bufio.ErrBufferFull {
+			break
+		}
+	}
+	lr.buf = line
+	if total == 0 {
+		return "", false
+	}
+	size := total
+	if last == '\n' {
+		size--
+		last = prev
+	}
+	if last == '\r' && size > 0 {
+		size--
+	}
+	if lr.enc == encUTF8BOM && lr.pos == 0 && bytes.HasPrefix(line, []byte{0xEF, 0xBB, 0xBF}) {
+		line = line[3:]
+		size -= 3
+	}
+	if len(line) > size {
+		line = line[:size]
+	}
+	lr.size = size
+	lr.pos++
+	if lr.enc == encLatin1 {
+		return latin1ToUTF8(line), true
+	}
+	return string(line), true
+}
+
+func (lr *lineReader) close() {
+	if lr.f != nil {
+		lr.f.Close()
+	}
+}
+
+// cutLine shortens line for display. size is the line's full length, which is
+// larger than len(line) when the reader already dropped its end.
+func cutLine(line string, size int) string {
+	if len(line) <= maxLineLen && size <= len(line) {
+		return line
+	}
+	cut := min(len(line), maxLineLen)
+	for cut > 0 && cut < len(line) && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + fmt.Sprintf(" ... (line truncated, %d bytes)", max(size, len(line)))
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want textEncoding
+	}{
+		{"utf8", []byte("héllo\n"), encUTF8},
+		{"utf8 cut rune", []byte("h\xc3"), encUTF8},
+		{"bom", []byte("\xef\xbb\xbfhi"), encUTF8BOM},
+		{"utf16le", []byte{0xFF, 0xFE, 'h', 0}, encUTF16LE},
+		{"utf16be", []byte{0xFE, 0xFF, 0, 'h'}, encUTF16BE},
+		{"nul", []byte("ab\x00cd"), encBinary},
+		{"latin1", []byte("caf\xe9 au lait"), encLatin1},
+		{"control", []byte("\x01\x02\x03\x04\xff"), encBinary},
+		{"empty", nil, encUTF8},
+	}
+	for _, tt := range tests {
+		if got := detectEncoding(tt.head); got != tt.want {
+			t.Errorf("%s: detectEncoding = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLineReader_UTF16(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "u16.txt")
+	// "hi\r\nyo\n" in UTF-16LE with BOM.
+	data := []byte{0xFF, 0xFE, 'h', 0, 'i', 0, '\r', 0, '\n', 0, 'y', 0, 'o', 0, '\n', 0}
+	os.WriteFile(path, data, 0644)
+	lr, err := openLines(path, encUTF16LE, int64(len(data)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.close()
+	var got []string
+	for line, ok := lr.next(); ok; line, ok = lr.next() {
+		got = append(got, line)
+	}
+	if len(got) != 2 || got[0] != "hi" || got[1] != "yo" {
+		t.Errorf("lines = %q", got)
+	}
+}
+
+func TestLineReader_TrailingNewline(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	os.WriteFile(path, []byte("\xef\xbb\xbfone\r\ntwo\n"), 0644)
+	lr, err := openLines(path, encUTF8BOM, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.close()
+	var got []string
+	for line, ok := lr.next(); ok; line, ok = lr.next() {
+		got = append(got, line)
+	}
+	if len(got) != 2 || got[0] != "one" || got[1] != "two" {
+		t.Errorf("lines = %q", got)
+	}
+}
+
+func TestLineReader_Limit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "min.js")
+	long := strings.Repeat("x", 200*1024) // longer than the reader's 64KB buffer
+	os.WriteFile(path, []byte(long+"\r\nshort\nlast"), 0644)
+	lr, err := openLines(path, encUTF8, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.close()
+	want := []struct {
+		text string
+		size int
+	}{{long[:100], len(long)}, {"short", 5}, {"last", 4}}
+	for _, w := range want {
+		line, ok := lr.next()
+		if !ok || line != w.text || lr.size != w.size {
+			t.Fatalf("next = %.20q (%d bytes, size %d), want %.20q (size %d)", line, len(line), lr.size, w.text, w.size)
+		}
+	}
+	if _, ok := lr.next(); ok {
+		t.Error("expected end of file")
+	}
+	if got := cutLine(long[:100], len(long)); got != long[:100]+" ... (line truncated, 204800 bytes)" {
+		t.Errorf("cutLine = %q", got)
+	}
+}
+
+func TestCutLine(t *testing.T) {
+	long := make([]byte, maxLineLen+10)
+	for i := range long {
+		long[i] = 'x'
+	}
+	if got := cutLine(string(long), len(long)); len(got) >= len(long)+40 || got[:maxLineLen] != string(long[:maxLineLen]) {
+		t.Errorf("cutLine did not truncate: len %d", len(got))
+	}
+	if got := cutLine("short", 5); got != "short" {
+		t.Errorf("cutLine(short) = %q", got)
+	}
+}
@@ -1,10 +1,17 @@
 package tools
 
 import (
+	"devagent/internal/checkpoint"
+	"devagent/internal/codesearch"
+	"devagent/internal/lsp"
+	"devagent/internal/memory"
 	"devagent/internal/sandbox"
+	"errors"
 	"fmt"
+	"log"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type Result struct {
@@ -19,10 +26,16 @@ type Tool interface {
 
 // Registry manages tools and applies sandbox + path translation before execution.
 type Registry struct {
-	tools           map[string]Tool
-	sandbox         *sandbox.Sandbox
-	hostWorkDir     string
+	tools            map[string]Tool
+	sandbox          *sandbox.Sandbox
+	hostWorkDir      string
 	containerWorkDir string // "/workspace" when Docker is active, empty otherwise
+	txn              *TxnManager
+	checkpoints      *checkpoint.Store
+	treeTooLarge     bool // ErrTreeTooLarge was already logged
+	hooks            *editHooks
+	formatters       *formatters
+	lsp              *lsp.Manager
 }
 
 func NewRegistry() *Registry {
@@ -41,6 +54,105 @@ func (r *Registry) SetContainerPath(hostWorkDir, containerWorkDir string) {
 	r.containerWorkDir = containerWorkDir
 }
 
+// SetTransactions sets the transaction manager that snapshots files before mutating tools run.
+func (r *Registry) SetTransactions(m *TxnManager) {
+	r.txn = m
+}
+
+// Transactions returns the registry's transaction manager (nil if none).
+func (r *Registry) Transactions() *TxnManager {
+	return r.txn
+}
+
+// SetShellOptions configures timeouts and live output of the shell tool.
+func (r *Registry) SetShellOptions(opts ShellOptions) {
+	if sh, ok := r.tools["shell"].(*ShellTool); ok {
+		sh.SetOptions(opts)
+	}
+}
+
+// Interrupt stops the shell commands that are running: the session's command and
+// one-off commands such as run_tests, edit hooks and validation.
+func (r *Registry) Interrupt() {
+	if sh, ok := r.tools["shell"].(*ShellTool); ok {
+		sh.Interrupt()
+	}
+}
+
+// RunOnce runs command in a fresh shell from the project root (inside the container
+// when Docker is enabled), independent of the shell session. A zero timeout uses the
+// default shell timeout.
+func (r *Registry) RunOnce(command string, timeout time.Duration) Result {
+	sh, ok := r.tools["shell"].(*ShellTool)
+	if !ok {
+		return Result{Success: false, Output: "no shell available"}
+	}
+	return sh.runOnce(command, timeout)
+}
+
+// SetAsker sets how ask_user reaches the user; nil makes it fail at once.
+func (r *Registry) SetAsker(ask Asker) {
+	if t, ok := r.tools["ask_user"].(*AskUserTool); ok {
+		t.SetAsker(ask)
+	}
+}
+
+// SetCheckpoints enables checkpointing of file changes made by tools.
+func (r *Registry) SetCheckpoints(cp *checkpoint.Store) {
+	r.checkpoints = cp
+}
+
+// SetEditHooks sets the checks that run after a file edit; their diagnostics are
+// appended to the edit's output. Hooks run through the shell tool, so a registry
+// without one ignores them.
+func (r *Registry) SetEditHooks(hooks []EditHook) {
+	sh, ok := r.tools["shell"].(*ShellTool)
+	if !ok || len(hooks) == 0 {
+		r.hooks = nil
+		return
+	}
+	r.hooks = &editHooks{hooks: hooks, shell: sh, containerDir: r.containerWorkDir}
+}
+
+// SetFormatters maps file extensions (".go", or several as ".js,.ts") to the formatter
+// applied after an edit: "builtin:gofmt", "builtin:whitespace" or a shell command
+// using {file}. Like edit hooks, formatters need the shell tool.
+func (r *Registry) SetFormatters(byExt map[string]string) {
+	sh, ok := r.tools["shell"].(*ShellTool)
+	if !ok {
+		r.formatters = nil
+		return
+	}
+	r.formatters = newFormatters(byExt, sh)
+}
+
+// SetCodeSearchEmbedder adds semantic ranking to code_search with the vectors of
+// e, computed by the embedding model named model.
+func (r *Registry) SetCodeSearchEmbedder(e codesearch.Embedder, model string) {
+	if cs, ok := r.tools["code_search"].(*CodeSearchTool); ok {
+		cs.index.SetEmbedder(e, model)
+	}
+}
+
+// SetLSP registers the language server tools (lsp_definition, lsp_references,
+// lsp_hover, lsp_rename, lsp_diagnostics) backed by m. Files changed by the edit
+// tools are forwarded to running servers so their diagnostics stay current.
+func (r *Registry) SetLSP(m *lsp.Manager) {
+	if r.lsp != nil && r.lsp != m {
+		r.lsp.Close()
+	}
+	r.lsp = m
+	if m == nil {
+		return
+	}
+	base := lspBase{workDir: m.Root(), mgr: m}
+	r.Register(&LSPDefinitionTool{base})
+	r.Register(&LSPReferencesTool{base})
+	r.Register(&LSPHoverTool{base})
+	r.Register(&LSPRenameTool{lspBase: base, txn: r.txn})
+	r.Register(&LSPDiagnosticsTool{base})
+}
+
 func (r *Registry) Register(t Tool) {
 	r.tools[t.Name()] = t
 }
@@ -53,6 +165,18 @@ func (r *Registry) Get(name string) (Tool, bool) {
 var pathArgForTool = map[string]string{
 	"read_file": "path", "write_file": "path", "str_replace": "path", "insert_line": "path",
 	"list_dir": "path", "search_files": "path", "grep": "path",
+	"list_symbols": "path", "outline": "path",
+	"lsp_definition": "path", "lsp_references": "path", "lsp_hover": "path",
+	"lsp_rename": "path", "lsp_diagnostics": "path", "code_search": "path",
+}
+
+// PathArg returns the file or directory a call of the named tool works on ("" if
+// it takes none). Call it after Execute to get the host path in Docker mode.
+func PathArg(name string, args map[string]string) string {
+	if key, ok := pathArgForTool[name]; ok {
+		return args[key]
+	}
+	return ""
 }
 
 func (r *Registry) Execute(name string, args map[string]string) Result {
@@ -83,7 +207,77 @@ func (r *Registry) Execute(name string, args map[string]string) Result {
 			return Result{Success: false, Output: out}
 		}
 	}
-	return tool.Execute(args)
+
+	if r.txn != nil && mutatingTools[name] {
+		if err := r.txn.Track(args["path"]); err != nil {
+			return Result{Success: false, Output: fmt.Sprintf("transaction: %v", err)}
+		}
+	}
+
+	capture := r.captureCheckpoint(name, args)
+	result := tool.Execute(args)
+	if result.Success && mutatingTools[name] {
+		// Format first, so the checks see the final content.
+		if r.formatters != nil {
+			result.Output += r.formatters.run(args["path"])
+		}
+		if r.hooks != nil {
+			result.Output += r.hooks.run(args["path"])
+		}
+		if r.lsp != nil {
+			r.lsp.FileChanged(args["path"])
+		}
+	}
+	r.commitCheckpoint(name, capture)
+	if !sandbox.IsReadOnly(name) {
+		// Anything else may have changed files (shell commands, hooks, background
+		// processes, the user while ask_user waited): drop the parsed project.
+		if t, ok := r.tools["find_definition"].(*FindDefinitionTool); ok {
+			t.projects.invalidate()
+		}
+	}
+	return result
+}
+
+// treeChangingTools may modify arbitrary files; the whole tree is compared before and after.
+var treeChangingTools = map[string]bool{
+	"shell": true, "commit_transaction": true, "rollback_transaction": true,
+	"lsp_rename": true,
+}
+
+func (r *Registry) captureCheckpoint(name string, args map[string]string) *checkpoint.Capture {
+	if r.checkpoints == nil {
+		return nil
+	}
+	var c *checkpoint.Capture
+	var err error
+	switch {
+	case mutatingTools[name] && args["path"] != "":
+		c, err = r.checkpoints.Capture([]string{args["path"]})
+	case treeChangingTools[name]:
+		c, err = r.checkpoints.CaptureTree()
+	}
+	if errors.Is(err, checkpoint.ErrTreeTooLarge) {
+		if !r.treeTooLarge {
+			log.Printf("checkpoint: %v", err)
+			r.treeTooLarge = true
+		}
+		return nil
+	}
+	if err != nil {
+		log.Printf("checkpoint: %v", err)
+		return nil
+	}
+	return c
+}
+
+func (r *Registry) commitCheckpoint(name string, c *checkpoint.Capture) {
+	if c == nil {
+		return
+	}
+	if _, err := r.checkpoints.Commit(name, c); err != nil {
+		log.Printf("checkpoint: %v", err)
+	}
 }
 
 // translatePaths rewrites container paths (/workspace/...) to host paths for file tools.
@@ -108,6 +302,48 @@ func (r *Registry) translatePaths(toolName string, args map[string]string) {
 	}
 }
 
+// ReadOnly returns a registry with only the read-only tools of r (see
+// sandbox.IsReadOnly), sharing their
+// instances, the sandbox and path translation. The tools stay owned by r: don't
+// Close the returned registry.
+func (r *Registry) ReadOnly() *Registry {
+	ro := NewRegistry()
+	ro.sandbox = r.sandbox
+	ro.hostWorkDir, ro.containerWorkDir = r.hostWorkDir, r.containerWorkDir
+	for name, t := range r.tools {
+		if sandbox.IsReadOnly(name) {
+			ro.tools[name] = t
+		}
+	}
+	return ro
+}
+
+// closer is implemented by tools that hold processes for the duration of a run.
+type closer interface {
+	Close()
+}
+
+// Close releases resources held by tools, such as shell sessions, and rolls back a
+// transaction left open, so a run never ends with a half-applied change. Call it
+// when a run ends.
+func (r *Registry) Close() {
+	if r.txn != nil && r.txn.Active() {
+		if n, err := r.txn.Rollback(); err != nil {
+			log.Printf("Warning: rolling back the open transaction: %v", err)
+		} else {
+			log.Printf("Warning: the run ended with an open transaction; rolled back %d file(s)", n)
+		}
+	}
+	if r.lsp != nil {
+		r.lsp.Close()
+	}
+	for _, t := range r.tools {
+		if c, ok := t.(closer); ok {
+			c.Close()
+		}
+	}
+}
+
 func (r *Registry) List() []string {
 	names := make([]string, 0, len(r.tools))
 	for name := range r.tools {
@@ -123,9 +359,32 @@ func DefaultRegistry(workDir string, dockerExec *sandbox.DockerExecutor) *Regist
 	reg.Register(&ListDirTool{workDir: workDir})
 	reg.Register(&SearchFilesTool{workDir: workDir})
 	reg.Register(&GrepTool{workDir: workDir})
-	reg.Register(&ShellTool{workDir: workDir, docker: dockerExec})
+	projects := &projectCache{}
+	reg.Register(&FindDefinitionTool{workDir: workDir, projects: projects})
+	reg.Register(&FindReferencesTool{workDir: workDir, projects: projects})
+	reg.Register(&ListSymbolsTool{workDir: workDir})
+	reg.Register(&OutlineTool{workDir: workDir})
+	reg.Register(NewCodeSearchTool(workDir))
+	shell := &ShellTool{workDir: workDir, docker: dockerExec}
+	reg.Register(shell)
+	reg.Register(&RunTestsTool{workDir: workDir, shell: shell})
 	reg.Register(&StrReplaceTool{workDir: workDir})
 	reg.Register(&InsertLineTool{workDir: workDir})
 	reg.Register(&DoneTool{})
+	reg.Register(NewRememberTool(memory.Open(workDir)))
+	reg.Register(&AskUserTool{})
+
+	bg := NewBackgroundManager(workDir, dockerExec)
+	reg.Register(&ShellStartTool{bg: bg})
+	reg.Register(&ShellReadOutputTool{bg: bg})
+	reg.Register(&ShellStopTool{bg: bg})
+
+	txn := NewTxnManager(workDir, func(command string) Result {
+		return shell.runOnce(command, 0)
+	})
+	reg.SetTransactions(txn)
+	reg.Register(&BeginTransactionTool{txn: txn})
+	reg.Register(&CommitTransactionTool{txn: txn})
+	reg.Register(&RollbackTransactionTool{txn: txn})
 	return reg
 }
@@ -5,7 +5,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"devagent/internal/checkpoint"
 	"devagent/internal/sandbox"
 )
 
@@ -76,6 +78,15 @@ func TestTranslatePaths_AllFileTools(t *testing.T) {
 	}
 }
 
+func TestPathArg(t *testing.T) {
+	if got := PathArg("read_file", map[string]string{"path": "a/b.go"}); got != "a/b.go" {
+		t.Errorf("PathArg(read_file) = %q, want a/b.go", got)
+	}
+	if got := PathArg("shell", map[string]string{"command": "ls", "path": "x"}); got != "" {
+		t.Errorf("PathArg(shell) = %q, want empty", got)
+	}
+}
+
 func TestRegistry_Get(t *testing.T) {
 	reg := NewRegistry()
 	reg.Register(&ReadFileTool{workDir: "/tmp"})
@@ -115,6 +126,23 @@ func TestRegistry_Execute_KnownCommand(t *testing.T) {
 	}
 }
 
+func TestRegistry_RunOnce(t *testing.T) {
+	reg := DefaultRegistry(t.TempDir(), nil)
+	t.Cleanup(reg.Close)
+	if r := reg.RunOnce("echo verified", 0); !r.Success || !strings.Contains(r.Output, "verified") {
+		t.Errorf("RunOnce = %+v", r)
+	}
+	if r := reg.RunOnce("exit 2", 0); r.Success || !strings.Contains(r.Output, "exit code: 2") {
+		t.Errorf("failing RunOnce = %+v", r)
+	}
+	if r := reg.RunOnce("sleep 5", 300*time.Millisecond); r.Success || !strings.Contains(r.Output, "timed out after 300ms") {
+		t.Errorf("RunOnce with a timeout = %+v", r)
+	}
+	if r := NewRegistry().RunOnce("true", 0); r.Success {
+		t.Error("RunOnce without a shell should fail")
+	}
+}
+
 func TestRegistry_List(t *testing.T) {
 	reg := NewRegistry()
 	reg.Register(&ReadFileTool{workDir: "/tmp"})
@@ -145,6 +173,27 @@ func TestDefaultRegistry(t *testing.T) {
 	}
 }
 
+func TestRegistry_ReadOnly(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("hello\n"), 0o644)
+	reg := DefaultRegistry(workDir, nil)
+	reg.SetContainerPath(workDir, "/workspace")
+	ro := reg.ReadOnly()
+	for _, name := range []string{"read_file", "grep", "find_references", "code_search"} {
+		if _, ok := ro.Get(name); !ok {
+			t.Errorf("read-only registry should have %s", name)
+		}
+	}
+	for _, name := range []string{"write_file", "str_replace", "shell", "shell_start", "remember", "ask_user", "done"} {
+		if _, ok := ro.Get(name); ok {
+			t.Errorf("read-only registry should not have %s", name)
+		}
+	}
+	if r := ro.Execute("read_file", map[string]string{"path": "/workspace/a.txt"}); !r.Success || !strings.Contains(r.Output, "hello") {
+		t.Errorf("read through container path: %+v", r)
+	}
+}
+
 func TestRegistry_SetSandbox(t *testing.T) {
 	reg := NewRegistry()
 	sb := sandbox.NewSandbox(&sandbox.Policy{WorkDir: t.TempDir(), Shell: &sandbox.ShellPolicy{}, Path: &sandbox.PathPolicy{}})
@@ -182,3 +231,40 @@ func TestRegistry_Execute_SandboxDenies(t *testing.T) {
 		t.Errorf("output should mention blocked: %q", result.Output)
 	}
 }
+
+func TestRegistry_Execute_RecordsCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one"), 0644)
+	cp, err := checkpoint.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := DefaultRegistry(dir, nil)
+	reg.SetCheckpoints(cp)
+
+	cp.BeginStep()
+	reg.Execute("str_replace", map[string]string{"path": "a.txt", "old_str": "one", "new_str": "two"})
+	reg.Execute("read_file", map[string]string{"path": "a.txt"})
+	cp.BeginStep()
+	reg.Execute("shell", map[string]string{"command": "echo shell > b.txt"})
+	reg.Execute("str_replace", map[string]string{"path": "a.txt", "old_str": "missing", "new_str": "x"})
+
+	entries := cp.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v, want 2 (str_replace, shell)", entries)
+	}
+	if entries[0].Tool != "str_replace" || entries[1].Tool != "shell" || entries[1].Step != 2 {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	if _, err := cp.RestoreTo(0); err != nil {
+		t.Fatalf("RestoreTo: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if string(data) != "one" {
+		t.Errorf("a.txt = %q, want one", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.txt")); !os.IsNotExist(err) {
+		t.Error("b.txt created by shell should be removed")
+	}
+}
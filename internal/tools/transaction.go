@@ -0,0 +1,246 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrTxnActive   = errors.New("a transaction is already active")
+	ErrNoTxnActive = errors.New("no active transaction")
+)
+
+// mutatingTools are the tools that change file contents through a "path" argument.
+var mutatingTools = map[string]bool{
+	"write_file": true, "str_replace": true, "insert_line": true,
+}
+
+// IsMutating reports whether the named tool modifies a file.
+func IsMutating(name string) bool {
+	return mutatingTools[name]
+}
+
+// fileSnapshot is the state of a file before the first write inside a transaction.
+type fileSnapshot struct {
+	existed bool
+	data    []byte
+}
+
+// TxnManager tracks the active multi-file edit transaction. While a transaction
+// is active, the registry snapshots each file before its first modification so
+// that all changes can be validated and then committed or rolled back together.
+type TxnManager struct {
+	workDir  string
+	runShell func(command string) Result // runs the validate command (Docker or direct)
+	validate string
+
+	mu        sync.Mutex
+	active    bool
+	originals map[string]fileSnapshot
+}
+
+// NewTxnManager creates a TxnManager. runShell is used to execute the validate command.
+func NewTxnManager(workDir string, runShell func(command string) Result) *TxnManager {
+	return &TxnManager{workDir: workDir, runShell: runShell}
+}
+
+// SetValidateCommand sets the command run before commit (empty disables validation).
+func (m *TxnManager) SetValidateCommand(cmd string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validate = strings.TrimSpace(cmd)
+}
+
+// Active reports whether a transaction is in progress.
+func (m *TxnManager) Active() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.active
+}
+
+// Begin starts a new transaction.
+func (m *TxnManager) Begin() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active {
+		return ErrTxnActive
+	}
+	m.active = true
+	m.originals = make(map[string]fileSnapshot)
+	return nil
+}
+
+// Track snapshots path before it is modified. Only the first snapshot of a file is kept.
+// It is a no-op when no transaction is active.
+func (m *TxnManager) Track(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.active || path == "" {
+		return nil
+	}
+	abs := m.resolvePath(path)
+	if _, ok := m.originals[abs]; ok {
+		return nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			m.originals[abs] = fileSnapshot{existed: false}
+			return nil
+		}
+		return fmt.Errorf("snapshot %s: %w", abs, err)
+	}
+	m.originals[abs] = fileSnapshot{existed: true, data: data}
+	return nil
+}
+
+// Files returns the sorted list of files touched in the active transaction.
+func (m *TxnManager) Files() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make([]string, 0, len(m.originals))
+	for p := range m.originals {
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Commit runs the validate command (if configured) and keeps all changes when it succeeds.
+// When validation fails, every change is rolled back and the validation output is returned.
+func (m *TxnManager) Commit() Result {
+	m.mu.Lock()
+	if !m.active {
+		m.mu.Unlock()
+		return Result{Success: false, Output: ErrNoTxnActive.Error()}
+	}
+	validate := m.validate
+	m.mu.Unlock()
+
+	files := m.Files()
+	if validate != "" && m.runShell != nil {
+		res := m.runShell(validate)
+		if !res.Success {
+			restored, err := m.Rollback()
+			out := fmt.Sprintf("Validation failed (%s); rolled back %d file(s).\n%s", validate, restored, res.Output)
+			if err != nil {
+				out += fmt.Sprintf("\nrollback error: %v", err)
+			}
+			return Result{Success: false, Output: out}
+		}
+	}
+
+	m.mu.Lock()
+	m.active = false
+	m.originals = nil
+	m.mu.Unlock()
+
+	out := fmt.Sprintf("Committed transaction (%d file(s) changed)", len(files))
+	if validate != "" {
+		out += fmt.Sprintf(", validated with: %s", validate)
+	}
+	return Result{Success: true, Output: out + "\n" + m.relList(files)}
+}
+
+// Rollback restores every file touched in the active transaction to its original state
+// (files created in the transaction are removed) and ends the transaction.
+func (m *TxnManager) Rollback() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.active {
+		return 0, ErrNoTxnActive
+	}
+	var errs []string
+	restored := 0
+	for path, snap := range m.originals {
+		var err error
+		if snap.existed {
+			err = writeFileAtomic(path, snap.data)
+		} else {
+			err = os.Remove(path)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		restored++
+	}
+	m.active = false
+	m.originals = nil
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return restored, errors.New(strings.Join(errs, "; "))
+	}
+	return restored, nil
+}
+
+func (m *TxnManager) resolvePath(p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(m.workDir, p)
+}
+
+func (m *TxnManager) relList(files []string) string {
+	var sb strings.Builder
+	for _, f := range files {
+		if rel, err := filepath.Rel(m.workDir, f); err == nil {
+			f = rel
+		}
+		sb.WriteString("  " + f + "\n")
+	}
+	return sb.String()
+}
+
+// BeginTransactionTool starts a multi-file edit transaction.
+type BeginTransactionTool struct {
+	txn *TxnManager
+}
+
+func (t *BeginTransactionTool) Name() string { return "begin_transaction" }
+
+func (t *BeginTransactionTool) Execute(args map[string]string) Result {
+	if err := t.txn.Begin(); err != nil {
+		return Result{Success: false, Output: err.Error()}
+	}
+	return Result{Success: true, Output: "Transaction started. File edits will be committed or rolled back together."}
+}
+
+// CommitTransactionTool validates and commits the active transaction.
+type CommitTransactionTool struct {
+	txn *TxnManager
+}
+
+func (t *CommitTransactionTool) Name() string { return "commit_transaction" }
+
+func (t *CommitTransactionTool) Execute(args map[string]string) Result {
+	return t.txn.Commit()
+}
+
+// RollbackTransactionTool discards every file change made in the active transaction.
+type RollbackTransactionTool struct {
+	txn *TxnManager
+}
+
+func (t *RollbackTransactionTool) Name() string { return "rollback_transaction" }
+
+func (t *RollbackTransactionTool) Execute(args map[string]string) Result {
+	files := t.txn.Files()
+	restored, err := t.txn.Rollback()
+	if errors.Is(err, ErrNoTxnActive) {
+		return Result{Success: false, Output: err.Error()}
+	}
+	out := fmt.Sprintf("Rolled back %d file(s)\n%s", restored, t.txn.relList(files))
+	if err != nil {
+		return Result{Success: false, Output: out + fmt.Sprintf("rollback error: %v", err)}
+	}
+	return Result{Success: true, Output: out}
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTxnManager_RollbackRestoresAndRemoves(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "a.go")
+	os.WriteFile(existing, []byte("original"), 0644)
+
+	reg := DefaultRegistry(dir, nil)
+	if r := reg.Execute("begin_transaction", map[string]string{}); !r.Success {
+		t.Fatalf("begin: %s", r.Output)
+	}
+	reg.Execute("write_file", map[string]string{"path": "a.go", "content": "changed"})
+	reg.Execute("write_file", map[string]string{"path": "sub/new.go", "content": "new"})
+	reg.Execute("str_replace", map[string]string{"path": "a.go", "old_str": "changed", "new_str": "changed twice"})
+
+	r := reg.Execute("rollback_transaction", map[string]string{})
+	if !r.Success {
+		t.Fatalf("rollback: %s", r.Output)
+	}
+	if !strings.Contains(r.Output, "Rolled back 2 file(s)") {
+		t.Errorf("output = %q", r.Output)
+	}
+	data, _ := os.ReadFile(existing)
+	if string(data) != "original" {
+		t.Errorf("a.go = %q, want original", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "new.go")); !os.IsNotExist(err) {
+		t.Error("file created in transaction should be removed on rollback")
+	}
+	if reg.Transactions().Active() {
+		t.Error("transaction should end after rollback")
+	}
+}
+
+func TestTxnManager_CommitKeepsChanges(t *testing.T) {
+	dir := t.TempDir()
+	reg := DefaultRegistry(dir, nil)
+	reg.Execute("begin_transaction", map[string]string{})
+	reg.Execute("write_file", map[string]string{"path": "x.txt", "content": "kept"})
+	r := reg.Execute("commit_transaction", map[string]string{})
+	if !r.Success {
+		t.Fatalf("commit: %s", r.Output)
+	}
+	if !strings.Contains(r.Output, "x.txt") {
+		t.Errorf("commit output should list changed files: %q", r.Output)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "x.txt"))
+	if string(data) != "kept" {
+		t.Errorf("x.txt = %q", data)
+	}
+}
+
+func TestTxnManager_CommitValidationFailureRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "x.txt"), []byte("before"), 0644)
+	reg := DefaultRegistry(dir, nil)
+	reg.Transactions().SetValidateCommand("echo build broken; exit 1")
+	reg.Execute("begin_transaction", map[string]string{})
+	reg.Execute("write_file", map[string]string{"path": "x.txt", "content": "after"})
+	r := reg.Execute("commit_transaction", map[string]string{})
+	if r.Success {
+		t.Fatal("commit should fail when validation fails")
+	}
+	if !strings.Contains(r.Output, "build broken") || !strings.Contains(r.Output, "rolled back 1 file(s)") {
+		t.Errorf("output = %q", r.Output)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "x.txt"))
+	if string(data) != "before" {
+		t.Errorf("x.txt = %q, want before", data)
+	}
+}
+
+func TestTxnManager_CommitValidationSuccess(t *testing.T) {
+	dir := t.TempDir()
+	reg := DefaultRegistry(dir, nil)
+	reg.Transactions().SetValidateCommand("test -f x.txt")
+	reg.Execute("begin_transaction", map[string]string{})
+	reg.Execute("write_file", map[string]string{"path": "x.txt", "content": "ok"})
+	r := reg.Execute("commit_transaction", map[string]string{})
+	if !r.Success {
+		t.Fatalf("commit: %s", r.Output)
+	}
+	if !strings.Contains(r.Output, "validated with: test -f x.txt") {
+		t.Errorf("output = %q", r.Output)
+	}
+}
+
+func TestTxnManager_Errors(t *testing.T) {
+	reg := DefaultRegistry(t.TempDir(), nil)
+	if r := reg.Execute("commit_transaction", map[string]string{}); r.Success {
+		t.Error("commit without transaction should fail")
+	}
+	if r := reg.Execute("rollback_transaction", map[string]string{}); r.Success {
+		t.Error("rollback without transaction should fail")
+	}
+	reg.Execute("begin_transaction", map[string]string{})
+	if r := reg.Execute("begin_transaction", map[string]string{}); r.Success {
+		t.Error("nested begin should fail")
+	}
+}
+
+func TestTxnManager_TrackInactiveIsNoop(t *testing.T) {
+	m := NewTxnManager(t.TempDir(), nil)
+	if err := m.Track("a.go"); err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+	if len(m.Files()) != 0 {
+		t.Error("Track without an active transaction should not record files")
+	}
+}
+
+func TestWriteFileAtomic_PreservesMode(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "run.sh")
+	os.WriteFile(p, []byte("#!/bin/sh\n"), 0755)
+	if err := writeFileAtomic(p, []byte("#!/bin/sh\necho hi\n")); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	info, _ := os.Stat(p)
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temp file left behind: %d entries", len(entries))
+	}
+}
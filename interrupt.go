@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"devagent/internal/agent"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/chzyer/readline"
+)
+
+// taskInterrupts routes Ctrl-C while an interactive task runs: the first press
+// pauses the agent after its current step so the user can give guidance, the
+// second cancels the task and returns to the prompt.
+type taskInterrupts struct {
+	lang string
+
+	mu      sync.Mutex
+	agent   *agent.Agent
+	cancel  context.CancelFunc
+	presses int
+}
+
+// start registers the task that Ctrl-C acts on.
+func (t *taskInterrupts) start(ag *agent.Agent, cancel context.CancelFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.agent, t.cancel, t.presses = ag, cancel, 0
+}
+
+// finish forgets the task; Ctrl-C shuts down again.
+func (t *taskInterrupts) finish() {
+	t.start(nil, nil)
+}
+
+// resume counts the next Ctrl-C as a first press again, after the user has given
+// guidance.
+func (t *taskInterrupts) resume() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.presses = 0
+}
+
+// handle acts on a Ctrl-C. It returns false when no task is running.
+func (t *taskInterrupts) handle() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.agent == nil {
+		return false
+	}
+	t.presses++
+	if t.presses == 1 {
+		t.agent.Pause()
+		if t.lang == "zh" {
+			fmt.Println("\n⏸️  将在当前步骤结束后暂停... (再按 Ctrl-C 取消任务)")
+		} else {
+			fmt.Println("\n⏸️  Pausing after this step... (Ctrl-C again to cancel the task)")
+		}
+		return true
+	}
+	if t.lang == "zh" {
+		fmt.Println("\n🛑 正在取消任务...")
+	} else {
+		fmt.Println("\n🛑 Cancelling the task...")
+	}
+	t.cancel()
+	return true
+}
+
+// readlineGuidance asks for guidance at the prompt when the agent pauses. Enter
+// continues without guidance; Ctrl-C cancels the task.
+func readlineGuidance(rl *readline.Instance, lang string, interrupts *taskInterrupts, cancel context.CancelFunc) agent.Guidance {
+	return func() string {
+		if lang == "zh" {
+			fmt.Println("\n✋ 已暂停。输入给代理的指示，直接回车继续 (Ctrl-C 取消任务)")
+			rl.SetPrompt("✋ 指示 > ")
+		} else {
+			fmt.Println("\n✋ Paused. Type guidance for the agent, or press Enter to continue (Ctrl-C cancels the task)")
+			rl.SetPrompt("✋ Guidance > ")
+		}
+		defer func() {
+			rl.SetPrompt("🤖 > ")
+			interrupts.resume()
+		}()
+		line, err := rl.Readline()
+		if err != nil {
+			cancel()
+			return ""
+		}
+		return strings.TrimSpace(line)
+	}
+}
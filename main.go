@@ -3,10 +3,14 @@ package main
 import (
 	"context"
 	"devagent/internal/agent"
+	"devagent/internal/answers"
+	"devagent/internal/checkpoint"
 	"devagent/internal/config"
 	"devagent/internal/llm"
 	"devagent/internal/prompt"
 	"devagent/internal/sandbox"
+	"devagent/internal/tools"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -14,6 +18,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -26,6 +31,13 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "undo" {
+		os.Exit(runUndo(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "memory" {
+		os.Exit(runMemory(os.Args[2:]))
+	}
+
 	envFile := flag.String("env", "", "Path to .env file (default: .env in current directory)")
 	projectDir := flag.String("project", ".", "Path to the project directory")
 	model := flag.String("model", "", "OpenAI model name (default: gpt-4o, or OPENAI_MODEL env)")
@@ -40,6 +52,8 @@ func main() {
 	langFlag := flag.String("lang", "", "UI language: en / zh (default: auto-detect from LANG env)")
 	soulFlag := flag.String("soul", "", "Path to custom soul/identity prompt file")
 	guidelinesFlag := flag.String("guidelines", "", "Path to custom guidelines prompt file")
+	answersFlag := flag.String("answers", "", "YAML file answering ask_user questions in -task mode (without it, questions fail at once)")
+	planFlag := flag.Bool("plan", false, "Planning mode: the agent submits a plan (for approval in interactive mode) before changing anything")
 
 	flag.Usage = func() {
 		lang := detectLang(*langFlag)
@@ -93,8 +107,15 @@ func main() {
 	var dockerExec *sandbox.DockerExecutor
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	interrupts := &taskInterrupts{lang: lang}
 	go func() {
-		<-sigCh
+		for sig := range sigCh {
+			// While an interactive task runs, Ctrl-C pauses or cancels only the task.
+			if sig == syscall.SIGINT && interrupts.handle() {
+				continue
+			}
+			break
+		}
 		fmt.Println("\n\n⚠️  Interrupted. Shutting down...")
 		if dockerExec != nil {
 			dockerExec.Stop()
@@ -102,6 +123,12 @@ func main() {
 		cancel()
 	}()
 
+	projectCfg, err := config.LoadProject(absProject)
+	if err != nil {
+		log.Printf("Warning: loading project config: %v (using defaults)", err)
+		projectCfg = nil
+	}
+
 	sandboxCfg, err := sandbox.LoadConfig(absProject)
 	if err != nil {
 		log.Printf("Warning: loading sandbox config: %v (using defaults)", err)
@@ -133,18 +160,45 @@ func main() {
 	}
 
 	skillDirs := buildSkillDirs(absProject, *skillsFlag)
-	soul := prompt.ResolvePromptFile(*soulFlag, absProject, "SOUL.md")
-	guidelines := prompt.ResolvePromptFile(*guidelinesFlag, absProject, "GUIDELINES.md")
+	soul, soulPath := prompt.FindPromptFile(*soulFlag, absProject, "SOUL.md")
+	guidelines, guidelinesPath := prompt.FindPromptFile(*guidelinesFlag, absProject, "GUIDELINES.md")
 	if *soulFlag != "" && soul == "" {
 		fmt.Fprintf(os.Stderr, "⚠️  Soul file not found or unreadable: %s\n", *soulFlag)
 	}
 	if *guidelinesFlag != "" && guidelines == "" {
 		fmt.Fprintf(os.Stderr, "⚠️  Guidelines file not found or unreadable: %s\n", *guidelinesFlag)
 	}
-	ag := agent.New(client, absProject, *verbose, skillDirs, soul, guidelines, sb, dockerExec)
+	var answerFile *answers.File
+	if *answersFlag != "" {
+		if answerFile, err = answers.Load(*answersFlag); err != nil {
+			fatalf("answers file: %v", err)
+		}
+	}
+	checkpoints, err := checkpoint.Open(absProject)
+	if err != nil {
+		log.Printf("Warning: opening checkpoints: %v (undo disabled)", err)
+		checkpoints = nil
+	}
+
+	newAgent := func() *agent.Agent {
+		ag := agent.New(client, absProject, *verbose, skillDirs, soul, guidelines, sb, dockerExec)
+		ag.SetProjectConfig(projectCfg)
+		ag.SetPromptOrigins(prompt.OriginOf(soulPath, absProject), prompt.OriginOf(guidelinesPath, absProject))
+		if answerFile != nil {
+			ag.SetAsker(answerFile.Ask)
+		}
+		if *planFlag || projectCfg != nil && projectCfg.Plan.Enabled {
+			// Interactive mode asks for approval at the prompt (see runInteractive).
+			ag.SetPlanning(nil)
+		}
+		if checkpoints != nil {
+			ag.SetCheckpoints(checkpoints)
+		}
+		return ag
+	}
 
 	if *taskFlag != "" {
-		err := ag.Run(ctx, *taskFlag)
+		err := newAgent().Run(ctx, *taskFlag)
 		if dockerExec != nil {
 			dockerExec.Stop()
 		}
@@ -154,7 +208,7 @@ func main() {
 		return
 	}
 
-	runInteractive(ctx, ag, absProject, skillDirs, soul, guidelines, sb, dockerExec, lang)
+	runInteractive(ctx, newAgent, absProject, checkpoints, interrupts, lang)
 	if dockerExec != nil {
 		dockerExec.Stop()
 	}
@@ -179,7 +233,8 @@ func buildSkillDirs(projectDir, skillsFlag string) []string {
 	return dirs
 }
 
-func runInteractive(ctx context.Context, ag *agent.Agent, projectDir string, skillDirs []string, soul, guidelines string, sb *sandbox.Sandbox, dockerExec *sandbox.DockerExecutor, lang string) {
+// runInteractive reads tasks from the terminal; newAgent builds a fresh agent for each task.
+func runInteractive(ctx context.Context, newAgent func() *agent.Agent, projectDir string, checkpoints *checkpoint.Store, interrupts *taskInterrupts, lang string) {
 	if lang == "zh" {
 		fmt.Printf(`
 ╔══════════════════════════════════════════════════╗
@@ -255,20 +310,145 @@ func runInteractive(ctx context.Context, ag *agent.Agent, projectDir string, ski
 			continue
 		}
 
-		newAgent := agent.New(ag.LLMClient(), projectDir, ag.Verbose(), skillDirs, soul, guidelines, sb, dockerExec)
+		if fields := strings.Fields(input); fields[0] == "/undo" {
+			handleUndoCommand(checkpoints, fields[1:], lang)
+			continue
+		}
 
-		if err := newAgent.Run(ctx, input); err != nil {
+		taskCtx, cancelTask := context.WithCancel(ctx)
+		ag := newAgent()
+		ag.SetAsker(readlineAsker(rl, lang))
+		if ag.Planning() {
+			ag.SetPlanning(readlinePlanApproval(rl, lang))
+		}
+		ag.SetGuidance(readlineGuidance(rl, lang, interrupts, cancelTask))
+		interrupts.start(ag, cancelTask)
+		err = ag.Run(taskCtx, input)
+		interrupts.finish()
+		cancelTask()
+		if err != nil && !(errors.Is(err, context.Canceled) && ctx.Err() == nil) {
 			fmt.Printf("❌ Error: %v\n", err)
 		}
 	}
 }
 
+// handleUndoCommand implements "/undo" (revert the last step), "/undo <step>" and "/undo list".
+func handleUndoCommand(checkpoints *checkpoint.Store, args []string, lang string) {
+	if checkpoints == nil {
+		if lang == "zh" {
+			fmt.Println("检查点不可用")
+		} else {
+			fmt.Println("Checkpoints are not available")
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "list" {
+		printCheckpoints(checkpoints)
+		return
+	}
+	to := -1
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			if lang == "zh" {
+				fmt.Println("用法: /undo [步骤号|list]")
+			} else {
+				fmt.Println("Usage: /undo [step|list]")
+			}
+			return
+		}
+		to = n
+	}
+	if err := undoCheckpoints(checkpoints, to); err != nil {
+		fmt.Printf("❌ %v\n", err)
+	}
+}
+
+// readlineAsker asks the model's questions at the prompt. A number picks one of the
+// offered choices; Ctrl-C declines to answer.
+func readlineAsker(rl *readline.Instance, lang string) tools.Asker {
+	return func(question string, choices []string) (string, error) {
+		fmt.Printf("\n❓ %s\n", question)
+		for i, c := range choices {
+			fmt.Printf("   %d. %s\n", i+1, c)
+		}
+		if lang == "zh" {
+			rl.SetPrompt("💬 回答 > ")
+		} else {
+			rl.SetPrompt("💬 Answer > ")
+		}
+		rl.HistoryDisable()
+		defer func() {
+			rl.SetPrompt("🤖 > ")
+			rl.HistoryEnable()
+		}()
+		for {
+			line, err := rl.Readline()
+			if err != nil {
+				return "", fmt.Errorf("the user declined to answer")
+			}
+			answer := strings.TrimSpace(line)
+			if answer == "" {
+				continue
+			}
+			if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+				answer = choices[n-1]
+			}
+			return answer, nil
+		}
+	}
+}
+
+// readlinePlanApproval shows a plan and asks at the prompt whether to go ahead. An
+// answer other than yes or no is passed to the model as the changes the user wants;
+// Ctrl-C rejects the plan.
+func readlinePlanApproval(rl *readline.Instance, lang string) agent.PlanApprover {
+	return func(plan string) (bool, string) {
+		title, ask, input := "📝 Proposed plan:", "   Approve the plan? [y]es / [n]o / or describe what to change", "📝 Plan > "
+		if lang == "zh" {
+			title, ask, input = "📝 计划:", "   是否批准该计划? [y]是 / [n]否 / 或输入需要修改的内容", "📝 计划 > "
+		}
+		fmt.Printf("\n%s\n", title)
+		for _, l := range strings.Split(plan, "\n") {
+			fmt.Printf("   %s\n", l)
+		}
+		fmt.Println(ask)
+		rl.SetPrompt(input)
+		rl.HistoryDisable()
+		defer func() {
+			rl.SetPrompt("🤖 > ")
+			rl.HistoryEnable()
+		}()
+		for {
+			line, err := rl.Readline()
+			if err != nil {
+				return false, ""
+			}
+			answer := strings.TrimSpace(line)
+			switch strings.ToLower(answer) {
+			case "":
+				continue
+			case "y", "yes", "是":
+				return true, ""
+			case "n", "no", "否":
+				return false, ""
+			}
+			return false, answer
+		}
+	}
+}
+
 func printHelp(lang string) {
 	if lang == "zh" {
 		fmt.Print(`
 可用命令:
   help, h        显示帮助
   quit, exit, q  退出程序
+  /undo          撤销上一步的文件修改
+  /undo <步骤>   将文件恢复到指定步骤结束时的状态 (0 = 全部撤销)
+  /undo list     列出检查点
+
+任务运行中按 Ctrl-C 暂停并输入指示，再按一次取消任务。
 
 任务示例:
   "分析项目结构并解释架构"
@@ -282,6 +462,11 @@ func printHelp(lang string) {
 Available commands:
   help, h        Show this help
   quit, exit, q  Exit the program
+  /undo          Undo file changes of the last step
+  /undo <step>   Restore files to the end of a step (0 = undo everything)
+  /undo list     List checkpoints
+
+While a task runs, Ctrl-C pauses it for guidance; press it again to cancel the task.
 
 Task examples:
   "Analyze the project structure and explain the architecture"
@@ -312,6 +497,8 @@ func printUsageEn() {
 
 Usage:
   devagent [flags]
+  devagent undo [-project dir] [-to step] [-list]
+  devagent memory [list|add|forget|edit|path] [-project dir] [-user]
 
 Flags:
 `, version)
@@ -340,6 +527,8 @@ Examples:
   devagent -no-docker                                     # disable Docker sandbox
   devagent -lang zh                                       # Chinese UI
   devagent -soul ./SOUL.md -guidelines ./GUIDELINES.md    # custom prompts
+  devagent -plan                                          # plan first, approve, then act
+  devagent -task "..." -answers answers.yaml              # answer ask_user questions in batch runs
 `)
 }
 
@@ -348,6 +537,8 @@ func printUsageZh() {
 
 用法:
   devagent [参数]
+  devagent undo [-project 目录] [-to 步骤] [-list]
+  devagent memory [list|add|forget|edit|path] [-project 目录] [-user]
 
 参数:
 `, version)
@@ -376,6 +567,8 @@ Docker 沙箱:
   devagent -no-docker                                     # 禁用 Docker 沙箱
   devagent -lang en                                       # 英文界面
   devagent -soul ./SOUL.md -guidelines ./GUIDELINES.md    # 自定义提示词
+  devagent -plan                                          # 先制定计划, 批准后再执行
+  devagent -task "..." -answers answers.yaml              # 批处理时自动回答 ask_user 的提问
 `)
 }
 
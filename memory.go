@@ -0,0 +1,127 @@
+package main
+
+import (
+	"devagent/internal/memory"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const memoryUsage = `Usage:
+  devagent memory [list] [-project dir] [-user]
+  devagent memory add [-project dir] [-user] [-section name] <fact>
+  devagent memory forget [-project dir] [-user] <number>
+  devagent memory edit [-project dir] [-user]
+  devagent memory path [-project dir] [-user]
+`
+
+// runMemory implements "devagent memory": review and edit the facts the agent
+// keeps in .devagent/MEMORY.md (or ~/.devagent/MEMORY.md with -user).
+func runMemory(args []string) int {
+	sub := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("memory "+sub, flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, memoryUsage) }
+	projectDir := fs.String("project", ".", "Path to the project directory")
+	user := fs.Bool("user", false, "Use the user-level memory (~/.devagent/MEMORY.md)")
+	section := fs.String("section", "", "Heading to file the fact under (add)")
+	fs.Parse(args)
+
+	absProject, err := filepath.Abs(*projectDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid project path: %v\n", err)
+		return 1
+	}
+	store := memory.Open(absProject)
+	scope := memory.Project
+	if *user {
+		scope = memory.User
+	}
+	path := store.Path(scope)
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "Error: no home directory for the user memory")
+		return 1
+	}
+
+	switch sub {
+	case "list":
+		err = listMemory(store, scope)
+	case "add":
+		fact := strings.Join(fs.Args(), " ")
+		var added bool
+		if added, err = store.Add(scope, *section, fact); err == nil {
+			if added {
+				fmt.Printf("🧠 Remembered in %s\n", path)
+			} else {
+				fmt.Println("Already remembered.")
+			}
+		}
+	case "forget":
+		n, convErr := strconv.Atoi(fs.Arg(0))
+		if fs.NArg() != 1 || convErr != nil {
+			fmt.Fprint(os.Stderr, memoryUsage)
+			return 2
+		}
+		var f memory.Fact
+		if f, err = store.Forget(scope, n); err == nil {
+			fmt.Printf("🗑️  Forgot: %s\n", f.Text)
+		}
+	case "edit":
+		err = editMemory(store, scope)
+	case "path":
+		fmt.Println(path)
+	default:
+		fmt.Fprint(os.Stderr, memoryUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func listMemory(store *memory.Store, scope memory.Scope) error {
+	facts, err := store.Facts(scope)
+	if err != nil {
+		return err
+	}
+	if len(facts) == 0 {
+		fmt.Printf("No facts in %s.\n", store.Path(scope))
+		return nil
+	}
+	section := ""
+	for i, f := range facts {
+		if f.Section != section {
+			fmt.Printf("\n%s\n", f.Section)
+			section = f.Section
+		}
+		fmt.Printf("%4d. %s\n", i+1, f.Text)
+	}
+	return nil
+}
+
+// editMemory opens the memory file in $VISUAL or $EDITOR (vi by default), creating
+// it first so the editor starts from the usual header.
+func editMemory(store *memory.Store, scope memory.Scope) error {
+	if err := store.Init(scope); err != nil {
+		return err
+	}
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor setting may carry arguments ("code --wait").
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", store.Path(scope))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
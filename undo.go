@@ -0,0 +1,80 @@
+package main
+
+import (
+	"devagent/internal/checkpoint"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runUndo implements "devagent undo [-project dir] [-to step] [-list]".
+func runUndo(args []string) int {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	projectDir := fs.String("project", ".", "Path to the project directory")
+	to := fs.Int("to", -1, "Restore files to the state at the end of this step (0 = before any agent change)")
+	list := fs.Bool("list", false, "List recorded checkpoints")
+	fs.Parse(args)
+
+	absProject, err := filepath.Abs(*projectDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid project path: %v\n", err)
+		return 1
+	}
+	store, err := checkpoint.Open(absProject)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: open checkpoints: %v\n", err)
+		return 1
+	}
+
+	if *list {
+		printCheckpoints(store)
+		return 0
+	}
+	if err := undoCheckpoints(store, *to); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// undoCheckpoints reverts the last step (to < 0) or every step after to.
+func undoCheckpoints(store *checkpoint.Store, to int) error {
+	var files []string
+	var err error
+	if to < 0 {
+		var step int
+		step, files, err = store.Undo()
+		if errors.Is(err, checkpoint.ErrNothingToUndo) {
+			return err
+		}
+		fmt.Printf("↩️  Undid step %d\n", step)
+	} else {
+		files, err = store.RestoreTo(to)
+		if errors.Is(err, checkpoint.ErrNothingToUndo) {
+			return err
+		}
+		fmt.Printf("↩️  Restored files to step %d\n", to)
+	}
+	for _, f := range files {
+		fmt.Printf("   %s\n", f)
+	}
+	return err
+}
+
+func printCheckpoints(store *checkpoint.Store) {
+	entries := store.Entries()
+	if len(entries) == 0 {
+		fmt.Println("No checkpoints recorded.")
+		return
+	}
+	for _, e := range entries {
+		paths := make([]string, len(e.Files))
+		for i, f := range e.Files {
+			paths[i] = f.Path
+		}
+		fmt.Printf("step %-4d %s  %-20s %s\n", e.Step, e.Time.Format("2006-01-02 15:04:05"), e.Tool, strings.Join(paths, ", "))
+	}
+}